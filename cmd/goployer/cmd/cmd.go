@@ -66,6 +66,13 @@ You can find more information in https://goployer.dev`,
 	}
 
 	rootCmd.AddCommand(NewDeployCommand())
+	rootCmd.AddCommand(NewPlanCommand())
+	rootCmd.AddCommand(NewValidateCommand())
+	rootCmd.AddCommand(NewRollbackCommand())
+	rootCmd.AddCommand(NewResumeCommand())
+	rootCmd.AddCommand(NewUnlockCommand())
+	rootCmd.AddCommand(NewHistoryCommand())
+	rootCmd.AddCommand(NewReportCommand())
 	rootCmd.AddCommand(NewVersionCommand())
 	rootCmd.AddCommand(NewDeleteCommand())
 	rootCmd.AddCommand(NewInitCommand())
@@ -73,6 +80,7 @@ You can find more information in https://goployer.dev`,
 	rootCmd.AddCommand(NewAddCommand())
 	rootCmd.AddCommand(NewUpdateCommand())
 	rootCmd.AddCommand(NewRefreshCommand())
+	rootCmd.AddCommand(NewServerCommand())
 
 	rootCmd.PersistentFlags().StringVarP(&v, "log-level", "v", constants.DefaultLogLevel.String(), "Log level (debug, info, warn, error, fatal, panic)")
 
@@ -44,19 +44,29 @@ type Flag struct {
 const (
 	timeout         = 60 * time.Minute
 	pollingInterval = 60 * time.Second
+	lockTTL         = constants.DefaultLockTTL
 )
 
 var zeroTimeout = 0 * time.Minute
 var zeroPollingInterval = 0 * time.Second
+var zeroLockTTL = 0 * time.Second
 
 var flagKey = map[string]string{
-	"deploy":  "deploySet",
-	"delete":  "fullSet",
-	"init":    "initSet",
-	"status":  "statusSet",
-	"update":  "updateSet",
-	"add":     "addSet",
-	"refresh": "refreshSet",
+	"deploy":   "deploySet",
+	"plan":     "planSet",
+	"rollback": "rollbackSet",
+	"resume":   "resumeSet",
+	"delete":   "fullSet",
+	"init":     "initSet",
+	"status":   "statusSet",
+	"update":   "updateSet",
+	"add":      "addSet",
+	"refresh":  "refreshSet",
+	"validate": "validateSet",
+	"server":   "serverSet",
+	"unlock":   "unlockSet",
+	"history":  "historySet",
+	"report":   "reportSet",
 }
 
 var CommonFlagRegistry = []Flag{
@@ -68,6 +78,14 @@ var CommonFlagRegistry = []Flag{
 		DefValue:      constants.EmptyString,
 		FlagAddMethod: "StringVar",
 	},
+	{
+		Name:          "output",
+		Shorthand:     "o",
+		Usage:         "Output format (table, json, yaml). report also supports csv",
+		Value:         aws.String(constants.EmptyString),
+		DefValue:      constants.TableOutput,
+		FlagAddMethod: "StringVar",
+	},
 }
 
 var FlagRegistry = map[string][]Flag{
@@ -337,6 +355,13 @@ var FlagRegistry = map[string][]Flag{
 			DefValue:      constants.EmptyString,
 			FlagAddMethod: "StringVar",
 		},
+		{
+			Name:          "commit-timestamp",
+			Usage:         "Commit time of the deployed change in unix seconds or RFC3339 for lead time of DORA report",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
 		{
 			Name:          "force-manifest-capacity",
 			Usage:         "Force-apply the capacity of instances in the manifest file",
@@ -365,6 +390,300 @@ var FlagRegistry = map[string][]Flag{
 			DefValue:      false,
 			FlagAddMethod: "BoolVar",
 		},
+		{
+			Name:          "state-dir",
+			Usage:         "Directory where the progress of deployment is saved for resume (default ~/.goployer/state)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "events-file",
+			Usage:         "File where transitions of deployment steps are appended as json lines",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "lock-backend",
+			Usage:         "Backend of deployment lock: dynamodb, file or none (default dynamodb if metrics are stored in dynamodb, otherwise file)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "lock-ttl",
+			Usage:         "Time for deployment lock to expire without heartbeat (default 2m)",
+			Value:         &zeroLockTTL,
+			DefValue:      lockTTL,
+			FlagAddMethod: "DurationVar",
+		},
+	},
+	"planSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack that should be planned.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "ami",
+			Usage:         "Amazon AMI to use.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "env",
+			Usage:         "The environment that is being deployed into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "assume-role",
+			Usage:         "The Role ARN to assume into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region to plan, if undefined, then the plan will cover all regions for the given environment.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "log-level",
+			Shorthand:     "v",
+			Usage:         "Level of logging",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "override-instance-type",
+			Usage:         "Instance Type to override",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "force-manifest-capacity",
+			Usage:         "Force-apply the capacity of instances in the manifest file",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "complete-canary",
+			Usage:         "Plan the rest of canary deployment.(Only works with Canary replacement type)",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for deploy to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+	},
+	"rollbackSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack that should be rolled back.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "to-version",
+			Usage:         "Version of autoscaling group to roll back to, if undefined, then the version right before the current one is used.",
+			Value:         aws.Int(-1),
+			DefValue:      -1,
+			FlagAddMethod: "IntVar",
+		},
+		{
+			Name:          "assume-role",
+			Usage:         "The Role ARN to assume into.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region to roll back, if undefined, then the rollback will run against all regions for the given environment.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "slack-off",
+			Usage:         "Turn off slack alarm",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "log-level",
+			Shorthand:     "v",
+			Usage:         "Level of logging",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "disable-metrics",
+			Usage:         "Disable gathering metrics.",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for rollback to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+		},
+	},
+	"resumeSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack that should be resumed.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region which the unfinished deployment was started with.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "state-dir",
+			Usage:         "Directory where the progress of deployment is saved (default ~/.goployer/state)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "events-file",
+			Usage:         "File where transitions of deployment steps are appended as json lines",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "lock-backend",
+			Usage:         "Backend of deployment lock: dynamodb, file or none (default dynamodb if metrics are stored in dynamodb, otherwise file)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "lock-ttl",
+			Usage:         "Time for deployment lock to expire without heartbeat (default 2m)",
+			Value:         &zeroLockTTL,
+			DefValue:      lockTTL,
+			FlagAddMethod: "DurationVar",
+		},
+		{
+			Name:          "log-level",
+			Shorthand:     "v",
+			Usage:         "Level of logging",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      "warning",
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
 	},
 	"initSet": {
 		{
@@ -446,6 +765,235 @@ var FlagRegistry = map[string][]Flag{
 			FlagAddMethod: "DurationVar",
 		},
 	},
+	"validateSet": {
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to validate. (required if manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+	},
+	"unlockSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack whose lock should be removed. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region of lock",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "lock-backend",
+			Usage:         "Backend of deployment lock: dynamodb or file (default dynamodb if metrics are stored in dynamodb, otherwise file)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "auto-apply",
+			Usage:         "Apply command without confirmation from local terminal",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for deploy to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+	},
+	"historySet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack whose deployments should be listed.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region of deployments",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "since",
+			Usage:         "List deployments started after duration ago like 72h or 7d, or after date like 2020-01-02",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for deploy to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+	},
+	"reportSet": {
+		{
+			Name:          "manifest",
+			Shorthand:     "m",
+			Usage:         "The manifest configuration file to use. (required)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "manifest-s3-region",
+			Usage:         "Region of bucket containing the manifest configuration file to use. (required if –manifest starts with s3://)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "stack",
+			Usage:         "stack whose deployments should be reported.",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "region",
+			Usage:         "The region of deployments",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "period",
+			Usage:         "Report deployments started in duration like 30d or 72h, or after date like 2020-01-02",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.DefaultReportPeriod,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "timeout",
+			Usage:         "Time to wait for deploy to finish before timing out (default 60m)",
+			Value:         &zeroTimeout,
+			DefValue:      timeout,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+		{
+			Name:          "polling-interval",
+			Usage:         "Time to interval for polling health check (default 60s)",
+			Value:         &zeroPollingInterval,
+			DefValue:      pollingInterval,
+			FlagAddMethod: "DurationVar",
+			Hidden:        true,
+		},
+	},
+	"serverSet": {
+		{
+			Name:          "address",
+			Usage:         "Address which goployer server listens on",
+			Value:         aws.String(constants.DefaultServerAddr),
+			DefValue:      constants.DefaultServerAddr,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "port",
+			Usage:         "Port which goployer server listens on",
+			Value:         aws.Int64(constants.DefaultServerPort),
+			DefValue:      constants.DefaultServerPort,
+			FlagAddMethod: "Int64Var",
+		},
+		{
+			Name:          "tls-cert-file",
+			Usage:         "Certificate file for HTTPS. (required with --tls-key-file)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "tls-key-file",
+			Usage:         "Private key file for HTTPS. (required with --tls-cert-file)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "auth-config",
+			Usage:         "Configuration file of clients with their tokens and permissions. Authentication is disabled if it is not set",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "audit-log",
+			Usage:         "File where audit log of deployment API is appended (default stderr)",
+			Value:         aws.String(constants.EmptyString),
+			DefValue:      constants.EmptyString,
+			FlagAddMethod: "StringVar",
+		},
+		{
+			Name:          "allow-local-hooks",
+			Usage:         "Allow local lifecycle hooks which run commands in the server host",
+			Value:         aws.Bool(false),
+			DefValue:      false,
+			FlagAddMethod: "BoolVar",
+		},
+	},
 	"refreshSet": {
 		{
 			Name:          "region",
@@ -0,0 +1,64 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/runner"
+)
+
+// Create new history command
+func NewHistoryCommand() *cobra.Command {
+	return NewCmd("history").
+		WithDescription("List, show and compare past deployments").
+		WithLongDescription("History reads deployment records from the metric storage. `history show <asg>` prints the detail of a deployment and `history diff <asgA> <asgB>` compares stored config, tags and userdata of two releases.").
+		SetFlags().
+		RunWithArgs(funcHistory)
+}
+
+// funcHistory shows deployment history
+func funcHistory(ctx context.Context, _ io.Writer, args []string, mode string) error {
+	if !isHistoryArgs(args) {
+		return errors.New(constants.HistoryUsage)
+	}
+
+	return runWithoutExecutor(ctx, func() error {
+		builderSt, err := runner.SetupBuilder(mode)
+		if err != nil {
+			return err
+		}
+
+		return runner.StartWith(builderSt, mode, func(r *runner.Runner) {
+			r.Args = args
+		})
+	})
+}
+
+// isHistoryArgs checks if arguments are one of list, show and diff
+func isHistoryArgs(args []string) bool {
+	if len(args) == 0 {
+		return true
+	}
+
+	return (args[0] == "show" && len(args) == 2) || (args[0] == "diff" && len(args) == 3)
+}
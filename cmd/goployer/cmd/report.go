@@ -0,0 +1,55 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/runner"
+)
+
+// Create new report command
+func NewReportCommand() *cobra.Command {
+	return NewCmd("report").
+		WithDescription("Report delivery metrics computed from deployment history").
+		WithLongDescription("`report dora` computes deployment frequency, lead time for changes, change failure rate and mean time to restore of stacks from deployment records in the metric storage. Lead time is computed only for deployments with --commit-timestamp.").
+		SetFlags().
+		RunWithArgs(funcReport)
+}
+
+// funcReport shows report of deployments
+func funcReport(ctx context.Context, _ io.Writer, args []string, mode string) error {
+	if len(args) != 1 || args[0] != "dora" {
+		return errors.New(constants.ReportUsage)
+	}
+
+	return runWithoutExecutor(ctx, func() error {
+		builderSt, err := runner.SetupBuilder(mode)
+		if err != nil {
+			return err
+		}
+
+		return runner.StartWith(builderSt, mode, func(r *runner.Runner) {
+			r.Args = args
+		})
+	})
+}
@@ -21,19 +21,39 @@ import (
 	"io"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/DevopsArtFactory/goployer/pkg/server"
 )
 
-// Create new deploy command
+// Create new server command
 func NewServerCommand() *cobra.Command {
 	return NewCmd("server").
 		WithDescription("Run goployer as server").
+		WithLongDescription("Server receives deployment requests through HTTP API and runs them in the background. Deployments of the same stack are queued and run one at a time.").
+		SetFlags().
 		RunWithNoArgs(funcServer)
 }
 
-// funcDeploy run deployment
-func funcServer(ctx context.Context, _ io.Writer, mode string) error {
+// funcServer runs goployer server until it is interrupted
+func funcServer(ctx context.Context, _ io.Writer, _ string) error {
 	return runWithoutExecutor(ctx, func() error {
-		// ready to develop
-		return nil
+		s := server.New()
+		s.ServerConfig = server.Config{
+			Addr:            viper.GetString("address"),
+			Port:            viper.GetInt64("port"),
+			TLSCertFile:     viper.GetString("tls-cert-file"),
+			TLSKeyFile:      viper.GetString("tls-key-file"),
+			AuthConfig:      viper.GetString("auth-config"),
+			AuditLog:        viper.GetString("audit-log"),
+			AllowLocalHooks: viper.GetBool("allow-local-hooks"),
+		}
+
+		s, err := s.SetDefaultSetting().SetSecurity()
+		if err != nil {
+			return err
+		}
+
+		return s.SetRouter().Run(ctx)
 	})
 }
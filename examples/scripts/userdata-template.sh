@@ -0,0 +1,11 @@
+#!/bin/bash
+
+# rendered by goployer when userdata.template is true
+echo "app={{ .App }} stack={{ .Stack }} env={{ .Env }} region={{ .Region }}" > /etc/goployer-release
+echo "ami={{ .Ami }} autoscaling_group={{ .AutoScalingGroup }}" >> /etc/goployer-release
+
+sudo yum update
+sudo amazon-linux-extras install nginx1.12
+sudo service nginx start
+
+ansible-playbook site.yml --extra-vars "{{ .AnsibleExtraVars }}"
@@ -79,7 +79,8 @@ type Definition struct {
 }
 
 func main() {
-	if err := generateSchemas(".", false, "config", "schema"); err != nil {
+	// manifest schema is embedded for validation of manifests
+	if err := generateSchemas(".", false, "config", "schema", filepath.Join("pkg", "validator", "schema.json")); err != nil {
 		fmt.Println(err.Error())
 	}
 
@@ -88,7 +89,7 @@ func main() {
 	}
 }
 
-func generateSchemas(root string, dryRun bool, inputFile, outputFile string) error {
+func generateSchemas(root string, dryRun bool, inputFile, outputFile string, copies ...string) error {
 	input := filepath.Join(root, "pkg", "schemas", inputFile+".go")
 	output := filepath.Join(root, "docs", "content", "en", "schemas", outputFile+".json")
 
@@ -116,6 +117,12 @@ func generateSchemas(root string, dryRun bool, inputFile, outputFile string) err
 		if err := ioutil.WriteFile(output, buf, os.ModePerm); err != nil {
 			return fmt.Errorf("unable to write schema %q: %w", output, err)
 		}
+
+		for _, c := range copies {
+			if err := ioutil.WriteFile(filepath.Join(root, c), buf, 0644); err != nil {
+				return fmt.Errorf("unable to write schema %q: %w", c, err)
+			}
+		}
 	}
 
 	same := string(current) == string(buf)
@@ -304,12 +311,12 @@ func (g Generator) ParseDefinition(name string, t ast.Expr, comment string) *Def
 
 	case *ast.StructType:
 		for _, field := range tt.Fields.List {
-			if field.Tag == nil {
+			if len(field.Names) == 0 && field.Tag == nil {
 				continue
 			}
 			yamlName := yamlFieldName(field)
 
-			if strings.Contains(field.Tag.Value, "inline") {
+			if field.Tag != nil && strings.Contains(field.Tag.Value, "inline") {
 				def.PreferredOrder = append(def.PreferredOrder, "<inline>")
 				def.inlines = append(def.inlines, &Definition{
 					Ref: defPrefix + field.Type.(*ast.Ident).Name,
@@ -317,11 +324,11 @@ func (g Generator) ParseDefinition(name string, t ast.Expr, comment string) *Def
 				continue
 			}
 
-			if yamlName == "" || yamlName == "-" || yamlName == "ansible_tags" {
+			if yamlName == "" || yamlName == "-" {
 				continue
 			}
 
-			if strings.Contains(field.Tag.Value, "required") {
+			if field.Tag != nil && strings.Contains(field.Tag.Value, "required") {
 				def.Required = append(def.Required, yamlName)
 			}
 
@@ -397,17 +404,27 @@ func setTypeOrRef(def *Definition, typeName string) {
 		def.Type = "boolean"
 	case "int", "int64", "int32":
 		def.Type = "integer"
+	case "float32", "float64":
+		def.Type = "number"
 	default:
 		def.Ref = defPrefix + typeName
 	}
 }
 
+// yamlFieldName returns key of field in yaml which is lowercased field name without yaml tag
 func yamlFieldName(field *ast.Field) string {
-	tag := strings.Replace(field.Tag.Value, "`", "", -1)
-	tags := reflect.StructTag(tag)
-	yamlTag := tags.Get("yaml")
+	var yamlTag string
+	if field.Tag != nil {
+		tag := strings.Replace(field.Tag.Value, "`", "", -1)
+		tags := reflect.StructTag(tag)
+		yamlTag = tags.Get("yaml")
+	}
+
+	if name := strings.Split(yamlTag, ",")[0]; len(name) > 0 || len(field.Names) == 0 {
+		return name
+	}
 
-	return strings.Split(yamlTag, ",")[0]
+	return strings.ToLower(field.Names[0].Name)
 }
 
 // Make sure HTML description are not encoded
@@ -37,6 +37,7 @@ type Client struct {
 type MetricClient struct {
 	Region            string
 	DynamoDBService   DynamoDBClient
+	S3Service         S3Client
 	CloudWatchService CloudWatchClient
 }
 
@@ -45,6 +46,13 @@ type ManifestClient struct {
 	S3Service S3Client
 }
 
+// AssumeRoleConfig is configuration for assuming IAM role
+type AssumeRoleConfig struct {
+	RoleArn     string
+	ExternalID  string
+	SessionName string
+}
+
 // GetAwsSession generates new aws session
 func GetAwsSession() *session.Session {
 	profile := viper.GetString("profile")
@@ -65,14 +73,32 @@ func GetAwsSession() *session.Session {
 	return mySession
 }
 
+// NewAssumeRoleCredentials creates credentials of assumed role, or nil for default credentials
+func NewAssumeRoleCredentials(awsSession *session.Session, role AssumeRoleConfig) *credentials.Credentials {
+	if len(role.RoleArn) == 0 {
+		return nil
+	}
+
+	return stscreds.NewCredentials(awsSession, role.RoleArn, func(p *stscreds.AssumeRoleProvider) {
+		if len(role.ExternalID) > 0 {
+			p.ExternalID = aws.String(role.ExternalID)
+		}
+
+		if len(role.SessionName) > 0 {
+			p.RoleSessionName = role.SessionName
+		}
+	})
+}
+
 // BootstrapServices creates AWS client list
 func BootstrapServices(region string, assumeRole string) Client {
-	awsSession := GetAwsSession()
+	return BootstrapServicesWithRole(region, AssumeRoleConfig{RoleArn: assumeRole})
+}
 
-	var creds *credentials.Credentials
-	if len(assumeRole) != 0 {
-		creds = stscreds.NewCredentials(awsSession, assumeRole)
-	}
+// BootstrapServicesWithRole creates AWS client list with assume role configuration
+func BootstrapServicesWithRole(region string, role AssumeRoleConfig) Client {
+	awsSession := GetAwsSession()
+	creds := NewAssumeRoleCredentials(awsSession, role)
 
 	//Get all clients
 	client := Client{
@@ -89,16 +115,13 @@ func BootstrapServices(region string, assumeRole string) Client {
 
 func BootstrapMetricService(region string, assumeRole string) MetricClient {
 	awsSession := GetAwsSession()
-
-	var creds *credentials.Credentials
-	if len(assumeRole) != 0 {
-		creds = stscreds.NewCredentials(awsSession, assumeRole)
-	}
+	creds := NewAssumeRoleCredentials(awsSession, AssumeRoleConfig{RoleArn: assumeRole})
 
 	//Get all clients
 	client := MetricClient{
 		Region:            region,
-		DynamoDBService:   NewDynamoDBClient(awsSession, region, nil),
+		DynamoDBService:   NewDynamoDBClient(awsSession, region, creds),
+		S3Service:         NewS3Client(awsSession, region, creds),
 		CloudWatchService: NewCloudWatchClient(awsSession, region, creds),
 	}
 
@@ -107,11 +130,7 @@ func BootstrapMetricService(region string, assumeRole string) MetricClient {
 
 func BootstrapManifestService(region string, assumeRole string) ManifestClient {
 	awsSession := GetAwsSession()
-
-	var creds *credentials.Credentials
-	if len(assumeRole) != 0 {
-		creds = stscreds.NewCredentials(awsSession, assumeRole)
-	}
+	creds := NewAssumeRoleCredentials(awsSession, AssumeRoleConfig{RoleArn: assumeRole})
 
 	//Get all clients
 	client := ManifestClient{
@@ -18,6 +18,7 @@ package aws
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -32,13 +33,33 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
-type CloudWatchClient struct {
+// CloudWatchClient wraps cloudwatch alarm and metric operations
+type CloudWatchClient interface {
+	CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, policyArns map[string]string) error
+	CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error
+	GetTargetGroupRequestStatistics(tgs []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error)
+	GetLoadBalancerRequestStatistics(loadbalancers []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error)
+	GetOneDayStatisticsOfTargetGroup(tg string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error)
+	GetOneDayStatisticsOfLoadBalancer(lb string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error)
+	GetTargetGroupMetrics(tg, lb string, startTime, endTime time.Time) (TargetGroupMetrics, error)
+	GetMetricStatistic(namespace, metric, statistic string, dimensions map[string]string, startTime, endTime time.Time) (*float64, error)
+	GetMetricStatisticWithPeriod(namespace, metric, statistic string, dimensions map[string]string, period int64, startTime, endTime time.Time) (*float64, error)
+}
+
+// TargetGroupMetrics is the summary of target group metrics during a period
+type TargetGroupMetrics struct {
+	RequestCount   float64
+	Target5xxCount float64
+	ResponseTime   float64
+}
+
+type cloudWatchClient struct {
 	Client *cloudwatch.CloudWatch
 }
 
 // NewCloudWatchClient creates Cloudwatch client
 func NewCloudWatchClient(session client.ConfigProvider, region string, creds *credentials.Credentials) CloudWatchClient {
-	return CloudWatchClient{
+	return cloudWatchClient{
 		Client: getCloudWatchClientFn(session, region, creds),
 	}
 }
@@ -52,7 +73,7 @@ func getCloudWatchClientFn(session client.ConfigProvider, region string, creds *
 }
 
 // CreateScalingAlarms creates scaling alarms
-func (c CloudWatchClient) CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, policyArns map[string]string) error {
+func (c cloudWatchClient) CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, policyArns map[string]string) error {
 	if len(alarms) == 0 {
 		return nil
 	}
@@ -73,7 +94,7 @@ func (c CloudWatchClient) CreateScalingAlarms(asgName string, alarms []schemas.A
 }
 
 // CreateCloudWatchAlarm creates cloudwatch alarms for autoscaling group
-func (c CloudWatchClient) CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error {
+func (c cloudWatchClient) CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error {
 	input := &cloudwatch.PutMetricAlarmInput{
 		AlarmName:          aws.String(createAlarmName(asgName, alarm.Name)),
 		AlarmActions:       aws.StringSlice(alarm.AlarmActions),
@@ -103,7 +124,7 @@ func (c CloudWatchClient) CreateCloudWatchAlarm(asgName string, alarm schemas.Al
 }
 
 // GetTargetGroupRequestStatistics returns statistics for terminating autoscaling group
-func (c CloudWatchClient) GetTargetGroupRequestStatistics(tgs []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
+func (c cloudWatchClient) GetTargetGroupRequestStatistics(tgs []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
 	ret := map[string]map[string]float64{}
 
 	resetStartTime := startTime
@@ -168,7 +189,7 @@ func (c CloudWatchClient) GetTargetGroupRequestStatistics(tgs []*string, startTi
 }
 
 // GetLoadBalancerRequestStatistics returns statistics for terminating autoscaling group
-func (c CloudWatchClient) GetLoadBalancerRequestStatistics(loadbalancers []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
+func (c cloudWatchClient) GetLoadBalancerRequestStatistics(loadbalancers []*string, startTime, terminatedDate time.Time, logger *Logger.Logger) (map[string]map[string]float64, error) {
 	ret := map[string]map[string]float64{}
 
 	resetStartTime := startTime
@@ -233,7 +254,7 @@ func (c CloudWatchClient) GetLoadBalancerRequestStatistics(loadbalancers []*stri
 }
 
 // GetOneDayStatisticsOfTargetGroup returns all stats of one day
-func (c CloudWatchClient) GetOneDayStatisticsOfTargetGroup(tg string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
+func (c cloudWatchClient) GetOneDayStatisticsOfTargetGroup(tg string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
 	input := &cloudwatch.GetMetricDataInput{
 		StartTime: aws.Time(startTime),
 		EndTime:   aws.Time(endTime),
@@ -289,7 +310,7 @@ func (c CloudWatchClient) GetOneDayStatisticsOfTargetGroup(tg string, startTime,
 }
 
 // GetOneDayStatisticsOfLoadBalancer returns all stats of one day
-func (c CloudWatchClient) GetOneDayStatisticsOfLoadBalancer(lb string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
+func (c cloudWatchClient) GetOneDayStatisticsOfLoadBalancer(lb string, startTime, endTime time.Time, period int64, id string) (map[string]float64, float64, error) {
 	input := &cloudwatch.GetMetricDataInput{
 		StartTime: aws.Time(startTime),
 		EndTime:   aws.Time(endTime),
@@ -344,6 +365,174 @@ func (c CloudWatchClient) GetOneDayStatisticsOfLoadBalancer(lb string, startTime
 	return ret, sum, nil
 }
 
+// GetTargetGroupMetrics returns request count, 5xx count and average response time of target group in load balancer
+func (c cloudWatchClient) GetTargetGroupMetrics(tg, lb string, startTime, endTime time.Time) (TargetGroupMetrics, error) {
+	var ret TargetGroupMetrics
+
+	period := int64(endTime.Sub(startTime).Seconds())
+	period = (period/60 + 1) * 60
+
+	dimensions := []*cloudwatch.Dimension{
+		{
+			Name:  aws.String("TargetGroup"),
+			Value: aws.String(tool.GetTargetGroupDimension(tg)),
+		},
+		{
+			Name:  aws.String("LoadBalancer"),
+			Value: aws.String(tool.GetLoadBalancerDimension(lb)),
+		},
+	}
+
+	metrics := map[string]struct {
+		name string
+		stat string
+	}{
+		"requests":     {name: "RequestCount", stat: "Sum"},
+		"errors":       {name: "HTTPCode_Target_5XX_Count", stat: "Sum"},
+		"responsetime": {name: "TargetResponseTime", stat: "Average"},
+	}
+
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(startTime),
+		EndTime:   aws.Time(endTime),
+	}
+
+	for id, m := range metrics {
+		input.MetricDataQueries = append(input.MetricDataQueries, &cloudwatch.MetricDataQuery{
+			Id: aws.String(id),
+			MetricStat: &cloudwatch.MetricStat{
+				Metric: &cloudwatch.Metric{
+					Dimensions: dimensions,
+					MetricName: aws.String(m.name),
+					Namespace:  aws.String("AWS/ApplicationELB"),
+				},
+				Period: aws.Int64(period),
+				Stat:   aws.String(m.stat),
+			},
+		})
+	}
+
+	result, err := c.Client.GetMetricData(input)
+	if err != nil {
+		return ret, err
+	}
+
+	for _, r := range result.MetricDataResults {
+		sum := float64(0)
+		for _, v := range r.Values {
+			sum += *v
+		}
+
+		switch *r.Id {
+		case "requests":
+			ret.RequestCount = sum
+		case "errors":
+			ret.Target5xxCount = sum
+		case "responsetime":
+			if len(r.Values) > 0 {
+				ret.ResponseTime = sum / float64(len(r.Values))
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// GetMetricStatistic returns statistic of metric during the whole period or nil if there is no datapoint
+func (c cloudWatchClient) GetMetricStatistic(namespace, metric, statistic string, dimensions map[string]string, startTime, endTime time.Time) (*float64, error) {
+	period := int64(endTime.Sub(startTime).Seconds())
+	period = (period/60 + 1) * 60
+
+	return c.GetMetricStatisticWithPeriod(namespace, metric, statistic, dimensions, period, startTime, endTime)
+}
+
+// GetMetricStatisticWithPeriod returns statistic of metric aggregated from datapoints of the period or nil if there is no datapoint.
+// Percentile statistics like p99 are aggregated with the maximum of datapoints.
+func (c cloudWatchClient) GetMetricStatisticWithPeriod(namespace, metric, statistic string, dimensions map[string]string, period int64, startTime, endTime time.Time) (*float64, error) {
+	percentile := tool.IsPercentileStatistic(statistic)
+	input := &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(namespace),
+		MetricName: aws.String(metric),
+		Period:     aws.Int64(period),
+	}
+
+	if percentile {
+		input.ExtendedStatistics = aws.StringSlice([]string{statistic})
+	} else {
+		input.Statistics = aws.StringSlice([]string{statistic})
+	}
+
+	for k, v := range dimensions {
+		input.Dimensions = append(input.Dimensions, &cloudwatch.Dimension{
+			Name:  aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+
+	// cloudwatch returns limited number of datapoints for a request
+	window := time.Duration(period*constants.MaxMetricDatapoints) * time.Second
+	var values []float64
+	for start := startTime; start.Before(endTime); start = start.Add(window) {
+		end := start.Add(window)
+		if end.After(endTime) {
+			end = endTime
+		}
+		input.StartTime = aws.Time(start)
+		input.EndTime = aws.Time(end)
+
+		result, err := c.Client.GetMetricStatistics(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dp := range result.Datapoints {
+			if v := datapointValue(dp, statistic); v != nil {
+				values = append(values, *v)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	ret := values[0]
+	for _, v := range values[1:] {
+		switch {
+		case statistic == "Minimum":
+			ret = math.Min(ret, v)
+		case statistic == "Maximum" || percentile:
+			ret = math.Max(ret, v)
+		default:
+			ret += v
+		}
+	}
+
+	if statistic == "Average" {
+		ret /= float64(len(values))
+	}
+
+	return &ret, nil
+}
+
+// datapointValue returns value of the statistic in datapoint
+func datapointValue(dp *cloudwatch.Datapoint, statistic string) *float64 {
+	switch statistic {
+	case "Average":
+		return dp.Average
+	case "Sum":
+		return dp.Sum
+	case "Minimum":
+		return dp.Minimum
+	case "Maximum":
+		return dp.Maximum
+	case "SampleCount":
+		return dp.SampleCount
+	}
+
+	return dp.ExtendedStatistics[statistic]
+}
+
 // CheckMetricTimeValidation validates metric time
 func CheckMetricTimeValidation(startTime time.Time, endTime time.Time) bool {
 	return endTime.Sub(startTime) > 0
@@ -18,25 +18,42 @@ package aws
 
 import (
 	"fmt"
-	"time"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	Logger "github.com/sirupsen/logrus"
 
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
-	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 )
 
-type DynamoDBClient struct {
+// DynamoDBClient wraps dynamodb operations for deployment metrics
+type DynamoDBClient interface {
+	CheckTableExists(tableName string) (bool, error)
+	CreateTable(tableName, billingMode string) error
+	PutItem(tableName string, item map[string]*dynamodb.AttributeValue) error
+	UpdateItem(tableName, key string, fields map[string]*dynamodb.AttributeValue) error
+	GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error)
+	ScanItems(tableName string) ([]map[string]*dynamodb.AttributeValue, error)
+	QueryIndex(tableName, indexName string, conditions map[string]string) ([]map[string]*dynamodb.AttributeValue, error)
+	AcquireLease(tableName string, lease schemas.Lease, now int64) (bool, error)
+	RenewLease(tableName string, lease schemas.Lease) (bool, error)
+	GetLease(tableName, key string) (*schemas.Lease, error)
+	DeleteLease(tableName, key, token string) error
+}
+
+type dynamoDBClient struct {
 	Client *dynamodb.DynamoDB
 }
 
 func NewDynamoDBClient(session client.ConfigProvider, region string, creds *credentials.Credentials) DynamoDBClient {
-	return DynamoDBClient{
+	return dynamoDBClient{
 		Client: getDynamoDBClientFn(session, region, creds),
 	}
 }
@@ -48,7 +65,7 @@ func getDynamoDBClientFn(session client.ConfigProvider, region string, creds *cr
 	return dynamodb.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
 }
 
-func (d DynamoDBClient) CheckTableExists(tableName string) (bool, error) {
+func (d dynamoDBClient) CheckTableExists(tableName string) (bool, error) {
 	input := &dynamodb.DescribeTableInput{
 		TableName: aws.String(tableName),
 	}
@@ -80,13 +97,39 @@ func (d DynamoDBClient) CheckTableExists(tableName string) (bool, error) {
 	return true, nil
 }
 
-func (d DynamoDBClient) CreateTable(tableName string) error {
+// CreateTable creates metric table with global secondary index of application and stack
+func (d dynamoDBClient) CreateTable(tableName, billingMode string) error {
+	index := &dynamodb.GlobalSecondaryIndex{
+		IndexName: aws.String(constants.ApplicationIndex),
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(constants.ApplicationKey),
+				KeyType:       aws.String("HASH"),
+			},
+			{
+				AttributeName: aws.String(constants.StackNameKey),
+				KeyType:       aws.String("RANGE"),
+			},
+		},
+		Projection: &dynamodb.Projection{
+			ProjectionType: aws.String(dynamodb.ProjectionTypeAll),
+		},
+	}
+
 	input := &dynamodb.CreateTableInput{
 		AttributeDefinitions: []*dynamodb.AttributeDefinition{
 			{
 				AttributeName: aws.String(constants.HashKey),
 				AttributeType: aws.String("S"),
 			},
+			{
+				AttributeName: aws.String(constants.ApplicationKey),
+				AttributeType: aws.String("S"),
+			},
+			{
+				AttributeName: aws.String(constants.StackNameKey),
+				AttributeType: aws.String("S"),
+			},
 		},
 		KeySchema: []*dynamodb.KeySchemaElement{
 			{
@@ -94,11 +137,19 @@ func (d DynamoDBClient) CreateTable(tableName string) error {
 				KeyType:       aws.String("HASH"),
 			},
 		},
-		ProvisionedThroughput: &dynamodb.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(constants.DefaultWriteThroughput),
-			WriteCapacityUnits: aws.Int64(constants.DefaultReadThroughput),
-		},
-		TableName: aws.String(tableName),
+		BillingMode:            aws.String(constants.PayPerRequestBillingMode),
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndex{index},
+		TableName:              aws.String(tableName),
+	}
+
+	if billingMode == constants.ProvisionedBillingMode {
+		throughput := &dynamodb.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(constants.DefaultReadThroughput),
+			WriteCapacityUnits: aws.Int64(constants.DefaultWriteThroughput),
+		}
+		input.BillingMode = aws.String(constants.ProvisionedBillingMode)
+		input.ProvisionedThroughput = throughput
+		index.ProvisionedThroughput = throughput
 	}
 
 	_, err := d.Client.CreateTable(input)
@@ -125,124 +176,64 @@ func (d DynamoDBClient) CreateTable(tableName string) error {
 	return nil
 }
 
-func (d DynamoDBClient) MakeRecord(stack, config, tags string, asg string, tableName string, status, timezone string, additionalFields map[string]string) error {
+// PutItem puts item to the table
+func (d dynamoDBClient) PutItem(tableName string, item map[string]*dynamodb.AttributeValue) error {
 	input := &dynamodb.PutItemInput{
-		Item: map[string]*dynamodb.AttributeValue{
-			"identifier": {
-				S: aws.String(asg),
-			},
-			"deployment_status": {
-				S: aws.String(status),
-			},
-			"stack": {
-				S: aws.String(stack),
-			},
-			"config": {
-				S: aws.String(config),
-			},
-			"start_date": {
-				S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339)),
-			},
-			"tag": {
-				S: aws.String(tags),
-			},
-		},
+		Item:      item,
 		TableName: aws.String(tableName),
 	}
 
-	for k, v := range additionalFields {
-		input.Item[k] = &dynamodb.AttributeValue{S: aws.String(v)}
-	}
-
-	_, err := d.Client.PutItem(input)
-	if err != nil {
+	if _, err := d.Client.PutItem(input); err != nil {
 		return err
 	}
 
-	Logger.Debugf("deployment metric is saved")
+	Logger.Debugf("item is saved: %s", aws.StringValue(item[constants.HashKey].S))
 
 	return nil
 }
 
-func (d DynamoDBClient) UpdateRecord(updateKey, asg string, tableName string, status, timezone string, updateFields map[string]interface{}) error {
-	baseEx := "SET #S = :status, #T = :timestamp"
+// UpdateItem sets fields of the item with key. The item is created if it does not exist.
+func (d dynamoDBClient) UpdateItem(tableName, key string, fields map[string]*dynamodb.AttributeValue) error {
+	if len(fields) == 0 {
+		return nil
+	}
 
 	input := &dynamodb.UpdateItemInput{
-		ExpressionAttributeNames: map[string]*string{
-			"#S": aws.String(updateKey),
-			"#T": aws.String(constants.StatusTimeStampKey[status]),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":status": {
-				S: aws.String(status),
-			},
-			":timestamp": {
-				S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339)),
-			},
-		},
+		ExpressionAttributeNames:  map[string]*string{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
 		Key: map[string]*dynamodb.AttributeValue{
 			constants.HashKey: {
-				S: aws.String(asg),
+				S: aws.String(key),
 			},
 		},
-		TableName:        aws.String(tableName),
-		UpdateExpression: aws.String(baseEx),
-	}
-
-	if updateFields != nil {
-		ex := baseEx
-		for k, v := range updateFields {
-			ex = fmt.Sprintf("%s, #%s = :%s", ex, k, k)
-			input.UpdateExpression = aws.String(ex)
-			input.ExpressionAttributeNames[fmt.Sprintf("#%s", k)] = aws.String(k)
-			if k != "requestSum" {
-				input.ExpressionAttributeValues[fmt.Sprintf(":%s", k)] = &dynamodb.AttributeValue{
-					S: aws.String(v.(string)),
-				}
-			} else {
-				input.ExpressionAttributeValues[fmt.Sprintf(":%s", k)] = &dynamodb.AttributeValue{
-					N: aws.String(fmt.Sprintf("%f", v.(float64))),
-				}
-			}
-		}
+		TableName: aws.String(tableName),
 	}
 
-	_, err := d.Client.UpdateItem(input)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case dynamodb.ErrCodeConditionalCheckFailedException:
-				fmt.Println(dynamodb.ErrCodeConditionalCheckFailedException, aerr.Error())
-			case dynamodb.ErrCodeProvisionedThroughputExceededException:
-				fmt.Println(dynamodb.ErrCodeProvisionedThroughputExceededException, aerr.Error())
-			case dynamodb.ErrCodeResourceNotFoundException:
-				fmt.Println(dynamodb.ErrCodeResourceNotFoundException, aerr.Error())
-			case dynamodb.ErrCodeItemCollectionSizeLimitExceededException:
-				fmt.Println(dynamodb.ErrCodeItemCollectionSizeLimitExceededException, aerr.Error())
-			case dynamodb.ErrCodeTransactionConflictException:
-				fmt.Println(dynamodb.ErrCodeTransactionConflictException, aerr.Error())
-			case dynamodb.ErrCodeRequestLimitExceeded:
-				fmt.Println(dynamodb.ErrCodeRequestLimitExceeded, aerr.Error())
-			case dynamodb.ErrCodeInternalServerError:
-				fmt.Println(dynamodb.ErrCodeInternalServerError, aerr.Error())
-			default:
-				fmt.Println(aerr.Error())
-			}
-		} else {
-			// Print the error, cast err to awserr.Error to get the Code and
-			// Message from an error.
-			fmt.Println(err.Error())
-		}
+	var names []string
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sets []string
+	for i, k := range names {
+		input.ExpressionAttributeNames[fmt.Sprintf("#F%d", i)] = aws.String(k)
+		input.ExpressionAttributeValues[fmt.Sprintf(":f%d", i)] = fields[k]
+		sets = append(sets, fmt.Sprintf("#F%d = :f%d", i, i))
+	}
+	input.UpdateExpression = aws.String(fmt.Sprintf("SET %s", strings.Join(sets, ", ")))
+
+	if _, err := d.Client.UpdateItem(input); err != nil {
 		return err
 	}
 
-	Logger.Debugf("Status is updated to %s", status)
+	Logger.Debugf("item is updated: %s", key)
 
 	return nil
 }
 
 // GetSingleItem retrieves single item for single autoscaling group
-func (d DynamoDBClient) GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error) {
+func (d dynamoDBClient) GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error) {
 	input := &dynamodb.GetItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			constants.HashKey: {
@@ -260,64 +251,179 @@ func (d DynamoDBClient) GetSingleItem(asg, tableName string) (map[string]*dynamo
 	return result.Item, err
 }
 
-// UpdateStatistics updates the status value on metric table
-func (d DynamoDBClient) UpdateStatistics(asg string, tableName, timezone string, updateFields map[string]interface{}) error {
-	baseEx := "SET #T = :statisticsRecordTime"
+// ScanItems retrieves all items in the table
+func (d dynamoDBClient) ScanItems(tableName string) ([]map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+	}
 
-	input := &dynamodb.UpdateItemInput{
+	var ret []map[string]*dynamodb.AttributeValue
+	err := d.Client.ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		ret = append(ret, page.Items...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// QueryIndex retrieves items from global secondary index whose attributes are equal to the conditions
+func (d dynamoDBClient) QueryIndex(tableName, indexName string, conditions map[string]string) ([]map[string]*dynamodb.AttributeValue, error) {
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames:  map[string]*string{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+		IndexName:                 aws.String(indexName),
+		TableName:                 aws.String(tableName),
+	}
+
+	var names []string
+	for k := range conditions {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var exprs []string
+	for i, k := range names {
+		input.ExpressionAttributeNames[fmt.Sprintf("#K%d", i)] = aws.String(k)
+		input.ExpressionAttributeValues[fmt.Sprintf(":k%d", i)] = &dynamodb.AttributeValue{S: aws.String(conditions[k])}
+		exprs = append(exprs, fmt.Sprintf("#K%d = :k%d", i, i))
+	}
+	input.KeyConditionExpression = aws.String(strings.Join(exprs, " AND "))
+
+	var ret []map[string]*dynamodb.AttributeValue
+	err := d.Client.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		ret = append(ret, page.Items...)
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// AcquireLease puts lease unless another holder has a lease which is not expired yet
+func (d dynamoDBClient) AcquireLease(tableName string, lease schemas.Lease, now int64) (bool, error) {
+	input := &dynamodb.PutItemInput{
+		Item:                leaseItem(lease),
+		ConditionExpression: aws.String("attribute_not_exists(#K) OR #E < :now OR #T = :token"),
 		ExpressionAttributeNames: map[string]*string{
-			"#T": aws.String("statistics_record_time"),
+			"#K": aws.String(constants.HashKey),
+			"#E": aws.String("expires_at"),
+			"#T": aws.String("token"),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":statisticsRecordTime": {
-				S: aws.String(tool.GetBaseTimeWithTimezone(timezone).Format(time.RFC3339)),
-			},
+			":now":   {N: aws.String(fmt.Sprintf("%d", now))},
+			":token": {S: aws.String(lease.Token)},
 		},
-		Key: map[string]*dynamodb.AttributeValue{
-			constants.HashKey: {
-				S: aws.String(asg),
-			},
+		TableName: aws.String(tableName),
+	}
+
+	if _, err := d.Client.PutItem(input); err != nil {
+		if isConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RenewLease extends expiration of lease if it is still held with the token
+func (d dynamoDBClient) RenewLease(tableName string, lease schemas.Lease) (bool, error) {
+	input := &dynamodb.UpdateItemInput{
+		Key:                 leaseKey(lease.Key),
+		UpdateExpression:    aws.String("SET #E = :expires"),
+		ConditionExpression: aws.String("#T = :token"),
+		ExpressionAttributeNames: map[string]*string{
+			"#E": aws.String("expires_at"),
+			"#T": aws.String("token"),
 		},
-		TableName:        aws.String(tableName),
-		UpdateExpression: aws.String(baseEx),
-	}
-
-	if updateFields != nil {
-		ex := baseEx
-		for k, v := range updateFields {
-			ex = fmt.Sprintf("%s, #%s = :%s", ex, k, k)
-			input.UpdateExpression = aws.String(ex)
-			input.ExpressionAttributeNames[fmt.Sprintf("#%s", k)] = aws.String(k)
-			if !tool.IsStringInArray(k, []string{"tg_request_count", "lb_request_count"}) {
-				input.ExpressionAttributeValues[fmt.Sprintf(":%s", k)] = &dynamodb.AttributeValue{
-					S: aws.String(v.(string)),
-				}
-			} else {
-				// stat data
-				statData := v.(map[string]map[string]float64)
-				refined := map[string]*dynamodb.AttributeValue{}
-				for tg, vv := range statData {
-					temp := map[string]*dynamodb.AttributeValue{}
-					for id, vvv := range vv {
-						temp[id] = &dynamodb.AttributeValue{
-							N: aws.String(fmt.Sprintf("%f", vvv)),
-						}
-					}
-					refined[tg] = &dynamodb.AttributeValue{
-						M: temp,
-					}
-				}
-				input.ExpressionAttributeValues[fmt.Sprintf(":%s", k)] = &dynamodb.AttributeValue{
-					M: refined,
-				}
-			}
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":expires": {N: aws.String(fmt.Sprintf("%d", lease.ExpiresAt))},
+			":token":   {S: aws.String(lease.Token)},
+		},
+		TableName: aws.String(tableName),
+	}
+
+	if _, err := d.Client.UpdateItem(input); err != nil {
+		if isConditionalCheckFailed(err) {
+			return false, nil
 		}
+		return false, err
 	}
 
-	_, err := d.Client.UpdateItem(input)
+	return true, nil
+}
+
+// GetLease retrieves lease of key. It returns nil if nobody holds the lock.
+func (d dynamoDBClient) GetLease(tableName, key string) (*schemas.Lease, error) {
+	result, err := d.Client.GetItem(&dynamodb.GetItemInput{
+		Key:            leaseKey(key),
+		TableName:      aws.String(tableName),
+		ConsistentRead: aws.Bool(true),
+	})
 	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Item) == 0 {
+		return nil, nil
+	}
+
+	lease := schemas.Lease{Key: key}
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &lease); err != nil {
+		return nil, err
+	}
+	lease.Key = key
+
+	return &lease, nil
+}
+
+// DeleteLease deletes lease of key. Lease is deleted regardless of holder if token is empty.
+func (d dynamoDBClient) DeleteLease(tableName, key, token string) error {
+	input := &dynamodb.DeleteItemInput{
+		Key:       leaseKey(key),
+		TableName: aws.String(tableName),
+	}
+
+	if len(token) > 0 {
+		input.ConditionExpression = aws.String("#T = :token")
+		input.ExpressionAttributeNames = map[string]*string{"#T": aws.String("token")}
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":token": {S: aws.String(token)}}
+	}
+
+	if _, err := d.Client.DeleteItem(input); err != nil && !isConditionalCheckFailed(err) {
 		return err
 	}
 
 	return nil
 }
+
+// leaseKey returns hash key of lease item
+func leaseKey(key string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		constants.HashKey: {
+			S: aws.String(constants.LockKeyPrefix + key),
+		},
+	}
+}
+
+// leaseItem makes item of lease for metric table
+func leaseItem(lease schemas.Lease) map[string]*dynamodb.AttributeValue {
+	item := leaseKey(lease.Key)
+	item["holder"] = &dynamodb.AttributeValue{S: aws.String(lease.Holder)}
+	item["token"] = &dynamodb.AttributeValue{S: aws.String(lease.Token)}
+	item["acquired_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", lease.AcquiredAt))}
+	item["expires_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", lease.ExpiresAt))}
+
+	return item
+}
+
+// isConditionalCheckFailed checks if error is caused by condition expression
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -37,14 +38,64 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
-type EC2Client struct {
+// EC2Client wraps EC2, autoscaling and KMS operations used for deployment
+type EC2Client interface {
+	GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error)
+	GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error)
+	GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error)
+	DeleteLaunchConfigurations(asgName string) error
+	DeleteLaunchTemplates(asgName string) error
+	DeleteAutoscalingSet(asgName string) error
+	GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error)
+	CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool
+	CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions *schemas.InstanceMarketOptions, detailedMonitoringEnabled bool) error
+	GetSecurityGroupList(vpc string, sgList []string) ([]*string, error)
+	MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping
+	MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	GetVPCId(vpc string) (string, error)
+	CreateAutoScalingGroup(name, launchTemplateName, healthcheckType string, healthcheckGracePeriod int64, capacity schemas.Capacity, loadbalancers, availabilityZones []string, targetGroupArns, terminationPolicies []*string, tags []*autoscaling.Tag, subnets []string, mixedInstancePolicy schemas.MixedInstancesPolicy, hooks []*autoscaling.LifecycleHookSpecification) error
+	GetAvailabilityZones(vpc string, azs []string) ([]string, error)
+	GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error)
+	UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error)
+	CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error)
+	EnableMetrics(asgName string) error
+	GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification
+	GetTargetGroups(asgName string) ([]*string, error)
+	UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error
+	CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error
+	AttachAsgToTargetGroups(asg string, targetGroups []*string) error
+	DetachAsgFromTargetGroups(asg string, targetGroups []*string) error
+	CreateSecurityGroup(sgName string, vpcID *string) (*string, error)
+	GetSecurityGroup(sgName string) (*string, error)
+	UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error
+	UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error
+	UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error
+	DeleteSecurityGroup(sg string) error
+	RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error
+	DeleteCanaryTag(asg string) error
+	DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error)
+	GetInstanceStatusChecks(instanceIds []*string) (map[string]bool, error)
+	ModifyNetworkInterfaces(eni *string, groups []*string) error
+	CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error)
+	UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error
+	DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error
+	TerminateInstanceInAutoScalingGroup(instanceID string) error
+	DescribeScalingActivities(asg string, since time.Time) ([]*autoscaling.Activity, error)
+	CreateLaunchTemplateVersionWithInstanceType(ltName, sourceVersion, instanceType string) (*ec2.LaunchTemplateVersion, error)
+	StartInstanceRefresh(name *string, instanceWarmup, minHealthyPercentage int64) (*string, error)
+	DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error)
+	DescribeInstanceTypes() ([]string, error)
+	DescribeAMIArchitecture(amiID string) (string, error)
+}
+
+type ec2Client struct {
 	Client    *ec2.EC2
 	AsClient  *autoscaling.AutoScaling
 	KMSClient *kms.KMS
 }
 
 func NewEC2Client(session client.ConfigProvider, region string, creds *credentials.Credentials) EC2Client {
-	return EC2Client{
+	return ec2Client{
 		Client:    getEC2ClientFn(session, region, creds),
 		AsClient:  getAsgClientFn(session, region, creds),
 		KMSClient: getKMSClientFn(session, region, creds),
@@ -76,7 +127,7 @@ func getKMSClientFn(session client.ConfigProvider, region string, creds *credent
 }
 
 // GetMatchingAutoscalingGroup returns only one matching autoscaling group information
-func (e EC2Client) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
+func (e ec2Client) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
 	asgGroup, err := getSingleAutoScalingGroup(e.AsClient, name)
 	if err != nil {
 		return nil, err
@@ -86,7 +137,7 @@ func (e EC2Client) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group,
 }
 
 // GetMatchingLaunchTemplate returns information of launch template with matched ID
-func (e EC2Client) GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error) {
+func (e ec2Client) GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error) {
 	input := &ec2.DescribeLaunchTemplateVersionsInput{
 		LaunchTemplateId: aws.String(ltID),
 	}
@@ -100,7 +151,7 @@ func (e EC2Client) GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVe
 }
 
 // GetSecurityGroupDetails returns detailed information for security group
-func (e EC2Client) GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error) {
+func (e ec2Client) GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error) {
 	input := &ec2.DescribeSecurityGroupsInput{
 		GroupIds: sgIds,
 	}
@@ -114,7 +165,7 @@ func (e EC2Client) GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGrou
 }
 
 // DeleteLaunchConfigurations deletes All Launch Configurations belongs to the autoscaling group
-func (e EC2Client) DeleteLaunchConfigurations(asgName string) error {
+func (e ec2Client) DeleteLaunchConfigurations(asgName string) error {
 	lcs := getAllLaunchConfigurations(e.AsClient, []*autoscaling.LaunchConfiguration{}, nil)
 
 	for _, lc := range lcs {
@@ -130,7 +181,7 @@ func (e EC2Client) DeleteLaunchConfigurations(asgName string) error {
 }
 
 // DeleteLaunchTemplates deletes all launch template belongs to the autoscaling group
-func (e EC2Client) DeleteLaunchTemplates(asgName string) error {
+func (e ec2Client) DeleteLaunchTemplates(asgName string) error {
 	lts := getAllLaunchTemplates(e.Client, []*ec2.LaunchTemplate{}, nil)
 
 	for _, lt := range lts {
@@ -146,7 +197,7 @@ func (e EC2Client) DeleteLaunchTemplates(asgName string) error {
 }
 
 // DeleteAutoscalingSet Delete Autoscaling group Set
-func (e EC2Client) DeleteAutoscalingSet(asgName string) error {
+func (e ec2Client) DeleteAutoscalingSet(asgName string) error {
 	input := &autoscaling.DeleteAutoScalingGroupInput{
 		AutoScalingGroupName: aws.String(asgName),
 	}
@@ -161,7 +212,7 @@ func (e EC2Client) DeleteAutoscalingSet(asgName string) error {
 
 // GetAllMatchingAutoscalingGroupsWithPrefix Get All matching autoscaling groups with aws prefix
 // By this function, you could get the latest version of deployment
-func (e EC2Client) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
+func (e ec2Client) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
 	asgGroups, err := getAutoScalingGroups(e.AsClient, []*autoscaling.Group{}, nil)
 	if err != nil {
 		return nil, err
@@ -281,7 +332,7 @@ func deleteLaunchTemplate(client *ec2.EC2, ltName string) error {
 }
 
 // CreateNewLaunchConfiguration Create New Launch Configuration
-func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool {
+func (e ec2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized bool, securityGroups []*string, blockDevices []*autoscaling.BlockDeviceMapping) bool {
 	input := &autoscaling.CreateLaunchConfigurationInput{
 		LaunchConfigurationName: aws.String(name),
 		ImageId:                 aws.String(ami),
@@ -324,7 +375,7 @@ func (e EC2Client) CreateNewLaunchConfiguration(name, ami, instanceType, keyName
 }
 
 // CreateNewLaunchTemplate Create New Launch Template
-func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions *schemas.InstanceMarketOptions, detailedMonitoringEnabled bool) error {
+func (e ec2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, mixedInstancePolicyEnabled bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, instanceMarketOptions *schemas.InstanceMarketOptions, detailedMonitoringEnabled bool) error {
 	input := &ec2.CreateLaunchTemplateInput{
 		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
 			ImageId:      aws.String(ami),
@@ -382,7 +433,7 @@ func (e EC2Client) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iam
 }
 
 // GetSecurityGroupList Get All Security Group Information New Launch Configuration
-func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
+func (e ec2Client) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
 	if len(sgList) == 0 {
 		return nil, errors.New("need to specify at least one security group")
 	}
@@ -437,7 +488,7 @@ func (e EC2Client) GetSecurityGroupList(vpc string, sgList []string) ([]*string,
 }
 
 // MakeBlockDevices returns list of block device mapping for launch configuration
-func (e EC2Client) MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping {
+func (e ec2Client) MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping {
 	var ret []*autoscaling.BlockDeviceMapping
 
 	for _, block := range blocks {
@@ -468,7 +519,7 @@ func (e EC2Client) MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling
 }
 
 // MakeLaunchTemplateBlockDeviceMappings returns list of block device mappings for launch template
-func (e EC2Client) MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest {
+func (e ec2Client) MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest {
 	var ret []*ec2.LaunchTemplateBlockDeviceMappingRequest
 
 	for _, block := range blocks {
@@ -527,7 +578,7 @@ func (e EC2Client) MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockD
 	return ret
 }
 
-func (e EC2Client) GetVPCId(vpc string) (string, error) {
+func (e ec2Client) GetVPCId(vpc string) (string, error) {
 	ret, err := regexp.MatchString("vpc-[0-9A-Fa-f]{17}", vpc)
 	if err != nil {
 		return constants.EmptyString, fmt.Errorf("error occurs when checking regex %v", err.Error())
@@ -567,7 +618,7 @@ func (e EC2Client) GetVPCId(vpc string) (string, error) {
 }
 
 // CreateAutoScalingGroup creates new autoscaling group
-func (e EC2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckType string,
+func (e ec2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckType string,
 	healthcheckGracePeriod int64,
 	capacity schemas.Capacity,
 	loadbalancers, availabilityZones []string,
@@ -646,7 +697,7 @@ func (e EC2Client) CreateAutoScalingGroup(name, launchTemplateName, healthcheckT
 }
 
 // GetAvailabilityZones get all available availability zones
-func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
+func (e ec2Client) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
 	var ret []string
 	vpcID, err := e.GetVPCId(vpc)
 	if err != nil {
@@ -680,7 +731,7 @@ func (e EC2Client) GetAvailabilityZones(vpc string, azs []string) ([]string, err
 }
 
 // GetSubnets retrieves all subnets available
-func (e EC2Client) GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error) {
+func (e ec2Client) GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error) {
 	vpcID, err := e.GetVPCId(vpc)
 	if err != nil {
 		return nil, err
@@ -723,7 +774,7 @@ func (e EC2Client) GetSubnets(vpc string, usePublicSubnets bool, azs []string) (
 }
 
 // UpdateAutoScalingGroupSize Update Autoscaling Group size
-func (e EC2Client) UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error) {
+func (e ec2Client) UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error) {
 	input := &autoscaling.UpdateAutoScalingGroupInput{
 		AutoScalingGroupName: aws.String(asg),
 		MaxSize:              aws.Int64(max),
@@ -740,7 +791,7 @@ func (e EC2Client) UpdateAutoScalingGroupSize(asg string, min, max, desired, ret
 }
 
 // CreateScalingPolicy creates scaling policy
-func (e EC2Client) CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error) {
+func (e ec2Client) CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error) {
 	input := &autoscaling.PutScalingPolicyInput{
 		AdjustmentType:       aws.String(policy.AdjustmentType),
 		AutoScalingGroupName: aws.String(asgName),
@@ -758,7 +809,7 @@ func (e EC2Client) CreateScalingPolicy(policy schemas.ScalePolicy, asgName strin
 }
 
 // EnableMetrics enables metric monitoring of autoscaling group
-func (e EC2Client) EnableMetrics(asgName string) error {
+func (e ec2Client) EnableMetrics(asgName string) error {
 	input := &autoscaling.EnableMetricsCollectionInput{
 		AutoScalingGroupName: aws.String(asgName),
 		Granularity:          aws.String("1Minute"),
@@ -775,7 +826,7 @@ func (e EC2Client) EnableMetrics(asgName string) error {
 }
 
 // GenerateLifecycleHooks generate lifecycle hooks
-func (e EC2Client) GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
+func (e ec2Client) GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
 	var ret []*autoscaling.LifecycleHookSpecification
 
 	if len(hooks.LaunchTransition) > 0 {
@@ -826,7 +877,7 @@ func createSingleLifecycleHookSpecification(l schemas.LifecycleHookSpecification
 }
 
 // GetTargetGroups returns list of target group ARN of autoscaling group
-func (e EC2Client) GetTargetGroups(asgName string) ([]*string, error) {
+func (e ec2Client) GetTargetGroups(asgName string) ([]*string, error) {
 	input := &autoscaling.DescribeAutoScalingGroupsInput{
 		AutoScalingGroupNames: []*string{
 			aws.String(asgName),
@@ -864,7 +915,7 @@ func getSingleAutoScalingGroup(client *autoscaling.AutoScaling, asgName string)
 }
 
 // UpdateAutoScalingGroup  updates auto scaling group information
-func (e EC2Client) UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error {
+func (e ec2Client) UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error {
 	input := &autoscaling.UpdateAutoScalingGroupInput{
 		AutoScalingGroupName: aws.String(asg),
 		MaxSize:              aws.Int64(capacity.Max),
@@ -881,7 +932,7 @@ func (e EC2Client) UpdateAutoScalingGroup(asg string, capacity schemas.Capacity)
 }
 
 // CreateScheduledActions creates scheduled actions
-func (e EC2Client) CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error {
+func (e ec2Client) CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error {
 	input := &autoscaling.BatchPutScheduledUpdateGroupActionInput{
 		AutoScalingGroupName: aws.String(asg),
 	}
@@ -910,7 +961,7 @@ func (e EC2Client) CreateScheduledActions(asg string, actions []schemas.Schedule
 }
 
 // AttachAsgToTargetGroups attaches autoscaling group to target groups of ELB
-func (e EC2Client) AttachAsgToTargetGroups(asg string, targetGroups []*string) error {
+func (e ec2Client) AttachAsgToTargetGroups(asg string, targetGroups []*string) error {
 	input := &autoscaling.AttachLoadBalancerTargetGroupsInput{
 		AutoScalingGroupName: aws.String(asg),
 		TargetGroupARNs:      targetGroups,
@@ -925,7 +976,7 @@ func (e EC2Client) AttachAsgToTargetGroups(asg string, targetGroups []*string) e
 }
 
 // DetachAsgFromTargetGroups detaches autoscaling group from target groups of ELB
-func (e EC2Client) DetachAsgFromTargetGroups(asg string, targetGroups []*string) error {
+func (e ec2Client) DetachAsgFromTargetGroups(asg string, targetGroups []*string) error {
 	input := &autoscaling.DetachLoadBalancerTargetGroupsInput{
 		AutoScalingGroupName: aws.String(asg),
 		TargetGroupARNs:      targetGroups,
@@ -940,7 +991,7 @@ func (e EC2Client) DetachAsgFromTargetGroups(asg string, targetGroups []*string)
 }
 
 // CreateSecurityGroup creates new security group
-func (e EC2Client) CreateSecurityGroup(sgName string, vpcID *string) (*string, error) {
+func (e ec2Client) CreateSecurityGroup(sgName string, vpcID *string) (*string, error) {
 	input := &ec2.CreateSecurityGroupInput{
 		Description: aws.String("canary deployment"),
 		GroupName:   aws.String(sgName),
@@ -956,7 +1007,7 @@ func (e EC2Client) CreateSecurityGroup(sgName string, vpcID *string) (*string, e
 }
 
 // GetSecurityGroup retrieves group id of existing security group
-func (e EC2Client) GetSecurityGroup(sgName string) (*string, error) {
+func (e ec2Client) GetSecurityGroup(sgName string) (*string, error) {
 	input := &ec2.DescribeSecurityGroupsInput{
 		Filters: []*ec2.Filter{
 			{
@@ -981,7 +1032,7 @@ func (e EC2Client) GetSecurityGroup(sgName string) (*string, error) {
 }
 
 // UpdateInboundRules updates inbound rules for security group  with IP
-func (e EC2Client) UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+func (e ec2Client) UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
 	input := &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId: aws.String(sgID),
 		IpPermissions: []*ec2.IpPermission{
@@ -1008,7 +1059,7 @@ func (e EC2Client) UpdateInboundRules(sgID, protocol, cidr, description string,
 }
 
 // UpdateInboundRulesWithGroup updates inbound rules for security group  with other security group
-func (e EC2Client) UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error {
+func (e ec2Client) UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error {
 	input := &ec2.AuthorizeSecurityGroupIngressInput{
 		GroupId: aws.String(sgID),
 		IpPermissions: []*ec2.IpPermission{
@@ -1035,7 +1086,7 @@ func (e EC2Client) UpdateInboundRulesWithGroup(sgID, protocol, description strin
 }
 
 // UpdateOutboundRules updates inbound rules  for security group  with IP
-func (e EC2Client) UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+func (e ec2Client) UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
 	input := &ec2.AuthorizeSecurityGroupEgressInput{
 		GroupId: aws.String(sgID),
 		IpPermissions: []*ec2.IpPermission{
@@ -1067,7 +1118,7 @@ func (e EC2Client) UpdateOutboundRules(sgID, protocol, cidr, description string,
 }
 
 // DeleteSecurityGroup deletes security group
-func (e EC2Client) DeleteSecurityGroup(sg string) error {
+func (e ec2Client) DeleteSecurityGroup(sg string) error {
 	input := &ec2.DeleteSecurityGroupInput{
 		GroupId: aws.String(sg),
 	}
@@ -1080,7 +1131,7 @@ func (e EC2Client) DeleteSecurityGroup(sg string) error {
 }
 
 // RevokeInboundRulesWithGroup revokes inbound rules for security group  with other security group
-func (e EC2Client) RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error {
+func (e ec2Client) RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error {
 	input := &ec2.RevokeSecurityGroupIngressInput{
 		GroupId: aws.String(sgID),
 		IpPermissions: []*ec2.IpPermission{
@@ -1106,7 +1157,7 @@ func (e EC2Client) RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *st
 }
 
 // DeleteCanaryTag deletes canary tag from auto scaling group
-func (e EC2Client) DeleteCanaryTag(asg string) error {
+func (e ec2Client) DeleteCanaryTag(asg string) error {
 	input := &autoscaling.DeleteTagsInput{
 		Tags: []*autoscaling.Tag{
 			{
@@ -1127,7 +1178,7 @@ func (e EC2Client) DeleteCanaryTag(asg string) error {
 }
 
 // DescribeInstances return detailed information of instances
-func (e EC2Client) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error) {
+func (e ec2Client) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error) {
 	input := &ec2.DescribeInstancesInput{
 		InstanceIds: instanceIds,
 	}
@@ -1137,15 +1188,38 @@ func (e EC2Client) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, er
 		return nil, err
 	}
 
-	if len(result.Reservations) == 0 {
-		return nil, nil
+	// instances launched by autoscaling group are usually in different reservations
+	var ret []*ec2.Instance
+	for _, r := range result.Reservations {
+		ret = append(ret, r.Instances...)
+	}
+
+	return ret, nil
+}
+
+// GetInstanceStatusChecks returns whether both instance and system status checks of instances are passed
+func (e ec2Client) GetInstanceStatusChecks(instanceIds []*string) (map[string]bool, error) {
+	input := &ec2.DescribeInstanceStatusInput{
+		InstanceIds: instanceIds,
+	}
+
+	ret := map[string]bool{}
+	err := e.Client.DescribeInstanceStatusPages(input, func(page *ec2.DescribeInstanceStatusOutput, lastPage bool) bool {
+		for _, s := range page.InstanceStatuses {
+			ret[*s.InstanceId] = s.InstanceStatus != nil && aws.StringValue(s.InstanceStatus.Status) == ec2.SummaryStatusOk &&
+				s.SystemStatus != nil && aws.StringValue(s.SystemStatus.Status) == ec2.SummaryStatusOk
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result.Reservations[0].Instances, nil
+	return ret, nil
 }
 
 // ModifyNetworkInterfaces modifies network interface attributes
-func (e EC2Client) ModifyNetworkInterfaces(eni *string, groups []*string) error {
+func (e ec2Client) ModifyNetworkInterfaces(eni *string, groups []*string) error {
 	input := &ec2.ModifyNetworkInterfaceAttributeInput{
 		Groups:             groups,
 		NetworkInterfaceId: eni,
@@ -1160,7 +1234,7 @@ func (e EC2Client) ModifyNetworkInterfaces(eni *string, groups []*string) error
 }
 
 // CreateNewLaunchTemplateVersion creates new version of launch template
-func (e EC2Client) CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
+func (e ec2Client) CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
 	input := &ec2.CreateLaunchTemplateVersionInput{
 		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
 			SecurityGroupIds: sgs,
@@ -1179,7 +1253,7 @@ func (e EC2Client) CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion,
 }
 
 // UpdateAutoScalingLaunchTemplate updates autoscaling launch template
-func (e EC2Client) UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error {
+func (e ec2Client) UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error {
 	input := &autoscaling.UpdateAutoScalingGroupInput{
 		AutoScalingGroupName: aws.String(asg),
 		LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
@@ -1197,7 +1271,7 @@ func (e EC2Client) UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTem
 }
 
 // DetachLoadBalancerTargetGroup detaches target group from autoscaling group
-func (e EC2Client) DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error {
+func (e ec2Client) DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error {
 	input := &autoscaling.DetachLoadBalancerTargetGroupsInput{
 		AutoScalingGroupName: aws.String(asg),
 		TargetGroupARNs:      tgARNs,
@@ -1211,8 +1285,78 @@ func (e EC2Client) DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) e
 	return nil
 }
 
+// TerminateInstanceInAutoScalingGroup terminates instance without decreasing desired capacity so that it is replaced
+func (e ec2Client) TerminateInstanceInAutoScalingGroup(instanceID string) error {
+	input := &autoscaling.TerminateInstanceInAutoScalingGroupInput{
+		InstanceId:                     aws.String(instanceID),
+		ShouldDecrementDesiredCapacity: aws.Bool(false),
+	}
+
+	_, err := e.AsClient.TerminateInstanceInAutoScalingGroup(input)
+	return err
+}
+
+// DescribeScalingActivities returns scaling activities of autoscaling group started after since, the latest first
+func (e ec2Client) DescribeScalingActivities(asg string, since time.Time) ([]*autoscaling.Activity, error) {
+	input := &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: aws.String(asg),
+	}
+
+	var ret []*autoscaling.Activity
+	err := e.AsClient.DescribeScalingActivitiesPages(input, func(page *autoscaling.DescribeScalingActivitiesOutput, lastPage bool) bool {
+		for _, activity := range page.Activities {
+			if activity.StartTime != nil && activity.StartTime.Before(since) {
+				return false
+			}
+			ret = append(ret, activity)
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// CreateLaunchTemplateVersionWithInstanceType creates new version of launch template which only changes instance type
+func (e ec2Client) CreateLaunchTemplateVersionWithInstanceType(ltName, sourceVersion, instanceType string) (*ec2.LaunchTemplateVersion, error) {
+	if len(sourceVersion) == 0 {
+		sourceVersion = "$Default"
+	}
+
+	// source version should be a number, so $Latest or $Default is resolved first
+	versions, err := e.Client.DescribeLaunchTemplateVersions(&ec2.DescribeLaunchTemplateVersionsInput{
+		LaunchTemplateName: aws.String(ltName),
+		Versions:           aws.StringSlice([]string{sourceVersion}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions.LaunchTemplateVersions) == 0 {
+		return nil, fmt.Errorf("version %s of launch template is not found: %s", sourceVersion, ltName)
+	}
+
+	input := &ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateData: &ec2.RequestLaunchTemplateData{
+			InstanceType: aws.String(instanceType),
+		},
+		LaunchTemplateName: aws.String(ltName),
+		SourceVersion:      aws.String(strconv.FormatInt(*versions.LaunchTemplateVersions[0].VersionNumber, 10)),
+		VersionDescription: aws.String(fmt.Sprintf("Fallback to %s", instanceType)),
+	}
+
+	result, err := e.Client.CreateLaunchTemplateVersion(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.LaunchTemplateVersion, nil
+}
+
 // StartInstanceRefresh starts instance refresh
-func (e EC2Client) StartInstanceRefresh(name *string, instanceWarmup, minHealthyPercentage int64) (*string, error) {
+func (e ec2Client) StartInstanceRefresh(name *string, instanceWarmup, minHealthyPercentage int64) (*string, error) {
 	input := &autoscaling.StartInstanceRefreshInput{
 		AutoScalingGroupName: name,
 		Preferences: &autoscaling.RefreshPreferences{
@@ -1230,7 +1374,7 @@ func (e EC2Client) StartInstanceRefresh(name *string, instanceWarmup, minHealthy
 }
 
 // DescribeInstanceRefreshes describes instance refresh information
-func (e EC2Client) DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error) {
+func (e ec2Client) DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error) {
 	input := &autoscaling.DescribeInstanceRefreshesInput{
 		AutoScalingGroupName: name,
 	}
@@ -1259,7 +1403,7 @@ func (e EC2Client) DescribeInstanceRefreshes(name, id *string) (*autoscaling.Ins
 }
 
 // aws ec2 describe-instance-types --filters Name=processor-info.supported-architecture,Values=arm64 --query "InstanceTypes[*].InstanceType"
-func (e EC2Client) DescribeInstanceTypes() ([]string, error) {
+func (e ec2Client) DescribeInstanceTypes() ([]string, error) {
 	var instanceTypeList []string
 	params := &ec2.DescribeInstanceTypesInput{
 		Filters: []*ec2.Filter{
@@ -1284,7 +1428,7 @@ func (e EC2Client) DescribeInstanceTypes() ([]string, error) {
 }
 
 // $ aws ec2 describe-images --filters Name=image-id,Values=ami-01288945bd24ed49a --query "Images[*].Architecture"
-func (e EC2Client) DescribeAMIArchitecture(amiID string) (string, error) {
+func (e ec2Client) DescribeAMIArchitecture(amiID string) (string, error) {
 	var amiArchitecture string
 	params := &ec2.DescribeImagesInput{
 		Filters: []*ec2.Filter{
@@ -1303,7 +1447,7 @@ func (e EC2Client) DescribeAMIArchitecture(amiID string) (string, error) {
 	return amiArchitecture, nil
 }
 
-func (e EC2Client) getKmsKeyIdByAlias(alias string) (string, error) {
+func (e ec2Client) getKmsKeyIdByAlias(alias string) (string, error) {
 
 	if len(alias) == 0 {
 		Logger.Info("Volume Encrypt default KMS Key(aws/ebs)")
@@ -27,12 +27,17 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
-type ELBClient struct {
+// ELBClient wraps classic load balancer operations
+type ELBClient interface {
+	GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]HealthcheckHost, error)
+}
+
+type elbClient struct {
 	Client *elb.ELB
 }
 
 func NewELBClient(session client.ConfigProvider, region string, creds *credentials.Credentials) ELBClient {
-	return ELBClient{
+	return elbClient{
 		Client: getELBClientFn(session, region, creds),
 	}
 }
@@ -45,7 +50,7 @@ func getELBClientFn(session client.ConfigProvider, region string, creds *credent
 }
 
 // GetHostInELB returns instances in ELB
-func (e ELBClient) GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]HealthcheckHost, error) {
+func (e elbClient) GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]HealthcheckHost, error) {
 	input := &elb.DescribeInstanceHealthInput{
 		LoadBalancerName: aws.String(elbName),
 	}
@@ -17,6 +17,8 @@ limitations under the license.
 package aws
 
 import (
+	"sort"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
@@ -28,7 +30,25 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
-type ELBV2Client struct {
+// ELBV2Client wraps application/network load balancer operations
+type ELBV2Client interface {
+	GetTargetGroupARNs(targetGroups []string) ([]*string, error)
+	GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]HealthcheckHost, error)
+	GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error)
+	CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error)
+	DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error)
+	DeleteTargetGroup(targetGroup *string) error
+	DeleteLoadBalancer(lb string) error
+	DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error)
+	GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error)
+	CreateLoadBalancer(app string, subnets []string, groupID *string) (*elbv2.LoadBalancer, error)
+	CreateNewListener(loadBalancerArn string, targetGroupArn string) error
+	DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error)
+	ModifyListener(listenerArn *string, targetGroupArn string) error
+	ModifyListenerWeights(listenerArn *string, weights map[string]int64) error
+}
+
+type elbV2Client struct {
 	Client *elbv2.ELBV2
 }
 
@@ -38,10 +58,13 @@ type HealthcheckHost struct {
 	TargetStatus   string
 	HealthStatus   string
 	Valid          bool
+
+	// Checks are the results of health checkers like http:ok,ssm:fail
+	Checks string
 }
 
 func NewELBV2Client(session client.ConfigProvider, region string, creds *credentials.Credentials) ELBV2Client {
-	return ELBV2Client{
+	return elbV2Client{
 		Client: getElbClientFn(session, region, creds),
 	}
 }
@@ -54,7 +77,7 @@ func getElbClientFn(session client.ConfigProvider, region string, creds *credent
 }
 
 // GetTargetGroupARNs returns arn list of target groups
-func (e ELBV2Client) GetTargetGroupARNs(targetGroups []string) ([]*string, error) {
+func (e elbV2Client) GetTargetGroupARNs(targetGroups []string) ([]*string, error) {
 	// Return nil if there is no target group.
 	if len(targetGroups) == 0 {
 		return nil, nil
@@ -78,7 +101,7 @@ func (e ELBV2Client) GetTargetGroupARNs(targetGroups []string) ([]*string, error
 }
 
 // GetHostInTarget gets host instance
-func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]HealthcheckHost, error) {
+func (e elbV2Client) GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]HealthcheckHost, error) {
 	input := &elbv2.DescribeTargetHealthInput{
 		TargetGroupArn: aws.String(*targetGroupArn),
 	}
@@ -117,7 +140,7 @@ func (e ELBV2Client) GetHostInTarget(group *autoscaling.Group, targetGroupArn *s
 }
 
 // GetLoadBalancerFromTG returns list of loadbalancer from target groups
-func (e ELBV2Client) GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error) {
+func (e elbV2Client) GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error) {
 	input := &elbv2.DescribeTargetGroupsInput{
 		TargetGroupArns: targetGroups,
 	}
@@ -140,7 +163,7 @@ func (e ELBV2Client) GetLoadBalancerFromTG(targetGroups []*string) ([]*string, e
 }
 
 // CreateTargetGroup creates a new target group
-func (e ELBV2Client) CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error) {
+func (e elbV2Client) CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error) {
 	input := &elbv2.CreateTargetGroupInput{
 		Name:     aws.String(tgName),
 		Port:     tg.Port,
@@ -157,7 +180,7 @@ func (e ELBV2Client) CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*e
 }
 
 // DescribeTargetGroups returns arn list of target groups with detailed information
-func (e ELBV2Client) DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error) {
+func (e elbV2Client) DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error) {
 	input := &elbv2.DescribeTargetGroupsInput{
 		Names: targetGroups,
 	}
@@ -171,7 +194,7 @@ func (e ELBV2Client) DescribeTargetGroups(targetGroups []*string) ([]*elbv2.Targ
 }
 
 // DeleteTargetGroup deletes a target group
-func (e ELBV2Client) DeleteTargetGroup(targetGroup *string) error {
+func (e elbV2Client) DeleteTargetGroup(targetGroup *string) error {
 	input := &elbv2.DeleteTargetGroupInput{
 		TargetGroupArn: targetGroup,
 	}
@@ -185,7 +208,7 @@ func (e ELBV2Client) DeleteTargetGroup(targetGroup *string) error {
 }
 
 // DeleteLoadBalancer deletes a load balancer
-func (e ELBV2Client) DeleteLoadBalancer(lb string) error {
+func (e elbV2Client) DeleteLoadBalancer(lb string) error {
 	input := &elbv2.DeleteLoadBalancerInput{
 		LoadBalancerArn: aws.String(lb),
 	}
@@ -199,7 +222,7 @@ func (e ELBV2Client) DeleteLoadBalancer(lb string) error {
 }
 
 // DescribeLoadBalancers retrieves all load balancers
-func (e ELBV2Client) DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error) {
+func (e elbV2Client) DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error) {
 	input := &elbv2.DescribeLoadBalancersInput{}
 
 	result, err := e.Client.DescribeLoadBalancers(input)
@@ -211,7 +234,7 @@ func (e ELBV2Client) DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error) {
 }
 
 // DescribeLoadBalancers retrieves matching load balancer
-func (e ELBV2Client) GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error) {
+func (e elbV2Client) GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error) {
 	input := &elbv2.DescribeLoadBalancersInput{
 		LoadBalancerArns: []*string{
 			aws.String(lb),
@@ -236,7 +259,7 @@ func (e ELBV2Client) GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, er
 }
 
 // CreateLoadBalancer retrieves all load balancers
-func (e ELBV2Client) CreateLoadBalancer(app string, subnets []string, groupID *string) (*elbv2.LoadBalancer, error) {
+func (e elbV2Client) CreateLoadBalancer(app string, subnets []string, groupID *string) (*elbv2.LoadBalancer, error) {
 	input := &elbv2.CreateLoadBalancerInput{
 		Name: aws.String(app),
 		Tags: []*elbv2.Tag{
@@ -261,7 +284,7 @@ func (e ELBV2Client) CreateLoadBalancer(app string, subnets []string, groupID *s
 }
 
 // CreateNewListener creates a new listener and attach target group to load balancer
-func (e ELBV2Client) CreateNewListener(loadBalancerArn string, targetGroupArn string) error {
+func (e elbV2Client) CreateNewListener(loadBalancerArn string, targetGroupArn string) error {
 	input := &elbv2.CreateListenerInput{
 		DefaultActions: []*elbv2.Action{
 			{
@@ -283,7 +306,7 @@ func (e ELBV2Client) CreateNewListener(loadBalancerArn string, targetGroupArn st
 }
 
 // DescribeListeners describes all listeners in the load balancer
-func (e ELBV2Client) DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error) {
+func (e elbV2Client) DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error) {
 	input := &elbv2.DescribeListenersInput{
 		LoadBalancerArn: aws.String(loadBalancerArn),
 	}
@@ -297,7 +320,7 @@ func (e ELBV2Client) DescribeListeners(loadBalancerArn string) ([]*elbv2.Listene
 }
 
 // ModifyListener modifies the existing listener and change target to newly created target group
-func (e ELBV2Client) ModifyListener(listenerArn *string, targetGroupArn string) error {
+func (e elbV2Client) ModifyListener(listenerArn *string, targetGroupArn string) error {
 	input := &elbv2.ModifyListenerInput{
 		DefaultActions: []*elbv2.Action{
 			{
@@ -315,3 +338,39 @@ func (e ELBV2Client) ModifyListener(listenerArn *string, targetGroupArn string)
 
 	return nil
 }
+
+// ModifyListenerWeights modifies the existing listener to forward to target groups with weights
+func (e elbV2Client) ModifyListenerWeights(listenerArn *string, weights map[string]int64) error {
+	var arns []string
+	for arn := range weights {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+
+	var targetGroups []*elbv2.TargetGroupTuple
+	for _, arn := range arns {
+		targetGroups = append(targetGroups, &elbv2.TargetGroupTuple{
+			TargetGroupArn: aws.String(arn),
+			Weight:         aws.Int64(weights[arn]),
+		})
+	}
+
+	input := &elbv2.ModifyListenerInput{
+		DefaultActions: []*elbv2.Action{
+			{
+				ForwardConfig: &elbv2.ForwardActionConfig{
+					TargetGroups: targetGroups,
+				},
+				Type: aws.String("forward"),
+			},
+		},
+		ListenerArn: listenerArn,
+	}
+
+	_, err := e.Client.ModifyListener(input)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
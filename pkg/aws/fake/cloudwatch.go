@@ -0,0 +1,118 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// CloudWatch is a fake cloudwatch service which records alarms and returns empty statistics
+type CloudWatch struct {
+	Cloud *Cloud
+}
+
+var _ aws.CloudWatchClient = CloudWatch{}
+
+// CreateScalingAlarms creates scaling alarms
+func (c CloudWatch) CreateScalingAlarms(asgName string, alarms []schemas.AlarmConfigs, _ map[string]string) error {
+	for _, alarm := range alarms {
+		if err := c.CreateCloudWatchAlarm(asgName, alarm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CreateCloudWatchAlarm creates cloudwatch alarm
+func (c CloudWatch) CreateCloudWatchAlarm(asgName string, alarm schemas.AlarmConfigs) error {
+	c.Cloud.mu.Lock()
+	defer c.Cloud.mu.Unlock()
+
+	if _, ok := c.Cloud.asgs[asgName]; !ok {
+		return notFound("autoscaling group", asgName)
+	}
+	c.Cloud.alarms[asgName] = append(c.Cloud.alarms[asgName], alarm.Name)
+
+	return nil
+}
+
+// GetTargetGroupRequestStatistics returns empty statistics for target groups
+func (c CloudWatch) GetTargetGroupRequestStatistics(tgs []*string, _, _ time.Time, _ *Logger.Logger) (map[string]map[string]float64, error) {
+	return emptyStatistics(tgs), nil
+}
+
+// GetLoadBalancerRequestStatistics returns empty statistics for load balancers
+func (c CloudWatch) GetLoadBalancerRequestStatistics(loadbalancers []*string, _, _ time.Time, _ *Logger.Logger) (map[string]map[string]float64, error) {
+	return emptyStatistics(loadbalancers), nil
+}
+
+// GetOneDayStatisticsOfTargetGroup returns empty statistics
+func (c CloudWatch) GetOneDayStatisticsOfTargetGroup(_ string, _, _ time.Time, _ int64, _ string) (map[string]float64, float64, error) {
+	return map[string]float64{}, 0, nil
+}
+
+// GetOneDayStatisticsOfLoadBalancer returns empty statistics
+func (c CloudWatch) GetOneDayStatisticsOfLoadBalancer(_ string, _, _ time.Time, _ int64, _ string) (map[string]float64, float64, error) {
+	return map[string]float64{}, 0, nil
+}
+
+// GetTargetGroupMetrics returns metrics of target group set by SetTargetGroupMetrics
+func (c CloudWatch) GetTargetGroupMetrics(tg, _ string, _, _ time.Time) (aws.TargetGroupMetrics, error) {
+	c.Cloud.mu.Lock()
+	defer c.Cloud.mu.Unlock()
+
+	return c.Cloud.targetGroupMetrics[tg], nil
+}
+
+// GetMetricStatistic returns statistic of metric set by SetMetricStatistic regardless of dimensions
+func (c CloudWatch) GetMetricStatistic(namespace, metric, _ string, _ map[string]string, _, _ time.Time) (*float64, error) {
+	c.Cloud.mu.Lock()
+	defer c.Cloud.mu.Unlock()
+
+	v, ok := c.Cloud.metricStatistics[fmt.Sprintf("%s/%s", namespace, metric)]
+	if !ok {
+		return nil, nil
+	}
+
+	return &v, nil
+}
+
+// GetMetricStatisticWithPeriod returns statistic of metric set by SetMetricStatistic and records the dimensions of query
+func (c CloudWatch) GetMetricStatisticWithPeriod(namespace, metric, statistic string, dimensions map[string]string, _ int64, startTime, endTime time.Time) (*float64, error) {
+	c.Cloud.mu.Lock()
+	c.Cloud.metricQueries = append(c.Cloud.metricQueries, dimensions)
+	c.Cloud.mu.Unlock()
+
+	return c.GetMetricStatistic(namespace, metric, statistic, dimensions, startTime, endTime)
+}
+
+// emptyStatistics makes zero statistics for each target
+func emptyStatistics(targets []*string) map[string]map[string]float64 {
+	ret := map[string]map[string]float64{}
+	for _, t := range targets {
+		ret[*t] = map[string]float64{"requestSum": 0}
+	}
+
+	return ret
+}
@@ -0,0 +1,278 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// DynamoDB is a fake dynamodb service which keeps deployment records in memory
+type DynamoDB struct {
+	Cloud *Cloud
+}
+
+var _ aws.DynamoDBClient = DynamoDB{}
+
+// CheckTableExists checks if table exists
+func (d DynamoDB) CheckTableExists(tableName string) (bool, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	_, ok := d.Cloud.tables[tableName]
+
+	return ok, nil
+}
+
+// CreateTable creates an empty table. Table is billed on demand unless provisioned billing mode is given.
+func (d DynamoDB) CreateTable(tableName, billingMode string) error {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	if billingMode != constants.ProvisionedBillingMode {
+		billingMode = constants.PayPerRequestBillingMode
+	}
+
+	if _, ok := d.Cloud.tables[tableName]; !ok {
+		d.Cloud.tables[tableName] = map[string]map[string]*dynamodb.AttributeValue{}
+		d.Cloud.billingModes[tableName] = billingMode
+	}
+
+	return nil
+}
+
+// PutItem replaces the item
+func (d DynamoDB) PutItem(tableName string, item map[string]*dynamodb.AttributeValue) error {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	table, ok := d.Cloud.tables[tableName]
+	if !ok {
+		return notFound("table", tableName)
+	}
+
+	copied := map[string]*dynamodb.AttributeValue{}
+	for k, v := range item {
+		copied[k] = v
+	}
+	table[*item[constants.HashKey].S] = copied
+
+	return nil
+}
+
+// UpdateItem sets fields of the item
+func (d DynamoDB) UpdateItem(tableName, key string, fields map[string]*dynamodb.AttributeValue) error {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	item, err := d.Cloud.item(tableName, key)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range fields {
+		item[k] = v
+	}
+
+	return nil
+}
+
+// QueryIndex retrieves items whose attributes are equal to the conditions ordered by key
+func (d DynamoDB) QueryIndex(tableName, indexName string, conditions map[string]string) ([]map[string]*dynamodb.AttributeValue, error) {
+	if indexName != constants.ApplicationIndex {
+		return nil, awserr.New("ValidationException", "The table does not have the specified index", nil)
+	}
+
+	items, err := d.ScanItems(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []map[string]*dynamodb.AttributeValue
+	for _, item := range items {
+		matched := true
+		for k, v := range conditions {
+			if item[k] == nil || eaws.StringValue(item[k].S) != v {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			ret = append(ret, item)
+		}
+	}
+
+	return ret, nil
+}
+
+// GetSingleItem retrieves the deployment record of autoscaling group
+func (d DynamoDB) GetSingleItem(asg, tableName string) (map[string]*dynamodb.AttributeValue, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	table, ok := d.Cloud.tables[tableName]
+	if !ok {
+		return nil, notFound("table", tableName)
+	}
+
+	ret := map[string]*dynamodb.AttributeValue{}
+	for k, v := range table[asg] {
+		ret[k] = v
+	}
+
+	return ret, nil
+}
+
+// ScanItems retrieves all items in the table ordered by key
+func (d DynamoDB) ScanItems(tableName string) ([]map[string]*dynamodb.AttributeValue, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	table, ok := d.Cloud.tables[tableName]
+	if !ok {
+		return nil, notFound("table", tableName)
+	}
+
+	var keys []string
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret []map[string]*dynamodb.AttributeValue
+	for _, k := range keys {
+		item := map[string]*dynamodb.AttributeValue{}
+		for kk, v := range table[k] {
+			item[kk] = v
+		}
+		ret = append(ret, item)
+	}
+
+	return ret, nil
+}
+
+// AcquireLease puts lease unless another holder has a lease which is not expired yet
+func (d DynamoDB) AcquireLease(tableName string, lease schemas.Lease, now int64) (bool, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	table, ok := d.Cloud.tables[tableName]
+	if !ok {
+		return false, notFound("table", tableName)
+	}
+
+	key := constants.LockKeyPrefix + lease.Key
+	if current, ok := table[key]; ok {
+		if *current["token"].S != lease.Token && itemNumber(current, "expires_at") >= now {
+			return false, nil
+		}
+	}
+
+	table[key] = map[string]*dynamodb.AttributeValue{
+		constants.HashKey: {S: eaws.String(key)},
+		"holder":          {S: eaws.String(lease.Holder)},
+		"token":           {S: eaws.String(lease.Token)},
+		"acquired_at":     {N: eaws.String(fmt.Sprintf("%d", lease.AcquiredAt))},
+		"expires_at":      {N: eaws.String(fmt.Sprintf("%d", lease.ExpiresAt))},
+	}
+
+	return true, nil
+}
+
+// RenewLease extends expiration of lease if it is still held with the token
+func (d DynamoDB) RenewLease(tableName string, lease schemas.Lease) (bool, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	current, ok := d.Cloud.tables[tableName][constants.LockKeyPrefix+lease.Key]
+	if !ok || *current["token"].S != lease.Token {
+		return false, nil
+	}
+	current["expires_at"] = &dynamodb.AttributeValue{N: eaws.String(fmt.Sprintf("%d", lease.ExpiresAt))}
+
+	return true, nil
+}
+
+// GetLease retrieves lease of key
+func (d DynamoDB) GetLease(tableName, key string) (*schemas.Lease, error) {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	current, ok := d.Cloud.tables[tableName][constants.LockKeyPrefix+key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &schemas.Lease{
+		Key:        key,
+		Holder:     *current["holder"].S,
+		Token:      *current["token"].S,
+		AcquiredAt: itemNumber(current, "acquired_at"),
+		ExpiresAt:  itemNumber(current, "expires_at"),
+	}, nil
+}
+
+// DeleteLease deletes lease of key. Lease is deleted regardless of holder if token is empty.
+func (d DynamoDB) DeleteLease(tableName, key, token string) error {
+	d.Cloud.mu.Lock()
+	defer d.Cloud.mu.Unlock()
+
+	table := d.Cloud.tables[tableName]
+	current, ok := table[constants.LockKeyPrefix+key]
+	if ok && (len(token) == 0 || *current["token"].S == token) {
+		delete(table, constants.LockKeyPrefix+key)
+	}
+
+	return nil
+}
+
+// itemNumber returns number attribute of item
+func itemNumber(item map[string]*dynamodb.AttributeValue, key string) int64 {
+	v, ok := item[key]
+	if !ok || v.N == nil {
+		return 0
+	}
+
+	n, _ := strconv.ParseInt(*v.N, 10, 64)
+	return n
+}
+
+// item returns the deployment record without lock
+func (c *Cloud) item(tableName, asg string) (map[string]*dynamodb.AttributeValue, error) {
+	table, ok := c.tables[tableName]
+	if !ok {
+		return nil, notFound("table", tableName)
+	}
+
+	item, ok := table[asg]
+	if !ok {
+		item = map[string]*dynamodb.AttributeValue{constants.HashKey: {S: eaws.String(asg)}}
+		table[asg] = item
+	}
+
+	return item, nil
+}
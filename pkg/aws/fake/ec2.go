@@ -0,0 +1,795 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// EC2 is a fake EC2 and autoscaling service
+type EC2 struct {
+	Cloud *Cloud
+}
+
+var _ aws.EC2Client = EC2{}
+
+// GetMatchingAutoscalingGroup returns only one matching autoscaling group information
+func (e EC2) GetMatchingAutoscalingGroup(name string) (*autoscaling.Group, error) {
+	asg := e.Cloud.AutoScalingGroup(name)
+	if asg == nil {
+		return nil, fmt.Errorf("no autoscaling group exists with name: %s", name)
+	}
+
+	return asg, nil
+}
+
+// GetMatchingLaunchTemplate returns information of launch template with matched ID
+func (e EC2) GetMatchingLaunchTemplate(ltID string) (*ec2.LaunchTemplateVersion, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, lt := range e.Cloud.launchTemplates {
+		if *lt.LaunchTemplateId == ltID {
+			ret := *lt
+			return &ret, nil
+		}
+	}
+
+	return nil, notFound("launch template", ltID)
+}
+
+// GetSecurityGroupDetails returns detailed information for security group
+func (e EC2) GetSecurityGroupDetails(sgIds []*string) ([]*ec2.SecurityGroup, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*ec2.SecurityGroup
+	for _, id := range sgIds {
+		sg, ok := e.Cloud.securityGroups[*id]
+		if !ok {
+			return nil, notFound("security group", *id)
+		}
+		ret = append(ret, sg)
+	}
+
+	return ret, nil
+}
+
+// DeleteLaunchConfigurations does nothing because the fake only supports launch templates
+func (e EC2) DeleteLaunchConfigurations(_ string) error {
+	return nil
+}
+
+// DeleteLaunchTemplates deletes all launch template belongs to the autoscaling group
+func (e EC2) DeleteLaunchTemplates(asgName string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for name := range e.Cloud.launchTemplates {
+		if strings.HasPrefix(name, asgName) {
+			delete(e.Cloud.launchTemplates, name)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAutoscalingSet deletes autoscaling group with its instances
+func (e EC2) DeleteAutoscalingSet(asgName string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	asg, ok := e.Cloud.asgs[asgName]
+	if !ok {
+		return notFound("autoscaling group", asgName)
+	}
+
+	asg.DesiredCapacity = eaws.Int64(0)
+	e.Cloud.reconcile(asg)
+	delete(e.Cloud.asgs, asgName)
+
+	return nil
+}
+
+// GetAllMatchingAutoscalingGroupsWithPrefix returns all autoscaling groups with prefix
+func (e EC2) GetAllMatchingAutoscalingGroupsWithPrefix(prefix string) ([]*autoscaling.Group, error) {
+	var ret []*autoscaling.Group
+	for _, name := range e.Cloud.AutoScalingGroupNames() {
+		if strings.HasPrefix(name, prefix) {
+			ret = append(ret, e.Cloud.AutoScalingGroup(name))
+		}
+	}
+
+	return ret, nil
+}
+
+// CreateNewLaunchConfiguration is not supported by the fake
+func (e EC2) CreateNewLaunchConfiguration(_, _, _, _, _, _ string, _ bool, _ []*string, _ []*autoscaling.BlockDeviceMapping) bool {
+	return false
+}
+
+// CreateNewLaunchTemplate creates new launch template
+func (e EC2) CreateNewLaunchTemplate(name, ami, instanceType, keyName, iamProfileName, userdata string, ebsOptimized, _ bool, securityGroups []*string, blockDevices []*ec2.LaunchTemplateBlockDeviceMappingRequest, _ *schemas.InstanceMarketOptions, _ bool) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.launchTemplates[name]; ok {
+		return awserr.New("InvalidLaunchTemplateName.AlreadyExistsException", fmt.Sprintf("launch template already exists: %s", name), nil)
+	}
+
+	var devices []*ec2.LaunchTemplateBlockDeviceMapping
+	for _, b := range blockDevices {
+		devices = append(devices, &ec2.LaunchTemplateBlockDeviceMapping{DeviceName: b.DeviceName})
+	}
+
+	e.Cloud.launchTemplates[name] = &ec2.LaunchTemplateVersion{
+		LaunchTemplateId:   eaws.String(e.Cloud.nextID("lt")),
+		LaunchTemplateName: eaws.String(name),
+		VersionNumber:      eaws.Int64(1),
+		LaunchTemplateData: &ec2.ResponseLaunchTemplateData{
+			ImageId:             eaws.String(ami),
+			InstanceType:        eaws.String(instanceType),
+			KeyName:             eaws.String(keyName),
+			UserData:            eaws.String(userdata),
+			EbsOptimized:        eaws.Bool(ebsOptimized),
+			SecurityGroupIds:    securityGroups,
+			BlockDeviceMappings: devices,
+			IamInstanceProfile:  &ec2.LaunchTemplateIamInstanceProfileSpecification{Name: eaws.String(iamProfileName)},
+		},
+	}
+
+	return nil
+}
+
+// GetSecurityGroupList returns security group ids, creating unknown names on the fly
+func (e EC2) GetSecurityGroupList(vpc string, sgList []string) ([]*string, error) {
+	if len(sgList) == 0 {
+		return nil, errors.New("need to specify at least one security group")
+	}
+
+	if _, err := e.GetVPCId(vpc); err != nil {
+		return nil, err
+	}
+
+	var ret []*string
+	for _, sg := range sgList {
+		if strings.HasPrefix(sg, "sg-") {
+			ret = append(ret, eaws.String(sg))
+			continue
+		}
+
+		id, err := e.GetSecurityGroup(sg)
+		if err != nil {
+			id, err = e.CreateSecurityGroup(sg, eaws.String(VpcID))
+			if err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, id)
+	}
+
+	return ret, nil
+}
+
+// MakeBlockDevices returns list of block device mapping for launch configuration
+func (e EC2) MakeBlockDevices(blocks []schemas.BlockDevice) []*autoscaling.BlockDeviceMapping {
+	var ret []*autoscaling.BlockDeviceMapping
+	for _, block := range blocks {
+		ret = append(ret, &autoscaling.BlockDeviceMapping{
+			DeviceName: eaws.String(block.DeviceName),
+			Ebs: &autoscaling.Ebs{
+				VolumeSize: eaws.Int64(block.VolumeSize),
+				VolumeType: eaws.String(block.VolumeType),
+			},
+		})
+	}
+
+	return ret
+}
+
+// MakeLaunchTemplateBlockDeviceMappings returns list of block device mappings for launch template
+func (e EC2) MakeLaunchTemplateBlockDeviceMappings(blocks []schemas.BlockDevice) []*ec2.LaunchTemplateBlockDeviceMappingRequest {
+	var ret []*ec2.LaunchTemplateBlockDeviceMappingRequest
+	for _, block := range blocks {
+		ret = append(ret, &ec2.LaunchTemplateBlockDeviceMappingRequest{
+			DeviceName: eaws.String(block.DeviceName),
+			Ebs: &ec2.LaunchTemplateEbsBlockDeviceRequest{
+				VolumeSize: eaws.Int64(block.VolumeSize),
+				VolumeType: eaws.String(block.VolumeType),
+				Encrypted:  eaws.Bool(block.Encrypted),
+			},
+		})
+	}
+
+	return ret
+}
+
+// GetVPCId returns the id of the fake vpc
+func (e EC2) GetVPCId(vpc string) (string, error) {
+	if len(vpc) == 0 {
+		return constants.EmptyString, fmt.Errorf("unable to find VPC on name lookup for %v", vpc)
+	}
+
+	return VpcID, nil
+}
+
+// CreateAutoScalingGroup creates new autoscaling group and launches instances
+func (e EC2) CreateAutoScalingGroup(name, launchTemplateName, _ string, _ int64, capacity schemas.Capacity, loadbalancers, _ []string, targetGroupArns, _ []*string, tags []*autoscaling.Tag, _ []string, _ schemas.MixedInstancesPolicy, _ []*autoscaling.LifecycleHookSpecification) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.asgs[name]; ok {
+		return awserr.New(autoscaling.ErrCodeAlreadyExistsFault, fmt.Sprintf("autoscaling group already exists: %s", name), nil)
+	}
+
+	if _, ok := e.Cloud.launchTemplates[launchTemplateName]; !ok {
+		return notFound("launch template", launchTemplateName)
+	}
+
+	for _, tg := range targetGroupArns {
+		if _, ok := e.Cloud.targetGroups[*tg]; !ok {
+			return notFound("target group", *tg)
+		}
+	}
+
+	var tagDescriptions []*autoscaling.TagDescription
+	for _, t := range tags {
+		tagDescriptions = append(tagDescriptions, &autoscaling.TagDescription{
+			Key:          t.Key,
+			Value:        t.Value,
+			ResourceId:   eaws.String(name),
+			ResourceType: eaws.String("auto-scaling-group"),
+		})
+	}
+
+	e.Cloud.createAutoScalingGroup(name, launchTemplateName, capacity, eaws.StringSlice(loadbalancers), append([]*string{}, targetGroupArns...), tagDescriptions)
+
+	return nil
+}
+
+// GetAvailabilityZones returns availability zones of the fake vpc
+func (e EC2) GetAvailabilityZones(vpc string, azs []string) ([]string, error) {
+	if _, err := e.GetVPCId(vpc); err != nil {
+		return nil, err
+	}
+
+	if len(azs) > 0 {
+		return azs, nil
+	}
+
+	return append([]string{}, e.Cloud.AvailabilityZones...), nil
+}
+
+// GetSubnets returns one subnet per availability zone
+func (e EC2) GetSubnets(vpc string, usePublicSubnets bool, azs []string) ([]string, error) {
+	if _, err := e.GetVPCId(vpc); err != nil {
+		return nil, err
+	}
+
+	subnetType := "private"
+	if usePublicSubnets {
+		subnetType = "public"
+	}
+
+	var ret []string
+	for _, az := range azs {
+		ret = append(ret, fmt.Sprintf("subnet-%s-%s", subnetType, az))
+	}
+
+	return ret, nil
+}
+
+// UpdateAutoScalingGroupSize updates autoscaling group size
+func (e EC2) UpdateAutoScalingGroupSize(asg string, min, max, desired, retry int64) (int64, error) {
+	if err := e.UpdateAutoScalingGroup(asg, schemas.Capacity{Min: min, Max: max, Desired: desired}); err != nil {
+		return retry - 1, err
+	}
+
+	return 0, nil
+}
+
+// CreateScalingPolicy creates scaling policy
+func (e EC2) CreateScalingPolicy(policy schemas.ScalePolicy, asgName string) (*string, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.asgs[asgName]; !ok {
+		return nil, notFound("autoscaling group", asgName)
+	}
+
+	e.Cloud.scalingPolicies[asgName] = append(e.Cloud.scalingPolicies[asgName], policy.Name)
+
+	return eaws.String(fmt.Sprintf("arn:aws:autoscaling:%s:%s:scalingPolicy:%s:autoScalingGroupName/%s:policyName/%s", e.Cloud.Region, AccountID, e.Cloud.nextID("policy"), asgName, policy.Name)), nil
+}
+
+// EnableMetrics does nothing on the fake
+func (e EC2) EnableMetrics(_ string) error {
+	return nil
+}
+
+// GenerateLifecycleHooks generate lifecycle hooks
+func (e EC2) GenerateLifecycleHooks(hooks schemas.LifecycleHooks) []*autoscaling.LifecycleHookSpecification {
+	var ret []*autoscaling.LifecycleHookSpecification
+	for _, l := range hooks.LaunchTransition {
+		ret = append(ret, &autoscaling.LifecycleHookSpecification{
+			LifecycleHookName:   eaws.String(l.LifecycleHookName),
+			LifecycleTransition: eaws.String("autoscaling:EC2_INSTANCE_LAUNCHING"),
+		})
+	}
+
+	for _, l := range hooks.TerminateTransition {
+		ret = append(ret, &autoscaling.LifecycleHookSpecification{
+			LifecycleHookName:   eaws.String(l.LifecycleHookName),
+			LifecycleTransition: eaws.String("autoscaling:EC2_INSTANCE_TERMINATING"),
+		})
+	}
+
+	return ret
+}
+
+// GetTargetGroups returns list of target group ARN of autoscaling group
+func (e EC2) GetTargetGroups(asgName string) ([]*string, error) {
+	asg := e.Cloud.AutoScalingGroup(asgName)
+	if asg == nil {
+		return nil, nil
+	}
+
+	return asg.TargetGroupARNs, nil
+}
+
+// UpdateAutoScalingGroup updates capacity of autoscaling group
+func (e EC2) UpdateAutoScalingGroup(asg string, capacity schemas.Capacity) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[asg]
+	if !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	if capacity.Min > capacity.Desired || capacity.Desired > capacity.Max {
+		return awserr.New("ValidationError", fmt.Sprintf("invalid capacity, min: %d, desired: %d, max: %d", capacity.Min, capacity.Desired, capacity.Max), nil)
+	}
+
+	group.MinSize = eaws.Int64(capacity.Min)
+	group.MaxSize = eaws.Int64(capacity.Max)
+	group.DesiredCapacity = eaws.Int64(capacity.Desired)
+	e.Cloud.reconcile(group)
+
+	return nil
+}
+
+// CreateScheduledActions creates scheduled actions
+func (e EC2) CreateScheduledActions(asg string, actions []schemas.ScheduledAction) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.asgs[asg]; !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	for _, a := range actions {
+		e.Cloud.scheduledActions[asg] = append(e.Cloud.scheduledActions[asg], a.Name)
+	}
+
+	return nil
+}
+
+// AttachAsgToTargetGroups attaches autoscaling group to target groups
+func (e EC2) AttachAsgToTargetGroups(asg string, targetGroups []*string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[asg]
+	if !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	for _, tg := range targetGroups {
+		if _, ok := e.Cloud.targetGroups[*tg]; !ok {
+			return notFound("target group", *tg)
+		}
+
+		if !isRegisteredToTargetGroup(group, *tg) {
+			group.TargetGroupARNs = append(group.TargetGroupARNs, eaws.String(*tg))
+		}
+	}
+
+	return nil
+}
+
+// DetachAsgFromTargetGroups detaches autoscaling group from target groups
+func (e EC2) DetachAsgFromTargetGroups(asg string, targetGroups []*string) error {
+	return e.DetachLoadBalancerTargetGroup(asg, targetGroups)
+}
+
+// CreateSecurityGroup creates new security group
+func (e EC2) CreateSecurityGroup(sgName string, vpcID *string) (*string, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, sg := range e.Cloud.securityGroups {
+		if *sg.GroupName == sgName {
+			return nil, awserr.New("InvalidGroup.Duplicate", fmt.Sprintf("the security group '%s' already exists", sgName), nil)
+		}
+	}
+
+	id := e.Cloud.nextID("sg")
+	e.Cloud.securityGroups[id] = &ec2.SecurityGroup{
+		GroupId:   eaws.String(id),
+		GroupName: eaws.String(sgName),
+		VpcId:     vpcID,
+	}
+
+	return eaws.String(id), nil
+}
+
+// GetSecurityGroup retrieves group id of existing security group
+func (e EC2) GetSecurityGroup(sgName string) (*string, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, sg := range e.Cloud.securityGroups {
+		if *sg.GroupName == sgName {
+			return sg.GroupId, nil
+		}
+	}
+
+	return nil, fmt.Errorf("checked duplicated but cannot find the security group: %s", sgName)
+}
+
+// UpdateInboundRules adds inbound rule with IP
+func (e EC2) UpdateInboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+	return e.addPermission(sgID, false, &ec2.IpPermission{
+		FromPort:   eaws.Int64(fromPort),
+		ToPort:     eaws.Int64(toPort),
+		IpProtocol: eaws.String(protocol),
+		IpRanges:   []*ec2.IpRange{{CidrIp: eaws.String(cidr), Description: eaws.String(description)}},
+	})
+}
+
+// UpdateInboundRulesWithGroup adds inbound rule with other security group
+func (e EC2) UpdateInboundRulesWithGroup(sgID, protocol, description string, fromSg *string, fromPort, toPort int64) error {
+	return e.addPermission(sgID, false, &ec2.IpPermission{
+		FromPort:         eaws.Int64(fromPort),
+		ToPort:           eaws.Int64(toPort),
+		IpProtocol:       eaws.String(protocol),
+		UserIdGroupPairs: []*ec2.UserIdGroupPair{{GroupId: fromSg, Description: eaws.String(description)}},
+	})
+}
+
+// UpdateOutboundRules adds outbound rule with IP
+func (e EC2) UpdateOutboundRules(sgID, protocol, cidr, description string, fromPort, toPort int64) error {
+	return e.addPermission(sgID, true, &ec2.IpPermission{
+		FromPort:   eaws.Int64(fromPort),
+		ToPort:     eaws.Int64(toPort),
+		IpProtocol: eaws.String(protocol),
+		IpRanges:   []*ec2.IpRange{{CidrIp: eaws.String(cidr), Description: eaws.String(description)}},
+	})
+}
+
+// addPermission appends permission to security group
+func (e EC2) addPermission(sgID string, egress bool, permission *ec2.IpPermission) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	sg, ok := e.Cloud.securityGroups[sgID]
+	if !ok {
+		return notFound("security group", sgID)
+	}
+
+	if egress {
+		sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, permission)
+	} else {
+		sg.IpPermissions = append(sg.IpPermissions, permission)
+	}
+
+	return nil
+}
+
+// DeleteSecurityGroup deletes security group
+func (e EC2) DeleteSecurityGroup(sg string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.securityGroups[sg]; !ok {
+		return notFound("security group", sg)
+	}
+	delete(e.Cloud.securityGroups, sg)
+
+	return nil
+}
+
+// RevokeInboundRulesWithGroup revokes inbound rules with other security group
+func (e EC2) RevokeInboundRulesWithGroup(sgID, protocol string, fromSg *string, fromPort, toPort int64) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	sg, ok := e.Cloud.securityGroups[sgID]
+	if !ok {
+		return notFound("security group", sgID)
+	}
+
+	var permissions []*ec2.IpPermission
+	for _, p := range sg.IpPermissions {
+		if *p.IpProtocol == protocol && *p.FromPort == fromPort && *p.ToPort == toPort && len(p.UserIdGroupPairs) > 0 && *p.UserIdGroupPairs[0].GroupId == *fromSg {
+			continue
+		}
+		permissions = append(permissions, p)
+	}
+	sg.IpPermissions = permissions
+
+	return nil
+}
+
+// DeleteCanaryTag deletes canary tag from auto scaling group
+func (e EC2) DeleteCanaryTag(asg string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[asg]
+	if !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	var tags []*autoscaling.TagDescription
+	for _, t := range group.Tags {
+		if *t.Key != constants.DeploymentTagKey {
+			tags = append(tags, t)
+		}
+	}
+	group.Tags = tags
+
+	return nil
+}
+
+// DescribeInstances return detailed information of instances
+func (e EC2) DescribeInstances(instanceIds []*string) ([]*ec2.Instance, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*ec2.Instance
+	for _, id := range instanceIds {
+		if ins, ok := e.Cloud.instances[*id]; ok {
+			ret = append(ret, ins)
+		}
+	}
+
+	return ret, nil
+}
+
+// ModifyNetworkInterfaces changes security groups of network interface
+func (e EC2) ModifyNetworkInterfaces(eni *string, groups []*string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, ins := range e.Cloud.instances {
+		for _, ni := range ins.NetworkInterfaces {
+			if *ni.NetworkInterfaceId == *eni {
+				var identifiers []*ec2.GroupIdentifier
+				for _, g := range groups {
+					identifiers = append(identifiers, &ec2.GroupIdentifier{GroupId: g})
+				}
+				ni.Groups = identifiers
+				return nil
+			}
+		}
+	}
+
+	return notFound("network interface", *eni)
+}
+
+// CreateNewLaunchTemplateVersion creates new version of launch template
+func (e EC2) CreateNewLaunchTemplateVersion(lt *ec2.LaunchTemplateVersion, sgs []*string) (*ec2.LaunchTemplateVersion, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, l := range e.Cloud.launchTemplates {
+		if *l.LaunchTemplateId == *lt.LaunchTemplateId {
+			data := *l.LaunchTemplateData
+			data.SecurityGroupIds = sgs
+			l.LaunchTemplateData = &data
+			l.VersionNumber = eaws.Int64(*l.VersionNumber + 1)
+
+			ret := *l
+			return &ret, nil
+		}
+	}
+
+	return nil, notFound("launch template", *lt.LaunchTemplateId)
+}
+
+// UpdateAutoScalingLaunchTemplate updates autoscaling launch template
+func (e EC2) UpdateAutoScalingLaunchTemplate(asg string, lt *ec2.LaunchTemplateVersion) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[asg]
+	if !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	group.LaunchTemplate = &autoscaling.LaunchTemplateSpecification{
+		LaunchTemplateId:   lt.LaunchTemplateId,
+		LaunchTemplateName: lt.LaunchTemplateName,
+		Version:            eaws.String(fmt.Sprintf("%d", *lt.VersionNumber)),
+	}
+	e.Cloud.reconcile(group)
+
+	return nil
+}
+
+// DetachLoadBalancerTargetGroup detaches target group from autoscaling group
+func (e EC2) DetachLoadBalancerTargetGroup(asg string, tgARNs []*string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[asg]
+	if !ok {
+		return notFound("autoscaling group", asg)
+	}
+
+	var remained []*string
+	for _, tg := range group.TargetGroupARNs {
+		detached := false
+		for _, t := range tgARNs {
+			if *t == *tg {
+				detached = true
+				break
+			}
+		}
+
+		if !detached {
+			remained = append(remained, tg)
+		}
+	}
+	group.TargetGroupARNs = remained
+
+	return nil
+}
+
+// TerminateInstanceInAutoScalingGroup terminates instance and launches new one to keep desired capacity
+func (e EC2) TerminateInstanceInAutoScalingGroup(instanceID string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, group := range e.Cloud.asgs {
+		for i, instance := range group.Instances {
+			if *instance.InstanceId != instanceID {
+				continue
+			}
+
+			group.Instances = append(group.Instances[:i], group.Instances[i+1:]...)
+			delete(e.Cloud.instances, instanceID)
+			delete(e.Cloud.instanceHealth, instanceID)
+			e.Cloud.reconcile(group)
+
+			return nil
+		}
+	}
+
+	return notFound("instance", instanceID)
+}
+
+// DescribeScalingActivities returns scaling activities started after since, the latest first
+func (e EC2) DescribeScalingActivities(asg string, since time.Time) ([]*autoscaling.Activity, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*autoscaling.Activity
+	activities := e.Cloud.activities[asg]
+	for i := len(activities) - 1; i >= 0; i-- {
+		if activities[i].StartTime.Before(since) {
+			break
+		}
+		ret = append(ret, activities[i])
+	}
+
+	return ret, nil
+}
+
+// CreateLaunchTemplateVersionWithInstanceType changes instance type of launch template and increases its version
+func (e EC2) CreateLaunchTemplateVersionWithInstanceType(ltName, _, instanceType string) (*ec2.LaunchTemplateVersion, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	lt, ok := e.Cloud.launchTemplates[ltName]
+	if !ok {
+		return nil, notFound("launch template", ltName)
+	}
+
+	data := *lt.LaunchTemplateData
+	data.InstanceType = eaws.String(instanceType)
+	lt.LaunchTemplateData = &data
+	lt.VersionNumber = eaws.Int64(*lt.VersionNumber + 1)
+
+	ret := *lt
+	return &ret, nil
+}
+
+// StartInstanceRefresh replaces every instance of autoscaling group at once
+func (e EC2) StartInstanceRefresh(name *string, _, _ int64) (*string, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	group, ok := e.Cloud.asgs[*name]
+	if !ok {
+		return nil, notFound("autoscaling group", *name)
+	}
+
+	desired := *group.DesiredCapacity
+	group.DesiredCapacity = eaws.Int64(0)
+	e.Cloud.reconcile(group)
+	group.DesiredCapacity = eaws.Int64(desired)
+	e.Cloud.reconcile(group)
+
+	return eaws.String(e.Cloud.nextID("refresh")), nil
+}
+
+// DescribeInstanceRefreshes returns finished instance refresh
+func (e EC2) DescribeInstanceRefreshes(name, id *string) (*autoscaling.InstanceRefresh, error) {
+	if e.Cloud.AutoScalingGroup(*name) == nil {
+		return nil, fmt.Errorf("no instance refresh exists: %s", *name)
+	}
+
+	return &autoscaling.InstanceRefresh{
+		AutoScalingGroupName: name,
+		InstanceRefreshId:    id,
+		PercentageComplete:   eaws.Int64(100),
+		Status:               eaws.String(autoscaling.InstanceRefreshStatusSuccessful),
+	}, nil
+}
+
+// GetInstanceStatusChecks returns status checks set by SetStatusCheck. Status checks are passed by default.
+func (e EC2) GetInstanceStatusChecks(instanceIds []*string) (map[string]bool, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	ret := map[string]bool{}
+	for _, id := range instanceIds {
+		if _, ok := e.Cloud.instances[*id]; !ok {
+			continue
+		}
+
+		failed := e.Cloud.failedStatusChecks[*id]
+		ret[*id] = !failed
+	}
+
+	return ret, nil
+}
+
+// DescribeInstanceTypes returns instance families supporting arm64
+func (e EC2) DescribeInstanceTypes() ([]string, error) {
+	return []string{"a1", "c6g", "m6g", "r6g", "t4g"}, nil
+}
+
+// DescribeAMIArchitecture returns x86_64 for every image
+func (e EC2) DescribeAMIArchitecture(_ string) (string, error) {
+	return "x86_64", nil
+}
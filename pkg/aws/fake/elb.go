@@ -0,0 +1,70 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// ELB is a fake classic load balancer service
+type ELB struct {
+	Cloud *Cloud
+}
+
+var _ aws.ELBClient = ELB{}
+
+// GetHealthyHostInELB returns instances of autoscaling group registered to classic load balancer
+func (e ELB) GetHealthyHostInELB(group *autoscaling.Group, elbName string) ([]aws.HealthcheckHost, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	asg, ok := e.Cloud.asgs[*group.AutoScalingGroupName]
+	if !ok {
+		return nil, notFound("autoscaling group", *group.AutoScalingGroupName)
+	}
+
+	registered := false
+	for _, lb := range asg.LoadBalancerNames {
+		if *lb == elbName {
+			registered = true
+			break
+		}
+	}
+
+	ret := []aws.HealthcheckHost{}
+	if !registered {
+		return ret, nil
+	}
+
+	for _, instance := range group.Instances {
+		state := "OutOfService"
+		if e.Cloud.instanceHealth[*instance.InstanceId] == Healthy {
+			state = constants.InServiceStatus
+		}
+
+		ret = append(ret, aws.HealthcheckHost{
+			InstanceID:     *instance.InstanceId,
+			LifecycleState: state,
+			Valid:          state == constants.InServiceStatus,
+		})
+	}
+
+	return ret, nil
+}
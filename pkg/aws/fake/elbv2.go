@@ -0,0 +1,348 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// ELBV2 is a fake application load balancer service
+type ELBV2 struct {
+	Cloud *Cloud
+}
+
+var _ aws.ELBV2Client = ELBV2{}
+
+// GetTargetGroupARNs returns arn list of target groups
+func (e ELBV2) GetTargetGroupARNs(targetGroups []string) ([]*string, error) {
+	if len(targetGroups) == 0 {
+		return nil, nil
+	}
+
+	tgs, err := e.DescribeTargetGroups(eaws.StringSlice(targetGroups))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*string
+	for _, tg := range tgs {
+		ret = append(ret, tg.TargetGroupArn)
+	}
+
+	return ret, nil
+}
+
+// GetHostInTarget returns health of instances in autoscaling group registered to target group
+func (e ELBV2) GetHostInTarget(group *autoscaling.Group, targetGroupArn *string, isUpdate, downSizingUpdate bool) ([]aws.HealthcheckHost, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.targetGroups[*targetGroupArn]; !ok {
+		return nil, notFound("target group", *targetGroupArn)
+	}
+
+	registered := false
+	if asg, ok := e.Cloud.asgs[*group.AutoScalingGroupName]; ok {
+		registered = isRegisteredToTargetGroup(asg, *targetGroupArn)
+	}
+
+	ret := []aws.HealthcheckHost{}
+	for _, instance := range group.Instances {
+		targetState := constants.InitialStatus
+		if state, ok := e.Cloud.instanceHealth[*instance.InstanceId]; ok && registered {
+			targetState = state
+		}
+
+		var valid bool
+		if isUpdate && downSizingUpdate {
+			valid = *instance.LifecycleState == constants.InServiceStatus || targetState == Healthy || *instance.HealthStatus == "Healthy"
+		} else {
+			valid = *instance.LifecycleState == constants.InServiceStatus && targetState == Healthy && *instance.HealthStatus == "Healthy"
+		}
+
+		ret = append(ret, aws.HealthcheckHost{
+			InstanceID:     *instance.InstanceId,
+			LifecycleState: *instance.LifecycleState,
+			TargetStatus:   targetState,
+			HealthStatus:   *instance.HealthStatus,
+			Valid:          valid,
+		})
+	}
+
+	return ret, nil
+}
+
+// GetLoadBalancerFromTG returns list of loadbalancer from target groups
+func (e ELBV2) GetLoadBalancerFromTG(targetGroups []*string) ([]*string, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*string
+	for _, arn := range targetGroups {
+		tg, ok := e.Cloud.targetGroups[*arn]
+		if !ok {
+			return nil, notFound("target group", *arn)
+		}
+		ret = append(ret, tg.LoadBalancerArns...)
+	}
+
+	return ret, nil
+}
+
+// CreateTargetGroup creates a new target group with the same settings
+func (e ELBV2) CreateTargetGroup(tg *elbv2.TargetGroup, tgName string) (*elbv2.TargetGroup, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if existing := e.Cloud.findTargetGroupByName(tgName); existing != nil {
+		return existing, nil
+	}
+
+	return e.Cloud.createTargetGroup(tgName, *tg.Port), nil
+}
+
+// DescribeTargetGroups returns target groups with names
+func (e ELBV2) DescribeTargetGroups(targetGroups []*string) ([]*elbv2.TargetGroup, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*elbv2.TargetGroup
+	for _, name := range targetGroups {
+		tg := e.Cloud.findTargetGroupByName(*name)
+		if tg == nil {
+			return nil, awserr.New(elbv2.ErrCodeTargetGroupNotFoundException, fmt.Sprintf("one or more target groups not found: %s", *name), nil)
+		}
+		ret = append(ret, tg)
+	}
+
+	return ret, nil
+}
+
+// DeleteTargetGroup deletes a target group
+func (e ELBV2) DeleteTargetGroup(targetGroup *string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.targetGroups[*targetGroup]; !ok {
+		return notFound("target group", *targetGroup)
+	}
+
+	for _, asg := range e.Cloud.asgs {
+		if isRegisteredToTargetGroup(asg, *targetGroup) {
+			return awserr.New(elbv2.ErrCodeResourceInUseException, fmt.Sprintf("target group is currently in use: %s", *targetGroup), nil)
+		}
+	}
+	delete(e.Cloud.targetGroups, *targetGroup)
+
+	return nil
+}
+
+// DeleteLoadBalancer deletes a load balancer with its listeners
+func (e ELBV2) DeleteLoadBalancer(lb string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.loadBalancers[lb]; !ok {
+		return notFound("load balancer", lb)
+	}
+
+	for _, tg := range e.Cloud.targetGroups {
+		var remained []*string
+		for _, arn := range tg.LoadBalancerArns {
+			if *arn != lb {
+				remained = append(remained, arn)
+			}
+		}
+		tg.LoadBalancerArns = remained
+	}
+	delete(e.Cloud.listeners, lb)
+	delete(e.Cloud.loadBalancers, lb)
+
+	return nil
+}
+
+// DescribeLoadBalancers retrieves all load balancers
+func (e ELBV2) DescribeLoadBalancers() ([]*elbv2.LoadBalancer, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	var ret []*elbv2.LoadBalancer
+	for _, lb := range e.Cloud.loadBalancers {
+		ret = append(ret, lb)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return *ret[i].LoadBalancerName < *ret[j].LoadBalancerName
+	})
+
+	return ret, nil
+}
+
+// GetMatchingLoadBalancer retrieves matching load balancer
+func (e ELBV2) GetMatchingLoadBalancer(lb string) (*elbv2.LoadBalancer, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	return e.Cloud.loadBalancers[lb], nil
+}
+
+// CreateLoadBalancer creates a new load balancer for canary
+func (e ELBV2) CreateLoadBalancer(app string, _ []string, groupID *string) (*elbv2.LoadBalancer, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, lb := range e.Cloud.loadBalancers {
+		if *lb.LoadBalancerName == app {
+			return nil, awserr.New(elbv2.ErrCodeDuplicateLoadBalancerNameException, fmt.Sprintf("a load balancer with the same name '%s' exists", app), nil)
+		}
+	}
+
+	lb := &elbv2.LoadBalancer{
+		LoadBalancerName: eaws.String(app),
+		LoadBalancerArn:  eaws.String(fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/app/%s/%s", e.Cloud.Region, AccountID, app, e.Cloud.nextID("lb"))),
+		VpcId:            eaws.String(VpcID),
+	}
+
+	if groupID != nil {
+		lb.SecurityGroups = []*string{groupID}
+	}
+	e.Cloud.loadBalancers[*lb.LoadBalancerArn] = lb
+
+	return lb, nil
+}
+
+// CreateNewListener creates a new listener and attach target group to load balancer
+func (e ELBV2) CreateNewListener(loadBalancerArn string, targetGroupArn string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.loadBalancers[loadBalancerArn]; !ok {
+		return notFound("load balancer", loadBalancerArn)
+	}
+
+	listener := &elbv2.Listener{
+		ListenerArn:     eaws.String(fmt.Sprintf("%s/%s", loadBalancerArn, e.Cloud.nextID("listener"))),
+		LoadBalancerArn: eaws.String(loadBalancerArn),
+		Port:            eaws.Int64(80),
+		Protocol:        eaws.String("HTTP"),
+	}
+	e.Cloud.listeners[loadBalancerArn] = append(e.Cloud.listeners[loadBalancerArn], listener)
+
+	return e.Cloud.forward(listener, targetGroupArn)
+}
+
+// DescribeListeners describes all listeners in the load balancer
+func (e ELBV2) DescribeListeners(loadBalancerArn string) ([]*elbv2.Listener, error) {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	if _, ok := e.Cloud.loadBalancers[loadBalancerArn]; !ok {
+		return nil, notFound("load balancer", loadBalancerArn)
+	}
+
+	return append([]*elbv2.Listener{}, e.Cloud.listeners[loadBalancerArn]...), nil
+}
+
+// ModifyListener changes target of the existing listener
+func (e ELBV2) ModifyListener(listenerArn *string, targetGroupArn string) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, listeners := range e.Cloud.listeners {
+		for _, l := range listeners {
+			if *l.ListenerArn == *listenerArn {
+				return e.Cloud.forward(l, targetGroupArn)
+			}
+		}
+	}
+
+	return notFound("listener", *listenerArn)
+}
+
+// ModifyListenerWeights changes targets of the existing listener with weights
+func (e ELBV2) ModifyListenerWeights(listenerArn *string, weights map[string]int64) error {
+	e.Cloud.mu.Lock()
+	defer e.Cloud.mu.Unlock()
+
+	for _, listeners := range e.Cloud.listeners {
+		for _, l := range listeners {
+			if *l.ListenerArn != *listenerArn {
+				continue
+			}
+
+			var arns []string
+			for arn := range weights {
+				if err := e.Cloud.forward(l, arn); err != nil {
+					return err
+				}
+				arns = append(arns, arn)
+			}
+			sort.Strings(arns)
+
+			forwardConfig := &elbv2.ForwardActionConfig{}
+			for _, arn := range arns {
+				forwardConfig.TargetGroups = append(forwardConfig.TargetGroups, &elbv2.TargetGroupTuple{
+					TargetGroupArn: eaws.String(arn),
+					Weight:         eaws.Int64(weights[arn]),
+				})
+			}
+
+			l.DefaultActions = []*elbv2.Action{
+				{
+					ForwardConfig: forwardConfig,
+					Type:          eaws.String("forward"),
+				},
+			}
+
+			return nil
+		}
+	}
+
+	return notFound("listener", *listenerArn)
+}
+
+// forward sets default action of listener to target group
+func (c *Cloud) forward(listener *elbv2.Listener, targetGroupArn string) error {
+	tg, ok := c.targetGroups[targetGroupArn]
+	if !ok {
+		return notFound("target group", targetGroupArn)
+	}
+
+	listener.DefaultActions = []*elbv2.Action{
+		{
+			TargetGroupArn: eaws.String(targetGroupArn),
+			Type:           eaws.String("forward"),
+		},
+	}
+
+	for _, lb := range tg.LoadBalancerArns {
+		if *lb == *listener.LoadBalancerArn {
+			return nil
+		}
+	}
+	tg.LoadBalancerArns = append(tg.LoadBalancerArns, listener.LoadBalancerArn)
+
+	return nil
+}
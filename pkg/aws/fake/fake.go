@@ -0,0 +1,578 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+const (
+	// AccountID is the account id used for every ARN generated by the fake
+	AccountID = "123456789012"
+
+	// VpcID is the only vpc which exists in the fake
+	VpcID = "vpc-0123456789abcdef0"
+
+	// Healthy is the target health state of healthy instance
+	Healthy = "healthy"
+
+	// Unhealthy is the target health state of unhealthy instance
+	Unhealthy = "unhealthy"
+)
+
+// Command is a command sent through SSM
+type Command struct {
+	ID       string
+	Targets  []string
+	Commands []string
+
+	status string
+}
+
+// Cloud is an in-memory AWS region which keeps track of autoscaling groups, launch templates,
+// target groups, load balancers, security groups and health of instances.
+// Every change is applied immediately, so instances are launched or terminated as soon as capacity changes.
+type Cloud struct {
+	Region string
+
+	// HealthyOnLaunch decides target health of newly launched instances
+	HealthyOnLaunch bool
+
+	// AvailabilityZones are zones of subnets in the fake vpc
+	AvailabilityZones []string
+
+	// PrivateIPAddress is the private ip of every launched instance so that probes can reach local servers
+	PrivateIPAddress string
+
+	mu               sync.Mutex
+	seq              int
+	asgs             map[string]*autoscaling.Group
+	launchTemplates  map[string]*ec2.LaunchTemplateVersion
+	instances        map[string]*ec2.Instance
+	instanceHealth   map[string]string
+	targetGroups     map[string]*elbv2.TargetGroup
+	loadBalancers    map[string]*elbv2.LoadBalancer
+	listeners        map[string][]*elbv2.Listener
+	securityGroups   map[string]*ec2.SecurityGroup
+	scalingPolicies  map[string][]string
+	alarms           map[string][]string
+	scheduledActions map[string][]string
+	commands         []Command
+	commandFailures  int
+
+	failedStatusChecks map[string]bool
+	launchFailures     map[string]string
+	activities         map[string][]*autoscaling.Activity
+	tables             map[string]map[string]map[string]*dynamodb.AttributeValue
+	billingModes       map[string]string
+	objects            map[string][]byte
+
+	targetGroupMetrics map[string]aws.TargetGroupMetrics
+	metricStatistics   map[string]float64
+	metricQueries      []map[string]string
+}
+
+// NewCloud creates an empty fake region
+func NewCloud(region string) *Cloud {
+	return &Cloud{
+		Region:             region,
+		HealthyOnLaunch:    true,
+		PrivateIPAddress:   "127.0.0.1",
+		AvailabilityZones:  []string{fmt.Sprintf("%sa", region), fmt.Sprintf("%sc", region)},
+		asgs:               map[string]*autoscaling.Group{},
+		launchTemplates:    map[string]*ec2.LaunchTemplateVersion{},
+		instances:          map[string]*ec2.Instance{},
+		instanceHealth:     map[string]string{},
+		failedStatusChecks: map[string]bool{},
+		launchFailures:     map[string]string{},
+		activities:         map[string][]*autoscaling.Activity{},
+		targetGroups:       map[string]*elbv2.TargetGroup{},
+		loadBalancers:      map[string]*elbv2.LoadBalancer{},
+		listeners:          map[string][]*elbv2.Listener{},
+		securityGroups:     map[string]*ec2.SecurityGroup{},
+		scalingPolicies:    map[string][]string{},
+		alarms:             map[string][]string{},
+		scheduledActions:   map[string][]string{},
+		tables:             map[string]map[string]map[string]*dynamodb.AttributeValue{},
+		billingModes:       map[string]string{},
+		objects:            map[string][]byte{},
+
+		targetGroupMetrics: map[string]aws.TargetGroupMetrics{},
+		metricStatistics:   map[string]float64{},
+	}
+}
+
+// Client returns aws client whose services are all backed by the fake
+func (c *Cloud) Client() aws.Client {
+	return aws.Client{
+		Region:            c.Region,
+		EC2Service:        EC2{Cloud: c},
+		ELBV2Service:      ELBV2{Cloud: c},
+		ELBService:        ELB{Cloud: c},
+		CloudWatchService: CloudWatch{Cloud: c},
+		SSMService:        SSM{Cloud: c},
+	}
+}
+
+// MetricClient returns metric client whose services are backed by the fake
+func (c *Cloud) MetricClient() aws.MetricClient {
+	return aws.MetricClient{
+		Region:            c.Region,
+		DynamoDBService:   DynamoDB{Cloud: c},
+		S3Service:         S3{Cloud: c},
+		CloudWatchService: CloudWatch{Cloud: c},
+	}
+}
+
+// AddTargetGroup creates a target group and returns its ARN
+func (c *Cloud) AddTargetGroup(name string, port int64) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return *c.createTargetGroup(name, port).TargetGroupArn
+}
+
+// AddLoadBalancer creates a load balancer whose listener forwards to the target group and returns its ARN
+func (c *Cloud) AddLoadBalancer(name, targetGroupArn string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lbArn := fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:loadbalancer/app/%s/%s", c.Region, AccountID, name, c.nextID("lb"))
+	c.loadBalancers[lbArn] = &elbv2.LoadBalancer{
+		LoadBalancerName: eaws.String(name),
+		LoadBalancerArn:  eaws.String(lbArn),
+		VpcId:            eaws.String(VpcID),
+	}
+
+	listener := &elbv2.Listener{
+		ListenerArn:     eaws.String(fmt.Sprintf("%s/%s", lbArn, c.nextID("listener"))),
+		LoadBalancerArn: eaws.String(lbArn),
+		Port:            eaws.Int64(80),
+		Protocol:        eaws.String("HTTP"),
+	}
+	c.listeners[lbArn] = append(c.listeners[lbArn], listener)
+	if err := c.forward(listener, targetGroupArn); err != nil {
+		panic(err)
+	}
+
+	return lbArn
+}
+
+// AddAutoScalingGroup creates an autoscaling group which already exists before deployment
+func (c *Cloud) AddAutoScalingGroup(name string, capacity schemas.Capacity, targetGroupArns []string, tags map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var asgTags []*autoscaling.TagDescription
+	for k, v := range tags {
+		asgTags = append(asgTags, &autoscaling.TagDescription{
+			Key:          eaws.String(k),
+			Value:        eaws.String(v),
+			ResourceId:   eaws.String(name),
+			ResourceType: eaws.String("auto-scaling-group"),
+		})
+	}
+
+	c.createAutoScalingGroup(name, fmt.Sprintf("%s-lt", name), capacity, nil, eaws.StringSlice(targetGroupArns), asgTags)
+}
+
+// AutoScalingGroup returns a copy of autoscaling group with name
+func (c *Cloud) AutoScalingGroup(name string) *autoscaling.Group {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	asg, ok := c.asgs[name]
+	if !ok {
+		return nil
+	}
+
+	return copyGroup(asg)
+}
+
+// AutoScalingGroupNames returns sorted names of all autoscaling groups
+func (c *Cloud) AutoScalingGroupNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ret []string
+	for name := range c.asgs {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// LaunchTemplateNames returns sorted names of all launch templates
+func (c *Cloud) LaunchTemplateNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ret []string
+	for name := range c.launchTemplates {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// TargetGroupArn returns ARN of target group with name
+func (c *Cloud) TargetGroupArn(name string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tg := c.findTargetGroupByName(name)
+	if tg == nil {
+		return constants.EmptyString
+	}
+
+	return *tg.TargetGroupArn
+}
+
+// LoadBalancerNames returns sorted names of all application load balancers
+func (c *Cloud) LoadBalancerNames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ret []string
+	for _, lb := range c.loadBalancers {
+		ret = append(ret, *lb.LoadBalancerName)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// ListenerWeights returns weights of target groups which the first listener of load balancer forwards to
+func (c *Cloud) ListenerWeights(lbArn string) map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ret := map[string]int64{}
+	listeners := c.listeners[lbArn]
+	if len(listeners) == 0 || len(listeners[0].DefaultActions) == 0 {
+		return ret
+	}
+
+	action := listeners[0].DefaultActions[0]
+	if action.ForwardConfig == nil {
+		ret[*action.TargetGroupArn] = 1
+		return ret
+	}
+
+	for _, tg := range action.ForwardConfig.TargetGroups {
+		ret[*tg.TargetGroupArn] = *tg.Weight
+	}
+
+	return ret
+}
+
+// SetTargetGroupMetrics changes metrics of target group returned by cloudwatch
+func (c *Cloud) SetTargetGroupMetrics(tgArn string, metrics aws.TargetGroupMetrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.targetGroupMetrics[tgArn] = metrics
+}
+
+// SetMetricStatistic changes statistic of metric returned by cloudwatch
+func (c *Cloud) SetMetricStatistic(namespace, metric string, value float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.metricStatistics[fmt.Sprintf("%s/%s", namespace, metric)] = value
+}
+
+// MetricQueries returns dimensions of metric statistics queried with period
+func (c *Cloud) MetricQueries() []map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]map[string]string{}, c.metricQueries...)
+}
+
+// SetInstanceHealth changes target health of instance
+func (c *Cloud) SetInstanceHealth(instanceID, state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.instanceHealth[instanceID] = state
+}
+
+// ScalingPolicies returns names of scaling policies of autoscaling group
+func (c *Cloud) ScalingPolicies(asg string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.scalingPolicies[asg]...)
+}
+
+// Alarms returns names of cloudwatch alarms of autoscaling group
+func (c *Cloud) Alarms(asg string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.alarms[asg]...)
+}
+
+// ScheduledActions returns names of scheduled actions of autoscaling group
+func (c *Cloud) ScheduledActions(asg string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.scheduledActions[asg]...)
+}
+
+// Record returns a copy of item in the metric table
+func (c *Cloud) Record(table, asg string) map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.tables[table][asg]
+	if !ok {
+		return nil
+	}
+
+	ret := map[string]string{}
+	for k, v := range item {
+		ret[k] = eaws.StringValue(v.S)
+		if v.N != nil {
+			ret[k] = *v.N
+		}
+	}
+
+	return ret
+}
+
+// BillingMode returns billing mode of the metric table
+func (c *Cloud) BillingMode(table string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.billingModes[table]
+}
+
+// Commands returns all commands sent through SSM
+func (c *Cloud) Commands() []Command {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Command{}, c.commands...)
+}
+
+// SetStatusCheck sets whether status checks of instance are passed
+func (c *Cloud) SetStatusCheck(instanceID string, passed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failedStatusChecks[instanceID] = !passed
+}
+
+// SetLaunchFailure makes autoscaling groups fail to launch instances of the type with status message.
+// Empty message lets instances be launched again.
+func (c *Cloud) SetLaunchFailure(instanceType, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(message) == 0 {
+		delete(c.launchFailures, instanceType)
+		return
+	}
+	c.launchFailures[instanceType] = message
+}
+
+// SetCommandFailures makes the next commands fail in every target instance
+func (c *Cloud) SetCommandFailures(times int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.commandFailures = times
+}
+
+// nextID returns unique id with prefix
+func (c *Cloud) nextID(prefix string) string {
+	c.seq++
+	return fmt.Sprintf("%s-%017x", prefix, c.seq)
+}
+
+// createTargetGroup creates target group without lock
+func (c *Cloud) createTargetGroup(name string, port int64) *elbv2.TargetGroup {
+	tg := &elbv2.TargetGroup{
+		TargetGroupName: eaws.String(name),
+		TargetGroupArn:  eaws.String(fmt.Sprintf("arn:aws:elasticloadbalancing:%s:%s:targetgroup/%s/%s", c.Region, AccountID, name, c.nextID("tg"))),
+		Port:            eaws.Int64(port),
+		Protocol:        eaws.String("HTTP"),
+		VpcId:           eaws.String(VpcID),
+	}
+	c.targetGroups[*tg.TargetGroupArn] = tg
+
+	return tg
+}
+
+// findTargetGroupByName returns target group with name
+func (c *Cloud) findTargetGroupByName(name string) *elbv2.TargetGroup {
+	for _, tg := range c.targetGroups {
+		if *tg.TargetGroupName == name {
+			return tg
+		}
+	}
+
+	return nil
+}
+
+// createAutoScalingGroup creates autoscaling group and launches instances without lock
+func (c *Cloud) createAutoScalingGroup(name, launchTemplateName string, capacity schemas.Capacity, loadBalancers, targetGroupArns []*string, tags []*autoscaling.TagDescription) {
+	c.seq++
+	asg := &autoscaling.Group{
+		AutoScalingGroupName: eaws.String(name),
+		AutoScalingGroupARN:  eaws.String(fmt.Sprintf("arn:aws:autoscaling:%s:%s:autoScalingGroup:%s", c.Region, AccountID, name)),
+		MinSize:              eaws.Int64(capacity.Min),
+		MaxSize:              eaws.Int64(capacity.Max),
+		DesiredCapacity:      eaws.Int64(capacity.Desired),
+		CreatedTime:          eaws.Time(time.Now().Add(time.Duration(c.seq) * time.Second)),
+		LaunchTemplate: &autoscaling.LaunchTemplateSpecification{
+			LaunchTemplateName: eaws.String(launchTemplateName),
+		},
+		AvailabilityZones: eaws.StringSlice(c.AvailabilityZones),
+		LoadBalancerNames: loadBalancers,
+		TargetGroupARNs:   targetGroupArns,
+		Tags:              tags,
+	}
+
+	if lt, ok := c.launchTemplates[launchTemplateName]; ok {
+		asg.LaunchTemplate.LaunchTemplateId = lt.LaunchTemplateId
+	}
+
+	c.asgs[name] = asg
+	c.reconcile(asg)
+}
+
+// reconcile launches or terminates instances until the count meets desired capacity
+func (c *Cloud) reconcile(asg *autoscaling.Group) {
+	for int64(len(asg.Instances)) < *asg.DesiredCapacity {
+		if message, ok := c.launchFailure(asg); ok {
+			c.addActivity(*asg.AutoScalingGroupName, autoscaling.ScalingActivityStatusCodeFailed, message)
+			break
+		}
+
+		id := c.nextID("i")
+		c.addActivity(*asg.AutoScalingGroupName, autoscaling.ScalingActivityStatusCodeSuccessful, fmt.Sprintf("Launching a new EC2 instance: %s", id))
+		az := c.AvailabilityZones[len(asg.Instances)%len(c.AvailabilityZones)]
+		asg.Instances = append(asg.Instances, &autoscaling.Instance{
+			InstanceId:       eaws.String(id),
+			AvailabilityZone: eaws.String(az),
+			LifecycleState:   eaws.String(constants.InServiceStatus),
+			HealthStatus:     eaws.String("Healthy"),
+		})
+
+		var groups []*ec2.GroupIdentifier
+		if lt, ok := c.launchTemplates[*asg.LaunchTemplate.LaunchTemplateName]; ok {
+			for _, sg := range lt.LaunchTemplateData.SecurityGroupIds {
+				groups = append(groups, &ec2.GroupIdentifier{GroupId: sg})
+			}
+		}
+
+		c.instances[id] = &ec2.Instance{
+			InstanceId:       eaws.String(id),
+			PrivateIpAddress: eaws.String(c.PrivateIPAddress),
+			Placement:        &ec2.Placement{AvailabilityZone: eaws.String(az)},
+			NetworkInterfaces: []*ec2.InstanceNetworkInterface{
+				{
+					NetworkInterfaceId: eaws.String(c.nextID("eni")),
+					Groups:             groups,
+				},
+			},
+		}
+
+		state := Healthy
+		if !c.HealthyOnLaunch {
+			state = Unhealthy
+		}
+		c.instanceHealth[id] = state
+	}
+
+	for int64(len(asg.Instances)) > *asg.DesiredCapacity {
+		last := asg.Instances[len(asg.Instances)-1]
+		delete(c.instances, *last.InstanceId)
+		delete(c.instanceHealth, *last.InstanceId)
+		asg.Instances = asg.Instances[:len(asg.Instances)-1]
+	}
+}
+
+// launchFailure returns status message if instance type of launch template cannot be launched
+func (c *Cloud) launchFailure(asg *autoscaling.Group) (string, bool) {
+	if asg.LaunchTemplate == nil || asg.LaunchTemplate.LaunchTemplateName == nil {
+		return "", false
+	}
+
+	lt, ok := c.launchTemplates[*asg.LaunchTemplate.LaunchTemplateName]
+	if !ok || lt.LaunchTemplateData.InstanceType == nil {
+		return "", false
+	}
+
+	message, ok := c.launchFailures[*lt.LaunchTemplateData.InstanceType]
+	return message, ok
+}
+
+// addActivity records scaling activity of autoscaling group
+func (c *Cloud) addActivity(asg, status, message string) {
+	c.activities[asg] = append(c.activities[asg], &autoscaling.Activity{
+		ActivityId:           eaws.String(c.nextID("activity")),
+		AutoScalingGroupName: eaws.String(asg),
+		StartTime:            eaws.Time(time.Now()),
+		StatusCode:           eaws.String(status),
+		StatusMessage:        eaws.String(message),
+		Description:          eaws.String(message),
+	})
+}
+
+// copyGroup returns deep copy of autoscaling group so that callers cannot change the state
+func copyGroup(asg *autoscaling.Group) *autoscaling.Group {
+	return awsutil.CopyOf(asg).(*autoscaling.Group)
+}
+
+// isRegisteredToTargetGroup checks if autoscaling group is attached to target group
+func isRegisteredToTargetGroup(asg *autoscaling.Group, tgArn string) bool {
+	for _, tg := range asg.TargetGroupARNs {
+		if *tg == tgArn {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notFound returns error for missing resource
+func notFound(kind, name string) error {
+	return fmt.Errorf("%s not found: %s", strings.ToLower(kind), name)
+}
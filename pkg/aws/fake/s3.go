@@ -0,0 +1,84 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+)
+
+// S3 is a fake s3 service which keeps objects in memory
+type S3 struct {
+	Cloud *Cloud
+}
+
+var _ aws.S3Client = S3{}
+
+// GetManifest retrieves manifest object
+func (s S3) GetManifest(bucket, key string) ([]byte, error) {
+	return s.GetObject(bucket, key)
+}
+
+// GetObject retrieves body of the object
+func (s S3) GetObject(bucket, key string) ([]byte, error) {
+	s.Cloud.mu.Lock()
+	defer s.Cloud.mu.Unlock()
+
+	body, ok := s.Cloud.objects[objectPath(bucket, key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("the specified key does not exist: %s", key), nil)
+	}
+
+	return append([]byte{}, body...), nil
+}
+
+// PutObject stores the object
+func (s S3) PutObject(bucket, key string, body []byte) error {
+	s.Cloud.mu.Lock()
+	defer s.Cloud.mu.Unlock()
+
+	s.Cloud.objects[objectPath(bucket, key)] = append([]byte{}, body...)
+
+	return nil
+}
+
+// ListObjectKeys retrieves keys of objects with the prefix in order
+func (s S3) ListObjectKeys(bucket, prefix string) ([]string, error) {
+	s.Cloud.mu.Lock()
+	defer s.Cloud.mu.Unlock()
+
+	var ret []string
+	for path := range s.Cloud.objects {
+		if key := strings.TrimPrefix(path, fmt.Sprintf("%s/", bucket)); key != path && strings.HasPrefix(key, prefix) {
+			ret = append(ret, key)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret, nil
+}
+
+// objectPath returns unique path of object in buckets
+func objectPath(bucket, key string) string {
+	return fmt.Sprintf("%s/%s", bucket, key)
+}
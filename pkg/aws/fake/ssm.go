@@ -0,0 +1,83 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package fake
+
+import (
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+)
+
+// SSM is a fake systems manager service which records commands.
+// Commands are finished as soon as they are sent, and fail as many times as SetCommandFailures.
+type SSM struct {
+	Cloud *Cloud
+}
+
+var _ aws.SSMClient = SSM{}
+
+// SendCommand records command sent to instances
+func (s SSM) SendCommand(target []*string, commands []*string, _ time.Duration) (string, error) {
+	s.Cloud.mu.Lock()
+	defer s.Cloud.mu.Unlock()
+
+	for _, t := range target {
+		if _, ok := s.Cloud.instances[*t]; !ok {
+			return "", notFound("instance", *t)
+		}
+	}
+
+	status := ssm.CommandInvocationStatusSuccess
+	if s.Cloud.commandFailures > 0 {
+		s.Cloud.commandFailures--
+		status = ssm.CommandInvocationStatusFailed
+	}
+
+	id := s.Cloud.nextID("cmd")
+	s.Cloud.commands = append(s.Cloud.commands, Command{
+		ID:       id,
+		Targets:  eaws.StringValueSlice(target),
+		Commands: eaws.StringValueSlice(commands),
+		status:   status,
+	})
+
+	return id, nil
+}
+
+// GetCommandInvocation returns result of command in the instance
+func (s SSM) GetCommandInvocation(commandID, instanceID string) (aws.CommandInvocation, error) {
+	s.Cloud.mu.Lock()
+	defer s.Cloud.mu.Unlock()
+
+	for _, c := range s.Cloud.commands {
+		if c.ID != commandID {
+			continue
+		}
+
+		invocation := aws.CommandInvocation{InstanceID: instanceID, Status: c.status}
+		if c.status != ssm.CommandInvocationStatusSuccess {
+			invocation.ResponseCode = 1
+			invocation.Stderr = "command failed"
+		}
+		return invocation, nil
+	}
+
+	return aws.CommandInvocation{}, notFound("command", commandID)
+}
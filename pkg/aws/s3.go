@@ -17,6 +17,7 @@ limitations under the license.
 package aws
 
 import (
+	"bytes"
 	"io/ioutil"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -25,12 +26,20 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-type S3Client struct {
+// S3Client wraps s3 operations
+type S3Client interface {
+	GetManifest(bucket, key string) ([]byte, error)
+	GetObject(bucket, key string) ([]byte, error)
+	PutObject(bucket, key string, body []byte) error
+	ListObjectKeys(bucket, prefix string) ([]string, error)
+}
+
+type s3Client struct {
 	Client *s3.S3
 }
 
 func NewS3Client(session client.ConfigProvider, region string, creds *credentials.Credentials) S3Client {
-	return S3Client{
+	return s3Client{
 		Client: getS3ClientFn(session, region, creds),
 	}
 }
@@ -42,7 +51,12 @@ func getS3ClientFn(session client.ConfigProvider, region string, creds *credenti
 	return s3.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
 }
 
-func (s S3Client) GetManifest(bucket, key string) ([]byte, error) {
+func (s s3Client) GetManifest(bucket, key string) ([]byte, error) {
+	return s.GetObject(bucket, key)
+}
+
+// GetObject retrieves body of the object
+func (s s3Client) GetObject(bucket, key string) ([]byte, error) {
 	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -60,3 +74,37 @@ func (s S3Client) GetManifest(bucket, key string) ([]byte, error) {
 
 	return body, nil
 }
+
+// PutObject uploads the object
+func (s s3Client) PutObject(bucket, key string, body []byte) error {
+	input := &s3.PutObjectInput{
+		Body:        bytes.NewReader(body),
+		Bucket:      aws.String(bucket),
+		ContentType: aws.String("application/x-ndjson"),
+		Key:         aws.String(key),
+	}
+
+	_, err := s.Client.PutObject(input)
+	return err
+}
+
+// ListObjectKeys retrieves keys of all objects with the prefix
+func (s s3Client) ListObjectKeys(bucket, prefix string) ([]string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var ret []string
+	err := s.Client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			ret = append(ret, aws.StringValue(obj.Key))
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
@@ -17,18 +17,52 @@ limitations under the license.
 package aws
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
-type SSMClient struct {
+// SSMClient wraps systems manager operations
+type SSMClient interface {
+	SendCommand(target []*string, commands []*string, timeout time.Duration) (string, error)
+	GetCommandInvocation(commandID, instanceID string) (CommandInvocation, error)
+}
+
+// CommandInvocation is the result of command in an instance
+type CommandInvocation struct {
+	InstanceID   string
+	Status       string
+	ResponseCode int64
+	Stdout       string
+	Stderr       string
+}
+
+// Done checks if command is finished in the instance
+func (c CommandInvocation) Done() bool {
+	switch c.Status {
+	case ssm.CommandInvocationStatusSuccess, ssm.CommandInvocationStatusCancelled, ssm.CommandInvocationStatusTimedOut, ssm.CommandInvocationStatusFailed:
+		return true
+	}
+
+	return false
+}
+
+// Succeeded checks if command is finished successfully in the instance
+func (c CommandInvocation) Succeeded() bool {
+	return c.Status == ssm.CommandInvocationStatusSuccess
+}
+
+type ssmClient struct {
 	Client *ssm.SSM
 }
 
 func NewSSMClient(session client.ConfigProvider, region string, creds *credentials.Credentials) SSMClient {
-	return SSMClient{
+	return ssmClient{
 		Client: getSsmClientFn(session, region, creds),
 	}
 }
@@ -40,21 +74,48 @@ func getSsmClientFn(session client.ConfigProvider, region string, creds *credent
 	return ssm.New(session, &aws.Config{Region: aws.String(region), Credentials: creds})
 }
 
-// SSM Send command
-func (s SSMClient) SendCommand(target []*string, commands []*string) bool {
+// SendCommand runs shell commands in instances and returns command ID
+func (s ssmClient) SendCommand(target []*string, commands []*string, timeout time.Duration) (string, error) {
 	input := &ssm.SendCommandInput{
 		DocumentName:   aws.String("AWS-RunShellScript"),
-		TimeoutSeconds: aws.Int64(3600),
+		TimeoutSeconds: aws.Int64(int64(timeout.Seconds())),
 		InstanceIds:    target,
 		Comment:        aws.String("goployer lifecycle callbacks"),
 		Parameters: map[string][]*string{
-			"commands": commands,
+			"commands":         commands,
+			"executionTimeout": {aws.String(fmt.Sprintf("%.0f", timeout.Seconds()))},
 		},
 	}
 
-	if _, err := s.Client.SendCommand(input); err != nil {
-		return false
+	result, err := s.Client.SendCommand(input)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.Command.CommandId, nil
+}
+
+// GetCommandInvocation returns result of command in the instance.
+// Invocation which is not registered yet is regarded as pending.
+func (s ssmClient) GetCommandInvocation(commandID, instanceID string) (CommandInvocation, error) {
+	input := &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandID),
+		InstanceId: aws.String(instanceID),
+	}
+
+	result, err := s.Client.GetCommandInvocation(input)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ssm.ErrCodeInvocationDoesNotExist {
+			return CommandInvocation{InstanceID: instanceID, Status: ssm.CommandInvocationStatusPending}, nil
+		}
+		return CommandInvocation{}, err
 	}
 
-	return true
+	return CommandInvocation{
+		InstanceID:   instanceID,
+		Status:       aws.StringValue(result.Status),
+		ResponseCode: aws.Int64Value(result.ResponseCode),
+		Stdout:       aws.StringValue(result.StandardOutputContent),
+		Stderr:       aws.StringValue(result.StandardErrorContent),
+	}, nil
 }
@@ -36,6 +36,7 @@ import (
 	"gopkg.in/ini.v1"
 	"gopkg.in/yaml.v2"
 
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 	"github.com/DevopsArtFactory/goployer/pkg/templates"
@@ -68,7 +69,13 @@ type LocalProvider struct {
 }
 
 type S3Provider struct {
-	Path string
+	Path      string
+	Region    string
+	S3Service aws.S3Client
+}
+
+type StaticProvider struct {
+	Userdata string
 }
 
 // Provide provides userdata from local file
@@ -89,9 +96,30 @@ func (l LocalProvider) Provide() (string, error) {
 }
 
 // Provide provides userdata from s3
-// Need to develop
 func (s S3Provider) Provide() (string, error) {
-	return constants.EmptyString, nil
+	if !strings.HasPrefix(s.Path, constants.S3Prefix) {
+		return constants.EmptyString, fmt.Errorf("userdata path should start with %s: %s", constants.S3Prefix, s.Path)
+	}
+
+	if s.S3Service == nil {
+		region := s.Region
+		if len(region) == 0 {
+			region = constants.DefaultRegion
+		}
+		s.S3Service = aws.BootstrapManifestService(region, "").S3Service
+	}
+
+	userdata, err := s.S3Service.GetManifest(tool.ParseS3Path(s.Path))
+	if err != nil {
+		return constants.EmptyString, fmt.Errorf("error reading userdata from %s: %v", s.Path, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(userdata), nil
+}
+
+// Provide provides userdata which is already encoded
+func (s StaticProvider) Provide() (string, error) {
+	return s.Userdata, nil
 }
 
 // NewBuilder create new builder
@@ -145,7 +173,23 @@ func (b Builder) SetStacks(stacks []schemas.Stack) Builder {
 		}
 	}
 
+	// userdata in s3 is read from the same region as manifest unless specified
+	if len(b.AwsConfig.Userdata.Region) == 0 {
+		b.AwsConfig.Userdata.Region = b.Config.ManifestS3Region
+	}
+
 	for i, stack := range stacks {
+		// stack without its own role assumes the role of its account
+		if account, ok := b.AwsConfig.Accounts[stack.Account]; ok && len(stack.AssumeRole) == 0 {
+			stacks[i].AssumeRole = account.AssumeRole
+			if len(stack.ExternalID) == 0 {
+				stacks[i].ExternalID = account.ExternalID
+			}
+			if len(stack.SessionName) == 0 {
+				stacks[i].SessionName = account.SessionName
+			}
+		}
+
 		if b.Config.PollingInterval > 0 {
 			stacks[i].PollingInterval = b.Config.PollingInterval
 		}
@@ -209,11 +253,32 @@ func (b Builder) CheckValidation() error {
 		return fmt.Errorf("ami id cannot be used in different regions : %s", targetAmi)
 	}
 
+	// check output format
+	if len(b.Config.Output) > 0 && !tool.IsStringInArray(b.Config.Output, constants.AllowedOutputFormats) {
+		return fmt.Errorf("output format is not allowed: %s", b.Config.Output)
+	}
+
+	// check deployment lock
+	if len(b.Config.LockBackend) > 0 && !tool.IsStringInArray(b.Config.LockBackend, constants.AllowedLockBackends) {
+		return fmt.Errorf("lock backend is not allowed: %s", b.Config.LockBackend)
+	}
+
+	if b.Config.LockTTL != 0 && b.Config.LockTTL < constants.MinLockTTL {
+		return fmt.Errorf("lock ttl cannot be smaller than %.0f sec", constants.MinLockTTL.Seconds())
+	}
+
 	// check release notes
 	if len(b.Config.ReleaseNotes) > 0 && len(b.Config.ReleaseNotesBase64) > 0 {
 		return errors.New("you cannot specify the release-notes and release-notes-base64 at the same time")
 	}
 
+	// check commit timestamp
+	if len(b.Config.CommitTimestamp) > 0 {
+		if _, err := tool.ParseTimestamp(b.Config.CommitTimestamp); err != nil {
+			return fmt.Errorf("commit timestamp should be unix seconds or RFC3339 time: %s", b.Config.CommitTimestamp)
+		}
+	}
+
 	// check polling interval
 	if b.Config.PollingInterval < constants.MinPollingInterval {
 		return fmt.Errorf("polling interval cannot be smaller than %.0f sec", constants.MinPollingInterval.Seconds())
@@ -229,10 +294,25 @@ func (b Builder) CheckValidation() error {
 			return errors.New("you do not specify the region for metrics")
 		}
 
-		if len(b.MetricConfig.Storage.Name) == 0 {
+		storageType := b.MetricConfig.Storage.Type
+		if len(storageType) > 0 && !tool.IsStringInArray(storageType, constants.AllowedStorageTypes) {
+			return fmt.Errorf("storage type should be one of %v: %s", constants.AllowedStorageTypes, storageType)
+		}
+
+		// local storage uses the default database file if name is not specified
+		if len(b.MetricConfig.Storage.Name) == 0 && storageType != constants.LocalStorage {
 			return errors.New("you do not specify the name of storage for metrics")
 		}
 
+		billingMode := b.MetricConfig.Storage.BillingMode
+		if len(billingMode) > 0 && !tool.IsStringInArray(billingMode, constants.AllowedBillingModes) {
+			return fmt.Errorf("billing mode should be one of %v: %s", constants.AllowedBillingModes, billingMode)
+		}
+
+		if err := checkCustomMetrics(b.MetricConfig.Metrics.Custom); err != nil {
+			return err
+		}
+
 		if !tool.CheckFileExists(constants.MetricYamlPath) {
 			return fmt.Errorf("no %s file exists", constants.MetricYamlPath)
 		}
@@ -254,6 +334,18 @@ func (b Builder) CheckValidation() error {
 		stackMap[stack.Env]++
 	}
 
+	// check notifications
+	if err := checkNotifications(b.AwsConfig.Notifications, b.Stacks); err != nil {
+		return err
+	}
+
+	// check accounts
+	for alias, account := range b.AwsConfig.Accounts {
+		if len(account.AssumeRole) == 0 {
+			return fmt.Errorf("assume_role is required for account: %s", alias)
+		}
+	}
+
 	// check validations in API test templates
 	if b.APITestTemplates != nil && len(b.APITestTemplates) > 0 {
 		for _, att := range b.APITestTemplates {
@@ -291,6 +383,17 @@ func (b Builder) CheckValidation() error {
 			return fmt.Errorf("you cannot use prohibited tags : %s", strings.Join(constants.ProhibitedTags, ","))
 		}
 
+		// Check account
+		if len(b.AwsConfig.Accounts) > 0 && len(stack.AssumeRole) == 0 {
+			if _, ok := b.AwsConfig.Accounts[stack.Account]; !ok {
+				return fmt.Errorf("account is not defined in accounts: %s", stack.Account)
+			}
+		}
+
+		if len(stack.AssumeRole) == 0 && (len(stack.ExternalID) > 0 || len(stack.SessionName) > 0) {
+			return fmt.Errorf("external_id and session_name cannot be used without assume_role: %s", stack.Stack)
+		}
+
 		// Check AMI
 		// Check Autoscaling and Alarm setting
 		if len(stack.Autoscaling) != 0 && len(stack.Alarms) != 0 {
@@ -407,6 +510,105 @@ func (b Builder) CheckValidation() error {
 			}
 		}
 
+		if lc := stack.LifecycleCallbacks; lc != nil {
+			if len(lc.FailurePolicy) > 0 && !tool.IsStringInArray(lc.FailurePolicy, constants.AllowedLifecycleCallbackPolicies) {
+				return fmt.Errorf("failure_policy of lifecycle_callbacks should be one of %v: %s", constants.AllowedLifecycleCallbackPolicies, lc.FailurePolicy)
+			}
+
+			if lc.Timeout < 0 || lc.Retries < 0 {
+				return fmt.Errorf("timeout and retries of lifecycle_callbacks cannot be negative")
+			}
+
+			if err := checkLifecycleHooks(lc); err != nil {
+				return err
+			}
+		}
+
+		if stack.HealthCheck != nil {
+			if err := checkHealthCheckers(stack.HealthCheck.Checkers); err != nil {
+				return fmt.Errorf("health_check of %s: %s", stack.Stack, err.Error())
+			}
+		}
+
+		if stack.HealthPolicy != nil {
+			if err := checkHealthPolicy(stack.HealthPolicy); err != nil {
+				return fmt.Errorf("health_policy of %s: %s", stack.Stack, err.Error())
+			}
+		}
+
+		if sf := stack.ScalingFailure; sf != nil {
+			if len(sf.FailurePolicy) > 0 && !tool.IsStringInArray(sf.FailurePolicy, constants.AllowedScalingFailurePolicies) {
+				return fmt.Errorf("failure_policy of scaling_failure should be one of %v: %s", constants.AllowedScalingFailurePolicies, sf.FailurePolicy)
+			}
+
+			for _, instanceType := range sf.FallbackInstanceTypes {
+				if len(instanceType) == 0 {
+					return fmt.Errorf("fallback_instance_types of scaling_failure cannot have empty instance type")
+				}
+			}
+		}
+
+		if stack.ProgressiveCanary != nil {
+			if stack.ReplacementType != constants.CanaryDeployment {
+				return fmt.Errorf("progressive_canary can only be used with canary replacement type")
+			}
+
+			if len(stack.ProgressiveCanary.Steps) == 0 {
+				return fmt.Errorf("you have to specify at least one step of progressive_canary")
+			}
+
+			prevWeight := int64(0)
+			for _, step := range stack.ProgressiveCanary.Steps {
+				if step.Weight <= prevWeight || step.Weight > 100 {
+					return fmt.Errorf("weight of progressive_canary steps should increase in 0<x<=100: %d", step.Weight)
+				}
+				prevWeight = step.Weight
+			}
+
+			if prevWeight != 100 {
+				return fmt.Errorf("weight of the last progressive_canary step should be 100")
+			}
+
+			analysis := stack.ProgressiveCanary.Analysis
+			if analysis.Max5xxRate < 0 || analysis.MaxLatency < 0 || analysis.MinRequestCount < 0 {
+				return fmt.Errorf("thresholds of progressive_canary analysis cannot be negative")
+			}
+
+			for _, region := range stack.Regions {
+				if len(region.HealthcheckTargetGroup) == 0 {
+					return fmt.Errorf("progressive_canary needs healthcheck_target_group: %s", region.Region)
+				}
+			}
+		}
+
+		if stack.Verification != nil {
+			if stack.Verification.Window <= 0 {
+				return fmt.Errorf("window of verification should be longer than 0")
+			}
+
+			if len(stack.Verification.Metrics) == 0 {
+				return fmt.Errorf("you have to specify at least one metric of verification")
+			}
+
+			for _, m := range stack.Verification.Metrics {
+				if len(m.Name) == 0 || len(m.Namespace) == 0 || len(m.Metric) == 0 {
+					return fmt.Errorf("name, namespace and metric are required for verification metric")
+				}
+
+				if !tool.IsStringInArray(m.Statistic, constants.AllowedStatistics) {
+					return fmt.Errorf("statistic of verification metric should be one of %s: %s", constants.AllowedStatistics, m.Name)
+				}
+
+				if !tool.IsStringInArray(m.Comparison, constants.AllowedComparisons) {
+					return fmt.Errorf("comparison of verification metric should be one of %s: %s", constants.AllowedComparisons, m.Name)
+				}
+
+				if len(m.TreatMissingData) > 0 && !tool.IsStringInArray(m.TreatMissingData, constants.AllowedTreatMissingData) {
+					return fmt.Errorf("treat_missing_data of verification metric should be one of %s: %s", constants.AllowedTreatMissingData, m.Name)
+				}
+			}
+		}
+
 		for _, region := range stack.Regions {
 			// Check ami id
 			if len(targetAmi) == 0 && len(region.AmiID) == 0 {
@@ -443,6 +645,10 @@ func (b Builder) CheckValidation() error {
 				return errors.New("script file does not exists")
 			}
 
+			if stack.Userdata.Type == "s3" && len(stack.Userdata.Path) > 0 && !strings.HasPrefix(stack.Userdata.Path, constants.S3Prefix) {
+				return fmt.Errorf("userdata path should start with %s for s3 type: %s", constants.S3Prefix, stack.Userdata.Path)
+			}
+
 			// Check scheduled actions
 			if len(region.ScheduledActions) > 0 {
 				for _, sa := range region.ScheduledActions {
@@ -514,24 +720,12 @@ func (b Builder) PrintSummary(out io.Writer, targetStack, targetRegion string) e
 	table.Render()
 
 	// Print Stack Information
-	var ts []schemas.Stack
-	for _, stack := range b.Stacks {
-		if stack.Stack == targetStack {
-			ts = append(ts, stack)
-			break
-		}
-	}
-
-	if len(ts) == 0 {
-		ts = b.Stacks
-	}
-
 	var deploymentData = struct {
 		Stacks        []schemas.Stack
 		Region        string
 		ConfigSummary string
 	}{
-		Stacks:        ts,
+		Stacks:        b.summaryStacks(targetStack),
 		Region:        targetRegion,
 		ConfigSummary: configStr.String(),
 	}
@@ -552,6 +746,50 @@ func (b Builder) PrintSummary(out io.Writer, targetStack, targetRegion string) e
 	return nil
 }
 
+// Summary returns configurations of deployment as a document
+func (b Builder) Summary(targetStack, targetRegion string) schemas.DeploymentSummary {
+	summary := schemas.DeploymentSummary{
+		Application: b.AwsConfig.Name,
+		Region:      targetRegion,
+		Config:      map[string]string{},
+		Stacks:      []schemas.StackSummary{},
+	}
+
+	for _, kv := range ExtractAppliedConfig(b.Config) {
+		summary.Config[kv[0]] = kv[1]
+	}
+
+	for _, stack := range b.summaryStacks(targetStack) {
+		ss := schemas.StackSummary{
+			Stack:           stack.Stack,
+			Account:         stack.Account,
+			Env:             stack.Env,
+			ReplacementType: stack.ReplacementType,
+			Regions:         []string{},
+			Capacity:        stack.Capacity,
+		}
+		for _, region := range stack.Regions {
+			if len(targetRegion) == 0 || targetRegion == region.Region {
+				ss.Regions = append(ss.Regions, region.Region)
+			}
+		}
+		summary.Stacks = append(summary.Stacks, ss)
+	}
+
+	return summary
+}
+
+// summaryStacks returns the target stack or all stacks if the target does not exist
+func (b Builder) summaryStacks(targetStack string) []schemas.Stack {
+	for _, stack := range b.Stacks {
+		if stack.Stack == targetStack {
+			return []schemas.Stack{stack}
+		}
+	}
+
+	return b.Stacks
+}
+
 // Parsing Manifest File
 func ParsingManifestFile(manifest string) (schemas.AWSConfig, []schemas.Stack, []*schemas.APITestTemplate) {
 	var yamlFile []byte
@@ -579,6 +817,8 @@ func buildStructFromYaml(yamlFile []byte) (schemas.AWSConfig, []schemas.Stack, [
 		Userdata:         yamlConfig.Userdata,
 		Tags:             yamlConfig.Tags,
 		ScheduledActions: yamlConfig.ScheduledActions,
+		Accounts:         yamlConfig.Accounts,
+		Notifications:    yamlConfig.Notifications,
 	}
 
 	Stacks := yamlConfig.Stacks
@@ -619,8 +859,8 @@ func argumentParsing() (schemas.Config, error) {
 	return RefineConfig(config)
 }
 
-// Set Userdata provider
-func SetUserdataProvider(userdata schemas.Userdata, defaultUserdata schemas.Userdata) UserdataProvider {
+// MergeUserdata fills empty userdata configuration of the stack with the default
+func MergeUserdata(userdata schemas.Userdata, defaultUserdata schemas.Userdata) schemas.Userdata {
 	//Set default if no userdata exists in the stack
 	if userdata.Type == "" {
 		userdata.Type = defaultUserdata.Type
@@ -628,10 +868,22 @@ func SetUserdataProvider(userdata schemas.Userdata, defaultUserdata schemas.User
 
 	if userdata.Path == "" {
 		userdata.Path = defaultUserdata.Path
+		userdata.Template = userdata.Template || defaultUserdata.Template
+	}
+
+	if userdata.Region == "" {
+		userdata.Region = defaultUserdata.Region
 	}
 
+	return userdata
+}
+
+// Set Userdata provider
+func SetUserdataProvider(userdata schemas.Userdata, defaultUserdata schemas.Userdata) UserdataProvider {
+	userdata = MergeUserdata(userdata, defaultUserdata)
+
 	if userdata.Type == "s3" {
-		return S3Provider{Path: userdata.Path}
+		return S3Provider{Path: userdata.Path, Region: userdata.Region}
 	}
 
 	return LocalProvider{
@@ -65,6 +65,18 @@ func TestCheckValidationConfig(t *testing.T) {
 	}
 	b.Config.Region = "ap-northeast-2"
 
+	b.Config.LockBackend = "redis"
+	if err := b.CheckValidation(); err == nil || err.Error() != "lock backend is not allowed: redis" {
+		t.Errorf("validation failed: lock backend")
+	}
+	b.Config.LockBackend = constants.FileLockBackend
+
+	b.Config.LockTTL = constants.MinLockTTL - 1
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("lock ttl cannot be smaller than %.0f sec", constants.MinLockTTL.Seconds()) {
+		t.Errorf("validation failed: lock ttl")
+	}
+	b.Config.LockTTL = 0
+
 	b.Config.ReleaseNotesBase64 = "test-base64"
 	b.Config.ReleaseNotes = constants.TestString
 	if err := b.CheckValidation(); err == nil || err.Error() != "you cannot specify the release-notes and release-notes-base64 at the same time" {
@@ -72,6 +84,12 @@ func TestCheckValidationConfig(t *testing.T) {
 	}
 
 	b.Config.ReleaseNotesBase64 = ""
+
+	b.Config.CommitTimestamp = "yesterday"
+	if err := b.CheckValidation(); err == nil || err.Error() != "commit timestamp should be unix seconds or RFC3339 time: yesterday" {
+		t.Errorf("validation failed: commit timestamp")
+	}
+	b.Config.CommitTimestamp = ""
 	b.Config.PollingInterval = constants.MinPollingInterval - 1
 	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("polling interval cannot be smaller than %.0f sec", constants.MinPollingInterval.Seconds()) {
 		t.Errorf("validation failed: min polling interval")
@@ -96,6 +114,37 @@ func TestCheckValidationConfig(t *testing.T) {
 	}
 	b.MetricConfig.Storage.Name = "goployer-test"
 
+	b.MetricConfig.Storage.Type = "mysql"
+	if err := b.CheckValidation(); err == nil || err.Error() != "storage type should be one of [dynamodb local s3]: mysql" {
+		t.Errorf("validation failed: storage type")
+	}
+	b.MetricConfig.Storage.Type = constants.DynamoDBStorage
+
+	b.MetricConfig.Storage.BillingMode = "ON_DEMAND"
+	if err := b.CheckValidation(); err == nil || err.Error() != "billing mode should be one of [PAY_PER_REQUEST PROVISIONED]: ON_DEMAND" {
+		t.Errorf("validation failed: billing mode")
+	}
+	b.MetricConfig.Storage.BillingMode = ""
+
+	customMetrics := []struct {
+		metric schemas.CustomMetric
+		err    string
+	}{
+		{metric: schemas.CustomMetric{Name: "cpu", Namespace: "AWS/EC2"}, err: "name, namespace and metric are required for custom metric"},
+		{metric: schemas.CustomMetric{Name: "uptime_hour", Namespace: "AWS/EC2", Metric: "CPUUtilization", Statistic: "Average"}, err: "name of custom metric is duplicated: uptime_hour"},
+		{metric: schemas.CustomMetric{Name: "cpu", Namespace: "AWS/EC2", Metric: "CPUUtilization", Statistic: "Median"}, err: "statistic of custom metric should be one of [Average Sum Minimum Maximum SampleCount] or percentile like p99: cpu"},
+		{metric: schemas.CustomMetric{Name: "cpu", Namespace: "AWS/EC2", Metric: "CPUUtilization", Statistic: "p99", Period: 90}, err: "period of custom metric should be a multiple of 60 seconds: cpu"},
+		{metric: schemas.CustomMetric{Name: "cpu", Namespace: "AWS/EC2", Metric: "CPUUtilization", Statistic: "Average", Dimensions: map[string]string{"InstanceId": "{{ .Instance }}"}}, err: "dimensions of custom metric are not valid: cpu"},
+	}
+
+	for _, cm := range customMetrics {
+		b.MetricConfig.Metrics.Custom = []schemas.CustomMetric{cm.metric}
+		if err := b.CheckValidation(); err == nil || !strings.HasPrefix(err.Error(), cm.err) {
+			t.Errorf("validation failed: custom metric %s: %v", cm.err, err)
+		}
+	}
+	b.MetricConfig.Metrics.Custom = nil
+
 	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("no %s file exists", constants.MetricYamlPath) {
 		t.Errorf("validation failed: metric file")
 	}
@@ -420,6 +469,64 @@ func TestCheckValidationStack(t *testing.T) {
 	}
 	b.Stacks[0].TerminationDelayRate = 0
 
+	b.Stacks[0].ProgressiveCanary = &schemas.ProgressiveCanary{
+		Steps: []schemas.CanaryStep{{Weight: 10}, {Weight: 100}},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "progressive_canary can only be used with canary replacement type" {
+		t.Errorf("validation failed: progressive canary replacement type")
+	}
+
+	b.Stacks[0].ReplacementType = constants.CanaryDeployment
+	b.Stacks[0].ProgressiveCanary.Steps = []schemas.CanaryStep{{Weight: 50}, {Weight: 10}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "weight of progressive_canary steps should increase in 0<x<=100: 10" {
+		t.Errorf("validation failed: progressive canary decreasing weight")
+	}
+
+	b.Stacks[0].ProgressiveCanary.Steps = []schemas.CanaryStep{{Weight: 10}, {Weight: 50}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "weight of the last progressive_canary step should be 100" {
+		t.Errorf("validation failed: progressive canary last weight")
+	}
+
+	b.Stacks[0].ProgressiveCanary.Steps = []schemas.CanaryStep{{Weight: 10}, {Weight: 100}}
+	b.Stacks[0].ProgressiveCanary.Analysis.Max5xxRate = -1
+	if err := b.CheckValidation(); err == nil || err.Error() != "thresholds of progressive_canary analysis cannot be negative" {
+		t.Errorf("validation failed: progressive canary negative threshold")
+	}
+	b.Stacks[0].ProgressiveCanary = nil
+	b.Stacks[0].ReplacementType = constants.BlueGreenDeployment
+
+	b.Stacks[0].Verification = &schemas.Verification{}
+	if err := b.CheckValidation(); err == nil || err.Error() != "window of verification should be longer than 0" {
+		t.Errorf("validation failed: verification window")
+	}
+
+	b.Stacks[0].Verification.Window = time.Minute
+	b.Stacks[0].Verification.Metrics = []schemas.VerificationMetric{
+		{
+			Name:       "5xx",
+			Namespace:  constants.ApplicationELBNamespace,
+			Metric:     "HTTPCode_Target_5XX_Count",
+			Statistic:  "Total",
+			Comparison: "GreaterThanThreshold",
+		},
+	}
+	if err := b.CheckValidation(); err == nil || err.Error() != "statistic of verification metric should be one of [Average Sum Minimum Maximum SampleCount]: 5xx" {
+		t.Errorf("validation failed: verification statistic")
+	}
+
+	b.Stacks[0].Verification.Metrics[0].Statistic = "Sum"
+	b.Stacks[0].Verification.Metrics[0].Comparison = "Greater"
+	if err := b.CheckValidation(); err == nil || err.Error() != "comparison of verification metric should be one of [GreaterThanThreshold GreaterThanOrEqualToThreshold LessThanThreshold LessThanOrEqualToThreshold]: 5xx" {
+		t.Errorf("validation failed: verification comparison")
+	}
+
+	b.Stacks[0].Verification.Metrics[0].Comparison = "GreaterThanThreshold"
+	b.Stacks[0].Verification.Metrics[0].TreatMissingData = "ignore"
+	if err := b.CheckValidation(); err == nil || err.Error() != "treat_missing_data of verification metric should be one of [breaching notBreaching]: 5xx" {
+		t.Errorf("validation failed: verification treat_missing_data")
+	}
+	b.Stacks[0].Verification = nil
+
 	b.Stacks[0].Regions = []schemas.RegionConfig{
 		{
 			Region: "ap-northeast-2",
@@ -496,11 +603,76 @@ func TestCheckValidationStack(t *testing.T) {
 	}
 	b.Stacks[0].APITestTemplate = "api-test"
 
+	b.AwsConfig.Accounts = map[string]schemas.Account{"prod": {AssumeRole: "arn:aws:iam::123456789012:role/deployer"}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "account is not defined in accounts: dev" {
+		t.Errorf("validation failed: undefined account")
+	}
+
+	b.AwsConfig.Accounts["dev"] = schemas.Account{}
+	if err := b.CheckValidation(); err == nil || err.Error() != "assume_role is required for account: dev" {
+		t.Errorf("validation failed: account without assume role")
+	}
+	b.AwsConfig.Accounts = nil
+
+	b.Stacks[0].ExternalID = "external-id"
+	if err := b.CheckValidation(); err == nil || err.Error() != "external_id and session_name cannot be used without assume_role: artd" {
+		t.Errorf("validation failed: external id without assume role")
+	}
+	b.Stacks[0].ExternalID = ""
+
+	b.Stacks[0].ScalingFailure = &schemas.ScalingFailure{FailurePolicy: "retry"}
+	if err := b.CheckValidation(); err == nil || err.Error() != fmt.Sprintf("failure_policy of scaling_failure should be one of %v: retry", constants.AllowedScalingFailurePolicies) {
+		t.Errorf("validation failed: scaling failure policy")
+	}
+
+	b.Stacks[0].ScalingFailure = &schemas.ScalingFailure{FallbackInstanceTypes: []string{"m5.large", ""}}
+	if err := b.CheckValidation(); err == nil || err.Error() != "fallback_instance_types of scaling_failure cannot have empty instance type" {
+		t.Errorf("validation failed: empty fallback instance type")
+	}
+	b.Stacks[0].ScalingFailure = &schemas.ScalingFailure{FailurePolicy: constants.ScalingFailureContinue, FallbackInstanceTypes: []string{"m5.large"}}
+
 	if err := b.CheckValidation(); err != nil {
 		t.Errorf("validation failed: no error")
 	}
 }
 
+func TestSetStacksWithAccounts(t *testing.T) {
+	b := Builder{
+		AwsConfig: schemas.AWSConfig{
+			Accounts: map[string]schemas.Account{
+				"dev":  {AssumeRole: "arn:aws:iam::111111111111:role/deployer"},
+				"prod": {AssumeRole: "arn:aws:iam::222222222222:role/deployer", ExternalID: "goployer", SessionName: "goployer-prod"},
+			},
+		},
+	}
+
+	stacks := []schemas.Stack{
+		{Stack: "artd", Account: "dev"},
+		{Stack: "artp", Account: "prod", SessionName: "artp"},
+		{Stack: "arts", Account: "stage", AssumeRole: "arn:aws:iam::333333333333:role/deployer"},
+		{Stack: "artl", Account: "prod", AssumeRole: "arn:aws:iam::444444444444:role/deployer"},
+	}
+
+	expected := []schemas.Stack{
+		{Stack: "artd", Account: "dev", AssumeRole: "arn:aws:iam::111111111111:role/deployer"},
+		{Stack: "artp", Account: "prod", AssumeRole: "arn:aws:iam::222222222222:role/deployer", ExternalID: "goployer", SessionName: "artp"},
+		{Stack: "arts", Account: "stage", AssumeRole: "arn:aws:iam::333333333333:role/deployer"},
+		{Stack: "artl", Account: "prod", AssumeRole: "arn:aws:iam::444444444444:role/deployer"},
+	}
+
+	if diff := deep.Equal(b.SetStacks(stacks).Stacks, expected); diff != nil {
+		t.Error(diff)
+	}
+
+	// assume role from command overrides all stacks
+	b.Config.AssumeRole = "arn:aws:iam::555555555555:role/deployer"
+	for _, stack := range b.SetStacks([]schemas.Stack{{Stack: "artd", Account: "dev"}}).Stacks {
+		if stack.AssumeRole != b.Config.AssumeRole {
+			t.Errorf("expected %s, got %s", b.Config.AssumeRole, stack.AssumeRole)
+		}
+	}
+}
+
 func TestRefineConfig(t *testing.T) {
 	type TestData struct {
 		input  schemas.Config
@@ -0,0 +1,84 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// checkHealthCheckers checks required fields by type of health checker
+func checkHealthCheckers(checkers []schemas.HealthChecker) error {
+	names := map[string]bool{}
+	for _, checker := range checkers {
+		name := checker.Name
+		if len(name) == 0 {
+			name = checker.Type
+		}
+
+		if names[name] {
+			return fmt.Errorf("name of checker is duplicated: %s", name)
+		}
+		names[name] = true
+
+		switch checker.Type {
+		case constants.HTTPHealthChecker, constants.TCPHealthChecker:
+			if checker.Port <= 0 || checker.Port > 65535 {
+				return fmt.Errorf("checker %s: valid port is required for %s checker", name, checker.Type)
+			}
+
+			if len(checker.BodyMatch) > 0 {
+				if _, err := regexp.Compile(checker.BodyMatch); err != nil {
+					return fmt.Errorf("checker %s: body_match is not a valid regular expression: %s", name, err.Error())
+				}
+			}
+		case constants.SSMHealthChecker:
+			if len(checker.Commands) == 0 {
+				return fmt.Errorf("checker %s: commands are required for ssm checker", name)
+			}
+		case constants.EC2StatusHealthChecker:
+		default:
+			return fmt.Errorf("type should be one of %v: %s", constants.AllowedHealthCheckers, checker.Type)
+		}
+
+		if checker.Timeout < 0 {
+			return fmt.Errorf("checker %s: timeout cannot be negative", name)
+		}
+	}
+
+	return nil
+}
+
+// checkHealthPolicy checks thresholds of health policy
+func checkHealthPolicy(policy *schemas.HealthPolicy) error {
+	if policy.HealthyPercentage > 0 && policy.HealthyCount > 0 {
+		return fmt.Errorf("you cannot use healthy_percentage and healthy_count together")
+	}
+
+	if policy.HealthyPercentage < 0 || policy.HealthyPercentage > 100 {
+		return fmt.Errorf("healthy_percentage should be between 0 and 100: %d", policy.HealthyPercentage)
+	}
+
+	if policy.HealthyCount < 0 || policy.MinHealthyPerAZ < 0 || policy.ConsecutiveSuccesses < 0 || policy.ReplaceUnhealthy < 0 || policy.ReplaceUnhealthyAfter < 0 {
+		return fmt.Errorf("values of health_policy cannot be negative")
+	}
+
+	return nil
+}
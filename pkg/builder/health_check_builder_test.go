@@ -0,0 +1,101 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestCheckHealthCheckers(t *testing.T) {
+	testData := []struct {
+		name     string
+		checkers []schemas.HealthChecker
+		err      bool
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			checkers: []schemas.HealthChecker{
+				{Type: constants.HTTPHealthChecker, Port: 8080, Path: "/health", BodyMatch: `"status":\s*"ok"`},
+				{Type: constants.TCPHealthChecker, Port: 5432},
+				{Type: constants.SSMHealthChecker, Commands: []string{"systemctl is-active worker"}},
+				{Type: constants.EC2StatusHealthChecker},
+			},
+		},
+		{
+			name:     "unknown type",
+			checkers: []schemas.HealthChecker{{Type: "grpc", Port: 50051}},
+			err:      true,
+		},
+		{
+			name:     "http without port",
+			checkers: []schemas.HealthChecker{{Type: constants.HTTPHealthChecker}},
+			err:      true,
+		},
+		{
+			name:     "ssm without commands",
+			checkers: []schemas.HealthChecker{{Type: constants.SSMHealthChecker}},
+			err:      true,
+		},
+		{
+			name:     "invalid body match",
+			checkers: []schemas.HealthChecker{{Type: constants.HTTPHealthChecker, Port: 80, BodyMatch: "(ok"}},
+			err:      true,
+		},
+		{
+			name:     "duplicated name",
+			checkers: []schemas.HealthChecker{{Type: constants.TCPHealthChecker, Port: 80}, {Type: constants.TCPHealthChecker, Port: 443}},
+			err:      true,
+		},
+		{
+			name:     "negative timeout",
+			checkers: []schemas.HealthChecker{{Type: constants.EC2StatusHealthChecker, Timeout: -1}},
+			err:      true,
+		},
+	}
+
+	for _, td := range testData {
+		if err := checkHealthCheckers(td.checkers); (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+	}
+}
+
+func TestCheckHealthPolicy(t *testing.T) {
+	testData := []struct {
+		name   string
+		policy schemas.HealthPolicy
+		err    bool
+	}{
+		{name: "empty"},
+		{name: "valid", policy: schemas.HealthPolicy{HealthyPercentage: 90, MinHealthyPerAZ: 1, ConsecutiveSuccesses: 3, ReplaceUnhealthy: 2, ReplaceUnhealthyAfter: time.Minute}},
+		{name: "count", policy: schemas.HealthPolicy{HealthyCount: 38}},
+		{name: "percentage and count", policy: schemas.HealthPolicy{HealthyPercentage: 90, HealthyCount: 38}, err: true},
+		{name: "percentage over 100", policy: schemas.HealthPolicy{HealthyPercentage: 120}, err: true},
+		{name: "negative replacements", policy: schemas.HealthPolicy{ReplaceUnhealthy: -1}, err: true},
+	}
+
+	for _, td := range testData {
+		if err := checkHealthPolicy(&td.policy); (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+	}
+}
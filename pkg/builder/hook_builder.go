@@ -0,0 +1,89 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"fmt"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// checkLifecycleHooks validates hooks of every phase
+func checkLifecycleHooks(lc *schemas.LifecycleCallbacks) error {
+	phases := map[string][]schemas.LifecycleHook{
+		constants.PreDeployHook:   lc.PreDeploy,
+		constants.PostDeployHook:  lc.PostDeploy,
+		constants.PostHealthyHook: lc.PostHealthy,
+		constants.PreCleanupHook:  lc.PreCleanup,
+		constants.PostCleanupHook: lc.PostCleanup,
+		constants.OnFailureHook:   lc.OnFailure,
+	}
+
+	for phase, hooks := range phases {
+		for _, hook := range hooks {
+			if len(hook.Name) == 0 {
+				return fmt.Errorf("you have to set name of %s hook", phase)
+			}
+
+			if err := checkLifecycleHook(phase, hook); err != nil {
+				return fmt.Errorf("%s hook %s: %s", phase, hook.Name, err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkLifecycleHook checks required fields by type of hook
+func checkLifecycleHook(phase string, hook schemas.LifecycleHook) error {
+	switch hook.Type {
+	case constants.SSMHook:
+		if len(hook.Commands) == 0 {
+			return fmt.Errorf("commands are required for ssm hook")
+		}
+
+		if len(hook.Targets) > 0 && !tool.IsStringInArray(hook.Targets, constants.AllowedHookTargets) {
+			return fmt.Errorf("targets should be one of %v: %s", constants.AllowedHookTargets, hook.Targets)
+		}
+
+		if phase == constants.PreDeployHook && hook.Targets == constants.NewInstances {
+			return fmt.Errorf("new instances do not exist before deployment")
+		}
+	case constants.LocalHook:
+		if len(hook.Commands) == 0 {
+			return fmt.Errorf("commands are required for local hook")
+		}
+	case constants.HTTPHook:
+		if len(hook.URL) == 0 {
+			return fmt.Errorf("url is required for http hook")
+		}
+	default:
+		return fmt.Errorf("type should be one of %v: %s", constants.AllowedHookTypes, hook.Type)
+	}
+
+	if len(hook.FailurePolicy) > 0 && !tool.IsStringInArray(hook.FailurePolicy, constants.AllowedLifecycleCallbackPolicies) {
+		return fmt.Errorf("failure_policy should be one of %v: %s", constants.AllowedLifecycleCallbackPolicies, hook.FailurePolicy)
+	}
+
+	if hook.Timeout < 0 || hook.Retries < 0 {
+		return fmt.Errorf("timeout and retries cannot be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestCheckLifecycleHooks(t *testing.T) {
+	testData := []struct {
+		name string
+		lc   schemas.LifecycleCallbacks
+		err  bool
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			lc: schemas.LifecycleCallbacks{
+				PreDeploy:   []schemas.LifecycleHook{{Name: "migrate", Type: constants.LocalHook, Commands: []string{"make migrate"}}},
+				PostHealthy: []schemas.LifecycleHook{{Name: "warmup", Type: constants.SSMHook, Commands: []string{"curl localhost/warmup"}}},
+				PostCleanup: []schemas.LifecycleHook{{Name: "purge", Type: constants.HTTPHook, URL: "https://cdn.example.com/purge", FailurePolicy: constants.LifecycleCallbackRetry}},
+			},
+		},
+		{
+			name: "no name",
+			lc:   schemas.LifecycleCallbacks{OnFailure: []schemas.LifecycleHook{{Type: constants.LocalHook, Commands: []string{"echo"}}}},
+			err:  true,
+		},
+		{
+			name: "unknown type",
+			lc:   schemas.LifecycleCallbacks{PostDeploy: []schemas.LifecycleHook{{Name: "hook", Type: "lambda"}}},
+			err:  true,
+		},
+		{
+			name: "http without url",
+			lc:   schemas.LifecycleCallbacks{PostDeploy: []schemas.LifecycleHook{{Name: "hook", Type: constants.HTTPHook}}},
+			err:  true,
+		},
+		{
+			name: "new instances before deployment",
+			lc:   schemas.LifecycleCallbacks{PreDeploy: []schemas.LifecycleHook{{Name: "hook", Type: constants.SSMHook, Commands: []string{"echo"}, Targets: constants.NewInstances}}},
+			err:  true,
+		},
+		{
+			name: "unknown policy",
+			lc:   schemas.LifecycleCallbacks{PreCleanup: []schemas.LifecycleHook{{Name: "hook", Type: constants.LocalHook, Commands: []string{"echo"}, FailurePolicy: "ignore"}}},
+			err:  true,
+		},
+	}
+
+	for _, td := range testData {
+		if err := checkLifecycleHooks(&td.lc); (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+	}
+}
@@ -17,11 +17,14 @@ limitations under the license.
 package builder
 
 import (
+	"fmt"
 	"io/ioutil"
 
 	Logger "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
@@ -54,3 +57,32 @@ func ParseMetricConfig(disabledMetrics bool, filename string) (schemas.MetricCon
 
 	return metricConfig, nil
 }
+
+// checkCustomMetrics validates user-defined metrics
+func checkCustomMetrics(metrics []schemas.CustomMetric) error {
+	names := map[string]bool{}
+	for _, m := range metrics {
+		if len(m.Name) == 0 || len(m.Namespace) == 0 || len(m.Metric) == 0 {
+			return fmt.Errorf("name, namespace and metric are required for custom metric")
+		}
+
+		if tool.IsStringInArray(m.Name, constants.ReservedMetricNames) || names[m.Name] {
+			return fmt.Errorf("name of custom metric is duplicated: %s", m.Name)
+		}
+		names[m.Name] = true
+
+		if !tool.IsStringInArray(m.Statistic, constants.AllowedStatistics) && !tool.IsPercentileStatistic(m.Statistic) {
+			return fmt.Errorf("statistic of custom metric should be one of %s or percentile like p99: %s", constants.AllowedStatistics, m.Name)
+		}
+
+		if m.Period < 0 || m.Period%60 != 0 {
+			return fmt.Errorf("period of custom metric should be a multiple of 60 seconds: %s", m.Name)
+		}
+
+		if _, err := collector.RenderMetricDimensions(m.Dimensions, collector.MetricVariables{}); err != nil {
+			return fmt.Errorf("dimensions of custom metric are not valid: %s: %s", m.Name, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// checkNotifications validates notification channels and routes of manifest and stacks
+func checkNotifications(notifications *schemas.Notifications, stacks []schemas.Stack) error {
+	channels := map[string]bool{constants.EnvSlackChannelName: true}
+	var routes []schemas.NotificationRoute
+
+	if notifications != nil {
+		names := map[string]bool{}
+		for _, c := range notifications.Channels {
+			if len(c.Name) == 0 {
+				return errors.New("you have to set name of notification channel")
+			}
+
+			if names[c.Name] {
+				return fmt.Errorf("name of notification channel is duplicated: %s", c.Name)
+			}
+			names[c.Name] = true
+			channels[c.Name] = true
+
+			if err := checkNotificationChannel(c); err != nil {
+				return err
+			}
+		}
+		routes = notifications.Routes
+	}
+
+	if err := checkNotificationRoutes(routes, channels); err != nil {
+		return err
+	}
+
+	for _, stack := range stacks {
+		if stack.Notifications == nil {
+			continue
+		}
+
+		if err := checkNotificationRoutes(stack.Notifications.Routes, channels); err != nil {
+			return fmt.Errorf("%s: %s", stack.Stack, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// checkNotificationChannel checks required fields by type of channel
+func checkNotificationChannel(c schemas.NotificationChannel) error {
+	switch c.Type {
+	case constants.SlackNotification:
+		if len(c.WebhookURL) == 0 && (len(c.Token) == 0 || len(c.Channel) == 0) {
+			return fmt.Errorf("webhook_url or token and channel are required for slack channel: %s", c.Name)
+		}
+	case constants.TeamsNotification, constants.WebhookNotification:
+		if len(c.WebhookURL) == 0 {
+			return fmt.Errorf("webhook_url is required for %s channel: %s", c.Type, c.Name)
+		}
+	case constants.EmailNotification:
+		if c.SMTP == nil || len(c.SMTP.Host) == 0 || len(c.SMTP.From) == 0 || len(c.SMTP.To) == 0 {
+			return fmt.Errorf("smtp host, from and to are required for email channel: %s", c.Name)
+		}
+	default:
+		return fmt.Errorf("type of notification channel should be one of %v: %s", constants.AllowedNotificationTypes, c.Name)
+	}
+
+	return nil
+}
+
+// checkNotificationRoutes checks events and channels of routes
+func checkNotificationRoutes(routes []schemas.NotificationRoute, channels map[string]bool) error {
+	for _, route := range routes {
+		if len(route.Channels) == 0 {
+			return errors.New("you have to set channels of notification route")
+		}
+
+		for _, event := range route.Events {
+			if !tool.IsStringInArray(event, constants.AllowedNotificationEvents) {
+				return fmt.Errorf("event of notification route should be one of %v: %s", constants.AllowedNotificationEvents, event)
+			}
+		}
+
+		for _, name := range route.Channels {
+			if !channels[name] {
+				return fmt.Errorf("notification channel does not exist: %s", name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestCheckNotifications(t *testing.T) {
+	teams := schemas.NotificationChannel{Name: "teams", Type: constants.TeamsNotification, WebhookURL: "https://example.com/teams"}
+	email := schemas.NotificationChannel{
+		Name: "mail",
+		Type: constants.EmailNotification,
+		SMTP: &schemas.SMTPConfig{Host: "smtp.example.com", From: "goployer@example.com", To: []string{"ops@example.com"}},
+	}
+
+	testData := []struct {
+		name          string
+		notifications *schemas.Notifications
+		stacks        []schemas.Stack
+		err           bool
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			notifications: &schemas.Notifications{
+				Channels: []schemas.NotificationChannel{teams, email},
+				Routes: []schemas.NotificationRoute{
+					{Events: []string{constants.NotificationFailed}, Channels: []string{"mail"}},
+					{Channels: []string{"teams", constants.EnvSlackChannelName}},
+				},
+			},
+			stacks: []schemas.Stack{{
+				Stack:         "artd",
+				Notifications: &schemas.StackNotifications{Routes: []schemas.NotificationRoute{{Channels: []string{"teams"}}}},
+			}},
+		},
+		{
+			name:          "duplicated name",
+			notifications: &schemas.Notifications{Channels: []schemas.NotificationChannel{teams, teams}},
+			err:           true,
+		},
+		{
+			name:          "unknown type",
+			notifications: &schemas.Notifications{Channels: []schemas.NotificationChannel{{Name: "pager", Type: "pager"}}},
+			err:           true,
+		},
+		{
+			name:          "webhook without url",
+			notifications: &schemas.Notifications{Channels: []schemas.NotificationChannel{{Name: "hook", Type: constants.WebhookNotification}}},
+			err:           true,
+		},
+		{
+			name:          "email without smtp",
+			notifications: &schemas.Notifications{Channels: []schemas.NotificationChannel{{Name: "mail", Type: constants.EmailNotification}}},
+			err:           true,
+		},
+		{
+			name: "unknown event",
+			notifications: &schemas.Notifications{
+				Channels: []schemas.NotificationChannel{teams},
+				Routes:   []schemas.NotificationRoute{{Events: []string{"deleted"}, Channels: []string{"teams"}}},
+			},
+			err: true,
+		},
+		{
+			name: "unknown channel of stack",
+			notifications: &schemas.Notifications{
+				Channels: []schemas.NotificationChannel{teams},
+			},
+			stacks: []schemas.Stack{{
+				Stack:         "artd",
+				Notifications: &schemas.StackNotifications{Routes: []schemas.NotificationRoute{{Channels: []string{"mail"}}}},
+			}},
+			err: true,
+		},
+	}
+
+	for _, td := range testData {
+		if err := checkNotifications(td.notifications, td.stacks); (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+	}
+}
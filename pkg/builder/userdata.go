@@ -0,0 +1,72 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"text/template"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// UserdataVariables are variables which can be used in userdata template
+type UserdataVariables struct {
+	App              string
+	Stack            string
+	Env              string
+	Region           string
+	Ami              string
+	AutoScalingGroup string
+	ReleaseNotes     string
+	AnsibleExtraVars string
+}
+
+// RenderUserdata renders base64 encoded userdata as go template with variables
+func RenderUserdata(userdata string, variables UserdataVariables) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(userdata)
+	if err != nil {
+		return constants.EmptyString, fmt.Errorf("error decoding userdata: %v", err)
+	}
+
+	t, err := template.New("userdata").Parse(string(raw))
+	if err != nil {
+		return constants.EmptyString, fmt.Errorf("error parsing userdata template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, variables); err != nil {
+		return constants.EmptyString, fmt.Errorf("error rendering userdata template: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ValidateUserdataSize checks if raw userdata fits in the limit of EC2
+func ValidateUserdataSize(userdata string) error {
+	raw, err := base64.StdEncoding.DecodeString(userdata)
+	if err != nil {
+		return fmt.Errorf("error decoding userdata: %v", err)
+	}
+
+	if len(raw) > constants.MaxUserdataSize {
+		return fmt.Errorf("userdata is %d bytes which exceeds the limit of %d bytes", len(raw), constants.MaxUserdataSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package builder
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+type fakeS3 struct {
+	aws.S3Client
+	objects map[string]string
+}
+
+func (f fakeS3) GetManifest(bucket, key string) ([]byte, error) {
+	body, ok := f.objects[fmt.Sprintf("%s/%s", bucket, key)]
+	if !ok {
+		return nil, fmt.Errorf("NoSuchKey: %s/%s", bucket, key)
+	}
+
+	return []byte(body), nil
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestS3Provider_Provide(t *testing.T) {
+	s3 := fakeS3{objects: map[string]string{"goployer/userdata/hello.sh": "#!/bin/bash\necho hello"}}
+
+	testData := []struct {
+		path     string
+		expected string
+		err      bool
+	}{
+		{path: "s3://goployer/userdata/hello.sh", expected: encode("#!/bin/bash\necho hello")},
+		{path: "s3://goployer/userdata/none.sh", err: true},
+		{path: "goployer/userdata/hello.sh", err: true},
+	}
+
+	for _, td := range testData {
+		userdata, err := S3Provider{Path: td.path, S3Service: s3}.Provide()
+		if (err != nil) != td.err {
+			t.Errorf("unexpected error for %s: %v", td.path, err)
+		}
+
+		if userdata != td.expected {
+			t.Errorf("%s: expected %s, got %s", td.path, td.expected, userdata)
+		}
+	}
+}
+
+func TestRenderUserdata(t *testing.T) {
+	variables := UserdataVariables{
+		App:              "hello",
+		Stack:            "artd",
+		Env:              "dev",
+		Region:           "ap-northeast-2",
+		Ami:              "ami-01288945bd24ed49a",
+		AutoScalingGroup: "hello-dev_apne2-v001",
+		ReleaseNotes:     "first release",
+		AnsibleExtraVars: "foo=bar",
+	}
+
+	testData := []struct {
+		script   string
+		expected string
+		err      bool
+	}{
+		{script: "#!/bin/bash\necho hello", expected: "#!/bin/bash\necho hello"},
+		{script: "APP={{ .App }} STACK={{ .Stack }} ENV={{ .Env }} REGION={{ .Region }}", expected: "APP=hello STACK=artd ENV=dev REGION=ap-northeast-2"},
+		{script: "{{ .Ami }} {{ .AutoScalingGroup }} '{{ .ReleaseNotes }}' {{ .AnsibleExtraVars }}", expected: "ami-01288945bd24ed49a hello-dev_apne2-v001 'first release' foo=bar"},
+		{script: "{{ .Unknown }}", err: true},
+		{script: "{{ .App ", err: true},
+	}
+
+	for _, td := range testData {
+		userdata, err := RenderUserdata(encode(td.script), variables)
+		if (err != nil) != td.err {
+			t.Errorf("unexpected error for %s: %v", td.script, err)
+		}
+
+		if !td.err && userdata != encode(td.expected) {
+			t.Errorf("%s: expected %s, got %s", td.script, encode(td.expected), userdata)
+		}
+	}
+}
+
+func TestValidateUserdataSize(t *testing.T) {
+	testData := []struct {
+		userdata string
+		err      bool
+	}{
+		{userdata: encode("#!/bin/bash")},
+		{userdata: encode(strings.Repeat("a", constants.MaxUserdataSize))},
+		{userdata: encode(strings.Repeat("a", constants.MaxUserdataSize+1)), err: true},
+		{userdata: "not base64", err: true},
+	}
+
+	for _, td := range testData {
+		if err := ValidateUserdataSize(td.userdata); (err != nil) != td.err {
+			t.Errorf("unexpected error for userdata of %d bytes: %v", len(td.userdata), err)
+		}
+	}
+}
+
+func TestMergeUserdata(t *testing.T) {
+	defaultUserdata := schemas.Userdata{Type: "s3", Path: "s3://goployer/default.sh", Region: "ap-northeast-2", Template: true}
+
+	testData := []struct {
+		userdata schemas.Userdata
+		expected schemas.Userdata
+	}{
+		{userdata: schemas.Userdata{}, expected: defaultUserdata},
+		{
+			userdata: schemas.Userdata{Path: "s3://goployer/stack.sh"},
+			expected: schemas.Userdata{Type: "s3", Path: "s3://goployer/stack.sh", Region: "ap-northeast-2"},
+		},
+		{
+			userdata: schemas.Userdata{Type: "local", Path: "script.sh", Template: true},
+			expected: schemas.Userdata{Type: "local", Path: "script.sh", Region: "ap-northeast-2", Template: true},
+		},
+	}
+
+	for _, td := range testData {
+		if merged := MergeUserdata(td.userdata, defaultUserdata); merged != td.expected {
+			t.Errorf("expected %+v, got %+v", td.expected, merged)
+		}
+	}
+}
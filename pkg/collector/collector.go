@@ -17,8 +17,11 @@ limitations under the license.
 package collector
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/autoscaling"
@@ -27,12 +30,14 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/storage"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
 type Collector struct {
 	MetricConfig      schemas.MetricConfig
 	MetricClient      aws.MetricClient
+	Storage           storage.Storage
 	TargetMetricsList []TargetMetrics
 }
 
@@ -48,6 +53,13 @@ type TargetMetrics struct {
 	MappingFunction func(*HelperStruct, *Logger.Logger, aws.MetricClient, string) (map[string]interface{}, error)
 }
 
+// DeploymentRecord is the deployment information stamped to storage
+type DeploymentRecord struct {
+	Stack    schemas.Stack
+	Config   schemas.Config
+	Userdata string
+}
+
 type HelperStruct struct {
 	BaseTimeDuration float64
 	StartDate        time.Time
@@ -55,20 +67,31 @@ type HelperStruct struct {
 	CurrentTime      time.Time
 	TargetGroups     []*string
 	LoadBalancers    []*string
-	Storage          string
+	Storage          storage.Storage
 }
 
 // NewCollector creates new collector
-func NewCollector(mc schemas.MetricConfig, assumeRole string) Collector {
+func NewCollector(mc schemas.MetricConfig, assumeRole string) (Collector, error) {
+	return NewCollectorWithClient(mc, aws.BootstrapMetricService(mc.Region, assumeRole))
+}
+
+// NewCollectorWithClient creates new collector with metric client
+func NewCollectorWithClient(mc schemas.MetricConfig, client aws.MetricClient) (Collector, error) {
+	s, err := storage.New(mc, client)
+	if err != nil {
+		return Collector{}, err
+	}
+
 	return Collector{
 		MetricConfig:      mc,
-		MetricClient:      aws.BootstrapMetricService(mc.Region, assumeRole),
-		TargetMetricsList: SetTargetMetrics(),
-	}
+		MetricClient:      client,
+		Storage:           s,
+		TargetMetricsList: SetTargetMetrics(mc.Metrics.Custom),
+	}, nil
 }
 
 // SetTargetMetrics sets what collector will gather for measurement
-func SetTargetMetrics() []TargetMetrics {
+func SetTargetMetrics(custom []schemas.CustomMetric) []TargetMetrics {
 	tm := []TargetMetrics{
 		{
 			Name:            "uptime",
@@ -87,6 +110,14 @@ func SetTargetMetrics() []TargetMetrics {
 		},
 	}
 
+	for _, m := range custom {
+		tm = append(tm, TargetMetrics{
+			Name:            m.Name,
+			Enable:          true,
+			MappingFunction: GatherCustomMetric(m),
+		})
+	}
+
 	return tm
 }
 
@@ -95,29 +126,12 @@ func (c Collector) CheckStorage(logger *Logger.Logger) error {
 	if len(c.MetricConfig.Storage.Type) == 0 {
 		logger.Warnf("you did not specify the storage type so that default storage type will be applied : %s", constants.DefaultMetricStorageType)
 	}
-	if c.MetricConfig.Storage.Type == "dynamodb" {
-		isExist, err := c.MetricClient.DynamoDBService.CheckTableExists(c.MetricConfig.Storage.Name)
-		if err != nil {
-			return err
-		}
-
-		if isExist {
-			logger.Infof("you already had a table : %s", c.MetricConfig.Storage.Name)
-		} else {
-			logger.Infof("you don't have a table : %s", c.MetricConfig.Storage.Name)
-			if err := c.MetricClient.DynamoDBService.CreateTable(c.MetricConfig.Storage.Name); err != nil {
-				return err
-			}
-
-			logger.Infof("new table is created : %s", c.MetricConfig.Storage.Name)
-		}
-	}
 
-	return nil
+	return c.Storage.Prepare(logger)
 }
 
 // StampDeployment records deployment information to storage
-func (c Collector) StampDeployment(stack schemas.Stack, config schemas.Config, tags []*autoscaling.Tag, asg string, status string, additionalFields map[string]string) error {
+func (c Collector) StampDeployment(app string, stack schemas.Stack, config schemas.Config, tags []*autoscaling.Tag, asg string, status string, additionalFields map[string]string) error {
 	tagsMap := map[string]string{}
 
 	for _, tag := range tags {
@@ -128,31 +142,76 @@ func (c Collector) StampDeployment(stack schemas.Stack, config schemas.Config, t
 	if err != nil {
 		return err
 	}
-	tagString := string(tagJSON)
 
 	stackJSON, err := json.Marshal(stack)
 	if err != nil {
 		return err
 	}
-	stackString := string(stackJSON)
 
 	configJSON, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
-	configString := string(configJSON)
 
-	if err := c.MetricClient.DynamoDBService.MakeRecord(stackString, configString, tagString, asg, c.MetricConfig.Storage.Name, status, c.MetricConfig.Metrics.BaseTimezone, additionalFields); err != nil {
+	record := storage.Record{
+		constants.ApplicationKey: app,
+		constants.StackNameKey:   stack.Stack,
+		"deployment_status":      status,
+		"stack":                  string(stackJSON),
+		"config":                 string(configJSON),
+		"start_date":             c.now(),
+		"tag":                    string(tagJSON),
+	}
+
+	for k, v := range additionalFields {
+		record[k] = v
+	}
+
+	if err := c.Storage.Put(asg, record); err != nil {
 		return err
 	}
+	Logger.Debugf("deployment metric is saved")
+
+	return nil
+}
+
+// GetDeploymentRecord retrieves the deployment information of autoscaling group from storage
+func (c Collector) GetDeploymentRecord(asg string) (*DeploymentRecord, error) {
+	item, err := c.Storage.Get(asg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(item.String("stack")) == 0 || len(item.String("config")) == 0 {
+		return nil, nil
+	}
+
+	var record DeploymentRecord
+	if err := json.Unmarshal([]byte(item.String("stack")), &record.Stack); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(item.String("config")), &record.Config); err != nil {
+		return nil, err
+	}
+	record.Userdata = item.String("userdata")
 
-	return err
+	return &record, nil
 }
 
 // UpdateStatus updates status of deployment on the table
 func (c Collector) UpdateStatus(asg string, status string, updateFields map[string]interface{}) error {
 	Logger.Debugf("deployment statuses of previous autoscaling groups are started")
-	if err := c.MetricClient.DynamoDBService.UpdateRecord("deployment_status", asg, c.MetricConfig.Storage.Name, status, c.MetricConfig.Metrics.BaseTimezone, updateFields); err != nil {
+	fields := storage.Record{"deployment_status": status}
+	if key, ok := constants.StatusTimeStampKey[status]; ok {
+		fields[key] = c.now()
+	}
+
+	for k, v := range updateFields {
+		fields[k] = v
+	}
+
+	if err := c.Storage.Update(asg, fields); err != nil {
 		return err
 	}
 	Logger.Debugf("deployment statuses of previous autoscaling groups are updated")
@@ -162,10 +221,17 @@ func (c Collector) UpdateStatus(asg string, status string, updateFields map[stri
 
 // UpdateStatistics update value of metric table
 func (c Collector) UpdateStatistics(asg string, updateFields map[string]interface{}) error {
-	if err := c.MetricClient.DynamoDBService.UpdateStatistics(asg, c.MetricConfig.Storage.Name, c.MetricConfig.Metrics.BaseTimezone, updateFields); err != nil {
-		return err
+	fields := storage.Record{"statistics_record_time": c.now()}
+	for k, v := range updateFields {
+		fields[k] = v
 	}
-	return nil
+
+	return c.Storage.Update(asg, fields)
+}
+
+// now returns current time in base timezone as string stored in records
+func (c Collector) now() string {
+	return tool.GetBaseTimeWithTimezone(c.MetricConfig.Metrics.BaseTimezone).Format(time.RFC3339)
 }
 
 // GetAdditionalMetric retrieves additional metrics to store
@@ -178,7 +244,7 @@ func (c Collector) GetAdditionalMetric(asg string, tgs []*string, lbs []*string,
 		CurrentTime:      tool.GetBaseTimeWithTimezone(c.MetricConfig.Metrics.BaseTimezone),
 		TargetGroups:     tgs,
 		LoadBalancers:    lbs,
-		Storage:          c.MetricConfig.Storage.Name,
+		Storage:          c.Storage,
 	}
 
 	logger.Debugf("current time in timezone %s : %s", c.MetricConfig.Metrics.BaseTimezone, hs.CurrentTime)
@@ -200,20 +266,20 @@ func (c Collector) GetAdditionalMetric(asg string, tgs []*string, lbs []*string,
 }
 
 // GatherUptime gathers uptime information about autoscaling group
-func GatherUptime(hs *HelperStruct, _ *Logger.Logger, client aws.MetricClient, name string) (map[string]interface{}, error) {
-	item, err := client.DynamoDBService.GetSingleItem(hs.AutoScalingGroup, hs.Storage)
+func GatherUptime(hs *HelperStruct, _ *Logger.Logger, _ aws.MetricClient, name string) (map[string]interface{}, error) {
+	item, err := hs.Storage.Get(hs.AutoScalingGroup)
 	if err != nil {
 		return nil, err
 	}
 
 	if item == nil {
-		return nil, fmt.Errorf("there is no dynamodb record for %s", hs.AutoScalingGroup)
+		return nil, fmt.Errorf("there is no deployment record for %s", hs.AutoScalingGroup)
 	}
 
 	ret := map[string]interface{}{}
-	for k, v := range item {
+	for k := range item {
 		if k == "deployed_date" {
-			d, _ := time.Parse(time.RFC3339, *v.S)
+			d, _ := time.Parse(time.RFC3339, item.String(k))
 			diff := hs.CurrentTime.Sub(d)
 			ret["uptime_second"] = fmt.Sprintf("%f", diff.Seconds())
 			ret["uptime_minute"] = fmt.Sprintf("%f", diff.Minutes())
@@ -282,3 +348,131 @@ func GetLoadBalancerRequestCount(hs *HelperStruct, logger *Logger.Logger, client
 
 	return ret, nil
 }
+
+// GatherCustomMetric returns mapping function which retrieves user-defined metric over the lifetime of autoscaling group.
+// The metric is queried for each target group or load balancer if its dimensions use them.
+func GatherCustomMetric(m schemas.CustomMetric) func(*HelperStruct, *Logger.Logger, aws.MetricClient, string) (map[string]interface{}, error) {
+	return func(hs *HelperStruct, logger *Logger.Logger, client aws.MetricClient, name string) (map[string]interface{}, error) {
+		ret := map[string]interface{}{}
+		if hs.StartDate.Sub(constants.MinTimestamp) <= 0 {
+			return ret, nil
+		}
+
+		startDate := hs.StartDate
+		if hs.BaseTimeDuration > constants.MonthToSec {
+			startDate = hs.CurrentTime.Add(-2592000 * time.Second)
+		}
+
+		if hs.CurrentTime.Sub(startDate) <= 0 {
+			logger.Debugf("too short to gather metrics: current: %s,terminated: %s", hs.CurrentTime, startDate)
+			return ret, nil
+		}
+
+		period := m.Period
+		if period == 0 {
+			period = constants.DefaultCustomMetricPeriod
+		}
+
+		values := map[string]float64{}
+		for _, v := range metricVariables(m.Dimensions, hs) {
+			dimensions, err := RenderMetricDimensions(m.Dimensions, v)
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := client.CloudWatchService.GetMetricStatisticWithPeriod(m.Namespace, m.Metric, m.Statistic, dimensions, period, startDate, hs.CurrentTime)
+			if err != nil {
+				return nil, err
+			}
+
+			if value == nil {
+				logger.Debugf("no datapoint of metric %s: %v", name, dimensions)
+				continue
+			}
+			values[v.target()] = *value
+		}
+
+		if len(values) > 0 {
+			ret[name] = values
+		}
+
+		return ret, nil
+	}
+}
+
+// MetricVariables are values which can be used in dimensions of user-defined metrics
+type MetricVariables struct {
+	AutoScalingGroup string
+	TargetGroup      string
+	LoadBalancer     string
+}
+
+// target returns the most specific resource of variables which is used as the key of metric value
+func (v MetricVariables) target() string {
+	if len(v.TargetGroup) > 0 {
+		return v.TargetGroup
+	}
+
+	if len(v.LoadBalancer) > 0 {
+		return v.LoadBalancer
+	}
+
+	return v.AutoScalingGroup
+}
+
+// RenderMetricDimensions renders templates in values of dimensions
+func RenderMetricDimensions(dimensions map[string]string, v MetricVariables) (map[string]string, error) {
+	ret := map[string]string{}
+	for k, d := range dimensions {
+		t, err := template.New(k).Option("missingkey=error").Parse(d)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, v); err != nil {
+			return nil, err
+		}
+		ret[k] = buf.String()
+	}
+
+	return ret, nil
+}
+
+// metricVariables makes variables for each target group and load balancer which are used in dimensions
+func metricVariables(dimensions map[string]string, hs *HelperStruct) []MetricVariables {
+	var useTargetGroup, useLoadBalancer bool
+	for _, d := range dimensions {
+		useTargetGroup = useTargetGroup || strings.Contains(d, ".TargetGroup")
+		useLoadBalancer = useLoadBalancer || strings.Contains(d, ".LoadBalancer")
+	}
+
+	ret := []MetricVariables{{AutoScalingGroup: hs.AutoScalingGroup}}
+	if useTargetGroup {
+		ret = expandVariables(ret, hs.TargetGroups, func(v *MetricVariables, arn string) {
+			v.TargetGroup = tool.GetTargetGroupDimension(arn)
+		})
+	}
+
+	if useLoadBalancer {
+		ret = expandVariables(ret, hs.LoadBalancers, func(v *MetricVariables, arn string) {
+			v.LoadBalancer = tool.GetLoadBalancerDimension(arn)
+		})
+	}
+
+	return ret
+}
+
+// expandVariables copies variables for each resource
+func expandVariables(vars []MetricVariables, arns []*string, set func(*MetricVariables, string)) []MetricVariables {
+	var ret []MetricVariables
+	for _, v := range vars {
+		for _, arn := range arns {
+			copied := v
+			set(&copied, *arn)
+			ret = append(ret, copied)
+		}
+	}
+
+	return ret
+}
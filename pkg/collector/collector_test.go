@@ -0,0 +1,114 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package collector
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestRenderMetricDimensions(t *testing.T) {
+	v := MetricVariables{AutoScalingGroup: "hello-dev_apnortheast2-v001", TargetGroup: "targetgroup/hello/1"}
+
+	dimensions, err := RenderMetricDimensions(map[string]string{"AutoScalingGroupName": "{{ .AutoScalingGroup }}", "TargetGroup": "{{ .TargetGroup }}", "Fixed": "value"}, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"AutoScalingGroupName": "hello-dev_apnortheast2-v001", "TargetGroup": "targetgroup/hello/1", "Fixed": "value"}
+	if !reflect.DeepEqual(dimensions, expected) {
+		t.Errorf("expected %v, got %v", expected, dimensions)
+	}
+
+	if _, err := RenderMetricDimensions(map[string]string{"Instance": "{{ .Instance }}"}, v); err == nil {
+		t.Error("unknown variable should not be rendered")
+	}
+}
+
+func TestGatherCustomMetric(t *testing.T) {
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+
+	cloud := fake.NewCloud("ap-northeast-2")
+	cloud.SetMetricStatistic("AWS/EC2", "CPUUtilization", 42)
+	cloud.SetMetricStatistic("AWS/ApplicationELB", "TargetResponseTime", 0.3)
+
+	now := time.Now()
+	hs := &HelperStruct{
+		StartDate:        now.Add(-time.Hour),
+		CurrentTime:      now,
+		AutoScalingGroup: "hello-dev_apnortheast2-v001",
+		TargetGroups: []*string{
+			eaws.String("arn:aws:elasticloadbalancing:ap-northeast-2:123456789012:targetgroup/hello-a/1"),
+			eaws.String("arn:aws:elasticloadbalancing:ap-northeast-2:123456789012:targetgroup/hello-b/2"),
+		},
+		LoadBalancers: []*string{eaws.String("arn:aws:elasticloadbalancing:ap-northeast-2:123456789012:loadbalancer/app/hello/3")},
+	}
+
+	testData := []struct {
+		metric   schemas.CustomMetric
+		expected map[string]interface{}
+		queries  []map[string]string
+	}{
+		{
+			metric: schemas.CustomMetric{Name: "cpu", Namespace: "AWS/EC2", Metric: "CPUUtilization", Statistic: "Average", Dimensions: map[string]string{"AutoScalingGroupName": "{{ .AutoScalingGroup }}"}},
+			expected: map[string]interface{}{
+				"cpu": map[string]float64{"hello-dev_apnortheast2-v001": 42},
+			},
+			queries: []map[string]string{{"AutoScalingGroupName": "hello-dev_apnortheast2-v001"}},
+		},
+		{
+			metric: schemas.CustomMetric{Name: "latency_p99", Namespace: "AWS/ApplicationELB", Metric: "TargetResponseTime", Statistic: "p99", Dimensions: map[string]string{"TargetGroup": "{{ .TargetGroup }}", "LoadBalancer": "{{ .LoadBalancer }}"}},
+			expected: map[string]interface{}{
+				"latency_p99": map[string]float64{"targetgroup/hello-a/1": 0.3, "targetgroup/hello-b/2": 0.3},
+			},
+			queries: []map[string]string{
+				{"TargetGroup": "targetgroup/hello-a/1", "LoadBalancer": "app/hello/3"},
+				{"TargetGroup": "targetgroup/hello-b/2", "LoadBalancer": "app/hello/3"},
+			},
+		},
+		{
+			metric:   schemas.CustomMetric{Name: "spot", Namespace: "AWS/EC2Spot", Metric: "InterruptionCount", Statistic: "Sum"},
+			expected: map[string]interface{}{},
+			queries:  []map[string]string{{}},
+		},
+	}
+
+	for _, td := range testData {
+		before := len(cloud.MetricQueries())
+		ret, err := GatherCustomMetric(td.metric)(hs, logger, cloud.MetricClient(), td.metric.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(ret, td.expected) {
+			t.Errorf("%s: expected %v, got %v", td.metric.Name, td.expected, ret)
+		}
+
+		if queries := cloud.MetricQueries()[before:]; !reflect.DeepEqual(queries, td.queries) {
+			t.Errorf("%s: expected queries %v, got %v", td.metric.Name, td.queries, queries)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DORAReport is DORA metrics of a stack computed from deployment records
+type DORAReport struct {
+	Stack                   string    `json:"stack"`
+	Since                   time.Time `json:"since"`
+	Until                   time.Time `json:"until"`
+	Deployments             int       `json:"deployments"`
+	DeploymentFrequency     float64   `json:"deployment_frequency_per_day"`
+	LeadTimeSecond          *float64  `json:"lead_time_second,omitempty"`
+	FailedDeployments       int       `json:"failed_deployments"`
+	ChangeFailureRate       float64   `json:"change_failure_rate"`
+	RestoredFailures        int       `json:"restored_failures"`
+	MeanTimeToRestoreSecond *float64  `json:"mean_time_to_restore_second,omitempty"`
+}
+
+// deployment is a version of stack which is deployed to one or more regions
+type deployment struct {
+	deployedDate *time.Time
+	commitDate   *time.Time
+	failedDate   *time.Time
+	restoredDate *time.Time
+	failed       bool
+	unrestored   bool
+}
+
+// ComputeDORA computes DORA metrics of each stack from deployment records between since and until.
+// Records of the same autoscaling group version in different regions are counted as one deployment.
+// - Deployment frequency is the number of deployments per day.
+// - Lead time is the median from commit timestamp to the latest deployed date of deployments which have commit timestamp.
+// - Failed deployments are rolled back or never reached deployed in any region before terminated or replaced by next deployment.
+// - Time to restore is from failure to rollback or next successful deployment in all failed regions.
+func ComputeDORA(histories []History, since, until time.Time) []DORAReport {
+	stacks := map[string]map[string][]History{}
+	for _, h := range histories {
+		if h.StartDate.Before(since) || h.StartDate.After(until) {
+			continue
+		}
+
+		if _, ok := stacks[h.Stack]; !ok {
+			stacks[h.Stack] = map[string][]History{}
+		}
+		stacks[h.Stack][h.Region] = append(stacks[h.Stack][h.Region], h)
+	}
+
+	var ret []DORAReport
+	for stack, regions := range stacks {
+		report := DORAReport{Stack: stack, Since: since, Until: until}
+
+		deployments := map[string]*deployment{}
+		for region, hs := range regions {
+			sort.SliceStable(hs, func(i, j int) bool {
+				return hs[i].StartDate.Before(hs[j].StartDate)
+			})
+
+			for i, h := range hs {
+				key := deploymentKey(h, region, i)
+				d, ok := deployments[key]
+				if !ok {
+					d = &deployment{}
+					deployments[key] = d
+				}
+				d.add(h)
+
+				if !isFailedDeployment(h, i < len(hs)-1) {
+					continue
+				}
+
+				failed := h.StartDate
+				if h.DeployedDate != nil {
+					failed = *h.DeployedDate
+				}
+				d.fail(failed, restoredDate(h, hs[i+1:]))
+			}
+		}
+
+		var leadTimes, restoreTimes []float64
+		for _, d := range deployments {
+			report.Deployments++
+
+			if d.deployedDate != nil && d.commitDate != nil {
+				leadTimes = append(leadTimes, d.deployedDate.Sub(*d.commitDate).Seconds())
+			}
+
+			if !d.failed {
+				continue
+			}
+			report.FailedDeployments++
+
+			if !d.unrestored {
+				restoreTimes = append(restoreTimes, d.restoredDate.Sub(*d.failedDate).Seconds())
+			}
+		}
+
+		if days := until.Sub(since).Hours() / 24; days > 0 {
+			report.DeploymentFrequency = float64(report.Deployments) / days
+		}
+
+		if report.Deployments > 0 {
+			report.ChangeFailureRate = float64(report.FailedDeployments) / float64(report.Deployments)
+		}
+
+		report.LeadTimeSecond = median(leadTimes)
+		report.RestoredFailures = len(restoreTimes)
+		report.MeanTimeToRestoreSecond = mean(restoreTimes)
+
+		ret = append(ret, report)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Stack < ret[j].Stack
+	})
+
+	return ret
+}
+
+// deploymentKey returns version of autoscaling group. Record without version is regarded as a deployment by itself.
+func deploymentKey(h History, region string, index int) string {
+	if i := strings.LastIndex(h.AutoScalingGroup, "-v"); i >= 0 {
+		return h.AutoScalingGroup[i+1:]
+	}
+
+	return fmt.Sprintf("%s/%d", region, index)
+}
+
+// add merges record of a region into deployment
+func (d *deployment) add(h History) {
+	if h.DeployedDate != nil && (d.deployedDate == nil || h.DeployedDate.After(*d.deployedDate)) {
+		d.deployedDate = h.DeployedDate
+	}
+
+	if h.CommitDate != nil && (d.commitDate == nil || h.CommitDate.Before(*d.commitDate)) {
+		d.commitDate = h.CommitDate
+	}
+}
+
+// fail marks deployment failed in a region. Deployment is restored when every failed region is restored.
+func (d *deployment) fail(failed time.Time, restored *time.Time) {
+	d.failed = true
+	if d.failedDate == nil || failed.Before(*d.failedDate) {
+		d.failedDate = &failed
+	}
+
+	if restored == nil {
+		d.unrestored = true
+	} else if d.restoredDate == nil || restored.After(*d.restoredDate) {
+		d.restoredDate = restored
+	}
+}
+
+// isFailedDeployment checks if deployment is rolled back or never reached deployed.
+// Deployment which is not deployed yet is in progress unless it is terminated or replaced.
+func isFailedDeployment(h History, replaced bool) bool {
+	if h.RolledbackDate != nil {
+		return true
+	}
+
+	return h.DeployedDate == nil && (h.TerminatedDate != nil || replaced)
+}
+
+// restoredDate returns when the failure is restored by rollback or the next successful deployment
+func restoredDate(failed History, next []History) *time.Time {
+	if failed.RolledbackDate != nil {
+		return failed.RolledbackDate
+	}
+
+	for _, h := range next {
+		if h.DeployedDate != nil && h.RolledbackDate == nil {
+			return h.DeployedDate
+		}
+	}
+
+	return nil
+}
+
+// median returns median of values or nil if there is no value
+func median(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	m := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		m = (sorted[len(sorted)/2-1] + m) / 2
+	}
+
+	return &m
+}
+
+// mean returns average of values or nil if there is no value
+func mean(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	m := sum / float64(len(values))
+
+	return &m
+}
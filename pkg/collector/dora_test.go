@@ -0,0 +1,101 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeDORA(t *testing.T) {
+	since := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 0, 10)
+	at := func(hour int) *time.Time {
+		t := since.Add(time.Duration(hour) * time.Hour)
+		return &t
+	}
+
+	histories := []History{
+		// deployed an hour after commit and replaced later
+		{Stack: "artd", Region: "ap-northeast-2", StartDate: *at(1), CommitDate: at(0), DeployedDate: at(1), TerminatedDate: at(10)},
+		// never reached deployed and replaced by the next deployment
+		{Stack: "artd", Region: "ap-northeast-2", StartDate: *at(10)},
+		// restores the failure above after 3 hours
+		{Stack: "artd", Region: "ap-northeast-2", StartDate: *at(12), CommitDate: at(9), DeployedDate: at(13)},
+		// rolled back an hour after it was deployed
+		{Stack: "artd", Region: "us-east-1", StartDate: *at(20), DeployedDate: at(21), RolledbackDate: at(22)},
+		// in progress
+		{Stack: "artd", Region: "us-east-1", StartDate: *at(30)},
+		// out of period
+		{Stack: "artd", Region: "us-east-1", StartDate: since.Add(-time.Hour)},
+		// terminated before deployed and not restored yet
+		{Stack: "artp", Region: "ap-northeast-2", StartDate: *at(5), TerminatedDate: at(6)},
+		// deployed to two regions at once
+		{Stack: "arts", Region: "ap-northeast-2", AutoScalingGroup: "hello-arts_apnortheast2-v001", StartDate: *at(1), CommitDate: at(0), DeployedDate: at(1)},
+		{Stack: "arts", Region: "us-east-1", AutoScalingGroup: "hello-arts_useast1-v001", StartDate: *at(1), CommitDate: at(0), DeployedDate: at(2)},
+		// rolled back in only one of two regions
+		{Stack: "arts", Region: "ap-northeast-2", AutoScalingGroup: "hello-arts_apnortheast2-v002", StartDate: *at(10), DeployedDate: at(11)},
+		{Stack: "arts", Region: "us-east-1", AutoScalingGroup: "hello-arts_useast1-v002", StartDate: *at(10), DeployedDate: at(11), RolledbackDate: at(12)},
+	}
+
+	reports := ComputeDORA(histories, since, until)
+	if len(reports) != 3 {
+		t.Fatalf("expected reports of 3 stacks, got: %+v", reports)
+	}
+
+	artd := reports[0]
+	if artd.Stack != "artd" || artd.Deployments != 5 || artd.FailedDeployments != 2 || artd.RestoredFailures != 2 {
+		t.Errorf("unexpected report: %+v", artd)
+	}
+
+	if artd.DeploymentFrequency != 0.5 {
+		t.Errorf("expected 0.5 deployments per day, got %f", artd.DeploymentFrequency)
+	}
+
+	if artd.ChangeFailureRate != 0.4 {
+		t.Errorf("expected change failure rate 0.4, got %f", artd.ChangeFailureRate)
+	}
+
+	// median of 1h and 4h
+	if artd.LeadTimeSecond == nil || *artd.LeadTimeSecond != 2.5*3600 {
+		t.Errorf("unexpected lead time: %v", artd.LeadTimeSecond)
+	}
+
+	// mean of 3h and 1h
+	if artd.MeanTimeToRestoreSecond == nil || *artd.MeanTimeToRestoreSecond != 2*3600 {
+		t.Errorf("unexpected mean time to restore: %v", artd.MeanTimeToRestoreSecond)
+	}
+
+	artp := reports[1]
+	if artp.Stack != "artp" || artp.FailedDeployments != 1 || artp.ChangeFailureRate != 1 || artp.MeanTimeToRestoreSecond != nil || artp.LeadTimeSecond != nil {
+		t.Errorf("unexpected report: %+v", artp)
+	}
+
+	arts := reports[2]
+	if arts.Stack != "arts" || arts.Deployments != 2 || arts.FailedDeployments != 1 || arts.ChangeFailureRate != 0.5 || arts.DeploymentFrequency != 0.2 {
+		t.Errorf("unexpected report: %+v", arts)
+	}
+
+	// commit to the deployment in the last region
+	if arts.LeadTimeSecond == nil || *arts.LeadTimeSecond != 2*3600 {
+		t.Errorf("unexpected lead time: %v", arts.LeadTimeSecond)
+	}
+
+	if arts.MeanTimeToRestoreSecond == nil || *arts.MeanTimeToRestoreSecond != 3600 {
+		t.Errorf("unexpected mean time to restore: %v", arts.MeanTimeToRestoreSecond)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package collector
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/storage"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// ignoredDiffKeys are fields of config which are different for every deployment
+var ignoredDiffKeys = []string{"StartTimestamp"}
+
+// History is a deployment record of autoscaling group read from storage
+type History struct {
+	AutoScalingGroup     string            `json:"autoscaling_group"`
+	Stack                string            `json:"stack"`
+	Region               string            `json:"region"`
+	Status               string            `json:"status"`
+	Ami                  string            `json:"ami"`
+	InstanceType         string            `json:"instance_type"`
+	StartDate            time.Time         `json:"start_date"`
+	DeployedDate         *time.Time        `json:"deployed_date,omitempty"`
+	TerminatedDate       *time.Time        `json:"terminated_date,omitempty"`
+	RolledbackDate       *time.Time        `json:"rolledback_date,omitempty"`
+	CommitDate           *time.Time        `json:"commit_date,omitempty"`
+	DurationSecond       float64           `json:"duration_second"`
+	UptimeHour           float64           `json:"uptime_hour"`
+	TargetGroupRequests  float64           `json:"target_group_requests"`
+	LoadBalancerRequests float64           `json:"load_balancer_requests"`
+	ReleaseNotes         string            `json:"release_notes,omitempty"`
+	Tags                 map[string]string `json:"tags"`
+	Userdata             string            `json:"userdata,omitempty"`
+	StackConfig          schemas.Stack     `json:"stack_config"`
+	Config               schemas.Config    `json:"config"`
+}
+
+// HistoryDiff is a changed value between two deployment records
+type HistoryDiff struct {
+	Section string `json:"section"`
+	Key     string `json:"key,omitempty"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// ListHistory retrieves deployment records of stacks in the region which are started after since
+func (c Collector) ListHistory(app string, stacks []schemas.Stack, region string, since time.Time) ([]History, error) {
+	items, err := c.Storage.List(app, constants.EmptyString)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []History
+	for _, item := range items {
+		h, err := parseHistory(app, item)
+		if err != nil {
+			return nil, err
+		}
+
+		// records of other applications or regions do not have the region
+		if h == nil || len(h.Region) == 0 || h.StartDate.Before(since) || (len(region) > 0 && h.Region != region) {
+			continue
+		}
+
+		for _, stack := range stacks {
+			if stack.Stack == h.Stack && stack.Env == h.StackConfig.Env {
+				ret = append(ret, *h)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].StartDate.After(ret[j].StartDate)
+	})
+
+	return ret, nil
+}
+
+// GetHistory retrieves the deployment record of autoscaling group
+func (c Collector) GetHistory(app, asg string) (*History, error) {
+	item, err := c.Storage.Get(asg)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := parseHistory(app, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if h == nil {
+		return nil, fmt.Errorf("no deployment record exists: %s", asg)
+	}
+
+	return h, nil
+}
+
+// DiffHistory compares stack, config, tags and userdata of two deployment records
+func DiffHistory(old, new History) ([]HistoryDiff, error) {
+	var ret []HistoryDiff
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{name: "stack", old: old.StackConfig, new: new.StackConfig},
+		{name: "config", old: old.Config, new: new.Config},
+		{name: "tags", old: old.Tags, new: new.Tags},
+	}
+
+	for _, s := range sections {
+		oldFields, err := flatten(s.old)
+		if err != nil {
+			return nil, err
+		}
+
+		newFields, err := flatten(s.new)
+		if err != nil {
+			return nil, err
+		}
+
+		var keys []string
+		for k := range oldFields {
+			keys = append(keys, k)
+		}
+		for k := range newFields {
+			if _, ok := oldFields[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if oldFields[k] != newFields[k] && !tool.IsStringInArray(k, ignoredDiffKeys) {
+				ret = append(ret, HistoryDiff{Section: s.name, Key: k, Old: oldFields[k], New: newFields[k]})
+			}
+		}
+	}
+
+	return append(ret, diffLines(old.Userdata, new.Userdata)...), nil
+}
+
+// Duration returns how long the deployment took until autoscaling group became healthy
+func (h History) Duration() time.Duration {
+	return time.Duration(h.DurationSecond * float64(time.Second))
+}
+
+// parseHistory makes history from the item of storage. It returns nil if the item is not a deployment record.
+func parseHistory(app string, item storage.Record) (*History, error) {
+	if len(item.String(constants.HashKey)) == 0 || len(item.String("stack")) == 0 || len(item.String("config")) == 0 {
+		return nil, nil
+	}
+
+	h := History{
+		AutoScalingGroup: item.String(constants.HashKey),
+		Status:           item.String("deployment_status"),
+		Tags:             map[string]string{},
+	}
+
+	if err := json.Unmarshal([]byte(item.String("stack")), &h.StackConfig); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(item.String("config")), &h.Config); err != nil {
+		return nil, err
+	}
+
+	if tags := item.String("tag"); len(tags) > 0 {
+		if err := json.Unmarshal([]byte(tags), &h.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	h.Stack = h.StackConfig.Stack
+	h.StartDate, _ = time.Parse(time.RFC3339, item.String("start_date"))
+	h.DeployedDate = recordTime(item, constants.StatusTimeStampKey["deployed"])
+	h.TerminatedDate = recordTime(item, constants.StatusTimeStampKey["terminated"])
+	h.RolledbackDate = recordTime(item, constants.StatusTimeStampKey["rolledback"])
+	if h.DeployedDate != nil {
+		h.DurationSecond = h.DeployedDate.Sub(h.StartDate).Seconds()
+	}
+
+	if len(h.Config.CommitTimestamp) > 0 {
+		if t, err := tool.ParseTimestamp(h.Config.CommitTimestamp); err == nil {
+			h.CommitDate = &t
+		}
+	}
+	h.UptimeHour = item.Number("uptime_hour")
+	h.TargetGroupRequests = item.Sum("tg_request_count")
+	h.LoadBalancerRequests = item.Sum("lb_request_count")
+
+	for _, region := range h.StackConfig.Regions {
+		if strings.HasPrefix(h.AutoScalingGroup, fmt.Sprintf("%s-v", tool.BuildPrefixName(app, h.StackConfig.Env, region.Region))) {
+			h.Region = region.Region
+			h.Ami = region.AmiID
+			h.InstanceType = region.InstanceType
+			break
+		}
+	}
+
+	if len(h.Config.Ami) > 0 {
+		h.Ami = h.Config.Ami
+	}
+
+	if len(h.Config.OverrideInstanceType) > 0 {
+		h.InstanceType = h.Config.OverrideInstanceType
+	}
+
+	h.ReleaseNotes = item.String("release-notes")
+	if notes := item.String("release-notes-base64"); len(notes) > 0 {
+		decoded, err := base64.StdEncoding.DecodeString(notes)
+		if err != nil {
+			return nil, err
+		}
+		h.ReleaseNotes = string(decoded)
+	}
+
+	if userdata := item.String("userdata"); len(userdata) > 0 {
+		decoded, err := base64.StdEncoding.DecodeString(userdata)
+		if err != nil {
+			return nil, err
+		}
+		h.Userdata = string(decoded)
+	}
+
+	return &h, nil
+}
+
+// recordTime returns time value of the field if it exists
+func recordTime(item storage.Record, key string) *time.Time {
+	t, err := time.Parse(time.RFC3339, item.String(key))
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// flatten converts value to the map of json paths and values
+func flatten(v interface{}) (map[string]string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return nil, err
+	}
+
+	ret := map[string]string{}
+	flattenValue(constants.EmptyString, decoded, ret)
+
+	return ret, nil
+}
+
+// flattenValue sets leaf values of v to ret with their paths
+func flattenValue(path string, v interface{}, ret map[string]string) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range val {
+			key := k
+			if len(path) > 0 {
+				key = fmt.Sprintf("%s.%s", path, k)
+			}
+			flattenValue(key, vv, ret)
+		}
+	case []interface{}:
+		for i, vv := range val {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), vv, ret)
+		}
+	case nil:
+	case string:
+		if len(val) > 0 {
+			ret[path] = val
+		}
+	default:
+		ret[path] = fmt.Sprint(val)
+	}
+}
+
+// diffLines compares userdata line by line with the longest common subsequence
+func diffLines(old, new string) []HistoryDiff {
+	if old == new {
+		return nil
+	}
+
+	a := strings.Split(old, "\n")
+	b := strings.Split(new, "\n")
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ret []HistoryDiff
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ret = append(ret, HistoryDiff{Section: "userdata", Key: fmt.Sprintf("+%d", j+1), New: b[j]})
+			j++
+		default:
+			ret = append(ret, HistoryDiff{Section: "userdata", Key: fmt.Sprintf("-%d", i+1), Old: a[i]})
+			i++
+		}
+	}
+
+	return ret
+}
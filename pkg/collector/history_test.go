@@ -0,0 +1,218 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package collector
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+const historyTable = "goployer-metrics"
+
+func historyStack(stack, env, ami string) schemas.Stack {
+	return schemas.Stack{
+		Stack: stack,
+		Env:   env,
+		Regions: []schemas.RegionConfig{
+			{Region: "ap-northeast-2", AmiID: ami, InstanceType: "t3.small"},
+			{Region: "us-east-1", AmiID: ami, InstanceType: "t3.large"},
+		},
+	}
+}
+
+func newHistoryCollector(t *testing.T) Collector {
+	cloud := fake.NewCloud("ap-northeast-2")
+	c, err := NewCollectorWithClient(schemas.MetricConfig{
+		Enabled: true,
+		Storage: schemas.Storage{Type: "dynamodb", Name: historyTable},
+	}, cloud.MetricClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.CheckStorage(Logger.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestCollector_ListHistory(t *testing.T) {
+	c := newHistoryCollector(t)
+	artd := historyStack("artd", "dev", "ami-1")
+	artp := historyStack("artp", "prod", "ami-2")
+	tags := []*autoscaling.Tag{{Key: eaws.String("app"), Value: eaws.String("hello")}}
+
+	records := []struct {
+		app    string
+		stack  schemas.Stack
+		config schemas.Config
+		asg    string
+	}{
+		{app: "hello", stack: artd, asg: "hello-dev_apnortheast2-v001"},
+		{app: "hello", stack: artd, config: schemas.Config{Ami: "ami-3", OverrideInstanceType: "c5.large"}, asg: "hello-dev_apnortheast2-v002"},
+		{app: "hello", stack: artd, asg: "hello-dev_useast1-v001"},
+		{app: "hello", stack: artp, asg: "hello-prod_apnortheast2-v001"},
+		{app: "other", stack: artd, asg: "other-dev_apnortheast2-v001"},
+	}
+
+	for _, r := range records {
+		if err := c.StampDeployment(r.app, r.stack, r.config, tags, r.asg, "creating", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.UpdateStatus("hello-dev_apnortheast2-v001", "deployed", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := map[string]map[string]float64{
+		"tg-a": {"requestSum": 10},
+		"tg-b": {"requestSum": 5},
+	}
+	if err := c.UpdateStatistics("hello-dev_apnortheast2-v001", map[string]interface{}{"tg_request_count": stats, "uptime_hour": "1.500000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	histories, err := c.ListHistory("hello", []schemas.Stack{artd, artp}, "ap-northeast-2", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]History{}
+	for _, h := range histories {
+		got[h.AutoScalingGroup] = h
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("expected 3 records, got: %v", got)
+	}
+
+	deployed := got["hello-dev_apnortheast2-v001"]
+	if deployed.Status != "deployed" || deployed.DeployedDate == nil || deployed.Region != "ap-northeast-2" {
+		t.Errorf("unexpected deployed record: %+v", deployed)
+	}
+
+	if deployed.TargetGroupRequests != 15 || deployed.UptimeHour != 1.5 {
+		t.Errorf("unexpected statistics: %.0f requests, %.1f hours", deployed.TargetGroupRequests, deployed.UptimeHour)
+	}
+
+	if deployed.Ami != "ami-1" || deployed.InstanceType != "t3.small" || deployed.Tags["app"] != "hello" {
+		t.Errorf("unexpected stack information: %+v", deployed)
+	}
+
+	overridden := got["hello-dev_apnortheast2-v002"]
+	if overridden.Ami != "ami-3" || overridden.InstanceType != "c5.large" || overridden.DeployedDate != nil {
+		t.Errorf("unexpected overridden record: %+v", overridden)
+	}
+
+	histories, err = c.ListHistory("hello", []schemas.Stack{artp}, "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(histories) != 1 || histories[0].AutoScalingGroup != "hello-prod_apnortheast2-v001" {
+		t.Errorf("unexpected records of stack: %+v", histories)
+	}
+
+	histories, err = c.ListHistory("hello", []schemas.Stack{artd, artp}, "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(histories) != 0 {
+		t.Errorf("records before since should be filtered: %+v", histories)
+	}
+}
+
+func TestCollector_GetHistory(t *testing.T) {
+	c := newHistoryCollector(t)
+	userdata := base64.StdEncoding.EncodeToString([]byte("#!/bin/bash\necho hello"))
+	fields := map[string]string{
+		"userdata":             userdata,
+		"release-notes-base64": base64.StdEncoding.EncodeToString([]byte("first release")),
+	}
+
+	if err := c.StampDeployment("hello", historyStack("artd", "dev", "ami-1"), schemas.Config{}, nil, "hello-dev_useast1-v001", "creating", fields); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := c.GetHistory("hello", "hello-dev_useast1-v001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Region != "us-east-1" || h.InstanceType != "t3.large" || h.Userdata != "#!/bin/bash\necho hello" || h.ReleaseNotes != "first release" {
+		t.Errorf("unexpected record: %+v", h)
+	}
+
+	if _, err := c.GetHistory("hello", "hello-dev_useast1-v002"); err == nil {
+		t.Error("expected error for autoscaling group without record")
+	}
+}
+
+func TestDiffHistory(t *testing.T) {
+	old := History{
+		StackConfig: historyStack("artd", "dev", "ami-1"),
+		Config:      schemas.Config{StartTimestamp: 1},
+		Tags:        map[string]string{"app": "hello", "owner": "alice"},
+		Userdata:    "#!/bin/bash\necho hello\nstart",
+	}
+	new := History{
+		StackConfig: historyStack("artd", "dev", "ami-2"),
+		Config:      schemas.Config{StartTimestamp: 2, ReleaseNotes: "fix"},
+		Tags:        map[string]string{"app": "hello", "team": "devops"},
+		Userdata:    "#!/bin/bash\necho world\nstart",
+	}
+
+	diffs, err := DiffHistory(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []HistoryDiff{
+		{Section: "stack", Key: "Regions[0].AmiID", Old: "ami-1", New: "ami-2"},
+		{Section: "stack", Key: "Regions[1].AmiID", Old: "ami-1", New: "ami-2"},
+		{Section: "config", Key: "release_notes", New: "fix"},
+		{Section: "tags", Key: "owner", Old: "alice"},
+		{Section: "tags", Key: "team", New: "devops"},
+		{Section: "userdata", Key: "-2", Old: "echo hello"},
+		{Section: "userdata", Key: "+2", New: "echo world"},
+	}
+
+	if len(diffs) != len(expected) {
+		t.Fatalf("expected %d differences, got: %+v", len(expected), diffs)
+	}
+
+	for i := range expected {
+		if diffs[i] != expected[i] {
+			t.Errorf("expected: %+v, got: %+v", expected[i], diffs[i])
+		}
+	}
+
+	if diffs, _ := DiffHistory(old, old); len(diffs) != 0 {
+		t.Errorf("same records should not have differences: %+v", diffs)
+	}
+}
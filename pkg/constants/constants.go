@@ -75,8 +75,55 @@ const (
 	// TestMetricYamlPath is the relative path for test metrics file
 	TestMetricYamlPath = "../../test/metrics_test.yaml"
 
+	// DefaultStateDirectory is the directory under home where deployment states are saved
+	DefaultStateDirectory = ".goployer/state"
+
+	// DefaultServerAddr is the default address of goployer server
+	DefaultServerAddr = "localhost"
+
+	// DefaultServerPort is the default port of goployer server
+	DefaultServerPort = int64(9037)
+
+	// DefaultMaxFinishedJobs is how many finished deployment jobs server keeps with their logs
+	DefaultMaxFinishedJobs = 100
+
+	// DefaultLockDirectory is the directory under home where deployment locks are kept by file backend
+	DefaultLockDirectory = ".goployer/locks"
+
+	// DefaultLockTTL is how long a deployment lock is valid without heartbeat
+	DefaultLockTTL = 2 * time.Minute
+
+	// MinLockTTL is the minimum ttl of deployment lock
+	MinLockTTL = 30 * time.Second
+
+	// LockKeyPrefix is the prefix of hash key of deployment lock in metric table
+	LockKeyPrefix = "goployer-lock:"
+
+	// Lock backends
+	DynamoDBLockBackend = "dynamodb"
+	FileLockBackend     = "file"
+	NoLockBackend       = "none"
+
+	// Metric storage types
+	DynamoDBStorage = "dynamodb"
+	LocalStorage    = "local"
+	S3Storage       = "s3"
+
 	// DefaultMetricStorageType is the default storage type for metrics
-	DefaultMetricStorageType = "dynamodb"
+	DefaultMetricStorageType = DynamoDBStorage
+
+	// Billing modes of metric table
+	PayPerRequestBillingMode = "PAY_PER_REQUEST"
+	ProvisionedBillingMode   = "PROVISIONED"
+
+	// ApplicationIndex is the global secondary index of metric table for querying records of application and stack
+	ApplicationIndex = "application-stack-index"
+
+	// DefaultLocalStoragePath is the default database file of local metric storage
+	DefaultLocalStoragePath = ".goployer/metrics.db"
+
+	// DefaultS3StoragePrefix is the default prefix of objects in s3 metric storage
+	DefaultS3StoragePrefix = "deployments"
 
 	// DefaultRegion is a default region
 	DefaultRegion = "us-east-1"
@@ -96,9 +143,18 @@ const (
 	// S3Prefix is prefix of s3 URL
 	S3Prefix = "s3://"
 
+	// MaxUserdataSize is the maximum size of raw userdata allowed by EC2
+	MaxUserdataSize = 16 * 1024
+
 	// HashKey is the default value of hash key for metric table
 	HashKey = "identifier"
 
+	// ApplicationKey is the attribute of metric record for application name
+	ApplicationKey = "application"
+
+	// StackNameKey is the attribute of metric record for stack name
+	StackNameKey = "stack_name"
+
 	// DefaultReadThroughput is the default value of RCU
 	DefaultReadThroughput = int64(5)
 
@@ -117,6 +173,13 @@ const (
 	// HourToSec changes an hour to seconds
 	HourToSec = int64(3600)
 
+	// ApplicationELBNamespace is cloudwatch namespace of application load balancer
+	ApplicationELBNamespace = "AWS/ApplicationELB"
+
+	// Ways to treat verification metric without datapoint
+	MissingDataBreaching    = "breaching"
+	MissingDataNotBreaching = "notBreaching"
+
 	// StepCheckPrevious = CheckPrevious
 	StepCheckPrevious = int64(1)
 
@@ -141,9 +204,18 @@ const (
 	// StepRunAPI = RunAPI
 	StepRunAPI = int64(8)
 
+	// StepVerify = Verify
+	StepVerify = int64(9)
+
 	// DefaultEnableStats is whether or not to enable gathering stats
 	DefaultEnableStats = true
 
+	// DefaultCustomMetricPeriod is the default period of user-defined metrics in seconds
+	DefaultCustomMetricPeriod = int64(300)
+
+	// MaxMetricDatapoints is the maximum number of datapoints which cloudwatch returns for a request
+	MaxMetricDatapoints = int64(1440)
+
 	// ALL means ALL as string
 	ALL = "ALL"
 
@@ -156,6 +228,77 @@ const (
 	// SlackWebHookURL is environment key for slack webhook url
 	SlackWebHookURL = "SLACK_WEBHOOK_URL"
 
+	// Types of notification channel
+	SlackNotification   = "slack"
+	TeamsNotification   = "teams"
+	WebhookNotification = "webhook"
+	EmailNotification   = "email"
+
+	// EnvSlackChannelName is the name of slack channel configured with environment variables
+	EnvSlackChannelName = "slack"
+
+	// DefaultSMTPPort is the default port of SMTP server
+	DefaultSMTPPort = int64(587)
+
+	// Events of notification
+	NotificationStart    = "start"
+	NotificationProgress = "progress"
+	NotificationHealthy  = "healthy"
+	NotificationFailed   = "failed"
+	NotificationCleaned  = "cleaned"
+	NotificationAPITest  = "api_test"
+	NotificationFinished = "finished"
+
+	// Failure policies of lifecycle callbacks
+	LifecycleCallbackAbort    = "abort"
+	LifecycleCallbackContinue = "continue"
+	LifecycleCallbackRetry    = "retry"
+
+	// DefaultLifecycleCallbackTimeout is how long goployer waits for lifecycle callbacks in instances
+	DefaultLifecycleCallbackTimeout = 10 * time.Minute
+
+	// DefaultLifecycleCallbackRetries is the number of retries of failed lifecycle callbacks with retry policy
+	DefaultLifecycleCallbackRetries = int64(1)
+
+	// Types of health checker
+	HTTPHealthChecker      = "http"
+	TCPHealthChecker       = "tcp"
+	SSMHealthChecker       = "ssm"
+	EC2StatusHealthChecker = "ec2_status"
+
+	// DefaultHealthCheckTimeout is the timeout of http and tcp health checkers
+	DefaultHealthCheckTimeout = 5 * time.Second
+
+	// DefaultSSMHealthCheckTimeout is the timeout of ssm health checker
+	DefaultSSMHealthCheckTimeout = 1 * time.Minute
+
+	// DefaultReplaceUnhealthyAfter is how long instance stays unhealthy before it is replaced by health policy
+	DefaultReplaceUnhealthyAfter = 5 * time.Minute
+
+	// Policies of scaling failure
+	ScalingFailureAbort    = "abort"
+	ScalingFailureContinue = "continue"
+
+	// RecentScalingActivities is the number of scaling activities shown when deployment fails
+	RecentScalingActivities = 5
+
+	// Phases of lifecycle hooks
+	PreDeployHook   = "pre_deploy"
+	PostDeployHook  = "post_deploy"
+	PostHealthyHook = "post_healthy"
+	PreCleanupHook  = "pre_cleanup"
+	PostCleanupHook = "post_cleanup"
+	OnFailureHook   = "on_failure"
+
+	// Types of lifecycle hook
+	SSMHook   = "ssm"
+	LocalHook = "local"
+	HTTPHook  = "http"
+
+	// Instances where ssm hook runs
+	NewInstances = "new"
+	OldInstances = "old"
+
 	// MinAPITestDuration is minimum duration of API test
 	MinAPITestDuration = 1 * time.Second
 
@@ -171,6 +314,43 @@ const (
 	RollingUpdateDeployment = "rollingupdate"
 	DeployOnly              = "deployonly"
 
+	// Actions in deployment plan
+	PlanCreate = "create"
+	PlanResize = "resize"
+	PlanAttach = "attach"
+	PlanDetach = "detach"
+	PlanDelete = "delete"
+
+	// Output formats
+	TableOutput = "table"
+	JSONOutput  = "json"
+	YAMLOutput  = "yaml"
+	CSVOutput   = "csv"
+
+	// Statuses of deployment step in events
+	StepStarted   = "started"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+
+	// Results of stack deployment
+	DeploymentSucceeded  = "succeeded"
+	DeploymentFailed     = "failed"
+	DeploymentRolledBack = "rolled_back"
+	DeploymentUnverified = "unverified"
+	DeploymentStopped    = "stopped"
+
+	// HistoryTimeFormat is the time format of deployment history
+	HistoryTimeFormat = "2006-01-02 15:04:05 MST"
+
+	// HistoryUsage is the usage of history command
+	HistoryUsage = "usage: goployer history [show <autoscaling group> | diff <autoscaling group> <autoscaling group>]"
+
+	// ReportUsage is the usage of report command
+	ReportUsage = "usage: goployer report dora"
+
+	// DefaultReportPeriod is the default period of report
+	DefaultReportPeriod = "30d"
+
 	DelimiterRegex = "[,/|!@$%^&*_=`~]+"
 )
 
@@ -201,7 +381,7 @@ var (
 	AllowedRequestMethod = []string{"GET", "POST", "PUT"}
 
 	// TimeFields is a list of time.Time field
-	TimeFields = []string{"timeout", "polling-interval"}
+	TimeFields = []string{"timeout", "polling-interval", "lock-ttl"}
 
 	// ProhibitedTags is a list of prohibited tags which are going to be attached by goployer
 	ProhibitedTags = []string{"Name", "stack"}
@@ -210,8 +390,95 @@ var (
 	StatusTimeStampKey = map[string]string{
 		"deployed":   "deployed_date",
 		"terminated": "terminated_date",
+		"rolledback": "rolledback_date",
+	}
+
+	// StepNames is a map of deployment steps with their names
+	StepNames = map[int64]string{
+		StepCheckPrevious:            "check_previous",
+		StepDeploy:                   "deploy",
+		StepAdditionalWork:           "additional_work",
+		StepTriggerLifecycleCallback: "trigger_lifecycle_callback",
+		StepCleanPreviousVersion:     "clean_previous_version",
+		StepCleanChecking:            "clean_checking",
+		StepGatherMetrics:            "gather_metrics",
+		StepRunAPI:                   "run_api",
+		StepVerify:                   "verify",
+	}
+
+	// AllowedLockBackends is a list of backends for deployment lock
+	AllowedLockBackends = []string{DynamoDBLockBackend, FileLockBackend, NoLockBackend}
+
+	// AllowedStorageTypes is a list of metric storage types
+	AllowedStorageTypes = []string{DynamoDBStorage, LocalStorage, S3Storage}
+
+	// AllowedBillingModes is a list of billing modes of metric table
+	AllowedBillingModes = []string{PayPerRequestBillingMode, ProvisionedBillingMode}
+
+	// AllowedNotificationTypes is a list of types of notification channel
+	AllowedNotificationTypes = []string{SlackNotification, TeamsNotification, WebhookNotification, EmailNotification}
+
+	// AllowedNotificationEvents is a list of events of notification
+	AllowedNotificationEvents = []string{NotificationStart, NotificationProgress, NotificationHealthy, NotificationFailed, NotificationCleaned, NotificationAPITest, NotificationFinished}
+
+	// AllowedLifecycleCallbackPolicies is a list of failure policies of lifecycle callbacks
+	AllowedLifecycleCallbackPolicies = []string{LifecycleCallbackAbort, LifecycleCallbackContinue, LifecycleCallbackRetry}
+
+	// AllowedScalingFailurePolicies is a list of policies of scaling failure
+	AllowedScalingFailurePolicies = []string{ScalingFailureAbort, ScalingFailureContinue}
+
+	// CapacityScalingFailures are parts of status messages of scaling activity which can be solved with other instance types
+	CapacityScalingFailures = []string{
+		"insufficientinstancecapacity",
+		"do not have sufficient",
+		"is not supported in your requested availability zone",
+		"spot request price",
+		"spotmaxpricetoolow",
+		"maxspotinstancecountexceeded",
 	}
 
+	// TerminalScalingFailures are parts of status messages of scaling activity which do not go away with retries
+	TerminalScalingFailures = []string{
+		"image id",
+		"invalidamiid",
+		"iaminstanceprofile",
+		"iam instance profile",
+		"not enough free addresses in subnet",
+		"insufficientfreeaddressesinsubnet",
+		"invalidsubnet",
+		"invalidkeypair",
+		"key pair",
+		"invalidgroup",
+		"instancelimitexceeded",
+		"vcpulimitexceeded",
+		"vcpu capacity",
+		"launch template",
+	}
+
+	// AllowedHealthCheckers is a list of types of health checker
+	AllowedHealthCheckers = []string{HTTPHealthChecker, TCPHealthChecker, SSMHealthChecker, EC2StatusHealthChecker}
+
+	// AllowedHookTypes is a list of types of lifecycle hook
+	AllowedHookTypes = []string{SSMHook, LocalHook, HTTPHook}
+
+	// AllowedHookTargets is a list of instances where ssm hook runs
+	AllowedHookTargets = []string{NewInstances, OldInstances}
+
+	// AllowedOutputFormats is a list of output formats for printing results
+	AllowedOutputFormats = []string{TableOutput, JSONOutput, YAMLOutput, CSVOutput}
+
+	// AllowedStatistics is a list of statistics for metric verification
+	AllowedStatistics = []string{"Average", "Sum", "Minimum", "Maximum", "SampleCount"}
+
+	// ReservedMetricNames is a list of record fields which user-defined metrics cannot use
+	ReservedMetricNames = []string{HashKey, ApplicationKey, StackNameKey, "deployment_status", "stack", "config", "tag", "userdata", "start_date", "deployed_date", "terminated_date", "rolledback_date", "statistics_record_time", "uptime", "uptime_second", "uptime_minute", "uptime_hour", "tg_request_count", "lb_request_count", "release-notes", "release-notes-base64"}
+
+	// AllowedComparisons is a list of comparison operators for metric verification
+	AllowedComparisons = []string{"GreaterThanThreshold", "GreaterThanOrEqualToThreshold", "LessThanThreshold", "LessThanOrEqualToThreshold"}
+
+	// AllowedTreatMissingData is a list of ways to treat verification metric without datapoint
+	AllowedTreatMissingData = []string{MissingDataBreaching, MissingDataNotBreaching}
+
 	// AllowedAnswerYes is a list of allowed answers with yes
 	AllowedAnswerYes = []string{"y", "yes"}
 
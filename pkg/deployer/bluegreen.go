@@ -21,9 +21,6 @@ import (
 	"fmt"
 	"time"
 
-	Logger "github.com/sirupsen/logrus"
-
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
@@ -37,16 +34,7 @@ type BlueGreen struct {
 
 // NewBlueGreen creates new BlueGreen deployment deployer
 func NewBlueGreen(h *helper.DeployerHelper) *BlueGreen {
-	awsClients := []aws.Client{}
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, BootstrapAWSClients(h))
 
 	return &BlueGreen{
 		Deployer: &d,
@@ -110,7 +98,7 @@ func (b *BlueGreen) HealthChecking(config schemas.Config) error {
 
 		isDone, err := b.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -27,7 +27,6 @@ import (
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
@@ -41,21 +40,13 @@ type Canary struct {
 	PrevHealthCheckTargetGroups map[string]string
 	LoadBalancer                map[string]string
 	LBSecurityGroup             map[string]*string
+	CanaryTraffic               map[string]*schemas.CanaryTraffic
 	*Deployer
 }
 
 // NewCanary creates new canary deployment deployer
 func NewCanary(h *helper.DeployerHelper) *Canary {
-	var awsClients []aws.Client
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, BootstrapAWSClients(h))
 
 	return &Canary{
 		PrevHealthCheckTargetGroups: map[string]string{},
@@ -63,6 +54,7 @@ func NewCanary(h *helper.DeployerHelper) *Canary {
 		TargetGroups:                map[string][]*string{},
 		LoadBalancer:                map[string]string{},
 		LBSecurityGroup:             map[string]*string{},
+		CanaryTraffic:               map[string]*schemas.CanaryTraffic{},
 		Deployer:                    &d,
 	}
 }
@@ -72,6 +64,42 @@ func (c *Canary) GetDeployer() *Deployer {
 	return c.Deployer
 }
 
+// ExportState returns the progress of canary deployment to persist
+func (c *Canary) ExportState(config schemas.Config) schemas.DeploymentState {
+	state := c.Deployer.ExportState(config)
+	state.PrevTargetGroups = c.PrevTargetGroups
+	state.TargetGroups = c.TargetGroups
+	state.PrevHealthCheckTargetGroups = c.PrevHealthCheckTargetGroups
+	state.LoadBalancer = c.LoadBalancer
+	state.LBSecurityGroup = c.LBSecurityGroup
+	state.CanaryTraffic = c.CanaryTraffic
+
+	return state
+}
+
+// ImportState restores the progress of canary deployment from persisted state
+func (c *Canary) ImportState(state schemas.DeploymentState) {
+	c.Deployer.ImportState(state)
+	copyStringMap(c.PrevHealthCheckTargetGroups, state.PrevHealthCheckTargetGroups)
+	copyStringMap(c.LoadBalancer, state.LoadBalancer)
+
+	for region, tgs := range state.PrevTargetGroups {
+		c.PrevTargetGroups[region] = tgs
+	}
+
+	for region, tgs := range state.TargetGroups {
+		c.TargetGroups[region] = tgs
+	}
+
+	for region, sg := range state.LBSecurityGroup {
+		c.LBSecurityGroup[region] = sg
+	}
+
+	for region, traffic := range state.CanaryTraffic {
+		c.CanaryTraffic[region] = traffic
+	}
+}
+
 // CheckPreviousResources checks if there is any previous version of autoscaling group
 func (c *Canary) CheckPreviousResources(config schemas.Config) error {
 	err := c.Deployer.CheckPrevious(config)
@@ -103,6 +131,16 @@ func (c *Canary) Deploy(config schemas.Config) error {
 			return err
 		}
 
+		if c.IsProgressive() {
+			// region of manifest is kept as it is and only health checking follows canary target group
+			canaryRegion, err := c.RunProgressiveCanaryDeployment(config, region)
+			if err != nil {
+				return err
+			}
+			c.setHealthCheckTargetGroup(region.Region, canaryRegion.HealthcheckTargetGroup)
+			continue
+		}
+
 		latestASG := c.LatestAsg[region.Region]
 		targetGroups, err := c.GetAsgTargetGroups(latestASG, region.Region)
 		if err != nil {
@@ -163,7 +201,7 @@ func (c *Canary) HealthChecking(config schemas.Config) error {
 
 		isDone, err := c.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -193,6 +231,12 @@ func (c *Canary) FinishAdditionalWork(config schemas.Config) error {
 	}
 
 	if !skipped {
+		if c.IsProgressive() {
+			if err := c.ProgressCanary(config); err != nil {
+				return err
+			}
+		}
+
 		// attach to the previous target group
 		if len(c.PrevTargetGroups) > 0 {
 			if err := c.AttachToOriginalTargetGroups(config); err != nil {
@@ -204,6 +248,12 @@ func (c *Canary) FinishAdditionalWork(config schemas.Config) error {
 			}
 		}
 
+		if c.IsProgressive() {
+			if err := c.PromoteCanary(config); err != nil {
+				return err
+			}
+		}
+
 		if err := c.DoCommonAdditionalWork(config); err != nil {
 			return err
 		}
@@ -252,13 +302,6 @@ func (c *Canary) CleanPreviousVersion(config schemas.Config) error {
 				return err
 			}
 		}
-		// TODO: Need to uncomment if goployer supports gradual canary deployment
-		////Apply AutoScaling Policies
-		//for _, region := range c.Stack.Regions {
-		//	if err := c.ReduceOriginalAutoscalingGroupCount(region); err != nil {
-		//		return err
-		//	}
-		//}
 	}
 	c.StepStatus[constants.StepCleanPreviousVersion] = true
 	return nil
@@ -279,7 +322,7 @@ func (c *Canary) GatherMetrics(config schemas.Config) error {
 		}
 	}
 
-	if !config.CompleteCanary {
+	if !config.CompleteCanary && !c.IsProgressive() {
 		c.Logger.Debug("Skip gathering metrics because canary is now applied")
 		return nil
 	}
@@ -298,7 +341,7 @@ func (c *Canary) RunAPITest(config schemas.Config) error {
 		return nil
 	}
 
-	if !config.CompleteCanary {
+	if !config.CompleteCanary && !c.IsProgressive() {
 		c.Logger.Debug("Skip API test because canary is now applied")
 		return nil
 	}
@@ -312,12 +355,228 @@ func (c *Canary) RunAPITest(config schemas.Config) error {
 	return nil
 }
 
+// Plan returns changes of resources that canary deployment would make without modifying anything
+func (c *Canary) Plan(config schemas.Config) (schemas.Plan, error) {
+	plan := schemas.Plan{
+		Stack: c.Stack.Stack,
+		Mode:  c.Mode,
+	}
+
+	for _, region := range c.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			c.Logger.Debugf("This region is skipped by user : %s", region.Region)
+			continue
+		}
+
+		if err := c.ValidateCanaryDeployment(config, region.Region); err != nil {
+			return plan, err
+		}
+
+		var changes []schemas.PlanChange
+		var err error
+		if c.IsProgressive() {
+			changes, err = c.PlanProgressiveCanary(config, region)
+		} else if config.CompleteCanary {
+			changes, err = c.PlanCompleteCanary(config, region)
+		} else {
+			changes, err = c.PlanCanaryDeployment(config, region)
+		}
+
+		if err != nil {
+			return plan, err
+		}
+		plan.Changes = append(plan.Changes, changes...)
+	}
+
+	return plan, nil
+}
+
+// PlanCanaryDeployment returns resources that a new canary would create in the region
+func (c *Canary) PlanCanaryDeployment(config schemas.Config, region schemas.RegionConfig) ([]schemas.PlanChange, error) {
+	var changes []schemas.PlanChange
+
+	canaryVersion := 0
+	if latestASG := c.LatestAsg[region.Region]; len(latestASG) > 0 {
+		targetGroups, err := c.GetAsgTargetGroups(latestASG, region.Region)
+		if err != nil {
+			return nil, err
+		}
+		canaryVersion = CheckCanaryVersion(targetGroups, region.Region)
+	}
+
+	newTgName := c.GenerateCanaryTargetGroupName(canaryVersion)
+	changes = append(changes, schemas.PlanChange{
+		Region:   region.Region,
+		Action:   constants.PlanCreate,
+		Resource: "target group",
+		Name:     newTgName,
+		Detail:   fmt.Sprintf("copy of %s", c.SelectTargetGroupForCopy(region, canaryVersion)),
+	})
+
+	canaryLoadBalancer, err := c.FindCanaryLoadBalancer(region)
+	if err != nil {
+		return nil, err
+	}
+
+	if canaryLoadBalancer == nil && (len(region.HealthcheckLB) > 0 || len(region.TargetGroups) > 0) {
+		changes = append(changes,
+			schemas.PlanChange{
+				Region:   region.Region,
+				Action:   constants.PlanCreate,
+				Resource: "security group",
+				Name:     c.GenerateCanaryLBSecurityGroupName(region.Region),
+				Detail:   "for canary load balancer",
+			},
+			schemas.PlanChange{
+				Region:   region.Region,
+				Action:   constants.PlanCreate,
+				Resource: "load balancer",
+				Name:     c.GenerateCanaryLoadBalancerName(region.Region),
+				Detail:   fmt.Sprintf("forward to %s", newTgName),
+			},
+		)
+	}
+
+	client, err := selectClientFromList(c.AWSClients, region.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	sgName := c.GenerateCanarySecurityGroupName(region.Region)
+	if _, err := client.EC2Service.GetSecurityGroup(sgName); err != nil {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanCreate,
+			Resource: "security group",
+			Name:     sgName,
+			Detail:   "for canary instances",
+		})
+	}
+
+	// The new autoscaling group is registered to the canary target group first
+	canaryRegion := region
+	canaryRegion.HealthcheckTargetGroup = newTgName
+	canaryRegion.TargetGroups = []string{newTgName}
+
+	deployment, err := c.Deployer.PlanDeployment(config, canaryRegion)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, deployment...)
+
+	for _, tg := range region.TargetGroups {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanAttach,
+			Resource: "target group",
+			Name:     tg,
+			Detail:   "after canary instances become healthy",
+		})
+	}
+
+	prevCanaryChanges, err := c.planPreviousCanaryResources(region, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(changes, prevCanaryChanges...), nil
+}
+
+// PlanCompleteCanary returns changes that completing canary deployment would make in the region
+func (c *Canary) PlanCompleteCanary(config schemas.Config, region schemas.RegionConfig) ([]schemas.PlanChange, error) {
+	var changes []schemas.PlanChange
+
+	appliedCapacity, err := c.Deployer.DecideCapacity(config.ForceManifestCapacity, config.CompleteCanary, region.Region, len(c.PrevAsgs[region.Region]), c.Stack.RollingUpdateInstanceCount)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = append(changes, schemas.PlanChange{
+		Region:   region.Region,
+		Action:   constants.PlanResize,
+		Resource: "autoscaling group",
+		Name:     c.LatestAsg[region.Region],
+		Detail:   fmt.Sprintf("min: %d, desired: %d, max: %d", appliedCapacity.Min, appliedCapacity.Desired, appliedCapacity.Max),
+	})
+
+	prevCanaryChanges, err := c.planPreviousCanaryResources(region, true)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, prevCanaryChanges...)
+
+	canaryLoadBalancer, err := c.FindCanaryLoadBalancer(region)
+	if err != nil {
+		return nil, err
+	}
+
+	if canaryLoadBalancer != nil {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanDelete,
+			Resource: "load balancer",
+			Name:     *canaryLoadBalancer.LoadBalancerName,
+		})
+	}
+
+	for _, sg := range []string{c.GenerateCanarySecurityGroupName(region.Region), c.GenerateCanaryLBSecurityGroupName(region.Region)} {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanDelete,
+			Resource: "security group",
+			Name:     sg,
+		})
+	}
+
+	return changes, nil
+}
+
+// planPreviousCanaryResources returns previous autoscaling groups and their canary target groups to clean
+func (c *Canary) planPreviousCanaryResources(region schemas.RegionConfig, completeCanary bool) ([]schemas.PlanChange, error) {
+	changes := c.Deployer.PlanCleaning(region.Region)
+
+	for _, asg := range c.PrevAsgs[region.Region] {
+		asgDetail, err := c.Deployer.DescribeAutoScalingGroup(asg, region.Region)
+		if err != nil {
+			return nil, err
+		}
+
+		if asgDetail == nil {
+			continue
+		}
+
+		// canary target group of the latest autoscaling group is only detached when canary is completed
+		action := constants.PlanDelete
+		if completeCanary && asg == c.LatestAsg[region.Region] {
+			action = constants.PlanDetach
+		}
+
+		for _, tg := range asgDetail.TargetGroupARNs {
+			if tool.IsCanaryTargetGroupArn(*tg, region.Region) {
+				changes = append(changes, schemas.PlanChange{
+					Region:   region.Region,
+					Action:   action,
+					Resource: "target group",
+					Name:     tool.ParseTargetGroupName(*tg),
+					Detail:   asg,
+				})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
 // ValidateCanaryDeployment validates if configuration is right for canary deployment
 func (c *Canary) ValidateCanaryDeployment(config schemas.Config, region string) error {
 	if c.Deployer.DeploymentFlag[region] != constants.CanaryDeployment && config.CompleteCanary {
 		return errors.New("you cannot complete canary deployment before start canary before")
 	}
 
+	if c.IsProgressive() && config.CompleteCanary {
+		return errors.New("progressive canary is completed automatically, so you cannot complete it manually")
+	}
+
 	return nil
 }
 
@@ -707,7 +966,7 @@ func (c *Canary) ReduceOriginalAutoscalingGroupCount(region schemas.RegionConfig
 	}
 
 	c.Logger.Debugf("Reduce size of autoscaling group by one instance: %s / %s", c.LatestAsg[region.Region], region.Region)
-	c.Slack.SendSimpleMessage(fmt.Sprintf("Reducing the size of autoscaling group by 1 : %s / %s", c.LatestAsg[region.Region], region.Region))
+	c.Notifier.NotifyRegion(constants.NotificationProgress, region.Region, fmt.Sprintf("Reducing the size of autoscaling group by 1 : %s / %s", c.LatestAsg[region.Region], region.Region))
 	changedCapacity.Desired--
 	if changedCapacity.Desired < changedCapacity.Min {
 		changedCapacity.Min--
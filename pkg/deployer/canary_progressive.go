@@ -0,0 +1,418 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// IsProgressive returns whether canary shifts traffic gradually with weighted target groups
+func (c *Canary) IsProgressive() bool {
+	return c.Stack.ProgressiveCanary != nil
+}
+
+// RunProgressiveCanaryDeployment creates canary target group next to the original one and deploys the new autoscaling group to it
+func (c *Canary) RunProgressiveCanaryDeployment(config schemas.Config, region schemas.RegionConfig) (schemas.RegionConfig, error) {
+	if len(c.PrevAsgs[region.Region]) == 0 {
+		c.Logger.Infof("No previous version to shift traffic from, so deploy with original target groups: %s", region.Region)
+		return region, c.Deployer.Deploy(config, region)
+	}
+
+	client, err := selectClientFromList(c.AWSClients, region.Region)
+	if err != nil {
+		return region, err
+	}
+
+	targetGroups, err := c.GetAsgTargetGroups(c.LatestAsg[region.Region], region.Region)
+	if err != nil {
+		return region, err
+	}
+	canaryVersion := CheckCanaryVersion(targetGroups, region.Region)
+
+	tgDetail, err := c.DescribeTargetGroup(region.HealthcheckTargetGroup, region.Region)
+	if err != nil {
+		return region, err
+	}
+
+	lbs, err := client.ELBV2Service.GetLoadBalancerFromTG([]*string{tgDetail.TargetGroupArn})
+	if err != nil {
+		return region, err
+	}
+
+	if len(lbs) == 0 {
+		return region, fmt.Errorf("target group is not attached to any load balancer: %s", region.HealthcheckTargetGroup)
+	}
+
+	listeners, err := client.ELBV2Service.DescribeListeners(*lbs[0])
+	if err != nil {
+		return region, err
+	}
+
+	var listenerArns []string
+	for _, l := range listeners {
+		if isForwardingTo(l, *tgDetail.TargetGroupArn) {
+			listenerArns = append(listenerArns, *l.ListenerArn)
+		}
+	}
+
+	if len(listenerArns) == 0 {
+		return region, fmt.Errorf("no listener forwards to target group: %s", region.HealthcheckTargetGroup)
+	}
+
+	newTgName := c.GenerateCanaryTargetGroupName(canaryVersion)
+	tg, err := c.CopyTargetGroups(tgDetail, newTgName, region.Region)
+	if err != nil {
+		return region, err
+	}
+	c.Logger.Debugf("New target group is created: %s", *tg.TargetGroupName)
+
+	c.CanaryTraffic[region.Region] = &schemas.CanaryTraffic{
+		OriginalTargetGroup: *tgDetail.TargetGroupArn,
+		CanaryTargetGroup:   *tg.TargetGroupArn,
+		LoadBalancer:        *lbs[0],
+		Listeners:           listenerArns,
+	}
+
+	// canary target group has to belong to load balancer so that targets are health checked
+	if err := c.ShiftTraffic(region.Region, 0); err != nil {
+		return region, err
+	}
+
+	region = c.ChangeTargetGroupInfo(newTgName, region)
+	if err := c.Deployer.Deploy(config, region); err != nil {
+		return region, err
+	}
+
+	return region, nil
+}
+
+// ShiftTraffic forwards weight percent of traffic to canary target group and the rest to original target group
+func (c *Canary) ShiftTraffic(region string, weight int64) error {
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	traffic := c.CanaryTraffic[region]
+	weights := map[string]int64{
+		traffic.OriginalTargetGroup: 100 - weight,
+		traffic.CanaryTargetGroup:   weight,
+	}
+
+	for _, l := range traffic.Listeners {
+		if err := client.ELBV2Service.ModifyListenerWeights(eaws.String(l), weights); err != nil {
+			return err
+		}
+	}
+	traffic.Weight = weight
+
+	c.Logger.Infof("[%s]Shifted %d%% of traffic to canary target group: %s", region, weight, tool.ParseTargetGroupName(traffic.CanaryTargetGroup))
+	return nil
+}
+
+// RestoreTraffic forwards the whole traffic to original target group only
+func (c *Canary) RestoreTraffic(region string) error {
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	traffic := c.CanaryTraffic[region]
+	for _, l := range traffic.Listeners {
+		if err := client.ELBV2Service.ModifyListener(eaws.String(l), traffic.OriginalTargetGroup); err != nil {
+			return err
+		}
+	}
+	traffic.Weight = 0
+
+	c.Logger.Infof("[%s]Listeners forward to original target group only: %s", region, tool.ParseTargetGroupName(traffic.OriginalTargetGroup))
+	return nil
+}
+
+// AnalyzeCanary compares metrics of canary target group during the step with thresholds
+func (c *Canary) AnalyzeCanary(region string, startTime, endTime time.Time) error {
+	client, err := selectClientFromList(c.AWSClients, region)
+	if err != nil {
+		return err
+	}
+
+	traffic := c.CanaryTraffic[region]
+	metrics, err := client.CloudWatchService.GetTargetGroupMetrics(traffic.CanaryTargetGroup, traffic.LoadBalancer, startTime, endTime)
+	if err != nil {
+		return err
+	}
+	c.Logger.Infof("[%s]Canary metrics - requests: %.0f, 5xx: %.0f, response time: %.3fs", region, metrics.RequestCount, metrics.Target5xxCount, metrics.ResponseTime)
+
+	analysis := c.Stack.ProgressiveCanary.Analysis
+	if metrics.RequestCount < float64(analysis.MinRequestCount) {
+		return fmt.Errorf("not enough requests to analyze canary: %.0f < %d", metrics.RequestCount, analysis.MinRequestCount)
+	}
+
+	if analysis.Max5xxRate > 0 && metrics.RequestCount > 0 {
+		rate := metrics.Target5xxCount / metrics.RequestCount * 100
+		if rate > analysis.Max5xxRate {
+			return fmt.Errorf("5xx rate exceeds threshold: %.2f%% > %.2f%%", rate, analysis.Max5xxRate)
+		}
+	}
+
+	if analysis.MaxLatency > 0 && metrics.ResponseTime > analysis.MaxLatency {
+		return fmt.Errorf("response time exceeds threshold: %.3fs > %.3fs", metrics.ResponseTime, analysis.MaxLatency)
+	}
+
+	return nil
+}
+
+// ProgressCanary shifts traffic step by step and aborts canary if analysis of any step fails
+func (c *Canary) ProgressCanary(config schemas.Config) error {
+	for _, step := range c.Stack.ProgressiveCanary.Steps {
+		var regions []string
+		for region, traffic := range c.CanaryTraffic {
+			if config.Region != "" && config.Region != region {
+				continue
+			}
+
+			// steps which are already passed before resuming are skipped
+			if traffic.Weight < step.Weight {
+				regions = append(regions, region)
+			}
+		}
+
+		if len(regions) == 0 {
+			continue
+		}
+
+		isTimeout, _ := tool.CheckTimeout(config.StartTimestamp, config.Timeout)
+		if isTimeout {
+			return c.AbortCanary(regions, fmt.Errorf("timeout has been exceeded : %.0f minutes", config.Timeout.Minutes()))
+		}
+
+		for _, region := range regions {
+			if err := c.ShiftTraffic(region, step.Weight); err != nil {
+				return c.AbortCanary(regions, err)
+			}
+		}
+		c.Notifier.Notify(constants.NotificationProgress, fmt.Sprintf("Shifted %d%% of traffic to canary : %s", step.Weight, c.Stack.Stack))
+
+		startTime := time.Now()
+		time.Sleep(step.Pause)
+
+		for _, region := range regions {
+			if err := c.AnalyzeCanary(region, startTime, time.Now()); err != nil {
+				return c.AbortCanary(regions, fmt.Errorf("canary analysis failed at %d%%: %s", step.Weight, err.Error()))
+			}
+		}
+	}
+
+	// health checking is done with the original target groups from now on
+	for region := range c.CanaryTraffic {
+		delete(c.healthCheckTargetGroups, region)
+	}
+
+	return nil
+}
+
+// CanaryAbortedError means that progressive canary is aborted and the previous version serves the whole traffic again
+type CanaryAbortedError struct {
+	Reason error
+}
+
+// Error returns the reason of abort
+func (e *CanaryAbortedError) Error() string {
+	return e.Reason.Error()
+}
+
+// AbortCanary restores traffic to original target groups and returns the reason as CanaryAbortedError
+func (c *Canary) AbortCanary(regions []string, reason error) error {
+	c.Logger.Errorf("Abort canary: %s", reason.Error())
+	c.Notifier.Notify(constants.NotificationFailed, fmt.Sprintf("Canary is aborted : %s", reason.Error()))
+
+	for _, region := range regions {
+		if err := c.RestoreTraffic(region); err != nil {
+			c.Logger.Errorf("failed to restore traffic: %s", err.Error())
+		}
+	}
+
+	return &CanaryAbortedError{Reason: reason}
+}
+
+// PromoteCanary makes original target groups serve the whole traffic and removes canary target group
+func (c *Canary) PromoteCanary(config schemas.Config) error {
+	for _, region := range c.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			continue
+		}
+
+		asg := c.AsgNames[region.Region]
+		if traffic, ok := c.CanaryTraffic[region.Region]; ok {
+			if err := c.RestoreTraffic(region.Region); err != nil {
+				return err
+			}
+
+			client, err := selectClientFromList(c.AWSClients, region.Region)
+			if err != nil {
+				return err
+			}
+
+			if err := client.EC2Service.DetachLoadBalancerTargetGroup(asg, []*string{eaws.String(traffic.CanaryTargetGroup)}); err != nil {
+				return err
+			}
+
+			if err := client.ELBV2Service.DeleteTargetGroup(eaws.String(traffic.CanaryTargetGroup)); err != nil {
+				return err
+			}
+			c.Logger.Debugf("Deleted canary target group: %s", traffic.CanaryTargetGroup)
+		}
+
+		if err := c.RemoveCanaryTag(asg, region); err != nil {
+			return err
+		}
+	}
+
+	c.Notifier.Notify(constants.NotificationHealthy, fmt.Sprintf("Canary is promoted : %s", c.Stack.Stack))
+	return nil
+}
+
+// Rollback restores traffic of progressive canary and deletes the new autoscaling group with canary target group.
+// Canary load balancer, security groups and target group of canary which is not progressive are left because
+// other canary versions may share them. They are removed by deployment with --complete-canary.
+func (c *Canary) Rollback(config schemas.Config) error {
+	for region := range c.CanaryTraffic {
+		if err := c.RestoreTraffic(region); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Deployer.Rollback(config); err != nil {
+		return err
+	}
+
+	for region, traffic := range c.CanaryTraffic {
+		client, err := selectClientFromList(c.AWSClients, region)
+		if err != nil {
+			return err
+		}
+
+		if err := client.ELBV2Service.DeleteTargetGroup(eaws.String(traffic.CanaryTargetGroup)); err != nil {
+			return err
+		}
+		delete(c.CanaryTraffic, region)
+	}
+
+	if !c.IsProgressive() {
+		c.Logger.Warnf("Canary load balancer and security groups are left until canary is completed: %s", c.Stack.Stack)
+	}
+
+	return nil
+}
+
+// PlanProgressiveCanary returns changes that progressive canary would make in the region
+func (c *Canary) PlanProgressiveCanary(config schemas.Config, region schemas.RegionConfig) ([]schemas.PlanChange, error) {
+	if len(c.PrevAsgs[region.Region]) == 0 {
+		return c.Deployer.PlanDeployment(config, region)
+	}
+
+	var changes []schemas.PlanChange
+	targetGroups, err := c.GetAsgTargetGroups(c.LatestAsg[region.Region], region.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	newTgName := c.GenerateCanaryTargetGroupName(CheckCanaryVersion(targetGroups, region.Region))
+	var weights []string
+	for _, step := range c.Stack.ProgressiveCanary.Steps {
+		weights = append(weights, fmt.Sprintf("%d%%", step.Weight))
+	}
+
+	changes = append(changes,
+		schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanCreate,
+			Resource: "target group",
+			Name:     newTgName,
+			Detail:   fmt.Sprintf("copy of %s", region.HealthcheckTargetGroup),
+		},
+		schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanAttach,
+			Resource: "listener",
+			Name:     newTgName,
+			Detail:   fmt.Sprintf("weighted forward with %s: %s", region.HealthcheckTargetGroup, strings.Join(weights, " -> ")),
+		},
+	)
+
+	canaryRegion := region
+	canaryRegion.HealthcheckTargetGroup = newTgName
+	canaryRegion.TargetGroups = []string{newTgName}
+
+	deployment, err := c.Deployer.PlanDeployment(config, canaryRegion)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, deployment...)
+
+	for _, tg := range region.TargetGroups {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanAttach,
+			Resource: "target group",
+			Name:     tg,
+			Detail:   "after every canary step passes analysis",
+		})
+	}
+
+	changes = append(changes, schemas.PlanChange{
+		Region:   region.Region,
+		Action:   constants.PlanDelete,
+		Resource: "target group",
+		Name:     newTgName,
+		Detail:   "after canary is promoted",
+	})
+
+	return append(changes, c.Deployer.PlanCleaning(region.Region)...), nil
+}
+
+// isForwardingTo checks if default action of listener forwards to the target group
+func isForwardingTo(listener *elbv2.Listener, tgArn string) bool {
+	for _, action := range listener.DefaultActions {
+		if action.Type == nil || *action.Type != "forward" {
+			continue
+		}
+
+		if action.TargetGroupArn != nil && *action.TargetGroupArn == tgArn {
+			return true
+		}
+
+		if action.ForwardConfig != nil {
+			for _, tg := range action.ForwardConfig.TargetGroups {
+				if *tg.TargetGroupArn == tgArn {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
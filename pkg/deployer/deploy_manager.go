@@ -17,6 +17,7 @@ limitations under the license.
 package deployer
 
 import (
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
 )
 
@@ -26,9 +27,54 @@ type DeployManager interface {
 	Deploy(config schemas.Config) error
 	HealthChecking(config schemas.Config) error
 	FinishAdditionalWork(config schemas.Config) error
+	Verify(config schemas.Config) error
 	CleanPreviousVersion(config schemas.Config) error
 	TriggerLifecycleCallbacks(config schemas.Config) error
 	CleanChecking(config schemas.Config) error
 	GatherMetrics(config schemas.Config) error
 	RunAPITest(config schemas.Config) error
+	Plan(config schemas.Config) (schemas.Plan, error)
+	Rollback(config schemas.Config) error
+	ExportState(config schemas.Config) schemas.DeploymentState
+	ImportState(state schemas.DeploymentState)
+}
+
+// Step is a single step of deployment
+type Step struct {
+	// Name of step used in logs
+	Name string
+
+	// Step status which is marked when the step is finished
+	Status int64
+
+	// Function of deploy manager for the step
+	Run func(config schemas.Config) error
+}
+
+// Steps returns the whole steps of deployment in order
+func Steps(d DeployManager) []Step {
+	return []Step{
+		{Name: "StepCheckPrevious", Status: constants.StepCheckPrevious, Run: d.CheckPreviousResources},
+		{Name: "StepDeploy", Status: constants.StepDeploy, Run: d.Deploy},
+		{Name: "StepHealthCheck", Status: constants.StepAdditionalWork, Run: d.HealthChecking},
+		{Name: "StepFinishAdditionalWork", Status: constants.StepAdditionalWork, Run: d.FinishAdditionalWork},
+		{Name: "StepVerify", Status: constants.StepVerify, Run: d.Verify},
+		{Name: "StepTriggerLifecycleCallbacks", Status: constants.StepTriggerLifecycleCallback, Run: d.TriggerLifecycleCallbacks},
+		{Name: "StepCleanPreviousVersion", Status: constants.StepCleanPreviousVersion, Run: d.CleanPreviousVersion},
+		{Name: "StepCleanChecking", Status: constants.StepCleanChecking, Run: d.CleanChecking},
+		{Name: "StepGatherMetrics", Status: constants.StepGatherMetrics, Run: d.GatherMetrics},
+		{Name: "StepRunAPITest", Status: constants.StepRunAPI, Run: d.RunAPITest},
+	}
+}
+
+// RemainingSteps returns steps of deployment from the first unfinished one
+func RemainingSteps(d DeployManager) []Step {
+	steps := Steps(d)
+	for i, step := range steps {
+		if !d.GetDeployer().StepStatus[step.Status] {
+			return steps[i:]
+		}
+	}
+
+	return nil
 }
@@ -18,10 +18,12 @@ package deployer
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"html/template"
-	"os"
+	"io"
 	"regexp"
 	"strings"
 	"sync"
@@ -32,6 +34,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/olekukonko/tablewriter"
 	Logger "github.com/sirupsen/logrus"
 	vegeta "github.com/tsenart/vegeta/lib"
@@ -41,8 +44,8 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
-	"github.com/DevopsArtFactory/goployer/pkg/slack"
 	"github.com/DevopsArtFactory/goployer/pkg/templates"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
@@ -63,11 +66,20 @@ type Deployer struct {
 	APITestTemplate   *schemas.APITestTemplate
 	AWSClients        []aws.Client
 	LocalProvider     builder.UserdataProvider
-	Slack             slack.Slack
+	Notifier          notifier.Notifier
 	AppliedCapacity   *schemas.Capacity
 	Collector         collector.Collector
+	Context           context.Context
 	StepStatus        map[int64]bool
 	DeploymentFlag    map[string]string
+	HealthyCount      map[string]int64
+	APITestResult     *schemas.APITestResult
+
+	healthPolicyStates    map[string]*healthPolicyState
+	scalingActivityStates map[string]*scalingActivityState
+
+	// target groups which are health checked instead of the ones in manifest
+	healthCheckTargetGroups map[string]string
 }
 
 type APIAttacker struct {
@@ -78,6 +90,26 @@ type APIAttacker struct {
 	Targets  []vegeta.Target
 }
 
+// BootstrapAWSClients creates aws clients of each region with the assume role of the stack
+func BootstrapAWSClients(h *helper.DeployerHelper) []aws.Client {
+	role := aws.AssumeRoleConfig{
+		RoleArn:     h.Stack.AssumeRole,
+		ExternalID:  h.Stack.ExternalID,
+		SessionName: h.Stack.SessionName,
+	}
+
+	var awsClients []aws.Client
+	for _, region := range h.Stack.Regions {
+		if len(h.Region) > 0 && h.Region != region.Region {
+			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
+			continue
+		}
+		awsClients = append(awsClients, aws.BootstrapServicesWithRole(region.Region, role))
+	}
+
+	return awsClients
+}
+
 // InitDeploymentConfiguration returns initialized configurations for Deployer
 func InitDeploymentConfiguration(h *helper.DeployerHelper, awsClients []aws.Client) Deployer {
 	return Deployer{
@@ -94,14 +126,34 @@ func InitDeploymentConfiguration(h *helper.DeployerHelper, awsClients []aws.Clie
 		SecurityGroup:     map[string]*string{},
 		DeploymentFlag:    map[string]string{},
 		LatestAsg:         map[string]string{},
+		HealthyCount:      map[string]int64{},
 		Stack:             h.Stack,
-		Slack:             h.Slack,
+		Notifier:          h.Notifier,
 		Collector:         h.Collector,
+		Context:           h.Context,
 		AppliedCapacity:   nil,
 		StepStatus:        helper.InitStartStatus(),
 	}
 }
 
+// setHealthCheckTargetGroup makes health checking of the region use the target group until it is unset
+func (d *Deployer) setHealthCheckTargetGroup(region, tg string) {
+	if d.healthCheckTargetGroups == nil {
+		d.healthCheckTargetGroups = map[string]string{}
+	}
+
+	d.healthCheckTargetGroups[region] = tg
+}
+
+// healthCheckRegion returns copy of region config with the target group which is health checked now
+func (d *Deployer) healthCheckRegion(region schemas.RegionConfig) schemas.RegionConfig {
+	if tg, ok := d.healthCheckTargetGroups[region.Region]; ok {
+		region.HealthcheckTargetGroup = tg
+	}
+
+	return region
+}
+
 // Polling is polling healthy information from instance/target group
 func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group, client aws.Client, forceManifestCapacity, isUpdate, downsizingUpdate bool) (bool, error) {
 	if asg.AutoScalingGroupName == nil {
@@ -110,7 +162,7 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 
 	threshold := d.AppliedCapacity.Desired
 
-	if region.HealthcheckTargetGroup == "" && region.HealthcheckLB == "" {
+	if region.HealthcheckTargetGroup == "" && region.HealthcheckLB == "" && !d.HasHealthCheckers() {
 		d.Logger.Info("health check skipped because of neither target group nor classic load balancer specified")
 		return true, nil
 	}
@@ -119,11 +171,6 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 	var err error
 	validHostCount := int64(0)
 
-	if len(region.HealthcheckLB) == 0 && len(region.HealthcheckTargetGroup) == 0 {
-		Logger.Infof("No health check target for this autoscaling group: %s / %s", *asg.AutoScalingGroupName, region.Region)
-		return true, nil
-	}
-
 	d.Logger.Debugf("[Checking healthy host count] Autoscaling Group: %s", *asg.AutoScalingGroupName)
 	if len(region.HealthcheckTargetGroup) > 0 {
 		var healthCheckTargetGroupArn *string
@@ -149,9 +196,16 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 		if err != nil {
 			return false, err
 		}
+	} else {
+		targetHosts = autoscalingHosts(asg, isUpdate, downsizingUpdate)
+	}
+
+	if d.HasHealthCheckers() {
+		targetHosts = d.CheckHosts(client, targetHosts)
 	}
 
 	validHostCount = d.GetValidHostCount(targetHosts)
+	d.HealthyCount[region.Region] = validHostCount
 
 	if isUpdate {
 		if validHostCount == threshold {
@@ -160,14 +214,18 @@ func (d *Deployer) Polling(region schemas.RegionConfig, asg *autoscaling.Group,
 		}
 		d.Logger.Infof("Desired count does not meet the requirement: %d/%d", validHostCount, threshold)
 	} else {
+		if d.Stack.HealthPolicy != nil {
+			return d.ApplyHealthPolicy(region.Region, asg, client, targetHosts, validHostCount)
+		}
+
 		if validHostCount >= threshold {
 			d.Logger.Infof("Healthy Count for %s : %d/%d", d.AsgNames[region.Region], validHostCount, threshold)
-			d.Slack.SendSimpleMessage(fmt.Sprintf("All instances are healthy in %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
+			d.Notifier.NotifyRegion(constants.NotificationHealthy, region.Region, fmt.Sprintf("All instances are healthy in %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
 			return true, nil
 		}
 
 		d.Logger.Infof("Healthy count does not meet the requirement(%s) : %d/%d", d.AsgNames[region.Region], validHostCount, threshold)
-		d.Slack.SendSimpleMessage(fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
+		d.Notifier.NotifyRegion(constants.NotificationProgress, region.Region, fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region.Region], validHostCount, threshold))
 	}
 	return false, nil
 }
@@ -181,7 +239,7 @@ func (d *Deployer) CheckTerminating(client aws.Client, target string, disableMet
 	}
 
 	if done {
-		d.Slack.SendSimpleMessage(fmt.Sprintf(":+1: All instances are deleted : %s", target))
+		d.Notifier.NotifyRegion(constants.NotificationCleaned, client.Region, fmt.Sprintf(":+1: All instances are deleted : %s", target))
 	} else {
 		return false
 	}
@@ -202,7 +260,7 @@ func (d *Deployer) CheckAutoscalingInstanceCount(client aws.Client, asg string,
 
 	if len(asgInfo.Instances) > desired {
 		d.Logger.Infof("still terminating<< desired: %d, current: %d: %s", desired, len(asgInfo.Instances), asg)
-		d.Slack.SendSimpleMessage(fmt.Sprintf("Still found %d instance to delete : %s", len(asgInfo.Instances)-desired, asg))
+		d.Notifier.NotifyRegion(constants.NotificationProgress, client.Region, fmt.Sprintf("Still found %d instance to delete : %s", len(asgInfo.Instances)-desired, asg))
 
 		return false, nil
 	}
@@ -224,7 +282,7 @@ func (d *Deployer) CleanAutoscalingSet(client aws.Client, target string) error {
 // ResizingAutoScalingGroupCount set autoscaling group instance count to 0
 func (d *Deployer) ResizingAutoScalingGroupCount(client aws.Client, asg string, count int64) error {
 	d.Logger.Info(fmt.Sprintf("Modifying the size of autoscaling group to %d : %s(%s)", count, asg, d.Stack.Stack))
-	d.Slack.SendSimpleMessage(fmt.Sprintf("Modifying the size of autoscaling group to %d : %s/%s", count, asg, d.Stack.Stack))
+	d.Notifier.NotifyRegion(constants.NotificationProgress, client.Region, fmt.Sprintf("Modifying the size of autoscaling group to %d : %s/%s", count, asg, d.Stack.Stack))
 
 	retry := int64(3)
 	var err error
@@ -247,21 +305,141 @@ func (d *Deployer) ResizingAutoScalingGroupCount(client aws.Client, asg string,
 	return nil
 }
 
-// RunLifecycleCallbacks runs commands before terminating.
-func (d *Deployer) RunLifecycleCallbacks(client aws.Client, region string) bool {
+// RunLifecycleCallbacks runs commands in previous instances before terminating and waits for the results.
+// Failures are handled with the failure policy of lifecycle callbacks.
+func (d *Deployer) RunLifecycleCallbacks(client aws.Client, region string, interval time.Duration) error {
 	targets := d.PrevInstances[region]
 	if len(targets) == 0 {
 		d.Logger.Debugf("no target instance exists\n")
-		return false
+		return nil
 	}
 
-	commands := d.Stack.LifecycleCallbacks.PreTerminatePastClusters
+	lc := d.Stack.LifecycleCallbacks
+	timeout := lc.Timeout
+	if timeout == 0 {
+		timeout = constants.DefaultLifecycleCallbackTimeout
+	}
 
-	d.Logger.Debugf("run lifecycle callbacks before termination : %s", targets)
-	return client.SSMService.SendCommand(
-		eaws.StringSlice(targets),
-		eaws.StringSlice(commands),
-	)
+	attempts := int64(1)
+	if lc.FailurePolicy == constants.LifecycleCallbackRetry {
+		retries := lc.Retries
+		if retries == 0 {
+			retries = constants.DefaultLifecycleCallbackRetries
+		}
+		attempts += retries
+	}
+
+	var failed []string
+	for attempt := int64(1); attempt <= attempts && len(targets) > 0; attempt++ {
+		d.Logger.Debugf("run lifecycle callbacks before termination(%d/%d) : %s", attempt, attempts, targets)
+		results, err := d.RunCommand(client, targets, lc.PreTerminatePastClusters, timeout, interval)
+		if err != nil {
+			return err
+		}
+		d.reportCommandResults(region, results)
+
+		failed = []string{}
+		for _, result := range results {
+			if !result.Succeeded() {
+				failed = append(failed, result.InstanceID)
+			}
+		}
+		targets = failed
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	err := fmt.Errorf("lifecycle callbacks failed in %s : %s", region, strings.Join(failed, ","))
+	if lc.FailurePolicy == constants.LifecycleCallbackContinue {
+		d.Logger.Warnf("%s, but cleaning continues", err.Error())
+		return nil
+	}
+
+	return err
+}
+
+// RunCommand sends commands to instances and waits until they are finished in every instance or timeout
+func (d *Deployer) RunCommand(client aws.Client, targets, commands []string, timeout, interval time.Duration) ([]aws.CommandInvocation, error) {
+	commandID, err := client.SSMService.SendCommand(eaws.StringSlice(targets), eaws.StringSlice(commands), timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]aws.CommandInvocation{}
+	deadline := time.Now().Add(timeout)
+	for {
+		done := true
+		for _, instance := range targets {
+			if results[instance].Done() {
+				continue
+			}
+
+			invocation, err := client.SSMService.GetCommandInvocation(commandID, instance)
+			if err != nil {
+				return nil, err
+			}
+			results[instance] = invocation
+			done = done && invocation.Done()
+		}
+
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(interval)
+	}
+
+	var ret []aws.CommandInvocation
+	for _, instance := range targets {
+		result := results[instance]
+		if !result.Done() {
+			result.InstanceID = instance
+			result.Status = ssm.CommandInvocationStatusTimedOut
+		}
+		ret = append(ret, result)
+	}
+
+	return ret, nil
+}
+
+// reportCommandResults prints results of instances and sends them to notification
+func (d *Deployer) reportCommandResults(region string, results []aws.CommandInvocation) {
+	succeeded := 0
+	lines := []string{}
+	for _, result := range results {
+		if result.Succeeded() {
+			succeeded++
+			d.Logger.Infof("lifecycle callbacks succeeded : %s", result.InstanceID)
+		} else {
+			d.Logger.Errorf("lifecycle callbacks %s with code %d : %s", strings.ToLower(result.Status), result.ResponseCode, result.InstanceID)
+		}
+
+		if len(result.Stdout) > 0 {
+			d.Logger.Debugf("[%s] stdout: %s", result.InstanceID, result.Stdout)
+		}
+		if len(result.Stderr) > 0 {
+			d.Logger.Warnf("[%s] stderr: %s", result.InstanceID, result.Stderr)
+		}
+
+		line := fmt.Sprintf("%s : %s(%d)", result.InstanceID, result.Status, result.ResponseCode)
+		if !result.Succeeded() && len(result.Stderr) > 0 {
+			line = fmt.Sprintf("%s %s", line, lastLine(result.Stderr))
+		}
+		lines = append(lines, line)
+	}
+
+	event := constants.NotificationProgress
+	if succeeded < len(results) {
+		event = constants.NotificationFailed
+	}
+	d.Notifier.NotifyRegion(event, region, fmt.Sprintf("Lifecycle callbacks are finished in %d/%d instances : %s\n%s", succeeded, len(results), region, strings.Join(lines, "\n")))
+}
+
+// lastLine returns the last line of output which is usually the cause of failure
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
 }
 
 // selectClientFromList get aws client.
@@ -281,14 +459,19 @@ func (d *Deployer) GatherMetrics(client aws.Client, asg string) error {
 		return err
 	}
 
-	if len(targetGroups) == 0 {
+	var lbs []*string
+	if len(targetGroups) > 0 {
+		lbs, err = client.ELBV2Service.GetLoadBalancerFromTG(targetGroups)
+		if err != nil {
+			return err
+		}
+	} else {
 		d.Logger.Warnf("this autoscaling group does not belong to any target group ")
-		return nil
-	}
 
-	lbs, err := client.ELBV2Service.GetLoadBalancerFromTG(targetGroups)
-	if err != nil {
-		return err
+		// user-defined metrics like cpu do not need target groups
+		if len(d.Collector.MetricConfig.Metrics.Custom) == 0 {
+			return nil
+		}
 	}
 
 	d.Logger.Debugf("start retrieving additional metrics")
@@ -309,16 +492,27 @@ func (d *Deployer) GatherMetrics(client aws.Client, asg string) error {
 // GetValidHostCount return the number of health host
 func (d *Deployer) GetValidHostCount(targetHosts []aws.HealthcheckHost) int64 {
 	ret := 0
+	checks := false
+	for _, host := range targetHosts {
+		if len(host.Checks) > 0 {
+			checks = true
+		}
+	}
+
 	var data [][]string
 	for _, host := range targetHosts {
-		data = append(data, []string{host.InstanceID, host.LifecycleState, host.TargetStatus, host.HealthStatus, fmt.Sprintf("%t", host.Valid)})
+		row := []string{host.InstanceID, host.LifecycleState, host.TargetStatus, host.HealthStatus}
+		if checks {
+			row = append(row, host.Checks)
+		}
+		data = append(data, append(row, fmt.Sprintf("%t", host.Valid)))
 		if host.Valid {
 			ret++
 		}
 	}
 
 	if len(data) > 0 {
-		printCurrentHostStatus(data)
+		printCurrentHostStatus(d.Logger.Out, data, checks)
 	}
 
 	return int64(ret)
@@ -426,10 +620,15 @@ func (a APIAttacker) Print(metrics []schemas.MetricResult) (string, error) {
 	return str, nil
 }
 
-// printCurrentHostStatus shows current instance status
-func printCurrentHostStatus(data [][]string) {
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Instance ID", "Lifecycle State", "Target Status", "Health Status", "Valid"})
+// printCurrentHostStatus shows current instance status where logs are written
+func printCurrentHostStatus(out io.Writer, data [][]string, checks bool) {
+	header := []string{"Instance ID", "Lifecycle State", "Target Status", "Health Status"}
+	if checks {
+		header = append(header, "Checks")
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader(append(header, "Valid"))
 	table.SetCenterSeparator("|")
 	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
@@ -511,7 +710,8 @@ func (d *Deployer) CheckPrevious(config schemas.Config) error {
 				}
 			}
 
-			if d.Mode != constants.CanaryDeployment || isBeingCanaryDeployed || config.CompleteCanary {
+			// progressive canary replaces every previous version like blue/green
+			if d.Mode != constants.CanaryDeployment || isBeingCanaryDeployed || config.CompleteCanary || d.Stack.ProgressiveCanary != nil {
 				prevAsgs = append(prevAsgs, *asgGroup.AutoScalingGroupName)
 			}
 
@@ -584,6 +784,18 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 		return err
 	}
 
+	// recorded userdata of previous version is already rendered
+	if _, ok := d.LocalProvider.(builder.StaticProvider); !ok && builder.MergeUserdata(d.Stack.Userdata, d.AwsConfig.Userdata).Template {
+		userdata, err = builder.RenderUserdata(userdata, d.userdataVariables(config, region.Region, ami, newAsgName))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := builder.ValidateUserdataSize(userdata); err != nil {
+		return err
+	}
+
 	//Stack check
 	securityGroups, err := client.EC2Service.GetSecurityGroupList(region.VPC, region.SecurityGroups)
 	if err != nil {
@@ -738,7 +950,7 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 			additionalFields["userdata"] = userdata
 		}
 
-		if err := d.Collector.StampDeployment(d.Stack, config, tags, newAsgName, "creating", additionalFields); err != nil {
+		if err := d.Collector.StampDeployment(d.AwsConfig.Name, d.Stack, config, tags, newAsgName, "creating", additionalFields); err != nil {
 			d.Logger.Error(err.Error())
 		}
 	}
@@ -749,9 +961,175 @@ func (d *Deployer) Deploy(config schemas.Config, region schemas.RegionConfig) er
 	return nil
 }
 
+// Plan returns changes of resources that deployment would make without modifying anything
+func (d *Deployer) Plan(config schemas.Config) (schemas.Plan, error) {
+	plan := schemas.Plan{
+		Stack: d.Stack.Stack,
+		Mode:  d.Mode,
+	}
+
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debug("This region is skipped by user : " + region.Region)
+			continue
+		}
+
+		changes, err := d.PlanDeployment(config, region)
+		if err != nil {
+			return plan, err
+		}
+
+		plan.Changes = append(plan.Changes, changes...)
+		plan.Changes = append(plan.Changes, d.PlanCleaning(region.Region)...)
+	}
+
+	return plan, nil
+}
+
+// PlanDeployment returns resources that Deploy and DoCommonAdditionalWork would create in the region
+func (d *Deployer) PlanDeployment(config schemas.Config, region schemas.RegionConfig) ([]schemas.PlanChange, error) {
+	var changes []schemas.PlanChange
+
+	prefix := tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region.Region)
+	newAsgName := tool.GenerateAsgName(prefix, getCurrentVersion(d.PrevVersions[region.Region]))
+
+	ami := region.AmiID
+	if len(config.Ami) > 0 {
+		ami = config.Ami
+	}
+
+	instanceType := region.InstanceType
+	if len(config.OverrideInstanceType) > 0 {
+		instanceType = config.OverrideInstanceType
+	}
+
+	appliedCapacity, err := d.DecideCapacity(config.ForceManifestCapacity, config.CompleteCanary, region.Region, len(d.PrevAsgs[region.Region]), d.Stack.RollingUpdateInstanceCount)
+	if err != nil {
+		return nil, err
+	}
+
+	changes = append(changes,
+		schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanCreate,
+			Resource: "launch template",
+			Name:     tool.GenerateLcName(newAsgName),
+			Detail:   fmt.Sprintf("ami: %s, instance type: %s", ami, instanceType),
+		},
+		schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanCreate,
+			Resource: "autoscaling group",
+			Name:     newAsgName,
+			Detail:   fmt.Sprintf("min: %d, desired: %d, max: %d", appliedCapacity.Min, appliedCapacity.Desired, appliedCapacity.Max),
+		},
+	)
+
+	for _, tg := range d.GetTargetGroupNames(region) {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanAttach,
+			Resource: "target group",
+			Name:     tg,
+			Detail:   newAsgName,
+		})
+	}
+
+	loadBalancers := region.LoadBalancers
+	if region.HealthcheckLB != "" && !tool.IsStringInArray(region.HealthcheckLB, loadBalancers) {
+		loadBalancers = append(loadBalancers, region.HealthcheckLB)
+	}
+
+	for _, lb := range loadBalancers {
+		changes = append(changes, schemas.PlanChange{
+			Region:   region.Region,
+			Action:   constants.PlanAttach,
+			Resource: "load balancer",
+			Name:     lb,
+			Detail:   newAsgName,
+		})
+	}
+
+	if len(d.Stack.Autoscaling) > 0 {
+		for _, policy := range d.Stack.Autoscaling {
+			changes = append(changes, schemas.PlanChange{
+				Region:   region.Region,
+				Action:   constants.PlanCreate,
+				Resource: "scaling policy",
+				Name:     policy.Name,
+				Detail:   newAsgName,
+			})
+		}
+
+		for _, alarm := range d.Stack.Alarms {
+			changes = append(changes, schemas.PlanChange{
+				Region:   region.Region,
+				Action:   constants.PlanCreate,
+				Resource: "cloudwatch alarm",
+				Name:     alarm.Name,
+				Detail:   newAsgName,
+			})
+		}
+	}
+
+	for _, sa := range d.AwsConfig.ScheduledActions {
+		if tool.IsStringInArray(sa.Name, region.ScheduledActions) {
+			changes = append(changes, schemas.PlanChange{
+				Region:   region.Region,
+				Action:   constants.PlanCreate,
+				Resource: "scheduled action",
+				Name:     sa.Name,
+				Detail:   newAsgName,
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// PlanCleaning returns previous autoscaling groups that would be drained and deleted in the region
+func (d *Deployer) PlanCleaning(region string) []schemas.PlanChange {
+	var changes []schemas.PlanChange
+	for _, asg := range d.PrevAsgs[region] {
+		if _, ok := d.LatestAsg[region]; ok && asg == d.LatestAsg[region] && d.Mode == constants.CanaryDeployment && d.Stack.ProgressiveCanary == nil {
+			continue
+		}
+
+		if d.Mode == constants.RollingUpdateDeployment {
+			changes = append(changes, schemas.PlanChange{
+				Region:   region,
+				Action:   constants.PlanResize,
+				Resource: "autoscaling group",
+				Name:     asg,
+				Detail:   fmt.Sprintf("decrease by %d instance(s) at a time", d.Stack.RollingUpdateInstanceCount),
+			})
+		}
+
+		if d.Mode == constants.BlueGreenDeployment && d.Stack.TerminationDelayRate > 0 {
+			changes = append(changes, schemas.PlanChange{
+				Region:   region,
+				Action:   constants.PlanResize,
+				Resource: "autoscaling group",
+				Name:     asg,
+				Detail:   fmt.Sprintf("decrease by %d%% of instances at a time", d.Stack.TerminationDelayRate),
+			})
+		}
+
+		changes = append(changes, schemas.PlanChange{
+			Region:   region,
+			Action:   constants.PlanDelete,
+			Resource: "autoscaling group",
+			Name:     asg,
+			Detail:   "with launch templates",
+		})
+	}
+
+	return changes
+}
+
 // DecideCapacity returns Applied Capacity for deployment
 func (d *Deployer) DecideCapacity(forceManifestCapacity, completeCanary bool, region string, prevAsgCount int, rollingUpdateInstanceCount int64) (schemas.Capacity, error) {
-	if prevAsgCount > 0 && NeedToInitializeCapacity(d.Mode, completeCanary) {
+	if prevAsgCount > 0 && NeedToInitializeCapacity(d.Mode, completeCanary) && d.Stack.ProgressiveCanary == nil {
 		instanceCnt := int64(1)
 		if d.Mode == constants.RollingUpdateDeployment {
 			instanceCnt = rollingUpdateInstanceCount
@@ -963,7 +1341,11 @@ func (d *Deployer) HealthChecking(config schemas.Config) (bool, error) {
 		}
 		d.Logger.Debugf("Health check target autoscaling group: %s / %s", region.Region, *asg.AutoScalingGroupName)
 
-		isHealthy, err := d.Polling(region, asg, client, config.ForceManifestCapacity, isUpdate, config.DownSizingUpdate)
+		if err := d.CheckScalingActivities(region.Region, asg, client, time.Unix(config.StartTimestamp, 0)); err != nil {
+			return false, err
+		}
+
+		isHealthy, err := d.Polling(d.healthCheckRegion(region), asg, client, config.ForceManifestCapacity, isUpdate, config.DownSizingUpdate)
 		if err != nil {
 			return false, err
 		}
@@ -1046,6 +1428,128 @@ func (d *Deployer) DoCommonAdditionalWork(config schemas.Config) error {
 	return nil
 }
 
+// Verify observes metrics of the new deployment and fails if any of them is breached
+func (d *Deployer) Verify(config schemas.Config) error {
+	if !d.StepStatus[constants.StepAdditionalWork] {
+		return nil
+	}
+
+	if d.Stack.Verification == nil || len(d.Stack.Verification.Metrics) == 0 {
+		d.StepStatus[constants.StepVerify] = true
+		return nil
+	}
+
+	d.Logger.Infof("Observe metrics of new deployment for %s: %s", d.Stack.Verification.Window, d.GetStackName())
+	startTime := time.Now()
+	if err := d.waitVerificationWindow(config); err != nil {
+		return err
+	}
+	endTime := time.Now()
+
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user : %s", region.Region)
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range d.Stack.Verification.Metrics {
+			dimensions := m.Dimensions
+			if len(dimensions) == 0 {
+				dimensions, err = d.getVerificationDimensions(client, m.Namespace, region)
+				if err != nil {
+					return err
+				}
+			}
+
+			value, err := client.CloudWatchService.GetMetricStatistic(m.Namespace, m.Metric, m.Statistic, dimensions, startTime, endTime)
+			if err != nil {
+				return err
+			}
+
+			if value == nil {
+				if m.TreatMissingData == constants.MissingDataNotBreaching {
+					d.Logger.Warnf("[%s]No datapoint of metric for verification: %s", region.Region, m.Name)
+					continue
+				}
+
+				d.Notifier.NotifyRegion(constants.NotificationFailed, region.Region, fmt.Sprintf("Metric verification is failed : %s / %s", m.Name, region.Region))
+				return fmt.Errorf("[%s]metric is breached: %s has no datapoint", region.Region, m.Name)
+			}
+			d.Logger.Infof("[%s]Metric for verification - %s: %.3f (threshold: %s %.3f)", region.Region, m.Name, *value, m.Comparison, m.Threshold)
+
+			if isMetricBreached(m.Comparison, *value, m.Threshold) {
+				d.Notifier.NotifyRegion(constants.NotificationFailed, region.Region, fmt.Sprintf("Metric verification is failed : %s / %s", m.Name, region.Region))
+				return fmt.Errorf("[%s]metric is breached: %s %.3f, %s %.3f", region.Region, m.Name, *value, m.Comparison, m.Threshold)
+			}
+		}
+	}
+
+	d.StepStatus[constants.StepVerify] = true
+	return nil
+}
+
+// waitVerificationWindow waits for the window of verification unless deployment is cancelled or timed out
+func (d *Deployer) waitVerificationWindow(config schemas.Config) error {
+	window := time.NewTimer(d.Stack.Verification.Window)
+	defer window.Stop()
+
+	var timeout <-chan time.Time
+	if config.StartTimestamp > 0 && config.Timeout > 0 {
+		t := time.NewTimer(time.Until(time.Unix(config.StartTimestamp, 0).Add(config.Timeout)))
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var cancelled <-chan struct{}
+	if d.Context != nil {
+		cancelled = d.Context.Done()
+	}
+
+	select {
+	case <-window.C:
+		return nil
+	case <-timeout:
+		return fmt.Errorf("timeout has been exceeded during verification : %.0f minutes", config.Timeout.Minutes())
+	case <-cancelled:
+		return fmt.Errorf("deployment is cancelled during verification: %s", d.GetStackName())
+	}
+}
+
+// getVerificationDimensions returns default dimensions of the new deployment for the namespace
+func (d *Deployer) getVerificationDimensions(client aws.Client, namespace string, region schemas.RegionConfig) (map[string]string, error) {
+	if namespace != constants.ApplicationELBNamespace {
+		return map[string]string{"AutoScalingGroupName": d.AsgNames[region.Region]}, nil
+	}
+
+	if len(region.HealthcheckTargetGroup) == 0 {
+		return nil, fmt.Errorf("no target group to verify metrics of %s", namespace)
+	}
+
+	tgs, err := client.ELBV2Service.GetTargetGroupARNs([]string{region.HealthcheckTargetGroup})
+	if err != nil {
+		return nil, err
+	}
+
+	lbs, err := client.ELBV2Service.GetLoadBalancerFromTG(tgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lbs) == 0 {
+		return nil, fmt.Errorf("target group is not attached to any load balancer: %s", region.HealthcheckTargetGroup)
+	}
+
+	return map[string]string{
+		"TargetGroup":  tool.GetTargetGroupDimension(*tgs[0]),
+		"LoadBalancer": tool.GetLoadBalancerDimension(*lbs[0]),
+	}, nil
+}
+
 // TriggerLifecycleCallbacks runs lifecycle callbacks before cleaning.
 func (d *Deployer) TriggerLifecycleCallbacks(config schemas.Config) error {
 	skipped := false
@@ -1076,10 +1580,12 @@ func (d *Deployer) TriggerLifecycleCallbacks(config schemas.Config) error {
 
 			if len(d.PrevInstances[region.Region]) > 0 {
 				d.Logger.Debugf("Run lifecycle callbacks: %s", d.PrevInstances[region.Region])
-				d.RunLifecycleCallbacks(client, region.Region)
+				if err := d.RunLifecycleCallbacks(client, region.Region, config.PollingInterval); err != nil {
+					return err
+				}
 			} else {
 				d.Logger.Debugf("No previous versions to be deleted : %s\n", region.Region)
-				d.Slack.SendSimpleMessage(fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
+				d.Notifier.NotifyRegion(constants.NotificationCleaned, region.Region, fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
 			}
 		}
 	}
@@ -1147,7 +1653,7 @@ func (d *Deployer) CleanPreviousAutoScalingGroup(config schemas.Config) error {
 			}
 		} else {
 			d.Logger.Infof("No previous versions to be deleted : %s", region.Region)
-			d.Slack.SendSimpleMessage(fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
+			d.Notifier.NotifyRegion(constants.NotificationCleaned, region.Region, fmt.Sprintf("No previous versions to be deleted : %s\n", region.Region))
 		}
 	}
 
@@ -1269,6 +1775,250 @@ func (d *Deployer) ClearResources(client aws.Client, target string, disableMetri
 	return true
 }
 
+// Rollback deletes the new autoscaling group and restores capacity of the previous one
+func (d *Deployer) Rollback(config schemas.Config) error {
+	rollbackStart := time.Now().Unix()
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user : %s", region.Region)
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		if latestAsg, ok := d.LatestAsg[region.Region]; ok && tool.IsStringInArray(latestAsg, d.PrevAsgs[region.Region]) {
+			capacity := d.PrevInstanceCount[region.Region]
+			d.Logger.Infof("[Rollback] restore capacity of previous autoscaling group: %s", latestAsg)
+			if err := d.ResizingAutoScalingGroup(latestAsg, region.Region, capacity); err != nil {
+				return err
+			}
+		}
+
+		// autoscaling group which existed before deployment should not be deleted
+		newAsg := d.AsgNames[region.Region]
+		if len(newAsg) == 0 || tool.IsStringInArray(newAsg, d.PrevAsgs[region.Region]) {
+			d.Logger.Debugf("No new autoscaling group to roll back : %s", region.Region)
+			continue
+		}
+
+		d.Logger.Infof("[Rollback] delete new autoscaling group: %s", newAsg)
+		if err := d.ResizingAutoScalingGroupCount(client, newAsg, 0); err != nil {
+			return err
+		}
+
+		done := false
+		for !done {
+			isTimeout, _ := tool.CheckTimeout(rollbackStart, config.Timeout)
+			if isTimeout {
+				return fmt.Errorf("timeout has been exceeded while rolling back : %s", newAsg)
+			}
+
+			done, err = d.CheckAutoscalingInstanceCount(client, newAsg, 0)
+			if err != nil {
+				return err
+			}
+
+			if !done {
+				time.Sleep(config.PollingInterval)
+			}
+		}
+
+		if !d.ClearResources(client, newAsg, true) {
+			return fmt.Errorf("failed to delete resources of autoscaling group: %s", newAsg)
+		}
+
+		if !config.DisableMetrics {
+			if err := d.Collector.UpdateStatus(newAsg, "rolledback", nil); err != nil {
+				d.Logger.Error(err.Error())
+			}
+		}
+
+		d.Notifier.NotifyRegion(constants.NotificationFailed, region.Region, fmt.Sprintf(":rewind: Deployment is rolled back : %s", newAsg))
+		delete(d.AsgNames, region.Region)
+	}
+
+	return nil
+}
+
+// RollbackToVersion brings back the previous version of autoscaling group instead of deploying a new one.
+// The previous version is resized to the current capacity if it still exists, or recreated with its deployment record.
+// The current version becomes the previous one so that it is drained by the cleaning steps.
+func (d *Deployer) RollbackToVersion(config schemas.Config) error {
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user : %s", region.Region)
+			continue
+		}
+
+		currentAsg, ok := d.LatestAsg[region.Region]
+		if !ok {
+			return fmt.Errorf("no autoscaling group exists to roll back in %s", region.Region)
+		}
+
+		targetVersion, err := GetRollbackVersion(tool.ParseAutoScalingVersion(currentAsg), config.ToVersion)
+		if err != nil {
+			return err
+		}
+
+		targetAsg := tool.GenerateAsgName(tool.BuildPrefixName(d.AwsConfig.Name, d.Stack.Env, region.Region), targetVersion)
+		if targetAsg == currentAsg {
+			return fmt.Errorf("version %d is already the current version: %s", targetVersion, currentAsg)
+		}
+
+		current, err := d.DescribeAutoScalingGroup(currentAsg, region.Region)
+		if err != nil {
+			return err
+		}
+
+		capacity := schemas.Capacity{
+			Min:     *current.MinSize,
+			Max:     *current.MaxSize,
+			Desired: *current.DesiredCapacity,
+		}
+
+		if isVersionExist(targetVersion, d.PrevVersions[region.Region]) {
+			d.Logger.Infof("[Rollback] restore capacity of autoscaling group: %s", targetAsg)
+			if err := d.ResizingAutoScalingGroup(targetAsg, region.Region, capacity); err != nil {
+				return err
+			}
+			d.AsgNames[region.Region] = targetAsg
+			d.AppliedCapacity = &capacity
+		} else if err := d.RecreateVersion(config, region, targetAsg, capacity); err != nil {
+			return err
+		}
+
+		var instanceIds []string
+		for _, instance := range current.Instances {
+			instanceIds = append(instanceIds, *instance.InstanceId)
+		}
+
+		d.PrevAsgs[region.Region] = []string{currentAsg}
+		d.PrevInstances[region.Region] = instanceIds
+		d.Notifier.NotifyRegion(constants.NotificationProgress, region.Region, fmt.Sprintf(":rewind: Rolling back from %s to %s", currentAsg, d.AsgNames[region.Region]))
+	}
+
+	d.StepStatus[constants.StepDeploy] = true
+	return nil
+}
+
+// RecreateVersion creates a new autoscaling group with the deployment record of the version which no longer exists
+func (d *Deployer) RecreateVersion(config schemas.Config, region schemas.RegionConfig, targetAsg string, capacity schemas.Capacity) error {
+	if !d.Collector.MetricConfig.Enabled {
+		return fmt.Errorf("autoscaling group does not exist and metrics are disabled to find its deployment record: %s", targetAsg)
+	}
+
+	record, err := d.Collector.GetDeploymentRecord(targetAsg)
+	if err != nil {
+		return err
+	}
+
+	if record == nil {
+		return fmt.Errorf("no deployment record exists: %s", targetAsg)
+	}
+
+	var recordedRegion *schemas.RegionConfig
+	for i := range record.Stack.Regions {
+		if record.Stack.Regions[i].Region == region.Region {
+			recordedRegion = &record.Stack.Regions[i]
+			break
+		}
+	}
+
+	if recordedRegion == nil {
+		return fmt.Errorf("region does not exist in the deployment record of %s: %s", targetAsg, region.Region)
+	}
+
+	// recorded stack is deployed with the current capacity while sharing the state of deployer
+	rd := *d
+	rd.Stack = record.Stack
+	rd.Stack.Capacity = capacity
+	rd.LocalProvider = builder.SetUserdataProvider(record.Stack.Userdata, d.AwsConfig.Userdata)
+	if len(record.Userdata) > 0 {
+		rd.LocalProvider = builder.StaticProvider{Userdata: record.Userdata}
+	}
+
+	rc := config
+	rc.Ami = record.Config.Ami
+	rc.OverrideInstanceType = record.Config.OverrideInstanceType
+	rc.OverrideSpotType = record.Config.OverrideSpotType
+	rc.ExtraTags = record.Config.ExtraTags
+	rc.AnsibleExtraVars = record.Config.AnsibleExtraVars
+	rc.ForceManifestCapacity = true
+
+	d.Logger.Infof("[Rollback] recreate autoscaling group with the deployment record of %s", targetAsg)
+	if err := rd.Deploy(rc, *recordedRegion); err != nil {
+		return err
+	}
+	d.AppliedCapacity = rd.AppliedCapacity
+
+	return nil
+}
+
+// ExportState returns the progress of deployment to persist
+func (d *Deployer) ExportState(config schemas.Config) schemas.DeploymentState {
+	return schemas.DeploymentState{
+		Application:       d.AwsConfig.Name,
+		Stack:             d.Stack.Stack,
+		Region:            config.Region,
+		Mode:              d.Mode,
+		UpdatedAt:         time.Now().Format(time.RFC3339),
+		Config:            config,
+		StepStatus:        d.StepStatus,
+		AsgNames:          d.AsgNames,
+		PrevAsgs:          d.PrevAsgs,
+		PrevInstances:     d.PrevInstances,
+		PrevVersions:      d.PrevVersions,
+		PrevInstanceCount: d.PrevInstanceCount,
+		LatestAsg:         d.LatestAsg,
+		SecurityGroup:     d.SecurityGroup,
+		DeploymentFlag:    d.DeploymentFlag,
+		AppliedCapacity:   d.AppliedCapacity,
+	}
+}
+
+// ImportState restores the progress of deployment from persisted state
+func (d *Deployer) ImportState(state schemas.DeploymentState) {
+	for step, done := range state.StepStatus {
+		d.StepStatus[step] = done
+	}
+
+	copyStringMap(d.AsgNames, state.AsgNames)
+	copyStringMap(d.LatestAsg, state.LatestAsg)
+	copyStringMap(d.DeploymentFlag, state.DeploymentFlag)
+
+	for region, asgs := range state.PrevAsgs {
+		d.PrevAsgs[region] = asgs
+	}
+
+	for region, instances := range state.PrevInstances {
+		d.PrevInstances[region] = instances
+	}
+
+	for region, versions := range state.PrevVersions {
+		d.PrevVersions[region] = versions
+	}
+
+	for region, capacity := range state.PrevInstanceCount {
+		d.PrevInstanceCount[region] = capacity
+	}
+
+	for region, sg := range state.SecurityGroup {
+		d.SecurityGroup[region] = sg
+	}
+
+	d.AppliedCapacity = state.AppliedCapacity
+}
+
+// copyStringMap copies values of src map to dst map
+func copyStringMap(dst, src map[string]string) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
 // StartGatheringMetrics starts to gather the whole metrics from deployer
 func (d *Deployer) StartGatheringMetrics(config schemas.Config) error {
 	for _, region := range d.Stack.Regions {
@@ -1333,13 +2083,16 @@ func (d *Deployer) RunAPITest(config schemas.Config) error {
 		return err
 	}
 
-	d.Logger.Debugf("Print API test result")
-	_, err = attacker.Print(result)
-	if err != nil {
-		return err
+	// API test result is a part of the document of deployment with json or yaml output
+	d.APITestResult = &schemas.APITestResult{Name: attacker.Name, Metrics: result}
+	if !tool.IsDocumentOutput(config.Output) {
+		d.Logger.Debugf("Print API test result")
+		if _, err := attacker.Print(result); err != nil {
+			return err
+		}
 	}
 
-	if err := d.Slack.SendAPITestResultMessage(result); err != nil {
+	if err := d.Notifier.NotifyAPITestResult(result); err != nil {
 		return err
 	}
 	d.Logger.Debugf("API test is done")
@@ -1386,6 +2139,68 @@ func getCurrentVersion(prevVersions []int) int {
 	return (prevVersions[len(prevVersions)-1] + 1) % 1000
 }
 
+// GetRollbackVersion returns the version to roll back to
+// If version is not specified, the one right before the current version is used.
+func GetRollbackVersion(current int, toVersion int64) (int, error) {
+	if toVersion >= 1000 {
+		return 0, fmt.Errorf("version should be less than 1000: %d", toVersion)
+	}
+
+	if toVersion >= 0 {
+		return int(toVersion), nil
+	}
+
+	return (current + 999) % 1000, nil
+}
+
+// isVersionExist checks if the version is in the version list
+func isVersionExist(version int, versions []int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userdataVariables returns variables for rendering userdata template
+func (d *Deployer) userdataVariables(config schemas.Config, region, ami, asgName string) builder.UserdataVariables {
+	releaseNotes := config.ReleaseNotes
+	if len(releaseNotes) == 0 && len(config.ReleaseNotesBase64) > 0 {
+		if decoded, err := base64.StdEncoding.DecodeString(config.ReleaseNotesBase64); err == nil {
+			releaseNotes = string(decoded)
+		}
+	}
+
+	return builder.UserdataVariables{
+		App:              d.AwsConfig.Name,
+		Stack:            d.Stack.Stack,
+		Env:              d.Stack.Env,
+		Region:           region,
+		Ami:              ami,
+		AutoScalingGroup: asgName,
+		ReleaseNotes:     releaseNotes,
+		AnsibleExtraVars: config.AnsibleExtraVars,
+	}
+}
+
+// isMetricBreached checks if value meets the comparison operator with threshold
+func isMetricBreached(comparison string, value, threshold float64) bool {
+	switch comparison {
+	case "GreaterThanThreshold":
+		return value > threshold
+	case "GreaterThanOrEqualToThreshold":
+		return value >= threshold
+	case "LessThanThreshold":
+		return value < threshold
+	case "LessThanOrEqualToThreshold":
+		return value <= threshold
+	}
+
+	return false
+}
+
 // NeedToInitializeCapacity checks if deployment process needs initialized capacity
 // If this value is true, then capacity will be adjusted to min: 1,desired: 1,max: 1
 func NeedToInitializeCapacity(mode string, completeCanary bool) bool {
@@ -1400,7 +2215,7 @@ func (d *Deployer) ResizingAutoScalingGroup(asg, region string, capacity schemas
 	}
 
 	d.Logger.Infof("Modifying the size of autoscaling group: %s(%s)", asg, d.Stack.Stack)
-	d.Slack.SendSimpleMessage(fmt.Sprintf("Modifying the size of autoscaling group: %s/%s", asg, d.Stack.Stack))
+	d.Notifier.NotifyRegion(constants.NotificationProgress, region, fmt.Sprintf("Modifying the size of autoscaling group: %s/%s", asg, d.Stack.Stack))
 
 	retry := int64(3)
 	for {
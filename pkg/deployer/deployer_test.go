@@ -88,3 +88,51 @@ func TestDeployer_ValidateOption(t *testing.T) {
 		t.Errorf("Invalid Override Spot Types Option: %s", validErr)
 	}
 }
+
+func TestGetRollbackVersion(t *testing.T) {
+	testData := []struct {
+		current   int
+		toVersion int64
+		expected  int
+		err       bool
+	}{
+		{current: 5, toVersion: -1, expected: 4},
+		{current: 0, toVersion: -1, expected: 999},
+		{current: 5, toVersion: 2, expected: 2},
+		{current: 5, toVersion: 0, expected: 0},
+		{current: 5, toVersion: 1000, err: true},
+	}
+
+	for _, td := range testData {
+		version, err := GetRollbackVersion(td.current, td.toVersion)
+		if (err != nil) != td.err {
+			t.Errorf("unexpected error for %d: %v", td.toVersion, err)
+		}
+
+		if !td.err && version != td.expected {
+			t.Errorf("expected: %d, got: %d", td.expected, version)
+		}
+	}
+}
+
+func TestIsMetricBreached(t *testing.T) {
+	testData := []struct {
+		comparison string
+		value      float64
+		expected   bool
+	}{
+		{comparison: "GreaterThanThreshold", value: 6, expected: true},
+		{comparison: "GreaterThanThreshold", value: 5, expected: false},
+		{comparison: "GreaterThanOrEqualToThreshold", value: 5, expected: true},
+		{comparison: "LessThanThreshold", value: 4, expected: true},
+		{comparison: "LessThanThreshold", value: 5, expected: false},
+		{comparison: "LessThanOrEqualToThreshold", value: 5, expected: true},
+		{comparison: "Unknown", value: 100, expected: false},
+	}
+
+	for _, td := range testData {
+		if breached := isMetricBreached(td.comparison, td.value, 5); breached != td.expected {
+			t.Errorf("%s %.0f: expected %t, got %t", td.comparison, td.value, td.expected, breached)
+		}
+	}
+}
@@ -23,7 +23,6 @@ import (
 
 	Logger "github.com/sirupsen/logrus"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
@@ -37,16 +36,7 @@ type DeployOnly struct {
 
 // NewDeployOnly creates new DeployOnly deployment deployer
 func NewDeployOnly(h *helper.DeployerHelper) *DeployOnly {
-	awsClients := []aws.Client{}
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, BootstrapAWSClients(h))
 
 	return &DeployOnly{
 		Deployer: &d,
@@ -0,0 +1,859 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/helper"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+const (
+	fakeApp    = "hello"
+	fakeEnv    = "dev"
+	fakeRegion = constants.DefaultRegion
+)
+
+// newFakeDeployer creates deployer whose aws clients are backed by fake cloud
+func newFakeDeployer(t *testing.T, cloud *fake.Cloud, mode string, capacity schemas.Capacity) *Deployer {
+	userdata := filepath.Join(t.TempDir(), "userdata.sh")
+	if err := os.WriteFile(userdata, []byte("#!/bin/bash\necho hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := helper.DeployerHelper{
+		Logger: logrus.New(),
+		Stack: schemas.Stack{
+			Stack:                      fakeEnv,
+			Env:                        fakeEnv,
+			ReplacementType:            mode,
+			Capacity:                   capacity,
+			RollingUpdateInstanceCount: 1,
+			Userdata: schemas.Userdata{
+				Type: "local",
+				Path: userdata,
+			},
+			BlockDevices: []schemas.BlockDevice{
+				{
+					DeviceName: "/dev/xvda",
+					VolumeSize: 8,
+					VolumeType: "gp3",
+				},
+			},
+			Regions: []schemas.RegionConfig{
+				{
+					Region:                 fakeRegion,
+					AmiID:                  "ami-0123456789abcdef0",
+					InstanceType:           "t3.micro",
+					VPC:                    "fake-vpc",
+					SecurityGroups:         []string{"hello-dev"},
+					HealthcheckTargetGroup: "hello-dev",
+					TargetGroups:           []string{"hello-dev"},
+				},
+			},
+		},
+		AwsConfig: schemas.AWSConfig{
+			Name: fakeApp,
+		},
+		Notifier: notifier.Router{},
+	}
+
+	d := InitDeploymentConfiguration(&h, []aws.Client{cloud.Client()})
+
+	return &d
+}
+
+// newFakeConfig creates configuration which finishes polling quickly
+func newFakeConfig() schemas.Config {
+	return schemas.Config{
+		Timeout:         time.Minute,
+		PollingInterval: 10 * time.Millisecond,
+		StartTimestamp:  time.Now().Unix(),
+		DisableMetrics:  true,
+	}
+}
+
+// runDeployment runs deployment steps in the same order as runner
+func runDeployment(t *testing.T, manager DeployManager, config schemas.Config) {
+	steps := []struct {
+		name string
+		run  func(config schemas.Config) error
+	}{
+		{name: "check previous resources", run: manager.CheckPreviousResources},
+		{name: "deploy", run: manager.Deploy},
+		{name: "health checking", run: manager.HealthChecking},
+		{name: "finish additional work", run: manager.FinishAdditionalWork},
+		{name: "trigger lifecycle callbacks", run: manager.TriggerLifecycleCallbacks},
+		{name: "clean previous version", run: manager.CleanPreviousVersion},
+		{name: "clean checking", run: manager.CleanChecking},
+	}
+
+	for _, step := range steps {
+		if err := step.run(config); err != nil {
+			t.Fatalf("%s: %s", step.name, err.Error())
+		}
+	}
+}
+
+func TestBlueGreen_DeployWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	b := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, capacity)}
+	runDeployment(t, b, newFakeConfig())
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != newAsg {
+		t.Fatalf("expected only %s to remain, got %v", newAsg, names)
+	}
+
+	group := cloud.AutoScalingGroup(newAsg)
+	if len(group.Instances) != int(capacity.Desired) {
+		t.Errorf("expected %d instances, got %d", capacity.Desired, len(group.Instances))
+	}
+
+	if len(group.TargetGroupARNs) != 1 || *group.TargetGroupARNs[0] != tgArn {
+		t.Errorf("new autoscaling group is not attached to target group: %v", group.TargetGroupARNs)
+	}
+
+	for _, lt := range cloud.LaunchTemplateNames() {
+		if strings.HasPrefix(lt, prevAsg) {
+			t.Errorf("launch template of previous version is not deleted: %s", lt)
+		}
+	}
+}
+
+func TestBlueGreen_HealthCheckTimeoutWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	cloud.HealthyOnLaunch = false
+	cloud.AddTargetGroup("hello-dev", 80)
+
+	b := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 1, Max: 1, Desired: 1})}
+
+	config := newFakeConfig()
+	config.Timeout = 50 * time.Millisecond
+	config.StartTimestamp = time.Now().Add(-time.Second).Unix()
+
+	if err := b.CheckPreviousResources(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Deploy(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.HealthChecking(config); err == nil {
+		t.Error("expected health checking to time out with unhealthy instances")
+	}
+}
+
+func TestRollingUpdate_DeployWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	capacity := schemas.Capacity{Min: 3, Max: 3, Desired: 3}
+	cloud.AddAutoScalingGroup(prevAsg, capacity, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.RollingUpdateDeployment, capacity)
+	r := &RollingUpdate{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		Deployer:                    d,
+	}
+	runDeployment(t, r, newFakeConfig())
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != newAsg {
+		t.Fatalf("expected only %s to remain, got %v", newAsg, names)
+	}
+
+	group := cloud.AutoScalingGroup(newAsg)
+	if *group.DesiredCapacity != capacity.Desired || len(group.Instances) != int(capacity.Desired) {
+		t.Errorf("expected %d instances after rolling update, got desired %d with %d instances", capacity.Desired, *group.DesiredCapacity, len(group.Instances))
+	}
+}
+
+func TestCanary_DeployWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.CanaryDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+	c := &Canary{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		Deployer:                    d,
+	}
+
+	config := newFakeConfig()
+	for _, step := range []func(config schemas.Config) error{c.CheckPreviousResources, c.Deploy, c.HealthChecking} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	group := cloud.AutoScalingGroup(newAsg)
+	if group == nil {
+		t.Fatalf("canary autoscaling group is not created: %s", newAsg)
+	}
+
+	canaryTg := cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0))
+	if len(canaryTg) == 0 || len(group.TargetGroupARNs) != 1 || *group.TargetGroupARNs[0] != canaryTg {
+		t.Errorf("canary autoscaling group is not attached to canary target group: %v", group.TargetGroupARNs)
+	}
+
+	if lbs := cloud.LoadBalancerNames(); len(lbs) != 1 || lbs[0] != c.GenerateCanaryLoadBalancerName(fakeRegion) {
+		t.Errorf("canary load balancer is not created: %v", lbs)
+	}
+
+	tagged := false
+	for _, tag := range group.Tags {
+		if *tag.Key == constants.DeploymentTagKey && *tag.Value == constants.CanaryDeployment {
+			tagged = true
+		}
+	}
+
+	if !tagged {
+		t.Error("canary autoscaling group does not have deployment tag")
+	}
+}
+
+func TestBlueGreen_PlanWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+	d.Stack.Autoscaling = []schemas.ScalePolicy{{Name: "scale-out"}}
+	d.Stack.Alarms = []schemas.AlarmConfigs{{Name: "cpu-high"}}
+	b := &BlueGreen{Deployer: d}
+
+	config := newFakeConfig()
+	if err := b.CheckPreviousResources(config); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := b.Plan(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		tool.GenerateAsgName(prefix, 1): constants.PlanCreate,
+		"hello-dev":                     constants.PlanAttach,
+		"scale-out":                     constants.PlanCreate,
+		"cpu-high":                      constants.PlanCreate,
+		prevAsg:                         constants.PlanDelete,
+	}
+
+	for _, change := range plan.Changes {
+		if action, ok := expected[change.Name]; ok && action == change.Action {
+			delete(expected, change.Name)
+		}
+	}
+
+	if len(expected) > 0 {
+		t.Errorf("changes are missing in plan: %v", expected)
+	}
+
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != prevAsg {
+		t.Errorf("plan should not modify autoscaling groups: %v", names)
+	}
+
+	if lts := cloud.LaunchTemplateNames(); len(lts) != 0 {
+		t.Errorf("plan should not create launch templates: %v", lts)
+	}
+}
+
+func TestCanary_PlanWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	c := &Canary{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		Deployer:                    newFakeDeployer(t, cloud, constants.CanaryDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2}),
+	}
+
+	config := newFakeConfig()
+	if err := c.CheckPreviousResources(config); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := c.Plan(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		c.GenerateCanaryTargetGroupName(0):              constants.PlanCreate,
+		c.GenerateCanaryLoadBalancerName(fakeRegion):    constants.PlanCreate,
+		c.GenerateCanarySecurityGroupName(fakeRegion):   constants.PlanCreate,
+		c.GenerateCanaryLBSecurityGroupName(fakeRegion): constants.PlanCreate,
+		tool.GenerateAsgName(prefix, 1):                 constants.PlanCreate,
+	}
+
+	for _, change := range plan.Changes {
+		if action, ok := expected[change.Name]; ok && action == change.Action {
+			delete(expected, change.Name)
+		}
+
+		if change.Name == prevAsg {
+			t.Errorf("original autoscaling group should not be changed by canary: %v", change)
+		}
+	}
+
+	if len(expected) > 0 {
+		t.Errorf("changes are missing in plan: %v", expected)
+	}
+
+	if lbs := cloud.LoadBalancerNames(); len(lbs) != 0 {
+		t.Errorf("plan should not create load balancers: %v", lbs)
+	}
+
+	if tg := cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0)); len(tg) > 0 {
+		t.Errorf("plan should not create target groups: %s", tg)
+	}
+}
+
+// newFakeProgressiveCanary creates progressive canary deployer with previous version behind a load balancer
+func newFakeProgressiveCanary(t *testing.T, cloud *fake.Cloud) *Canary {
+	d := newFakeDeployer(t, cloud, constants.CanaryDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+	d.Stack.ProgressiveCanary = &schemas.ProgressiveCanary{
+		Steps: []schemas.CanaryStep{
+			{Weight: 10},
+			{Weight: 50},
+			{Weight: 100},
+		},
+		Analysis: schemas.CanaryAnalysis{
+			Max5xxRate: 5,
+		},
+	}
+
+	return &Canary{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		CanaryTraffic:               map[string]*schemas.CanaryTraffic{},
+		Deployer:                    d,
+	}
+}
+
+func TestCanary_ProgressiveWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+	lbArn := cloud.AddLoadBalancer("hello-dev", tgArn)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	c := newFakeProgressiveCanary(t, cloud)
+	runDeployment(t, c, newFakeConfig())
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != newAsg {
+		t.Fatalf("expected only %s to remain, got %v", newAsg, names)
+	}
+
+	group := cloud.AutoScalingGroup(newAsg)
+	if len(group.Instances) != 2 || len(group.TargetGroupARNs) != 1 || *group.TargetGroupARNs[0] != tgArn {
+		t.Errorf("promoted autoscaling group should have 2 instances in original target group: %d instances, %v", len(group.Instances), group.TargetGroupARNs)
+	}
+
+	for _, tag := range group.Tags {
+		if *tag.Key == constants.DeploymentTagKey {
+			t.Errorf("canary tag is not removed from promoted autoscaling group")
+		}
+	}
+
+	if weights := cloud.ListenerWeights(lbArn); len(weights) != 1 || weights[tgArn] != 1 {
+		t.Errorf("listener should forward to original target group only: %v", weights)
+	}
+
+	if tg := cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0)); len(tg) > 0 {
+		t.Errorf("canary target group is not deleted: %s", tg)
+	}
+}
+
+func TestCanary_ProgressiveAbortWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+	lbArn := cloud.AddLoadBalancer("hello-dev", tgArn)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	c := newFakeProgressiveCanary(t, cloud)
+	config := newFakeConfig()
+	for _, step := range []func(config schemas.Config) error{c.CheckPreviousResources, c.Deploy, c.HealthChecking} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	canaryTg := cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0))
+	if weights := cloud.ListenerWeights(lbArn); len(weights) != 2 || weights[tgArn] != 100 || weights[canaryTg] != 0 {
+		t.Fatalf("canary target group should be attached to listener without traffic: %v", weights)
+	}
+
+	if region := c.Stack.Regions[0]; region.HealthcheckTargetGroup != "hello-dev" || len(region.TargetGroups) != 1 || region.TargetGroups[0] != "hello-dev" {
+		t.Errorf("region of manifest should not be changed by canary: %s, %v", region.HealthcheckTargetGroup, region.TargetGroups)
+	}
+
+	cloud.SetTargetGroupMetrics(canaryTg, aws.TargetGroupMetrics{RequestCount: 100, Target5xxCount: 10})
+	err := c.FinishAdditionalWork(config)
+	if err == nil || !strings.Contains(err.Error(), "canary analysis failed at 10%") {
+		t.Fatalf("canary should be aborted at the first step: %v", err)
+	}
+
+	if weights := cloud.ListenerWeights(lbArn); len(weights) != 1 || weights[tgArn] != 1 {
+		t.Errorf("traffic is not restored to original target group: %v", weights)
+	}
+
+	if err := c.Rollback(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != prevAsg {
+		t.Errorf("expected only %s to remain, got %v", prevAsg, names)
+	}
+
+	if tg := cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0)); len(tg) > 0 {
+		t.Errorf("canary target group is not deleted: %s", tg)
+	}
+}
+
+func TestBlueGreen_VerifyWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+	cloud.AddLoadBalancer("hello-dev", tgArn)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+	d.Stack.Verification = &schemas.Verification{
+		Window: 10 * time.Millisecond,
+		Metrics: []schemas.VerificationMetric{
+			{
+				Name:       "target-5xx",
+				Namespace:  constants.ApplicationELBNamespace,
+				Metric:     "HTTPCode_Target_5XX_Count",
+				Statistic:  "Sum",
+				Comparison: "GreaterThanThreshold",
+				Threshold:  5,
+			},
+		},
+	}
+	b := &BlueGreen{Deployer: d}
+
+	config := newFakeConfig()
+	for _, step := range []func(config schemas.Config) error{b.CheckPreviousResources, b.Deploy, b.HealthChecking, b.FinishAdditionalWork} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.Verify(config); err == nil || !strings.Contains(err.Error(), "metric is breached: target-5xx has no datapoint") {
+		t.Fatalf("verification should fail without datapoint: %v", err)
+	}
+
+	b.Stack.Verification.Metrics[0].TreatMissingData = constants.MissingDataNotBreaching
+	if err := b.Verify(config); err != nil {
+		t.Fatalf("verification should pass without datapoint when missing data is not breaching: %v", err)
+	}
+	b.Stack.Verification.Metrics[0].TreatMissingData = ""
+	b.StepStatus[constants.StepVerify] = false
+
+	cloud.SetMetricStatistic(constants.ApplicationELBNamespace, "HTTPCode_Target_5XX_Count", 10)
+	if err := b.Verify(config); err == nil || !strings.Contains(err.Error(), "metric is breached: target-5xx") {
+		t.Fatalf("verification should fail with breached metric: %v", err)
+	}
+
+	if b.StepStatus[constants.StepVerify] {
+		t.Error("verify step should not be marked when metric is breached")
+	}
+
+	cloud.SetMetricStatistic(constants.ApplicationELBNamespace, "HTTPCode_Target_5XX_Count", 1)
+	if err := b.Verify(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.StepStatus[constants.StepVerify] {
+		t.Error("verify step is not marked after metrics pass")
+	}
+	b.StepStatus[constants.StepVerify] = false
+
+	// window is not waited to the end when deployment is cancelled or timed out
+	b.Stack.Verification.Window = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.Context = ctx
+	if err := b.Verify(config); err == nil || !strings.Contains(err.Error(), "deployment is cancelled during verification") {
+		t.Fatalf("verification should stop when deployment is cancelled: %v", err)
+	}
+
+	b.Context = nil
+	config.StartTimestamp = time.Now().Add(-2 * config.Timeout).Unix()
+	if err := b.Verify(config); err == nil || !strings.Contains(err.Error(), "timeout has been exceeded during verification") {
+		t.Fatalf("verification should stop when timeout is exceeded: %v", err)
+	}
+
+	if b.StepStatus[constants.StepVerify] {
+		t.Error("verify step should not be marked when verification is stopped")
+	}
+}
+
+func TestBlueGreen_RollbackWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	cloud.HealthyOnLaunch = false
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	cloud.AddAutoScalingGroup(prevAsg, capacity, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, capacity)
+	d.Stack.RollbackOnFailure = true
+	b := &BlueGreen{Deployer: d}
+
+	config := newFakeConfig()
+	config.Timeout = 50 * time.Millisecond
+	config.StartTimestamp = time.Now().Add(-time.Second).Unix()
+	for _, step := range []func(config schemas.Config) error{b.CheckPreviousResources, b.Deploy} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := b.HealthChecking(config); err == nil {
+		t.Fatal("expected health checking to fail with unhealthy instances")
+	}
+
+	config.Timeout = time.Minute
+	if err := b.Rollback(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != prevAsg {
+		t.Fatalf("expected only %s to remain, got %v", prevAsg, names)
+	}
+
+	if group := cloud.AutoScalingGroup(prevAsg); *group.DesiredCapacity != capacity.Desired {
+		t.Errorf("previous autoscaling group should keep its capacity: %d", *group.DesiredCapacity)
+	}
+
+	if lts := cloud.LaunchTemplateNames(); len(lts) != 0 {
+		t.Errorf("launch template of new version is not deleted: %v", lts)
+	}
+}
+
+func TestRollingUpdate_RollbackWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	capacity := schemas.Capacity{Min: 3, Max: 3, Desired: 3}
+	cloud.AddAutoScalingGroup(prevAsg, capacity, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.RollingUpdateDeployment, capacity)
+	d.Stack.RollbackOnFailure = true
+	r := &RollingUpdate{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		Deployer:                    d,
+	}
+
+	config := newFakeConfig()
+	for _, step := range []func(config schemas.Config) error{r.CheckPreviousResources, r.Deploy} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := r.ReducePreviousAutoScalingGroupCapacity(fakeRegion, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Rollback(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != prevAsg {
+		t.Fatalf("expected only %s to remain, got %v", prevAsg, names)
+	}
+
+	if group := cloud.AutoScalingGroup(prevAsg); *group.DesiredCapacity != capacity.Desired {
+		t.Errorf("expected capacity of previous autoscaling group to be restored to %d, got %d", capacity.Desired, *group.DesiredCapacity)
+	}
+}
+
+// runRollback runs rollback steps in the same order as runner
+func runRollback(t *testing.T, manager DeployManager, config schemas.Config) {
+	steps := []struct {
+		name string
+		run  func(config schemas.Config) error
+	}{
+		{name: "check previous resources", run: manager.CheckPreviousResources},
+		{name: "rollback to version", run: manager.GetDeployer().RollbackToVersion},
+		{name: "health checking", run: manager.HealthChecking},
+		{name: "finish additional work", run: manager.FinishAdditionalWork},
+		{name: "trigger lifecycle callbacks", run: manager.TriggerLifecycleCallbacks},
+		{name: "clean previous version", run: manager.CleanPreviousVersion},
+		{name: "clean checking", run: manager.CleanChecking},
+	}
+
+	for _, step := range steps {
+		if err := step.run(config); err != nil {
+			t.Fatalf("%s: %s", step.name, err.Error())
+		}
+	}
+}
+
+func TestBlueGreen_RollbackToVersionWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	currentAsg := tool.GenerateAsgName(prefix, 1)
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{}, []string{tgArn}, nil)
+	cloud.AddAutoScalingGroup(currentAsg, capacity, []string{tgArn}, nil)
+
+	b := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 1, Max: 1, Desired: 1})}
+
+	config := newFakeConfig()
+	config.ToVersion = -1
+	runRollback(t, b, config)
+
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != prevAsg {
+		t.Fatalf("expected only %s to remain, got %v", prevAsg, names)
+	}
+
+	if group := cloud.AutoScalingGroup(prevAsg); *group.DesiredCapacity != capacity.Desired || len(group.Instances) != int(capacity.Desired) {
+		t.Errorf("expected %d instances in the previous version, got desired %d with %d instances", capacity.Desired, *group.DesiredCapacity, len(group.Instances))
+	}
+}
+
+func TestBlueGreen_RollbackToDeletedVersionWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	deletedAsg := tool.GenerateAsgName(prefix, 0)
+	currentAsg := tool.GenerateAsgName(prefix, 1)
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	cloud.AddAutoScalingGroup(currentAsg, capacity, []string{tgArn}, nil)
+
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 1, Max: 1, Desired: 1})
+	c, err := collector.NewCollectorWithClient(schemas.MetricConfig{
+		Enabled: true,
+		Storage: schemas.Storage{Type: "dynamodb", Name: "goployer-metrics"},
+	}, cloud.MetricClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Collector = c
+
+	if err := d.Collector.CheckStorage(d.Logger); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := d.Stack
+	recorded.Regions = []schemas.RegionConfig{d.Stack.Regions[0]}
+	recorded.Regions[0].AmiID = "ami-0000000000000000a"
+	if err := d.Collector.StampDeployment(fakeApp, recorded, schemas.Config{}, nil, deletedAsg, "terminated", map[string]string{"userdata": "b2xk"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := newFakeConfig()
+	config.DisableMetrics = false
+	config.ToVersion = 0
+	runRollback(t, &BlueGreen{Deployer: d}, config)
+
+	recreatedAsg := tool.GenerateAsgName(prefix, 2)
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != recreatedAsg {
+		t.Fatalf("expected only %s to remain, got %v", recreatedAsg, names)
+	}
+
+	group := cloud.AutoScalingGroup(recreatedAsg)
+	if *group.DesiredCapacity != capacity.Desired {
+		t.Errorf("expected capacity of recreated version to be %d, got %d", capacity.Desired, *group.DesiredCapacity)
+	}
+
+	lt, err := cloud.Client().EC2Service.GetMatchingLaunchTemplate(*group.LaunchTemplate.LaunchTemplateId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *lt.LaunchTemplateData.ImageId != recorded.Regions[0].AmiID || *lt.LaunchTemplateData.UserData != "b2xk" {
+		t.Errorf("recreated version does not use the deployment record: %s, %s", *lt.LaunchTemplateData.ImageId, *lt.LaunchTemplateData.UserData)
+	}
+
+	if status := cloud.Record("goployer-metrics", currentAsg)["deployment_status"]; status != "terminated" {
+		t.Errorf("expected status of %s to be terminated, got %s", currentAsg, status)
+	}
+}
+
+func TestBlueGreen_ResumeWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	cloud.AddAutoScalingGroup(prevAsg, capacity, []string{tgArn}, nil)
+
+	config := newFakeConfig()
+	b := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, capacity)}
+	for _, step := range []func(config schemas.Config) error{b.CheckPreviousResources, b.Deploy} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// process is killed here and the state is reloaded by a new process
+	saved, err := json.Marshal(b.ExportState(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state schemas.DeploymentState
+	if err := json.Unmarshal(saved, &state); err != nil {
+		t.Fatal(err)
+	}
+
+	resumed := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, capacity)}
+	resumed.ImportState(state)
+
+	steps := RemainingSteps(resumed)
+	if len(steps) == 0 || steps[0].Name != "StepHealthCheck" {
+		t.Fatalf("expected to resume from health check, got %v", steps)
+	}
+
+	for _, step := range steps {
+		if err := step.Run(state.Config); err != nil {
+			t.Fatalf("%s: %s", step.Name, err.Error())
+		}
+	}
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	if names := cloud.AutoScalingGroupNames(); len(names) != 1 || names[0] != newAsg {
+		t.Fatalf("expected only %s to remain, got %v", newAsg, names)
+	}
+}
+
+func TestDeployer_LifecycleCallbacksWithFake(t *testing.T) {
+	testData := []struct {
+		name     string
+		policy   string
+		failures int
+		commands int
+		err      bool
+	}{
+		{name: "success", commands: 1},
+		{name: "abort by default", failures: 1, commands: 1, err: true},
+		{name: "continue", policy: constants.LifecycleCallbackContinue, failures: 1, commands: 1},
+		{name: "retry", policy: constants.LifecycleCallbackRetry, failures: 1, commands: 2},
+		{name: "retry and abort", policy: constants.LifecycleCallbackRetry, failures: 2, commands: 2, err: true},
+	}
+
+	for _, td := range testData {
+		cloud := fake.NewCloud(fakeRegion)
+		tgArn := cloud.AddTargetGroup("hello-dev", 80)
+		prevAsg := tool.GenerateAsgName(tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion), 0)
+		cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+		cloud.SetCommandFailures(td.failures)
+
+		d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+		d.Stack.LifecycleCallbacks = &schemas.LifecycleCallbacks{
+			PreTerminatePastClusters: []string{"service hello stop"},
+			FailurePolicy:            td.policy,
+		}
+
+		config := newFakeConfig()
+		if err := d.CheckPrevious(config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.TriggerLifecycleCallbacks(config); (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+
+		commands := cloud.Commands()
+		if len(commands) != td.commands {
+			t.Errorf("%s: expected %d commands, got %d", td.name, td.commands, len(commands))
+		}
+
+		if len(commands) > 0 && len(commands[0].Targets) != 2 {
+			t.Errorf("%s: commands should be sent to previous instances: %v", td.name, commands[0].Targets)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// healthPolicyState keeps progress of health policy in a region between polls
+type healthPolicyState struct {
+	successes      int64
+	replaced       int64
+	unhealthySince map[string]time.Time
+}
+
+// policyState returns state of health policy in the region
+func (d *Deployer) policyState(region string) *healthPolicyState {
+	if d.healthPolicyStates == nil {
+		d.healthPolicyStates = map[string]*healthPolicyState{}
+	}
+
+	if _, ok := d.healthPolicyStates[region]; !ok {
+		d.healthPolicyStates[region] = &healthPolicyState{unhealthySince: map[string]time.Time{}}
+	}
+
+	return d.healthPolicyStates[region]
+}
+
+// RequiredHealthyCount returns how many instances should be healthy out of desired capacity
+func (d *Deployer) RequiredHealthyCount(desired int64) int64 {
+	policy := d.Stack.HealthPolicy
+	if policy == nil {
+		return desired
+	}
+
+	required := desired
+	if policy.HealthyCount > 0 {
+		required = policy.HealthyCount
+	} else if policy.HealthyPercentage > 0 {
+		required = (desired*policy.HealthyPercentage + 99) / 100
+	}
+
+	if required > desired {
+		return desired
+	}
+
+	return required
+}
+
+// ApplyHealthPolicy decides if deployment is healthy with health policy, and replaces instances which stay unhealthy
+func (d *Deployer) ApplyHealthPolicy(region string, asg *autoscaling.Group, client aws.Client, hosts []aws.HealthcheckHost, validHostCount int64) (bool, error) {
+	policy := d.Stack.HealthPolicy
+	state := d.policyState(region)
+	required := d.RequiredHealthyCount(d.AppliedCapacity.Desired)
+
+	unbalanced := unbalancedZones(asg, hosts, policy.MinHealthyPerAZ)
+	if validHostCount >= required && len(unbalanced) == 0 {
+		state.successes++
+		consecutive := policy.ConsecutiveSuccesses
+		if consecutive < 1 {
+			consecutive = 1
+		}
+
+		if state.successes >= consecutive {
+			d.Logger.Infof("Healthy Count for %s : %d/%d", d.AsgNames[region], validHostCount, required)
+			d.Notifier.NotifyRegion(constants.NotificationHealthy, region, fmt.Sprintf("Instances are healthy in %s  :  %d/%d", d.AsgNames[region], validHostCount, required))
+			return true, nil
+		}
+
+		d.Logger.Infof("Health policy is met(%s) : %d/%d consecutive polls", d.AsgNames[region], state.successes, consecutive)
+		return false, nil
+	}
+	state.successes = 0
+
+	if len(unbalanced) > 0 {
+		d.Logger.Infof("Healthy count per availability zone does not meet the requirement(%s) : %s", d.AsgNames[region], strings.Join(unbalanced, ", "))
+	}
+	d.Logger.Infof("Healthy count does not meet the requirement(%s) : %d/%d", d.AsgNames[region], validHostCount, required)
+	d.Notifier.NotifyRegion(constants.NotificationProgress, region, fmt.Sprintf("Waiting for healthy instances %s  :  %d/%d", d.AsgNames[region], validHostCount, required))
+
+	return false, d.replaceUnhealthyInstances(region, asg, client, hosts)
+}
+
+// replaceUnhealthyInstances terminates instances which stay unhealthy so that autoscaling group launches new ones
+func (d *Deployer) replaceUnhealthyInstances(region string, asg *autoscaling.Group, client aws.Client, hosts []aws.HealthcheckHost) error {
+	policy := d.Stack.HealthPolicy
+	if policy.ReplaceUnhealthy <= 0 {
+		// unhealthy instances are waited until health checking times out as without health policy
+		return nil
+	}
+	state := d.policyState(region)
+
+	wait := policy.ReplaceUnhealthyAfter
+	if wait == 0 {
+		wait = constants.DefaultReplaceUnhealthyAfter
+	}
+
+	valid := map[string]bool{}
+	for _, host := range hosts {
+		valid[host.InstanceID] = host.Valid
+	}
+
+	// instances which are still launching or already terminating are not regarded as unhealthy
+	now := time.Now()
+	unhealthy := map[string]time.Time{}
+	for _, instance := range asg.Instances {
+		id := *instance.InstanceId
+		if valid[id] || *instance.LifecycleState != constants.InServiceStatus {
+			continue
+		}
+
+		since, ok := state.unhealthySince[id]
+		if !ok {
+			since = now
+		}
+		unhealthy[id] = since
+	}
+	state.unhealthySince = unhealthy
+
+	var ids []string
+	for id := range unhealthy {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if now.Sub(unhealthy[id]) < wait {
+			continue
+		}
+
+		if state.replaced >= policy.ReplaceUnhealthy {
+			return fmt.Errorf("instance %s has been unhealthy for %s after %d replacements in %s", id, now.Sub(unhealthy[id]).Round(time.Second), state.replaced, region)
+		}
+
+		if err := client.EC2Service.TerminateInstanceInAutoScalingGroup(id); err != nil {
+			return err
+		}
+		state.replaced++
+		delete(state.unhealthySince, id)
+
+		d.Logger.Warnf("Unhealthy instance is replaced(%d/%d) : %s", state.replaced, policy.ReplaceUnhealthy, id)
+		d.Notifier.NotifyRegion(constants.NotificationProgress, region, fmt.Sprintf("Unhealthy instance is replaced in %s : %s", d.AsgNames[region], id))
+	}
+
+	return nil
+}
+
+// unbalancedZones returns availability zones which have fewer healthy instances than minimum
+func unbalancedZones(asg *autoscaling.Group, hosts []aws.HealthcheckHost, minimum int64) []string {
+	if minimum <= 0 {
+		return nil
+	}
+
+	valid := map[string]bool{}
+	for _, host := range hosts {
+		valid[host.InstanceID] = host.Valid
+	}
+
+	// zones without any instance should be counted as well
+	healthy := map[string]int64{}
+	for _, az := range asg.AvailabilityZones {
+		healthy[*az] = 0
+	}
+
+	for _, instance := range asg.Instances {
+		az := *instance.AvailabilityZone
+		if _, ok := healthy[az]; !ok {
+			healthy[az] = 0
+		}
+
+		if valid[*instance.InstanceId] {
+			healthy[az]++
+		}
+	}
+
+	var ret []string
+	for az, count := range healthy {
+		if count < minimum {
+			ret = append(ret, fmt.Sprintf("%s(%d/%d)", az, count, minimum))
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
@@ -0,0 +1,198 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"testing"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestDeployer_RequiredHealthyCount(t *testing.T) {
+	testData := []struct {
+		policy   *schemas.HealthPolicy
+		desired  int64
+		expected int64
+	}{
+		{policy: nil, desired: 40, expected: 40},
+		{policy: &schemas.HealthPolicy{}, desired: 40, expected: 40},
+		{policy: &schemas.HealthPolicy{HealthyPercentage: 95}, desired: 40, expected: 38},
+		{policy: &schemas.HealthPolicy{HealthyPercentage: 90}, desired: 5, expected: 5},
+		{policy: &schemas.HealthPolicy{HealthyPercentage: 50}, desired: 3, expected: 2},
+		{policy: &schemas.HealthPolicy{HealthyCount: 3}, desired: 40, expected: 3},
+		{policy: &schemas.HealthPolicy{HealthyCount: 10}, desired: 4, expected: 4},
+	}
+
+	for _, td := range testData {
+		d := Deployer{Stack: schemas.Stack{HealthPolicy: td.policy}}
+		if got := d.RequiredHealthyCount(td.desired); got != td.expected {
+			t.Errorf("%+v with %d desired: expected %d, got %d", td.policy, td.desired, td.expected, got)
+		}
+	}
+}
+
+func TestDeployer_HealthPolicyWithFake(t *testing.T) {
+	testData := []struct {
+		name      string
+		policy    schemas.HealthPolicy
+		unhealthy int
+		polls     []bool
+		err       bool
+		replaced  bool
+	}{
+		{
+			name:   "percentage",
+			policy: schemas.HealthPolicy{HealthyPercentage: 75},
+			polls:  []bool{true},
+		},
+		{
+			name:   "all instances by default",
+			policy: schemas.HealthPolicy{},
+			polls:  []bool{false, false},
+		},
+		{
+			name:   "consecutive successes",
+			policy: schemas.HealthPolicy{HealthyCount: 3, ConsecutiveSuccesses: 3},
+			polls:  []bool{false, false, true},
+		},
+		{
+			name:   "unbalanced availability zone",
+			policy: schemas.HealthPolicy{HealthyPercentage: 50, MinHealthyPerAZ: 2},
+			polls:  []bool{false, false},
+		},
+		{
+			name:     "replace unhealthy instance",
+			policy:   schemas.HealthPolicy{ReplaceUnhealthy: 1, ReplaceUnhealthyAfter: time.Millisecond},
+			polls:    []bool{false, false, true},
+			replaced: true,
+		},
+		{
+			name:   "no replacement by default",
+			policy: schemas.HealthPolicy{ReplaceUnhealthyAfter: time.Millisecond},
+			polls:  []bool{false, false, false},
+		},
+		{
+			name:      "replacements exhausted",
+			policy:    schemas.HealthPolicy{ReplaceUnhealthy: 1, ReplaceUnhealthyAfter: time.Millisecond},
+			unhealthy: 2,
+			polls:     []bool{false, false},
+			err:       true,
+			replaced:  true,
+		},
+	}
+
+	for _, td := range testData {
+		cloud := fake.NewCloud(fakeRegion)
+		cloud.AddTargetGroup("hello-dev", 80)
+
+		policy := td.policy
+		d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 4, Max: 4, Desired: 4})
+		d.Stack.HealthPolicy = &policy
+
+		b := &BlueGreen{Deployer: d}
+		config := newFakeConfig()
+		if err := b.CheckPreviousResources(config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.Deploy(config); err != nil {
+			t.Fatal(err)
+		}
+
+		if td.unhealthy == 0 {
+			td.unhealthy = 1
+		}
+
+		group := cloud.AutoScalingGroup(d.AsgNames[fakeRegion])
+		unhealthy := *group.Instances[0].InstanceId
+		for _, instance := range group.Instances[:td.unhealthy] {
+			cloud.SetInstanceHealth(*instance.InstanceId, fake.Unhealthy)
+		}
+
+		var err error
+		for i, expected := range td.polls {
+			if i > 0 {
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			var healthy bool
+			healthy, err = d.HealthChecking(config)
+			if err != nil {
+				break
+			}
+
+			if healthy != expected {
+				t.Errorf("%s: poll %d expected %t, got %t", td.name, i+1, expected, healthy)
+			}
+		}
+
+		if (err != nil) != td.err {
+			t.Errorf("%s: unexpected error: %v", td.name, err)
+		}
+
+		replaced := true
+		for _, instance := range cloud.AutoScalingGroup(d.AsgNames[fakeRegion]).Instances {
+			if *instance.InstanceId == unhealthy {
+				replaced = false
+			}
+		}
+
+		if replaced != td.replaced {
+			t.Errorf("%s: expected replaced %t, got %t", td.name, td.replaced, replaced)
+		}
+	}
+}
+
+func TestUnbalancedZones(t *testing.T) {
+	asg := &autoscaling.Group{
+		AvailabilityZones: eaws.StringSlice([]string{"ap-northeast-2a", "ap-northeast-2b", "ap-northeast-2c"}),
+		Instances: []*autoscaling.Instance{
+			{InstanceId: eaws.String("i-1"), AvailabilityZone: eaws.String("ap-northeast-2a")},
+			{InstanceId: eaws.String("i-2"), AvailabilityZone: eaws.String("ap-northeast-2a")},
+			{InstanceId: eaws.String("i-3"), AvailabilityZone: eaws.String("ap-northeast-2c")},
+		},
+	}
+	hosts := []aws.HealthcheckHost{
+		{InstanceID: "i-1", Valid: true},
+		{InstanceID: "i-2", Valid: true},
+		{InstanceID: "i-3", Valid: false},
+	}
+
+	testData := []struct {
+		minimum  int64
+		expected []string
+	}{
+		{minimum: 0},
+		{minimum: 1, expected: []string{"ap-northeast-2b(0/1)", "ap-northeast-2c(0/1)"}},
+		{minimum: 2, expected: []string{"ap-northeast-2b(0/2)", "ap-northeast-2c(0/2)"}},
+		{minimum: 3, expected: []string{"ap-northeast-2a(2/3)", "ap-northeast-2b(0/3)", "ap-northeast-2c(0/3)"}},
+	}
+
+	for _, td := range testData {
+		if diff := deep.Equal(unbalancedZones(asg, hosts, td.minimum), td.expected); diff != nil {
+			t.Errorf("minimum %d: %v", td.minimum, diff)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// HealthChecker checks health of instances in addition to target group or load balancer
+type HealthChecker interface {
+	// Check returns whether each instance is healthy
+	Check(client aws.Client, instances []string) (map[string]bool, error)
+}
+
+// httpChecker sends request to private ip of instance
+type httpChecker struct {
+	config  schemas.HealthChecker
+	timeout time.Duration
+}
+
+// tcpChecker connects to private ip of instance
+type tcpChecker struct {
+	config  schemas.HealthChecker
+	timeout time.Duration
+}
+
+// ssmChecker runs commands in instance which should exit 0
+type ssmChecker struct {
+	deployer *Deployer
+	config   schemas.HealthChecker
+	timeout  time.Duration
+}
+
+// ec2StatusChecker checks instance and system status checks of EC2
+type ec2StatusChecker struct{}
+
+// NewHealthChecker creates health checker by type
+func (d *Deployer) NewHealthChecker(config schemas.HealthChecker) (HealthChecker, error) {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = constants.DefaultHealthCheckTimeout
+		if config.Type == constants.SSMHealthChecker {
+			timeout = constants.DefaultSSMHealthCheckTimeout
+		}
+	}
+
+	switch config.Type {
+	case constants.HTTPHealthChecker:
+		return httpChecker{config: config, timeout: timeout}, nil
+	case constants.TCPHealthChecker:
+		return tcpChecker{config: config, timeout: timeout}, nil
+	case constants.SSMHealthChecker:
+		return ssmChecker{deployer: d, config: config, timeout: timeout}, nil
+	case constants.EC2StatusHealthChecker:
+		return ec2StatusChecker{}, nil
+	}
+
+	return nil, fmt.Errorf("type of health checker is not allowed: %s", config.Type)
+}
+
+// HasHealthCheckers checks if stack has health checkers
+func (d *Deployer) HasHealthCheckers() bool {
+	return d.Stack.HealthCheck != nil && len(d.Stack.HealthCheck.Checkers) > 0
+}
+
+// CheckHosts runs health checkers on valid hosts. Host stays valid only if it passes every checker.
+func (d *Deployer) CheckHosts(client aws.Client, hosts []aws.HealthcheckHost) []aws.HealthcheckHost {
+	var targets []string
+	passed := map[string]bool{}
+	for _, host := range hosts {
+		if host.Valid {
+			targets = append(targets, host.InstanceID)
+			passed[host.InstanceID] = true
+		}
+	}
+
+	if len(targets) == 0 {
+		return hosts
+	}
+
+	checks := map[string][]string{}
+	for _, c := range d.Stack.HealthCheck.Checkers {
+		name := c.Name
+		if len(name) == 0 {
+			name = c.Type
+		}
+
+		healthy := map[string]bool{}
+		checker, err := d.NewHealthChecker(c)
+		if err == nil {
+			healthy, err = checker.Check(client, targets)
+		}
+
+		// instances are regarded as unhealthy so that health check is tried again in the next polling
+		if err != nil {
+			d.Logger.Warnf("health checker %s failed: %s", name, err.Error())
+		}
+
+		for _, id := range targets {
+			status := "ok"
+			if !healthy[id] {
+				status = "fail"
+				passed[id] = false
+			}
+			checks[id] = append(checks[id], fmt.Sprintf("%s:%s", name, status))
+		}
+	}
+
+	for i, host := range hosts {
+		if host.Valid {
+			hosts[i].Valid = passed[host.InstanceID]
+			hosts[i].Checks = strings.Join(checks[host.InstanceID], ",")
+		}
+	}
+
+	return hosts
+}
+
+// autoscalingHosts makes hosts from health of autoscaling group when there is neither target group nor load balancer
+func autoscalingHosts(group *autoscaling.Group, isUpdate, downSizingUpdate bool) []aws.HealthcheckHost {
+	ret := []aws.HealthcheckHost{}
+	for _, instance := range group.Instances {
+		inService := *instance.LifecycleState == constants.InServiceStatus
+		healthy := *instance.HealthStatus == "Healthy"
+
+		valid := inService && healthy
+		if isUpdate && downSizingUpdate {
+			valid = inService || healthy
+		}
+
+		ret = append(ret, aws.HealthcheckHost{
+			InstanceID:     *instance.InstanceId,
+			LifecycleState: *instance.LifecycleState,
+			TargetStatus:   "-",
+			HealthStatus:   *instance.HealthStatus,
+			Valid:          valid,
+		})
+	}
+
+	return ret
+}
+
+// Check sends request to every instance and compares status code and body
+func (c httpChecker) Check(client aws.Client, instances []string) (map[string]bool, error) {
+	path := c.config.Path
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	expected := c.config.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+
+	var body *regexp.Regexp
+	if len(c.config.BodyMatch) > 0 {
+		r, err := regexp.Compile(c.config.BodyMatch)
+		if err != nil {
+			return nil, err
+		}
+		body = r
+	}
+
+	httpClient := &http.Client{Timeout: c.timeout}
+	return probe(client, instances, func(ip string) bool {
+		resp, err := httpClient.Get(fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, fmt.Sprintf("%d", c.config.Port)), path))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+
+		if int64(resp.StatusCode) != expected {
+			return false
+		}
+
+		if body == nil {
+			return true
+		}
+
+		b, err := ioutil.ReadAll(resp.Body)
+		return err == nil && body.Match(b)
+	})
+}
+
+// Check connects to port of every instance
+func (c tcpChecker) Check(client aws.Client, instances []string) (map[string]bool, error) {
+	return probe(client, instances, func(ip string) bool {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip, fmt.Sprintf("%d", c.config.Port)), c.timeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+
+		return true
+	})
+}
+
+// Check runs commands in instances and waits for them to finish
+func (c ssmChecker) Check(client aws.Client, instances []string) (map[string]bool, error) {
+	results, err := c.deployer.RunCommand(client, instances, c.config.Commands, c.timeout, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]bool{}
+	for _, result := range results {
+		ret[result.InstanceID] = result.Succeeded()
+		if !result.Succeeded() && len(result.Stderr) > 0 {
+			c.deployer.Logger.Debugf("[%s] health check stderr: %s", result.InstanceID, result.Stderr)
+		}
+	}
+
+	return ret, nil
+}
+
+// Check returns status checks of instances
+func (c ec2StatusChecker) Check(client aws.Client, instances []string) (map[string]bool, error) {
+	return client.EC2Service.GetInstanceStatusChecks(eaws.StringSlice(instances))
+}
+
+// probe runs check with private ip of every instance concurrently
+func probe(client aws.Client, instances []string, check func(ip string) bool) (map[string]bool, error) {
+	described, err := client.EC2Service.DescribeInstances(eaws.StringSlice(instances))
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ret := map[string]bool{}
+	for _, instance := range described {
+		if instance.PrivateIpAddress == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(id, ip string) {
+			defer wg.Done()
+			healthy := check(ip)
+
+			mu.Lock()
+			ret[id] = healthy
+			mu.Unlock()
+		}(*instance.InstanceId, *instance.PrivateIpAddress)
+	}
+	wg.Wait()
+
+	return ret, nil
+}
@@ -0,0 +1,197 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// listenerPort returns port of local listener
+func listenerPort(t *testing.T, addr string) int64 {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ret, err := strconv.ParseInt(port, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ret
+}
+
+func TestDeployer_CheckHostsWithFake(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer server.Close()
+	httpPort := listenerPort(t, server.Listener.Addr().String())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcpPort := listenerPort(t, listener.Addr().String())
+	listener.Close()
+
+	testData := []struct {
+		name        string
+		checkers    []schemas.HealthChecker
+		failures    int
+		statusCheck bool
+		valid       bool
+		checks      string
+	}{
+		{
+			name:        "http",
+			checkers:    []schemas.HealthChecker{{Type: constants.HTTPHealthChecker, Port: httpPort, Path: "/health", BodyMatch: `"status":\s*"ok"`}},
+			statusCheck: true,
+			valid:       true,
+			checks:      "http:ok",
+		},
+		{
+			name:        "http with unexpected status",
+			checkers:    []schemas.HealthChecker{{Type: constants.HTTPHealthChecker, Port: httpPort, Path: "/ready"}},
+			statusCheck: true,
+			checks:      "http:fail",
+		},
+		{
+			name:        "http with unmatched body",
+			checkers:    []schemas.HealthChecker{{Type: constants.HTTPHealthChecker, Port: httpPort, Path: "/health", BodyMatch: "warming"}},
+			statusCheck: true,
+			checks:      "http:fail",
+		},
+		{
+			name:        "tcp refused",
+			checkers:    []schemas.HealthChecker{{Name: "db", Type: constants.TCPHealthChecker, Port: tcpPort, Timeout: time.Second}},
+			statusCheck: true,
+			checks:      "db:fail",
+		},
+		{
+			name:        "tcp",
+			checkers:    []schemas.HealthChecker{{Name: "web", Type: constants.TCPHealthChecker, Port: httpPort}},
+			statusCheck: true,
+			valid:       true,
+			checks:      "web:ok",
+		},
+		{
+			name: "ssm and ec2 status",
+			checkers: []schemas.HealthChecker{
+				{Type: constants.SSMHealthChecker, Commands: []string{"systemctl is-active worker"}},
+				{Type: constants.EC2StatusHealthChecker},
+			},
+			statusCheck: true,
+			valid:       true,
+			checks:      "ssm:ok,ec2_status:ok",
+		},
+		{
+			name: "ssm failed",
+			checkers: []schemas.HealthChecker{
+				{Type: constants.SSMHealthChecker, Commands: []string{"systemctl is-active worker"}},
+				{Type: constants.EC2StatusHealthChecker},
+			},
+			failures:    1,
+			statusCheck: true,
+			checks:      "ssm:fail,ec2_status:ok",
+		},
+		{
+			name:     "ec2 status impaired",
+			checkers: []schemas.HealthChecker{{Type: constants.EC2StatusHealthChecker}},
+			checks:   "ec2_status:fail",
+		},
+	}
+
+	for _, td := range testData {
+		cloud := fake.NewCloud(fakeRegion)
+		cloud.AddAutoScalingGroup("hello-dev_v001", schemas.Capacity{Min: 1, Max: 1, Desired: 1}, nil, nil)
+		cloud.SetCommandFailures(td.failures)
+
+		group := cloud.AutoScalingGroup("hello-dev_v001")
+		id := *group.Instances[0].InstanceId
+		cloud.SetStatusCheck(id, td.statusCheck)
+
+		d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 1, Max: 1, Desired: 1})
+		d.Stack.HealthCheck = &schemas.HealthCheck{Checkers: td.checkers}
+
+		hosts := d.CheckHosts(cloud.Client(), []aws.HealthcheckHost{
+			{InstanceID: id, Valid: true},
+			{InstanceID: "i-unhealthy", Valid: false},
+		})
+
+		if hosts[0].Valid != td.valid {
+			t.Errorf("%s: expected valid %t, got %t", td.name, td.valid, hosts[0].Valid)
+		}
+
+		if hosts[0].Checks != td.checks {
+			t.Errorf("%s: expected checks %s, got %s", td.name, td.checks, hosts[0].Checks)
+		}
+
+		if hosts[1].Valid || len(hosts[1].Checks) > 0 {
+			t.Errorf("%s: invalid host should not be checked: %v", td.name, hosts[1])
+		}
+	}
+}
+
+func TestBlueGreen_HealthCheckersWithoutTargetGroupWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+
+	capacity := schemas.Capacity{Min: 2, Max: 2, Desired: 2}
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, capacity)
+	d.Stack.Regions[0].HealthcheckTargetGroup = ""
+	d.Stack.Regions[0].TargetGroups = nil
+	d.Stack.HealthCheck = &schemas.HealthCheck{
+		Checkers: []schemas.HealthChecker{{Type: constants.SSMHealthChecker, Commands: []string{"systemctl is-active worker"}}},
+	}
+
+	// the first round of checks fails so that polling should wait for the next one
+	cloud.SetCommandFailures(1)
+
+	b := &BlueGreen{Deployer: d}
+	runDeployment(t, b, newFakeConfig())
+
+	if d.HealthyCount[fakeRegion] != capacity.Desired {
+		t.Errorf("expected %d healthy instances, got %d", capacity.Desired, d.HealthyCount[fakeRegion])
+	}
+
+	var checks int
+	for _, command := range cloud.Commands() {
+		if strings.Contains(command.Commands[0], "is-active") {
+			checks++
+		}
+	}
+
+	if checks < 2 {
+		t.Errorf("health check should be retried after failure, got %d commands", checks)
+	}
+}
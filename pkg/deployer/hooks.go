@@ -0,0 +1,284 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Hooks returns lifecycle hooks of the phase
+func (d *Deployer) Hooks(phase string) []schemas.LifecycleHook {
+	lc := d.Stack.LifecycleCallbacks
+	if lc == nil {
+		return nil
+	}
+
+	switch phase {
+	case constants.PreDeployHook:
+		return lc.PreDeploy
+	case constants.PostDeployHook:
+		return lc.PostDeploy
+	case constants.PostHealthyHook:
+		return lc.PostHealthy
+	case constants.PreCleanupHook:
+		return lc.PreCleanup
+	case constants.PostCleanupHook:
+		return lc.PostCleanup
+	case constants.OnFailureHook:
+		return lc.OnFailure
+	}
+
+	return nil
+}
+
+// RunHooks runs lifecycle hooks of the phase in every region
+func (d *Deployer) RunHooks(phase string, config schemas.Config) error {
+	hooks := d.Hooks(phase)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	for _, region := range d.Stack.Regions {
+		if config.Region != "" && config.Region != region.Region {
+			d.Logger.Debugf("This region is skipped by user : %s", region.Region)
+			continue
+		}
+
+		client, err := selectClientFromList(d.AWSClients, region.Region)
+		if err != nil {
+			return err
+		}
+
+		for _, hook := range hooks {
+			d.Logger.Infof("Run %s hook %s : %s", phase, hook.Name, region.Region)
+			if err := d.runHookWithPolicy(client, phase, hook, config.PollingInterval); err != nil {
+				d.Notifier.NotifyRegion(constants.NotificationFailed, region.Region, fmt.Sprintf("%s hook is failed : %s / %s", phase, hook.Name, err.Error()))
+				if hook.FailurePolicy == constants.LifecycleCallbackContinue {
+					d.Logger.Warnf("%s hook %s failed, but deployment continues: %s", phase, hook.Name, err.Error())
+					continue
+				}
+				return fmt.Errorf("%s hook %s failed in %s: %s", phase, hook.Name, region.Region, err.Error())
+			}
+			d.Notifier.NotifyRegion(constants.NotificationProgress, region.Region, fmt.Sprintf("%s hook is finished : %s", phase, hook.Name))
+		}
+	}
+
+	return nil
+}
+
+// runHookWithPolicy runs hook again with retry policy until it succeeds
+func (d *Deployer) runHookWithPolicy(client aws.Client, phase string, hook schemas.LifecycleHook, interval time.Duration) error {
+	attempts := int64(1)
+	if hook.FailurePolicy == constants.LifecycleCallbackRetry {
+		retries := hook.Retries
+		if retries == 0 {
+			retries = constants.DefaultLifecycleCallbackRetries
+		}
+		attempts += retries
+	}
+
+	var err error
+	for attempt := int64(1); attempt <= attempts; attempt++ {
+		if err = d.runHook(client, phase, hook, interval); err == nil {
+			return nil
+		}
+		d.Logger.Warnf("%s hook %s failed(%d/%d): %s", phase, hook.Name, attempt, attempts, err.Error())
+	}
+
+	return err
+}
+
+// runHook runs hook by type
+func (d *Deployer) runHook(client aws.Client, phase string, hook schemas.LifecycleHook, interval time.Duration) error {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = constants.DefaultLifecycleCallbackTimeout
+	}
+
+	switch hook.Type {
+	case constants.SSMHook:
+		return d.runSSMHook(client, phase, hook, timeout, interval)
+	case constants.LocalHook:
+		return d.runLocalHook(client.Region, phase, hook, timeout)
+	case constants.HTTPHook:
+		return d.runHTTPHook(client.Region, phase, hook, timeout)
+	}
+
+	return fmt.Errorf("type of hook is not allowed: %s", hook.Type)
+}
+
+// runSSMHook runs commands in new or old instances
+func (d *Deployer) runSSMHook(client aws.Client, phase string, hook schemas.LifecycleHook, timeout, interval time.Duration) error {
+	targets, err := d.hookInstances(client, phase, hook)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		d.Logger.Debugf("no target instance exists for %s hook %s", phase, hook.Name)
+		return nil
+	}
+
+	results, err := d.RunCommand(client, targets, hook.Commands, timeout, interval)
+	if err != nil {
+		return err
+	}
+	d.reportCommandResults(client.Region, results)
+
+	var failed []string
+	for _, result := range results {
+		if !result.Succeeded() {
+			failed = append(failed, result.InstanceID)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("commands failed in instances: %s", strings.Join(failed, ","))
+	}
+
+	return nil
+}
+
+// hookInstances returns instances where ssm hook runs
+func (d *Deployer) hookInstances(client aws.Client, phase string, hook schemas.LifecycleHook) ([]string, error) {
+	targets := hook.Targets
+	if len(targets) == 0 {
+		targets = constants.NewInstances
+		if phase == constants.PreDeployHook || phase == constants.PreCleanupHook {
+			targets = constants.OldInstances
+		}
+	}
+
+	if targets == constants.OldInstances {
+		return d.PrevInstances[client.Region], nil
+	}
+
+	asg, ok := d.AsgNames[client.Region]
+	if !ok {
+		return nil, nil
+	}
+
+	group, err := client.EC2Service.GetMatchingAutoscalingGroup(asg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	if group != nil {
+		for _, instance := range group.Instances {
+			ret = append(ret, *instance.InstanceId)
+		}
+	}
+
+	return ret, nil
+}
+
+// runLocalHook runs commands in order with environment variables of deployment
+func (d *Deployer) runLocalHook(region, phase string, hook schemas.LifecycleHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// environment of goployer like credentials is not passed to commands
+	env := []string{
+		fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
+		fmt.Sprintf("HOME=%s", os.Getenv("HOME")),
+	}
+	for k, v := range d.hookVariables(region, phase) {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	for _, command := range hook.Commands {
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Env = env
+
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			d.Logger.Infof("[%s] %s", hook.Name, strings.TrimSpace(string(out)))
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("command timed out after %s: %s", timeout, command)
+		}
+
+		if err != nil {
+			return fmt.Errorf("command failed: %s: %s", command, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// runHTTPHook sends request with url, headers and body expanded with variables of deployment
+func (d *Deployer) runHTTPHook(region, phase string, hook schemas.LifecycleHook, timeout time.Duration) error {
+	// only variables of deployment are expanded so that environment of goployer is not sent anywhere
+	vars := d.hookVariables(region, phase)
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			return vars[key]
+		})
+	}
+
+	method := hook.Method
+	if len(method) == 0 {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), expand(hook.URL), bytes.NewBufferString(expand(hook.Body)))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range hook.Headers {
+		req.Header.Set(k, expand(v))
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code returned: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// hookVariables returns variables describing deployment in the region
+func (d *Deployer) hookVariables(region, phase string) map[string]string {
+	return map[string]string{
+		"GOPLOYER_APP":      d.AwsConfig.Name,
+		"GOPLOYER_STACK":    d.Stack.Stack,
+		"GOPLOYER_REGION":   region,
+		"GOPLOYER_PHASE":    phase,
+		"GOPLOYER_NEW_ASG":  d.AsgNames[region],
+		"GOPLOYER_OLD_ASGS": strings.Join(d.PrevAsgs[region], ","),
+	}
+}
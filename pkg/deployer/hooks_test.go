@@ -0,0 +1,104 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+func TestDeployer_RunHooksWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+	prefix := tool.BuildPrefixName(fakeApp, fakeEnv, fakeRegion)
+	prevAsg := tool.GenerateAsgName(prefix, 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 1, Max: 1, Desired: 1}, []string{tgArn}, nil)
+
+	var request, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		request = r.Method + " " + r.URL.String()
+		body = string(b)
+	}))
+	defer server.Close()
+
+	// environment of goployer should not be exposed to hooks
+	t.Setenv("HOOK_SECRET", "secret")
+
+	out := filepath.Join(t.TempDir(), "env")
+	d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+	d.Stack.LifecycleCallbacks = &schemas.LifecycleCallbacks{
+		PreDeploy: []schemas.LifecycleHook{
+			{Name: "env", Type: constants.LocalHook, Commands: []string{"echo $GOPLOYER_PHASE $GOPLOYER_APP $GOPLOYER_OLD_ASGS $HOOK_SECRET > " + out}},
+			{Name: "broken", Type: constants.LocalHook, Commands: []string{"exit 1"}, FailurePolicy: constants.LifecycleCallbackContinue},
+		},
+		PostHealthy: []schemas.LifecycleHook{
+			{Name: "warmup", Type: constants.SSMHook, Commands: []string{"curl localhost/warmup"}},
+			{Name: "purge", Type: constants.HTTPHook, URL: server.URL + "/purge?asg=${GOPLOYER_NEW_ASG}", Body: `{"stack":"${GOPLOYER_STACK}","secret":"${HOOK_SECRET}"}`},
+		},
+		OnFailure: []schemas.LifecycleHook{
+			{Name: "fail", Type: constants.LocalHook, Commands: []string{"exit 1"}},
+		},
+	}
+
+	config := newFakeConfig()
+	if err := d.CheckPrevious(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.RunHooks(constants.PreDeployHook, config); err != nil {
+		t.Fatal(err)
+	}
+
+	env, _ := os.ReadFile(out)
+	if strings.TrimSpace(string(env)) != strings.Join([]string{constants.PreDeployHook, fakeApp, prevAsg}, " ") {
+		t.Errorf("unexpected environment variables of local hook: %s", env)
+	}
+
+	b := &BlueGreen{Deployer: d}
+	if err := b.Deploy(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.RunHooks(constants.PostHealthyHook, config); err != nil {
+		t.Fatal(err)
+	}
+
+	newAsg := tool.GenerateAsgName(prefix, 1)
+	commands := cloud.Commands()
+	if len(commands) != 1 || len(commands[0].Targets) != 2 {
+		t.Errorf("ssm hook should run in new instances: %v", commands)
+	}
+
+	if request != "POST /purge?asg="+newAsg || body != `{"stack":"dev","secret":""}` {
+		t.Errorf("unexpected request of http hook: %s %s", request, body)
+	}
+
+	if err := d.RunHooks(constants.OnFailureHook, config); err == nil {
+		t.Error("error is expected from failed hook with abort policy")
+	}
+}
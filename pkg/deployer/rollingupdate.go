@@ -23,7 +23,6 @@ import (
 
 	"github.com/sirupsen/logrus"
 
-	"github.com/DevopsArtFactory/goployer/pkg/aws"
 	"github.com/DevopsArtFactory/goployer/pkg/builder"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
@@ -42,16 +41,7 @@ type RollingUpdate struct {
 
 // NewRollingUpdate creates new rolling-update deployment deployer
 func NewRollingUpdate(h *helper.DeployerHelper) *RollingUpdate {
-	var awsClients []aws.Client
-	for _, region := range h.Stack.Regions {
-		if len(h.Region) > 0 && h.Region != region.Region {
-			h.Logger.Debugf("skip creating aws clients in %s region", region.Region)
-			continue
-		}
-		awsClients = append(awsClients, aws.BootstrapServices(region.Region, h.Stack.AssumeRole))
-	}
-
-	d := InitDeploymentConfiguration(h, awsClients)
+	d := InitDeploymentConfiguration(h, BootstrapAWSClients(h))
 
 	return &RollingUpdate{
 		PrevHealthCheckTargetGroups: map[string]string{},
@@ -68,6 +58,37 @@ func (r *RollingUpdate) GetDeployer() *Deployer {
 	return r.Deployer
 }
 
+// ExportState returns the progress of rolling update deployment to persist
+func (r *RollingUpdate) ExportState(config schemas.Config) schemas.DeploymentState {
+	state := r.Deployer.ExportState(config)
+	state.PrevTargetGroups = r.PrevTargetGroups
+	state.TargetGroups = r.TargetGroups
+	state.PrevHealthCheckTargetGroups = r.PrevHealthCheckTargetGroups
+	state.LoadBalancer = r.LoadBalancer
+	state.LBSecurityGroup = r.LBSecurityGroup
+
+	return state
+}
+
+// ImportState restores the progress of rolling update deployment from persisted state
+func (r *RollingUpdate) ImportState(state schemas.DeploymentState) {
+	r.Deployer.ImportState(state)
+	copyStringMap(r.PrevHealthCheckTargetGroups, state.PrevHealthCheckTargetGroups)
+	copyStringMap(r.LoadBalancer, state.LoadBalancer)
+
+	for region, tgs := range state.PrevTargetGroups {
+		r.PrevTargetGroups[region] = tgs
+	}
+
+	for region, tgs := range state.TargetGroups {
+		r.TargetGroups[region] = tgs
+	}
+
+	for region, sg := range state.LBSecurityGroup {
+		r.LBSecurityGroup[region] = sg
+	}
+}
+
 // CheckPreviousResources checks if there is any previous version of autoscaling group
 func (r *RollingUpdate) CheckPreviousResources(config schemas.Config) error {
 	err := r.Deployer.CheckPrevious(config)
@@ -115,7 +136,7 @@ func (r *RollingUpdate) HealthChecking(config schemas.Config) error {
 
 		isDone, err := r.Deployer.HealthChecking(config)
 		if err != nil {
-			return errors.New("error happened while health checking")
+			return fmt.Errorf("error happened while health checking: %s", err.Error())
 		}
 
 		if isDone {
@@ -0,0 +1,164 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	eaws "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// scalingActivityState keeps failed activities which are already handled and fallbacks used in a region
+type scalingActivityState struct {
+	handled   map[string]bool
+	fallbacks int
+}
+
+// activityState returns state of scaling activities in the region
+func (d *Deployer) activityState(region string) *scalingActivityState {
+	if d.scalingActivityStates == nil {
+		d.scalingActivityStates = map[string]*scalingActivityState{}
+	}
+
+	if _, ok := d.scalingActivityStates[region]; !ok {
+		d.scalingActivityStates[region] = &scalingActivityState{handled: map[string]bool{}}
+	}
+
+	return d.scalingActivityStates[region]
+}
+
+// CheckScalingActivities reports failed scaling activities of autoscaling group,
+// and returns error if the latest one cannot be solved by waiting
+func (d *Deployer) CheckScalingActivities(region string, asg *autoscaling.Group, client aws.Client, since time.Time) error {
+	activities, err := client.EC2Service.DescribeScalingActivities(*asg.AutoScalingGroupName, since)
+	if err != nil {
+		d.Logger.Warnf("failed to get scaling activities of %s: %s", *asg.AutoScalingGroupName, err.Error())
+		return nil
+	}
+
+	if len(activities) == 0 {
+		return nil
+	}
+
+	// activities before the latest one are already recovered or replaced by new ones
+	latest := activities[0]
+	state := d.activityState(region)
+	if !isFailedActivity(latest) || state.handled[*latest.ActivityId] {
+		return nil
+	}
+	state.handled[*latest.ActivityId] = true
+
+	message := eaws.StringValue(latest.StatusMessage)
+	d.Logger.Warnf("[%s] Scaling activity failed : %s", *asg.AutoScalingGroupName, message)
+
+	if !isScalingFailure(message, constants.TerminalScalingFailures) && !isScalingFailure(message, constants.CapacityScalingFailures) {
+		return nil
+	}
+
+	if isScalingFailure(message, constants.CapacityScalingFailures) {
+		fallback, err := d.fallbackInstanceType(region, asg, client)
+		if err != nil {
+			return err
+		}
+
+		if fallback {
+			return nil
+		}
+	}
+
+	d.Notifier.NotifyRegion(constants.NotificationProgress, region, fmt.Sprintf(":warning: Autoscaling group cannot launch instances %s : %s", *asg.AutoScalingGroupName, message))
+
+	if sf := d.Stack.ScalingFailure; sf != nil && sf.FailurePolicy == constants.ScalingFailureContinue {
+		return nil
+	}
+
+	return fmt.Errorf("autoscaling group %s cannot launch instances in %s: %s\nrecent scaling activities:\n%s", *asg.AutoScalingGroupName, region, message, recentActivities(activities, constants.RecentScalingActivities))
+}
+
+// fallbackInstanceType changes instance type of autoscaling group to the next fallback instance type
+func (d *Deployer) fallbackInstanceType(region string, asg *autoscaling.Group, client aws.Client) (bool, error) {
+	sf := d.Stack.ScalingFailure
+	state := d.activityState(region)
+	if sf == nil || state.fallbacks >= len(sf.FallbackInstanceTypes) {
+		return false, nil
+	}
+
+	if asg.LaunchTemplate == nil || asg.LaunchTemplate.LaunchTemplateName == nil {
+		d.Logger.Warnf("fallback instance type is only supported for autoscaling group with launch template: %s", *asg.AutoScalingGroupName)
+		return false, nil
+	}
+
+	instanceType := sf.FallbackInstanceTypes[state.fallbacks]
+	state.fallbacks++
+
+	lt, err := client.EC2Service.CreateLaunchTemplateVersionWithInstanceType(*asg.LaunchTemplate.LaunchTemplateName, eaws.StringValue(asg.LaunchTemplate.Version), instanceType)
+	if err != nil {
+		return false, err
+	}
+
+	if err := client.EC2Service.UpdateAutoScalingLaunchTemplate(*asg.AutoScalingGroupName, lt); err != nil {
+		return false, err
+	}
+
+	d.Logger.Warnf("[%s] Instance type falls back to %s", *asg.AutoScalingGroupName, instanceType)
+	d.Notifier.NotifyRegion(constants.NotificationProgress, region, fmt.Sprintf(":warning: Instance type of %s falls back to %s", *asg.AutoScalingGroupName, instanceType))
+
+	return true, nil
+}
+
+// isFailedActivity checks if scaling activity is failed or cancelled
+func isFailedActivity(activity *autoscaling.Activity) bool {
+	status := eaws.StringValue(activity.StatusCode)
+	return status == autoscaling.ScalingActivityStatusCodeFailed || status == autoscaling.ScalingActivityStatusCodeCancelled
+}
+
+// isScalingFailure checks if status message contains one of failures
+func isScalingFailure(message string, failures []string) bool {
+	message = strings.ToLower(message)
+	for _, f := range failures {
+		if strings.Contains(message, f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recentActivities makes lines of the latest scaling activities
+func recentActivities(activities []*autoscaling.Activity, count int) string {
+	var lines []string
+	for i, activity := range activities {
+		if i >= count {
+			break
+		}
+
+		message := eaws.StringValue(activity.StatusMessage)
+		if len(message) == 0 {
+			message = eaws.StringValue(activity.Description)
+		}
+
+		lines = append(lines, fmt.Sprintf("- %s %s: %s", eaws.TimeValue(activity.StartTime).Format(time.RFC3339), eaws.StringValue(activity.StatusCode), message))
+	}
+
+	return strings.Join(lines, "\n")
+}
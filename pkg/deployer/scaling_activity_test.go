@@ -0,0 +1,157 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package deployer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+const (
+	insufficientCapacity = "We currently do not have sufficient t3.micro capacity in the Availability Zone you requested (ap-northeast-2a). Launching EC2 instance failed."
+	invalidAMI           = "The image id '[ami-0123456789abcdef0]' does not exist. Launching EC2 instance failed."
+)
+
+func TestIsScalingFailure(t *testing.T) {
+	testData := []struct {
+		message  string
+		terminal bool
+		capacity bool
+	}{
+		{message: insufficientCapacity, capacity: true},
+		{message: "Your Spot request price of 0.001 is lower than the minimum required Spot request fulfillment price of 0.0035.", capacity: true},
+		{message: invalidAMI, terminal: true},
+		{message: "Value (hello-profile) for parameter iamInstanceProfile.name is invalid. Invalid IAM Instance Profile name", terminal: true},
+		{message: "There are not enough free addresses in subnet 'subnet-0123' to satisfy the requested number of instances.", terminal: true},
+		{message: "Instance became unhealthy while waiting for instance to be in InService state.", terminal: false},
+	}
+
+	for _, td := range testData {
+		if got := isScalingFailure(td.message, constants.TerminalScalingFailures); got != td.terminal {
+			t.Errorf("%s: expected terminal %t, got %t", td.message, td.terminal, got)
+		}
+
+		if got := isScalingFailure(td.message, constants.CapacityScalingFailures); got != td.capacity {
+			t.Errorf("%s: expected capacity %t, got %t", td.message, td.capacity, got)
+		}
+	}
+}
+
+func TestBlueGreen_ScalingFailureWithFake(t *testing.T) {
+	testData := []struct {
+		name      string
+		message   string
+		failure   *schemas.ScalingFailure
+		polls     []bool
+		err       bool
+		instances int
+	}{
+		{
+			name:    "abort with invalid ami",
+			message: invalidAMI,
+			polls:   []bool{false},
+			err:     true,
+		},
+		{
+			name:    "abort with insufficient capacity",
+			message: insufficientCapacity,
+			polls:   []bool{false},
+			err:     true,
+		},
+		{
+			name:    "continue",
+			message: invalidAMI,
+			failure: &schemas.ScalingFailure{FailurePolicy: constants.ScalingFailureContinue},
+			polls:   []bool{false, false},
+		},
+		{
+			name:      "fallback instance type",
+			message:   insufficientCapacity,
+			failure:   &schemas.ScalingFailure{FallbackInstanceTypes: []string{"m5.large"}},
+			polls:     []bool{false, true},
+			instances: 2,
+		},
+	}
+
+	for _, td := range testData {
+		cloud := fake.NewCloud(fakeRegion)
+		cloud.AddTargetGroup("hello-dev", 80)
+		cloud.SetLaunchFailure("t3.micro", td.message)
+
+		d := newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 2, Max: 2, Desired: 2})
+		d.Stack.ScalingFailure = td.failure
+
+		b := &BlueGreen{Deployer: d}
+		config := newFakeConfig()
+		if err := b.CheckPreviousResources(config); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := b.Deploy(config); err != nil {
+			t.Fatal(err)
+		}
+
+		var err error
+		for i, expected := range td.polls {
+			var healthy bool
+			healthy, err = d.HealthChecking(config)
+			if err != nil {
+				break
+			}
+
+			if healthy != expected {
+				t.Errorf("%s: poll %d expected %t, got %t", td.name, i+1, expected, healthy)
+			}
+		}
+
+		if (err != nil) != td.err {
+			t.Fatalf("%s: unexpected error: %v", td.name, err)
+		}
+
+		if err != nil && (!strings.Contains(err.Error(), td.message) || !strings.Contains(err.Error(), "recent scaling activities")) {
+			t.Errorf("%s: error should have status message and recent activities: %s", td.name, err.Error())
+		}
+
+		if group := cloud.AutoScalingGroup(d.AsgNames[fakeRegion]); len(group.Instances) != td.instances {
+			t.Errorf("%s: expected %d instances, got %d", td.name, td.instances, len(group.Instances))
+		}
+	}
+}
+
+func TestBlueGreen_HealthCheckingErrorWithFake(t *testing.T) {
+	cloud := fake.NewCloud(fakeRegion)
+	cloud.AddTargetGroup("hello-dev", 80)
+	cloud.SetLaunchFailure("t3.micro", invalidAMI)
+
+	b := &BlueGreen{Deployer: newFakeDeployer(t, cloud, constants.BlueGreenDeployment, schemas.Capacity{Min: 1, Max: 1, Desired: 1})}
+	config := newFakeConfig()
+	if err := b.CheckPreviousResources(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Deploy(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.HealthChecking(config); err == nil || !strings.Contains(err.Error(), invalidAMI) {
+		t.Errorf("health checking should fail fast with status message: %v", err)
+	}
+}
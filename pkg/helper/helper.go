@@ -17,12 +17,14 @@ limitations under the license.
 package helper
 
 import (
+	"context"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/DevopsArtFactory/goployer/pkg/collector"
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
-	"github.com/DevopsArtFactory/goployer/pkg/slack"
 )
 
 // DeployerHelper is a struct for passing parameters when creating new deployer
@@ -32,8 +34,9 @@ type DeployerHelper struct {
 	AwsConfig        schemas.AWSConfig
 	APITestTemplates *schemas.APITestTemplate
 	Region           string
-	Slack            slack.Slack
+	Notifier         notifier.Notifier
 	Collector        collector.Collector
+	Context          context.Context
 }
 
 // InitStartStatus set start status for deployment
@@ -19,7 +19,7 @@ package inspector
 import (
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"text/tabwriter"
 	"text/template"
 	"time"
@@ -42,23 +42,23 @@ type Inspector struct {
 }
 
 type StatusSummary struct {
-	Name         string
-	Capacity     schemas.Capacity
-	CreatedTime  time.Time
-	InstanceType map[string]int64
-	Tags         []string
-	IngressRules []SecurityGroup
-	EgressRules  []SecurityGroup
+	Name         string           `json:"name"`
+	Capacity     schemas.Capacity `json:"capacity"`
+	CreatedTime  time.Time        `json:"created_time"`
+	InstanceType map[string]int64 `json:"instance_type"`
+	Tags         []string         `json:"tags"`
+	IngressRules []SecurityGroup  `json:"ingress_rules"`
+	EgressRules  []SecurityGroup  `json:"egress_rules"`
 }
 
 type SecurityGroup struct {
-	ID                  string
-	IPProtocol          string
-	FromPort            string
-	ToPort              string
-	IPRange             string
-	Description         string
-	SourceSecurityGroup string
+	ID                  string `json:"id,omitempty"`
+	IPProtocol          string `json:"ip_protocol"`
+	FromPort            string `json:"from_port"`
+	ToPort              string `json:"to_port"`
+	IPRange             string `json:"ip_range,omitempty"`
+	Description         string `json:"description,omitempty"`
+	SourceSecurityGroup string `json:"source_security_group,omitempty"`
 }
 
 type UpdateFields struct {
@@ -66,6 +66,13 @@ type UpdateFields struct {
 	Capacity        schemas.Capacity
 }
 
+// UpdateResult is the document of update
+type UpdateResult struct {
+	AutoScalingGroup string           `json:"auto_scaling_group"`
+	PreviousCapacity schemas.Capacity `json:"previous_capacity"`
+	Capacity         schemas.Capacity `json:"capacity"`
+}
+
 // New creates new Inspector
 func New(region string) Inspector {
 	return Inspector{
@@ -268,8 +275,12 @@ func (i Inspector) SetStatusSummary(asg *autoscaling.Group, sgs []*ec2.SecurityG
 	return summary
 }
 
-// Print prints the current status of deployment
-func (i Inspector) Print() error {
+// Print prints the current status of deployment with the output format
+func (i Inspector) Print(out io.Writer, output string) error {
+	if tool.IsDocumentOutput(output) {
+		return tool.PrintDocument(out, i.StatusSummary, output)
+	}
+
 	var data = struct {
 		Summary StatusSummary
 	}{
@@ -280,7 +291,7 @@ func (i Inspector) Print() error {
 		"decorate": tool.DecorateAttr,
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 5, 3, ' ', tabwriter.TabIndent)
+	w := tabwriter.NewWriter(out, 0, 5, 3, ' ', tabwriter.TabIndent)
 	t := template.Must(template.New("Describe status of deployment").Funcs(funcMap).Parse(templates.StatusResultTemplate))
 
 	err := t.Execute(w, data)
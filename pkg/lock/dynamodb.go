@@ -0,0 +1,50 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// DynamoDBBackend keeps leases in the metric table
+type DynamoDBBackend struct {
+	Client aws.DynamoDBClient
+	Table  string
+}
+
+// Acquire stores lease unless another holder has a lease which is not expired
+func (d DynamoDBBackend) Acquire(lease schemas.Lease, now time.Time) (bool, error) {
+	return d.Client.AcquireLease(d.Table, lease, now.Unix())
+}
+
+// Renew extends expiration of lease
+func (d DynamoDBBackend) Renew(lease schemas.Lease) (bool, error) {
+	return d.Client.RenewLease(d.Table, lease)
+}
+
+// Get returns lease of key
+func (d DynamoDBBackend) Get(key string) (*schemas.Lease, error) {
+	return d.Client.GetLease(d.Table, key)
+}
+
+// Delete removes lease of key
+func (d DynamoDBBackend) Delete(key, token string) error {
+	return d.Client.DeleteLease(d.Table, key, token)
+}
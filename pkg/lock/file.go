@@ -0,0 +1,189 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+const (
+	// guardRetry is how many times to wait for guard file of other process
+	guardRetry = 50
+
+	// guardInterval is the interval of waiting for guard file
+	guardInterval = 100 * time.Millisecond
+
+	// staleGuard is the age of guard file which is regarded as left by killed process
+	staleGuard = 10 * time.Second
+)
+
+// FileBackend keeps leases as files in a local directory. It only protects deployments on the same host.
+type FileBackend struct {
+	Dir string
+}
+
+// NewFileBackend creates a new file backend. Default directory is used if dir is empty.
+func NewFileBackend(dir string) (FileBackend, error) {
+	if len(dir) == 0 {
+		home, err := homedir.Dir()
+		if err != nil {
+			return FileBackend{}, err
+		}
+		dir = filepath.Join(home, constants.DefaultLockDirectory)
+	}
+
+	return FileBackend{Dir: dir}, nil
+}
+
+// Acquire stores lease unless another holder has a lease which is not expired
+func (f FileBackend) Acquire(lease schemas.Lease, now time.Time) (bool, error) {
+	acquired := false
+	err := f.withGuard(lease.Key, func() error {
+		current, err := f.Get(lease.Key)
+		if err != nil {
+			return err
+		}
+
+		if current != nil && current.Token != lease.Token && current.ExpiresAt >= now.Unix() {
+			return nil
+		}
+
+		acquired = true
+		return f.write(lease)
+	})
+
+	return acquired, err
+}
+
+// Renew extends expiration of lease if it is still held with the token
+func (f FileBackend) Renew(lease schemas.Lease) (bool, error) {
+	renewed := false
+	err := f.withGuard(lease.Key, func() error {
+		current, err := f.Get(lease.Key)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.Token != lease.Token {
+			return nil
+		}
+
+		renewed = true
+		return f.write(lease)
+	})
+
+	return renewed, err
+}
+
+// Get returns lease of key
+func (f FileBackend) Get(key string) (*schemas.Lease, error) {
+	b, err := ioutil.ReadFile(f.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var lease schemas.Lease
+	if err := json.Unmarshal(b, &lease); err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+// Delete removes lease of key
+func (f FileBackend) Delete(key, token string) error {
+	return f.withGuard(key, func() error {
+		current, err := f.Get(key)
+		if err != nil || current == nil {
+			return err
+		}
+
+		if len(token) > 0 && current.Token != token {
+			return nil
+		}
+
+		return os.Remove(f.path(key))
+	})
+}
+
+// write saves lease to the file
+func (f FileBackend) write(lease schemas.Lease) error {
+	b, err := json.MarshalIndent(lease, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := f.path(lease.Key)
+	tmp := fmt.Sprintf("%s.tmp", path)
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// withGuard runs fn while holding guard file so that other processes cannot change the lease at the same time
+func (f FileBackend) withGuard(key string, fn func() error) error {
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+
+	guard := fmt.Sprintf("%s.guard", f.path(key))
+	for i := 0; ; i++ {
+		g, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			g.Close()
+			break
+		}
+
+		if !os.IsExist(err) {
+			return err
+		}
+
+		if info, err := os.Stat(guard); err == nil && time.Since(info.ModTime()) > staleGuard {
+			os.Remove(guard)
+			continue
+		}
+
+		if i >= guardRetry {
+			return fmt.Errorf("lock file is busy: %s", guard)
+		}
+		time.Sleep(guardInterval)
+	}
+	defer os.Remove(guard)
+
+	return fn()
+}
+
+// path returns file path of lease
+func (f FileBackend) path(key string) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%s.lock", strings.ReplaceAll(key, "/", "_")))
+}
@@ -0,0 +1,250 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Backend stores leases of deployment locks
+type Backend interface {
+	// Acquire stores lease unless another holder has a lease which is not expired at now
+	Acquire(lease schemas.Lease, now time.Time) (bool, error)
+
+	// Renew extends expiration of lease if it is still held with the token
+	Renew(lease schemas.Lease) (bool, error)
+
+	// Get returns lease of key or nil if nobody holds it
+	Get(key string) (*schemas.Lease, error)
+
+	// Delete removes lease of key. Lease is removed regardless of holder if token is empty.
+	Delete(key, token string) error
+}
+
+// HeldError is returned when another holder has the lock
+type HeldError struct {
+	Lease schemas.Lease
+}
+
+func (e HeldError) Error() string {
+	return fmt.Sprintf("%s, run `goployer unlock` if the lock is stale", e.Description())
+}
+
+// Description shows key and holder of lease
+func (e HeldError) Description() string {
+	return fmt.Sprintf("%s is locked by %s since %s until %s",
+		e.Lease.Key,
+		e.Lease.Holder,
+		time.Unix(e.Lease.AcquiredAt, 0).Format(time.RFC3339),
+		time.Unix(e.Lease.ExpiresAt, 0).Format(time.RFC3339))
+}
+
+// Locker acquires deployment locks and keeps them alive with heartbeat
+type Locker struct {
+	Backend Backend
+	TTL     time.Duration
+	Holder  string
+	Logger  *Logger.Logger
+}
+
+// Lock is a set of leases held by this process until it is released
+type Lock struct {
+	locker Locker
+	leases []schemas.Lease
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewLocker creates a new locker with the identity of this process
+func NewLocker(backend Backend, ttl time.Duration, logger *Logger.Logger) Locker {
+	return Locker{
+		Backend: backend,
+		TTL:     ttl,
+		Holder:  Holder(),
+		Logger:  logger,
+	}
+}
+
+// Acquire takes locks of all keys. Locks which are already taken are released if any of them is held by others.
+func (l Locker) Acquire(keys []string) (*Lock, error) {
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+
+	lk := &Lock{
+		locker: l,
+		stop:   make(chan struct{}),
+	}
+
+	token := newToken()
+	for _, key := range sorted {
+		now := time.Now()
+		lease := schemas.Lease{
+			Key:        key,
+			Holder:     l.Holder,
+			Token:      token,
+			AcquiredAt: now.Unix(),
+			ExpiresAt:  now.Add(l.TTL).Unix(),
+		}
+
+		ok, err := l.Backend.Acquire(lease, now)
+		if err == nil && !ok {
+			err = l.heldError(key)
+		}
+
+		if err != nil {
+			lk.release()
+			return nil, err
+		}
+
+		l.Logger.Debugf("lock is acquired: %s", key)
+		lk.leases = append(lk.leases, lease)
+	}
+
+	lk.wg.Add(1)
+	go lk.heartbeat()
+
+	return lk, nil
+}
+
+// Release stops heartbeat and releases all leases
+func (lk *Lock) Release() {
+	close(lk.stop)
+	lk.wg.Wait()
+	lk.release()
+}
+
+// Keys returns keys of lock
+func (lk *Lock) Keys() []string {
+	var ret []string
+	for _, lease := range lk.leases {
+		ret = append(ret, lease.Key)
+	}
+
+	return ret
+}
+
+// release deletes leases from backend
+func (lk *Lock) release() {
+	for _, lease := range lk.leases {
+		if err := lk.locker.Backend.Delete(lease.Key, lease.Token); err != nil {
+			lk.locker.Logger.Warnf("failed to release lock of %s, it will expire in %s: %s", lease.Key, lk.locker.TTL, err.Error())
+			continue
+		}
+		lk.locker.Logger.Debugf("lock is released: %s", lease.Key)
+	}
+}
+
+// heartbeat renews leases before they expire
+func (lk *Lock) heartbeat() {
+	defer lk.wg.Done()
+
+	ticker := time.NewTicker(lk.locker.TTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-ticker.C:
+			for i := range lk.leases {
+				lk.leases[i].ExpiresAt = time.Now().Add(lk.locker.TTL).Unix()
+				ok, err := lk.locker.Backend.Renew(lk.leases[i])
+				if err != nil {
+					lk.locker.Logger.Warnf("failed to renew lock of %s: %s", lk.leases[i].Key, err.Error())
+					continue
+				}
+
+				if !ok {
+					lk.locker.Logger.Errorf("lock of %s is taken by others, another deployment may be running", lk.leases[i].Key)
+				}
+			}
+		}
+	}
+}
+
+// heldError returns error with the current holder of lock
+func (l Locker) heldError(key string) error {
+	current, err := l.Backend.Get(key)
+	if err != nil {
+		return err
+	}
+
+	if current == nil {
+		return fmt.Errorf("failed to acquire lock of %s, try again", key)
+	}
+
+	return HeldError{Lease: *current}
+}
+
+// Key returns lock key of stack in region
+func Key(app, stack, region string) string {
+	return strings.Join([]string{app, stack, region}, "/")
+}
+
+// Keys returns lock keys of stacks. All regions of stack are locked if region is empty.
+func Keys(app string, stacks []schemas.Stack, region string) []string {
+	var ret []string
+	for _, stack := range stacks {
+		if len(region) > 0 {
+			ret = append(ret, Key(app, stack.Stack, region))
+			continue
+		}
+
+		for _, r := range stack.Regions {
+			ret = append(ret, Key(app, stack.Stack, r.Region))
+		}
+	}
+
+	return ret
+}
+
+// Holder returns identity of this process
+func Holder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+// newToken creates a random token of lease
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
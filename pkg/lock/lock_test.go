@@ -0,0 +1,176 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func testBackends(t *testing.T) (map[string]Backend, func()) {
+	dir, err := ioutil.TempDir("", "goployer-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cloud := fake.NewCloud("ap-northeast-2")
+	client := cloud.MetricClient().DynamoDBService
+	if err := client.CreateTable("goployer-metrics", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]Backend{
+		"file":     FileBackend{Dir: dir},
+		"dynamodb": DynamoDBBackend{Client: client, Table: "goployer-metrics"},
+	}, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBackend(t *testing.T) {
+	backends, cleanup := testBackends(t)
+	defer cleanup()
+
+	now := time.Now()
+	first := schemas.Lease{Key: "hello/artd/ap-northeast-2", Holder: "alice", Token: "a", AcquiredAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+	second := schemas.Lease{Key: first.Key, Holder: "bob", Token: "b", AcquiredAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	for name, b := range backends {
+		if ok, err := b.Acquire(first, now); err != nil || !ok {
+			t.Fatalf("%s: first lease should be acquired: %v", name, err)
+		}
+
+		if ok, err := b.Acquire(second, now); err != nil || ok {
+			t.Errorf("%s: lock should not be acquired while it is held: %v", name, err)
+		}
+
+		if lease, err := b.Get(first.Key); err != nil || lease == nil || lease.Holder != "alice" || lease.ExpiresAt != first.ExpiresAt {
+			t.Errorf("%s: unexpected lease: %+v, %v", name, lease, err)
+		}
+
+		if ok, err := b.Renew(second); err != nil || ok {
+			t.Errorf("%s: lease of others should not be renewed: %v", name, err)
+		}
+
+		if err := b.Delete(first.Key, second.Token); err != nil {
+			t.Error(err)
+		}
+		if lease, _ := b.Get(first.Key); lease == nil {
+			t.Errorf("%s: lease should not be deleted with token of others", name)
+		}
+
+		// expired lease can be taken over
+		if ok, err := b.Acquire(second, now.Add(2*time.Minute)); err != nil || !ok {
+			t.Errorf("%s: expired lease should be taken over: %v", name, err)
+		}
+
+		if ok, err := b.Renew(first); err != nil || ok {
+			t.Errorf("%s: lost lease should not be renewed: %v", name, err)
+		}
+
+		if err := b.Delete(first.Key, ""); err != nil {
+			t.Error(err)
+		}
+		if lease, _ := b.Get(first.Key); lease != nil {
+			t.Errorf("%s: lease should be deleted without token: %+v", name, lease)
+		}
+	}
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	backends, cleanup := testBackends(t)
+	defer cleanup()
+
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+
+	stacks := []schemas.Stack{
+		{Stack: "artd", Regions: []schemas.RegionConfig{{Region: "ap-northeast-2"}, {Region: "us-east-1"}}},
+	}
+
+	for name, b := range backends {
+		alice := Locker{Backend: b, TTL: time.Minute, Holder: "alice", Logger: logger}
+		bob := Locker{Backend: b, TTL: time.Minute, Holder: "bob", Logger: logger}
+
+		lk, err := alice.Acquire(Keys("hello", stacks, ""))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if len(lk.Keys()) != 2 {
+			t.Errorf("%s: all regions should be locked: %v", name, lk.Keys())
+		}
+
+		// bob takes ap-northeast-2 first, but it should be released when us-east-1 is held by alice
+		lk.Release()
+		other, err := alice.Acquire([]string{Key("hello", "artd", "us-east-1")})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = bob.Acquire(Keys("hello", stacks, ""))
+		var held HeldError
+		if !errors.As(err, &held) || held.Lease.Holder != "alice" {
+			t.Errorf("%s: expected lock held by alice, got %v", name, err)
+		}
+
+		if lease, _ := b.Get(Key("hello", "artd", "ap-northeast-2")); lease != nil {
+			t.Errorf("%s: partially acquired lock should be released: %+v", name, lease)
+		}
+
+		other.Release()
+		if lease, _ := b.Get(Key("hello", "artd", "us-east-1")); lease != nil {
+			t.Errorf("%s: lock should be released: %+v", name, lease)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	stacks := []schemas.Stack{
+		{Stack: "artd", Regions: []schemas.RegionConfig{{Region: "ap-northeast-2"}, {Region: "us-east-1"}}},
+		{Stack: "artp", Regions: []schemas.RegionConfig{{Region: "ap-northeast-2"}}},
+	}
+
+	testData := []struct {
+		region   string
+		expected []string
+	}{
+		{region: "", expected: []string{"hello/artd/ap-northeast-2", "hello/artd/us-east-1", "hello/artp/ap-northeast-2"}},
+		{region: "ap-northeast-2", expected: []string{"hello/artd/ap-northeast-2", "hello/artp/ap-northeast-2"}},
+	}
+
+	for _, td := range testData {
+		keys := Keys("hello", stacks, td.region)
+		if len(keys) != len(td.expected) {
+			t.Fatalf("expected %v, got %v", td.expected, keys)
+		}
+
+		for i := range keys {
+			if keys[i] != td.expected[i] {
+				t.Errorf("expected %v, got %v", td.expected, keys)
+			}
+		}
+	}
+}
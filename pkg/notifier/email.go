@@ -0,0 +1,76 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Email sends messages with SMTP
+type Email struct {
+	SMTP schemas.SMTPConfig
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+var _ Channel = Email{}
+
+// NewEmail creates email channel
+func NewEmail(config schemas.SMTPConfig) Email {
+	if config.Port == 0 {
+		config.Port = constants.DefaultSMTPPort
+	}
+
+	return Email{
+		SMTP: config,
+		send: smtp.SendMail,
+	}
+}
+
+// Send sends message as plain text mail
+func (e Email) Send(msg Message) error {
+	var auth smtp.Auth
+	if len(e.SMTP.Username) > 0 {
+		auth = smtp.PlainAuth("", e.SMTP.Username, e.SMTP.Password, e.SMTP.Host)
+	}
+
+	addr := fmt.Sprintf("%s:%d", e.SMTP.Host, e.SMTP.Port)
+	return e.send(addr, auth, e.SMTP.From, e.SMTP.To, e.body(msg))
+}
+
+// body creates mail with headers
+func (e Email) body(msg Message) []byte {
+	subject := fmt.Sprintf("[goployer] %s %s", msg.Application, msg.Event)
+	if len(msg.Stack) > 0 {
+		subject = fmt.Sprintf("[goployer] %s/%s %s", msg.Application, msg.Stack, msg.Event)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.SMTP.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.SMTP.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(plainText(msg), "\n", "\r\n"))
+	b.WriteString("\r\n")
+
+	return []byte(b.String())
+}
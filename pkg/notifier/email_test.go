@@ -0,0 +1,59 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestEmail_Send(t *testing.T) {
+	var addr string
+	var body []byte
+	e := NewEmail(schemas.SMTPConfig{
+		Host: "smtp.example.com",
+		From: "goployer@example.com",
+		To:   []string{"ops@example.com", "dev@example.com"},
+	})
+	e.send = func(a string, _ smtp.Auth, _ string, _ []string, msg []byte) error {
+		addr = a
+		body = msg
+		return nil
+	}
+
+	msg := Message{Event: constants.NotificationCleaned, Application: "hello", Stack: "artd", Text: "All instances are deleted"}
+	if err := e.Send(msg); err != nil {
+		t.Error(err)
+	}
+
+	if addr != "smtp.example.com:587" {
+		t.Errorf("default port is expected: %s", addr)
+	}
+	for _, expected := range []string{
+		"To: ops@example.com, dev@example.com\r\n",
+		"Subject: [goployer] hello/artd cleaned\r\n",
+		"All instances are deleted",
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("%q is not in mail: %s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/slack"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Notifier sends notifications of deployment
+type Notifier interface {
+	// Enabled checks if there is any channel to send notifications to
+	Enabled() bool
+
+	// Notify sends simple message of event
+	Notify(event, text string) error
+
+	// NotifyRegion sends simple message of event in the region
+	NotifyRegion(event, region, text string) error
+
+	// NotifySummary sends configurations of deployment when it starts
+	NotifySummary(config schemas.Config, stacks []schemas.Stack) error
+
+	// NotifyAPITestResult sends result of API test
+	NotifyAPITestResult(metrics []schemas.MetricResult) error
+}
+
+// Channel is a destination of notifications like slack or email
+type Channel interface {
+	Send(msg Message) error
+}
+
+// Message is a notification of deployment
+type Message struct {
+	Event       string                     `json:"event"`
+	Application string                     `json:"application"`
+	Stack       string                     `json:"stack,omitempty"`
+	Region      string                     `json:"region,omitempty"`
+	Text        string                     `json:"text"`
+	Time        string                     `json:"time"`
+	Summary     *schemas.DeploymentSummary `json:"summary,omitempty"`
+	APITest     []schemas.MetricResult     `json:"api_test,omitempty"`
+
+	// configurations and stacks for the summary message of slack
+	config schemas.Config
+	stacks []schemas.Stack
+}
+
+// Router sends messages to channels according to the routes of events
+type Router struct {
+	Application string
+	Stack       string
+	channels    map[string]*channel
+	names       []string
+	routes      []schemas.NotificationRoute
+}
+
+// channel is turned off after it fails to send a message so that deployment is not delayed by it
+type channel struct {
+	Channel
+	mu  *sync.Mutex
+	off bool
+}
+
+var _ Notifier = Router{}
+
+// New creates router with channels in the manifest and slack channel configured with environment variables.
+// Router has no channel if notifications are turned off.
+func New(app string, notifications *schemas.Notifications, off bool) (Router, error) {
+	r := Router{
+		Application: app,
+		channels:    map[string]*channel{},
+	}
+
+	if off {
+		return r, nil
+	}
+
+	if s := slack.NewSlackClient(false); s.ValidClient() {
+		r.AddChannel(constants.EnvSlackChannelName, NewSlackWithClient(s))
+	}
+
+	if notifications == nil {
+		return r, nil
+	}
+
+	for _, nc := range notifications.Channels {
+		c, err := NewChannel(nc)
+		if err != nil {
+			return r, err
+		}
+		r.AddChannel(nc.Name, c)
+	}
+	r.routes = notifications.Routes
+
+	return r, nil
+}
+
+// NewChannel creates channel with configuration whose values are expanded with environment variables
+func NewChannel(nc schemas.NotificationChannel) (Channel, error) {
+	switch nc.Type {
+	case constants.SlackNotification:
+		return NewSlack(os.ExpandEnv(nc.WebhookURL), os.ExpandEnv(nc.Token), os.ExpandEnv(nc.Channel)), nil
+	case constants.TeamsNotification:
+		return Teams{WebhookURL: os.ExpandEnv(nc.WebhookURL)}, nil
+	case constants.WebhookNotification:
+		return Webhook{URL: os.ExpandEnv(nc.WebhookURL), Secret: os.ExpandEnv(nc.Secret)}, nil
+	case constants.EmailNotification:
+		if nc.SMTP == nil {
+			return nil, fmt.Errorf("smtp is required for email channel: %s", nc.Name)
+		}
+		smtp := *nc.SMTP
+		smtp.Host = os.ExpandEnv(smtp.Host)
+		smtp.Username = os.ExpandEnv(smtp.Username)
+		smtp.Password = os.ExpandEnv(smtp.Password)
+		smtp.From = os.ExpandEnv(smtp.From)
+		return NewEmail(smtp), nil
+	default:
+		return nil, fmt.Errorf("type of notification channel is not allowed: %s", nc.Type)
+	}
+}
+
+// AddChannel adds channel with the name. Channel with the same name is replaced.
+func (r *Router) AddChannel(name string, c Channel) {
+	if _, ok := r.channels[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.channels[name] = &channel{Channel: c, mu: &sync.Mutex{}}
+}
+
+// ForStack returns router for stack. Routes of stack replace the routes of manifest.
+func (r Router) ForStack(stack schemas.Stack) Router {
+	r.Stack = stack.Stack
+	if stack.Notifications != nil {
+		r.routes = stack.Notifications.Routes
+	}
+
+	return r
+}
+
+// Enabled checks if there is any channel
+func (r Router) Enabled() bool {
+	return len(r.channels) > 0
+}
+
+// Notify sends simple message of event
+func (r Router) Notify(event, text string) error {
+	return r.send(r.message(event, text))
+}
+
+// NotifyRegion sends simple message of event in the region
+func (r Router) NotifyRegion(event, region, text string) error {
+	msg := r.message(event, text)
+	msg.Region = region
+
+	return r.send(msg)
+}
+
+// NotifySummary sends configurations of deployment when it starts
+func (r Router) NotifySummary(config schemas.Config, stacks []schemas.Stack) error {
+	msg := r.message(constants.NotificationStart, fmt.Sprintf("[ %s ] Deployment has been started", r.Application))
+	summary := builder.Builder{
+		AwsConfig: schemas.AWSConfig{Name: r.Application},
+		Config:    config,
+		Stacks:    stacks,
+	}.Summary(constants.EmptyString, config.Region)
+	msg.Summary = &summary
+	msg.config = config
+	msg.stacks = stacks
+
+	return r.send(msg)
+}
+
+// NotifyAPITestResult sends result of API test
+func (r Router) NotifyAPITestResult(metrics []schemas.MetricResult) error {
+	msg := r.message(constants.NotificationAPITest, fmt.Sprintf("API test is finished : %s", r.Stack))
+	msg.APITest = metrics
+
+	return r.send(msg)
+}
+
+// Targets returns names of channels which event is routed to
+func (r Router) Targets(event string) []string {
+	if len(r.routes) == 0 {
+		return r.names
+	}
+
+	var ret []string
+	for _, route := range r.routes {
+		if len(route.Events) > 0 && !tool.IsStringInArray(event, route.Events) {
+			continue
+		}
+
+		for _, name := range route.Channels {
+			if _, ok := r.channels[name]; ok && !tool.IsStringInArray(name, ret) {
+				ret = append(ret, name)
+			}
+		}
+	}
+
+	return ret
+}
+
+// message creates message of event
+func (r Router) message(event, text string) Message {
+	return Message{
+		Event:       event,
+		Application: r.Application,
+		Stack:       r.Stack,
+		Text:        text,
+		Time:        time.Now().Format(time.RFC3339),
+	}
+}
+
+// send sends message to the routed channels
+func (r Router) send(msg Message) error {
+	var errs []string
+	for _, name := range r.Targets(msg.Event) {
+		if err := r.channels[name].send(msg); err != nil {
+			logrus.Warnf("notification channel %s is turned off: %s", name, err.Error())
+			errs = append(errs, fmt.Sprintf("%s: %s", name, err.Error()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send notification: %s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// send sends message unless channel is turned off
+func (c *channel) send(msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.off {
+		return nil
+	}
+
+	if err := c.Send(msg); err != nil {
+		c.off = true
+		return err
+	}
+
+	return nil
+}
+
+// plainText renders message as plain text with summary and API test result
+func plainText(msg Message) string {
+	lines := []string{msg.Text}
+
+	if msg.Summary != nil {
+		for _, s := range msg.Summary.Stacks {
+			lines = append(lines, fmt.Sprintf("stack: %s, replacement type: %s, regions: %s, capacity: min %d / desired %d / max %d",
+				s.Stack, s.ReplacementType, strings.Join(s.Regions, ","), s.Capacity.Min, s.Capacity.Desired, s.Capacity.Max))
+		}
+		for _, kv := range builder.ExtractAppliedConfig(msg.config) {
+			lines = append(lines, fmt.Sprintf("%s: %s", kv[0], kv[1]))
+		}
+	}
+
+	for _, m := range msg.APITest {
+		lines = append(lines, apiTestText(m))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// apiTestText renders result of API test
+func apiTestText(m schemas.MetricResult) string {
+	return fmt.Sprintf("API: %s %s, duration: %s, requests: %d, rate: %s, success: %s, latency p99: %s",
+		m.Method,
+		m.URL,
+		tool.RoundTime(m.Data.Duration),
+		m.Data.Requests,
+		tool.RoundNum(m.Data.Rate),
+		tool.RoundNum(m.Data.Success),
+		tool.RoundTime(m.Data.Latencies.P99),
+	)
+}
+
+// postJSON posts json body to url and checks status code of response
+func postJSON(url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code returned: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+type fakeChannel struct {
+	events *[]string
+	err    error
+}
+
+func (f fakeChannel) Send(msg Message) error {
+	*f.events = append(*f.events, msg.Event)
+	return f.err
+}
+
+func TestRouter_Targets(t *testing.T) {
+	r, _ := New("hello", nil, true)
+	for _, name := range []string{"ops", "dev", "mail"} {
+		r.AddChannel(name, fakeChannel{events: &[]string{}})
+	}
+
+	if got := r.Targets(constants.NotificationFailed); !reflect.DeepEqual(got, []string{"ops", "dev", "mail"}) {
+		t.Errorf("all channels are expected without routes, got: %v", got)
+	}
+
+	r.routes = []schemas.NotificationRoute{
+		{Events: []string{constants.NotificationFailed}, Channels: []string{"ops", "mail"}},
+		{Channels: []string{"dev", "unknown"}},
+	}
+
+	testData := []struct {
+		event    string
+		expected []string
+	}{
+		{event: constants.NotificationFailed, expected: []string{"ops", "mail", "dev"}},
+		{event: constants.NotificationStart, expected: []string{"dev"}},
+	}
+
+	for _, td := range testData {
+		if got := r.Targets(td.event); !reflect.DeepEqual(got, td.expected) {
+			t.Errorf("%s: expected %v, got %v", td.event, td.expected, got)
+		}
+	}
+
+	stack := r.ForStack(schemas.Stack{
+		Stack: "artd",
+		Notifications: &schemas.StackNotifications{
+			Routes: []schemas.NotificationRoute{{Channels: []string{"mail"}}},
+		},
+	})
+	if got := stack.Targets(constants.NotificationStart); !reflect.DeepEqual(got, []string{"mail"}) {
+		t.Errorf("routes of stack are expected, got: %v", got)
+	}
+	if stack.Stack != "artd" {
+		t.Errorf("stack is not set: %s", stack.Stack)
+	}
+}
+
+func TestRouter_TurnOffFailedChannel(t *testing.T) {
+	var ok, failed []string
+	r, _ := New("hello", nil, true)
+	r.AddChannel("ok", fakeChannel{events: &ok})
+	r.AddChannel("failed", fakeChannel{events: &failed, err: errors.New("unreachable")})
+
+	if err := r.Notify(constants.NotificationStart, "start"); err == nil {
+		t.Error("error is expected when channel fails")
+	}
+	if err := r.Notify(constants.NotificationFinished, "finished"); err != nil {
+		t.Errorf("failed channel should be turned off: %v", err)
+	}
+
+	if !reflect.DeepEqual(ok, []string{constants.NotificationStart, constants.NotificationFinished}) {
+		t.Errorf("unexpected events of ok channel: %v", ok)
+	}
+	if !reflect.DeepEqual(failed, []string{constants.NotificationStart}) {
+		t.Errorf("unexpected events of failed channel: %v", failed)
+	}
+}
+
+func TestNew(t *testing.T) {
+	notifications := &schemas.Notifications{
+		Channels: []schemas.NotificationChannel{
+			{Name: "teams", Type: constants.TeamsNotification, WebhookURL: "https://example.com/teams"},
+		},
+	}
+
+	r, err := New("hello", notifications, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if r.Enabled() {
+		t.Error("notifications should be turned off")
+	}
+
+	r, err = New("hello", notifications, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if !r.Enabled() {
+		t.Error("notifications should be enabled")
+	}
+
+	notifications.Channels = append(notifications.Channels, schemas.NotificationChannel{Name: "pager", Type: "pager"})
+	if _, err := New("hello", notifications, false); err == nil {
+		t.Error("error is expected for unknown type")
+	}
+}
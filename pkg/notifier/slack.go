@@ -0,0 +1,209 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	slackgo "github.com/slack-go/slack"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/slack"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+// Slack sends messages to slack channel.
+// With bot token, deployment is posted as one message which is updated with progress and details are sent to its thread.
+// With webhook, message is sent only when the state of stack and region is changed.
+type Slack struct {
+	Client slack.Slack
+	thread *slackThread
+}
+
+// slackThread is the state of deployment message shared by stacks
+type slackThread struct {
+	ts       string
+	app      string
+	keys     []string
+	rows     map[string]slackRow
+	last     map[string]string
+	failed   bool
+	finished bool
+}
+
+// slackRow is the latest state of stack and region
+type slackRow struct {
+	stack  string
+	region string
+	event  string
+	text   string
+}
+
+var _ Channel = Slack{}
+
+// NewSlack creates slack channel with webhook url or token and channel ID
+func NewSlack(webhookURL, token, channelID string) Slack {
+	return NewSlackWithClient(slack.Slack{
+		Client:     slackgo.New(token),
+		Token:      token,
+		ChannelID:  channelID,
+		WebhookURL: webhookURL,
+		Color:      tool.GetRandomRGBColor(),
+	})
+}
+
+// NewSlackWithClient creates slack channel with client
+func NewSlackWithClient(client slack.Slack) Slack {
+	return Slack{
+		Client: client,
+		thread: &slackThread{
+			rows: map[string]slackRow{},
+			last: map[string]string{},
+		},
+	}
+}
+
+// Send sends message to slack
+func (s Slack) Send(msg Message) error {
+	changed := s.thread.update(msg)
+
+	if len(s.Client.WebhookURL) > 0 {
+		if !changed {
+			return nil
+		}
+		return s.sendDetail(s.Client, msg)
+	}
+
+	if len(s.thread.ts) == 0 {
+		ts, err := s.Client.PostMessage(s.thread.options(s.Client.Color)...)
+		if err != nil {
+			return err
+		}
+		s.thread.ts = ts
+	}
+
+	if !changed {
+		return nil
+	}
+
+	threaded := s.Client
+	threaded.ThreadTS = s.thread.ts
+	if err := s.sendDetail(threaded, msg); err != nil {
+		return err
+	}
+
+	return s.Client.UpdateMessage(s.thread.ts, s.thread.options(s.Client.Color)...)
+}
+
+// sendDetail sends message with the client
+func (s Slack) sendDetail(client slack.Slack, msg Message) error {
+	switch {
+	case msg.Event == constants.NotificationStart && msg.Summary != nil:
+		return client.SendSummaryMessage(msg.config, msg.stacks, msg.Application)
+	case msg.Event == constants.NotificationAPITest:
+		return client.SendAPITestResultMessage(msg.APITest)
+	default:
+		return client.SendSimpleMessage(msg.Text)
+	}
+}
+
+// update applies message to the progress and checks if the state is changed
+func (t *slackThread) update(msg Message) bool {
+	t.app = msg.Application
+
+	switch msg.Event {
+	case constants.NotificationFinished:
+		t.finished = true
+	case constants.NotificationFailed:
+		t.failed = true
+	}
+
+	if msg.Summary != nil {
+		for _, ss := range msg.Summary.Stacks {
+			for _, region := range ss.Regions {
+				t.set(slackRow{stack: ss.Stack, region: region, event: msg.Event, text: "Deployment has been started"})
+			}
+		}
+	}
+
+	if len(msg.Stack) == 0 || msg.Event == constants.NotificationStart || msg.Event == constants.NotificationAPITest {
+		return true
+	}
+
+	key := fmt.Sprintf("%s/%s", msg.Stack, msg.Region)
+	if t.last[key] == msg.Text {
+		return false
+	}
+	t.last[key] = msg.Text
+
+	t.set(slackRow{stack: msg.Stack, region: msg.Region, event: msg.Event, text: msg.Text})
+	return true
+}
+
+// set replaces the row of stack and region
+func (t *slackThread) set(row slackRow) {
+	key := fmt.Sprintf("%s/%s", row.stack, row.region)
+	if _, ok := t.rows[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.rows[key] = row
+}
+
+// options creates message options of the progress table
+func (t *slackThread) options(color string) []slackgo.MsgOption {
+	title := fmt.Sprintf("*[ %s ] Deployment is in progress*", t.app)
+	switch {
+	case t.failed:
+		title = fmt.Sprintf("*[ %s ] Deployment has failed*", t.app)
+		color = "danger"
+	case t.finished:
+		title = fmt.Sprintf("*[ %s ] Deployment is done*", t.app)
+		color = "good"
+	}
+
+	attachment := slackgo.Attachment{
+		Color:      color,
+		Text:       fmt.Sprintf("```\n%s```", t.table()),
+		MarkdownIn: []string{"text"},
+	}
+
+	return []slackgo.MsgOption{
+		slackgo.MsgOptionText(title, false),
+		slackgo.MsgOptionAttachments(attachment),
+	}
+}
+
+// table renders the latest states of stacks and regions
+func (t *slackThread) table() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STACK\tREGION\tSTATUS\tMESSAGE")
+	for _, key := range t.keys {
+		row := t.rows[key]
+		region := row.region
+		if len(region) == 0 {
+			region = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.stack, region, row.event, strings.TrimSpace(row.text))
+	}
+	w.Flush()
+
+	return buf.String()
+}
@@ -0,0 +1,137 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	slackgo "github.com/slack-go/slack"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/slack"
+)
+
+type slackRequest struct {
+	method string
+	form   url.Values
+}
+
+// fakeSlackAPI records requests of chat API
+func fakeSlackAPI(requests *[]slackRequest) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		*requests = append(*requests, slackRequest{method: strings.TrimPrefix(r.URL.Path, "/"), form: r.PostForm})
+		ts := fmt.Sprintf("%d.0", len(*requests))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"ok":true,"channel":"C1","ts":"%s"}`, ts)
+	}))
+}
+
+func TestSlack_SendInThread(t *testing.T) {
+	var requests []slackRequest
+	server := fakeSlackAPI(&requests)
+	defer server.Close()
+
+	s := NewSlackWithClient(slack.Slack{
+		Client:    slackgo.New("token", slackgo.OptionAPIURL(server.URL+"/")),
+		Token:     "token",
+		ChannelID: "C1",
+	})
+
+	r, _ := New("hello", nil, true)
+	r.AddChannel("slack", s)
+	stack := r.ForStack(schemas.Stack{Stack: "artd"})
+
+	stack.Notify(constants.NotificationStart, "Deployment has been started")
+	stack.NotifyRegion(constants.NotificationProgress, "us-east-1", "Waiting for healthy instances : 0/2")
+	stack.NotifyRegion(constants.NotificationProgress, "us-east-1", "Waiting for healthy instances : 0/2")
+	stack.NotifyRegion(constants.NotificationHealthy, "us-east-1", "All instances are healthy : 2/2")
+	r.Notify(constants.NotificationFinished, "Deployment is done")
+
+	var methods []string
+	for _, req := range requests {
+		methods = append(methods, req.method)
+	}
+	expected := []string{
+		"chat.postMessage",                // deployment message
+		"chat.postMessage", "chat.update", // start
+		"chat.postMessage", "chat.update", // waiting, and the same message is skipped
+		"chat.postMessage", "chat.update", // healthy
+		"chat.postMessage", "chat.update", // finished
+	}
+	if strings.Join(methods, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, methods)
+	}
+
+	if ts := requests[0].form.Get("thread_ts"); len(ts) > 0 {
+		t.Errorf("deployment message should not be in thread: %s", ts)
+	}
+	for _, req := range requests[1:] {
+		if req.method == "chat.postMessage" && req.form.Get("thread_ts") != "1.0" {
+			t.Errorf("details should be sent to thread: %v", req.form)
+		}
+		if req.method == "chat.update" && req.form.Get("ts") != "1.0" {
+			t.Errorf("deployment message should be updated: %v", req.form)
+		}
+	}
+
+	last := requests[len(requests)-1].form
+	if !strings.Contains(last.Get("text"), "Deployment is done") || !strings.Contains(last.Get("attachments"), `"color":"good"`) {
+		t.Errorf("unexpected final message: %v", last)
+	}
+	if !strings.Contains(last.Get("attachments"), "All instances are healthy") {
+		t.Errorf("progress of region is not in table: %s", last.Get("attachments"))
+	}
+}
+
+func TestSlack_SendWithWebhook(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	s := NewSlack(server.URL, "", "")
+	msg := Message{Event: constants.NotificationProgress, Stack: "artd", Region: "us-east-1", Text: "Waiting for healthy instances : 0/2"}
+	for i := 0; i < 3; i++ {
+		if err := s.Send(msg); err != nil {
+			t.Error(err)
+		}
+	}
+
+	msg.Text = "Waiting for healthy instances : 1/2"
+	s.Send(msg)
+
+	msg.Region = "ap-northeast-2"
+	s.Send(msg)
+
+	if sent != 3 {
+		t.Errorf("message should be sent only when state is changed, sent: %d", sent)
+	}
+}
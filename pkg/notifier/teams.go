@@ -0,0 +1,116 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// Teams sends messages to Microsoft Teams with incoming webhook
+type Teams struct {
+	WebhookURL string
+}
+
+var _ Channel = Teams{}
+
+// teamsCard is a legacy actionable message card of incoming webhook
+type teamsCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	Summary    string         `json:"summary"`
+	ThemeColor string         `json:"themeColor"`
+	Title      string         `json:"title"`
+	Text       string         `json:"text,omitempty"`
+	Sections   []teamsSection `json:"sections,omitempty"`
+}
+
+type teamsSection struct {
+	ActivityTitle string      `json:"activityTitle,omitempty"`
+	Facts         []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Send sends message card to teams
+func (t Teams) Send(msg Message) error {
+	body, err := json.Marshal(t.card(msg))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(t.WebhookURL, body, nil)
+}
+
+// card creates message card from message
+func (t Teams) card(msg Message) teamsCard {
+	card := teamsCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    msg.Text,
+		ThemeColor: themeColor(msg.Event),
+		Title:      fmt.Sprintf("[ %s ] %s", msg.Application, msg.Event),
+		Text:       msg.Text,
+	}
+
+	if msg.Summary != nil {
+		for _, s := range msg.Summary.Stacks {
+			card.Sections = append(card.Sections, teamsSection{
+				ActivityTitle: s.Stack,
+				Facts: []teamsFact{
+					{Name: "replacement type", Value: s.ReplacementType},
+					{Name: "regions", Value: fmt.Sprintf("%v", s.Regions)},
+					{Name: "capacity", Value: fmt.Sprintf("min %d / desired %d / max %d", s.Capacity.Min, s.Capacity.Desired, s.Capacity.Max)},
+				},
+			})
+		}
+
+		section := teamsSection{ActivityTitle: "configurations"}
+		for _, kv := range builder.ExtractAppliedConfig(msg.config) {
+			section.Facts = append(section.Facts, teamsFact{Name: kv[0], Value: kv[1]})
+		}
+		if len(section.Facts) > 0 {
+			card.Sections = append(card.Sections, section)
+		}
+	}
+
+	for _, m := range msg.APITest {
+		card.Sections = append(card.Sections, teamsSection{
+			Facts: []teamsFact{{Name: "result", Value: apiTestText(m)}},
+		})
+	}
+
+	return card
+}
+
+// themeColor returns color of card by event
+func themeColor(event string) string {
+	switch event {
+	case constants.NotificationFailed:
+		return "d9534f"
+	case constants.NotificationHealthy, constants.NotificationCleaned, constants.NotificationFinished:
+		return "5cb85c"
+	default:
+		return "0076d7"
+	}
+}
@@ -0,0 +1,54 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestTeams_Send(t *testing.T) {
+	var card teamsCard
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&card)
+	}))
+	defer server.Close()
+
+	msg := Message{
+		Event:       constants.NotificationFailed,
+		Application: "hello",
+		Text:        "Deployment is rolled back",
+		Summary: &schemas.DeploymentSummary{
+			Stacks: []schemas.StackSummary{{Stack: "artd", ReplacementType: constants.BlueGreenDeployment}},
+		},
+	}
+	if err := (Teams{WebhookURL: server.URL}).Send(msg); err != nil {
+		t.Error(err)
+	}
+
+	if card.Type != "MessageCard" || card.ThemeColor != "d9534f" {
+		t.Errorf("unexpected card: %v", card)
+	}
+	if len(card.Sections) != 1 || card.Sections[0].ActivityTitle != "artd" {
+		t.Errorf("unexpected sections: %v", card.Sections)
+	}
+}
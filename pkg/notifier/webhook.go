@@ -0,0 +1,64 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// EventHeader is the header of event of message
+	EventHeader = "X-Goployer-Event"
+
+	// SignatureHeader is the header of HMAC SHA256 signature of body
+	SignatureHeader = "X-Goployer-Signature"
+)
+
+// Webhook posts messages as json to the URL
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+var _ Channel = Webhook{}
+
+// Send posts message to the URL with signature if secret exists
+func (w Webhook) Send(msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{EventHeader: msg.Event}
+	if len(w.Secret) > 0 {
+		headers[SignatureHeader] = Sign(body, w.Secret)
+	}
+
+	return postJSON(w.URL, body, headers)
+}
+
+// Sign creates HMAC SHA256 signature of body with secret
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return fmt.Sprintf("sha256=%s", hex.EncodeToString(mac.Sum(nil)))
+}
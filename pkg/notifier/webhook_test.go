@@ -0,0 +1,59 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package notifier
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+func TestWebhook_Send(t *testing.T) {
+	var received Message
+	var signature, event string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		event = r.Header.Get(EventHeader)
+		if signature != Sign(body, "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	msg := Message{Event: constants.NotificationHealthy, Application: "hello", Stack: "artd", Text: "All instances are healthy"}
+	if err := (Webhook{URL: server.URL, Secret: "secret"}).Send(msg); err != nil {
+		t.Error(err)
+	}
+
+	if event != constants.NotificationHealthy {
+		t.Errorf("unexpected event header: %s", event)
+	}
+	if received.Stack != "artd" || received.Text != msg.Text {
+		t.Errorf("unexpected message: %v", received)
+	}
+
+	if err := (Webhook{URL: server.URL, Secret: "wrong"}).Send(msg); err == nil {
+		t.Error("error is expected with wrong signature")
+	}
+}
@@ -19,8 +19,11 @@ package refresh
 import (
 	"errors"
 	"html/template"
+	"io"
+	"text/tabwriter"
 	"time"
 
+	eaws "github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/sirupsen/logrus"
 
@@ -106,8 +109,23 @@ func (r *Refresher) StatusCheck(pollingInterval, timeout time.Duration) error {
 	return nil
 }
 
-// PrintResult prints result of refresh work
-func (r *Refresher) PrintResult() error {
+// Result is the document of refresh work
+type Result struct {
+	AutoScalingGroup   string     `json:"auto_scaling_group"`
+	InstanceRefreshID  string     `json:"instance_refresh_id"`
+	Status             string     `json:"status"`
+	StatusReason       string     `json:"status_reason,omitempty"`
+	PercentageComplete int64      `json:"percentage_complete"`
+	StartTime          *time.Time `json:"start_time,omitempty"`
+	EndTime            *time.Time `json:"end_time,omitempty"`
+}
+
+// PrintResult prints result of refresh work with the output format
+func (r *Refresher) PrintResult(out io.Writer, output string) error {
+	if tool.IsDocumentOutput(output) {
+		return tool.PrintDocument(out, r.Result(), output)
+	}
+
 	var data = struct {
 		Target  autoscaling.Group
 		Summary autoscaling.InstanceRefresh
@@ -120,7 +138,24 @@ func (r *Refresher) PrintResult() error {
 		"decorate": tool.DecorateAttr,
 	}
 
+	w := tabwriter.NewWriter(out, 0, 5, 3, ' ', tabwriter.TabIndent)
 	t := template.Must(template.New("Instance Refresh Result").Funcs(funcMap).Parse(templates.InstanceRefreshStatusTemplate))
 
-	return tool.PrintTemplate(data, t)
+	if err := t.Execute(w, data); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Result returns result of refresh work
+func (r *Refresher) Result() Result {
+	return Result{
+		AutoScalingGroup:   eaws.StringValue(r.TargetGroup.AutoScalingGroupName),
+		InstanceRefreshID:  eaws.StringValue(r.Info.InstanceRefreshId),
+		Status:             eaws.StringValue(r.Info.Status),
+		StatusReason:       eaws.StringValue(r.Info.StatusReason),
+		PercentageComplete: eaws.Int64Value(r.Info.PercentageComplete),
+		StartTime:          r.Info.StartTime,
+		EndTime:            r.Info.EndTime,
+	}
 }
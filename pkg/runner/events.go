@@ -0,0 +1,116 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// EventWriter writes transitions of deployment steps as json lines
+type EventWriter struct {
+	mu  *sync.Mutex
+	out io.Writer
+	now func() time.Time
+}
+
+// NewEventWriter creates event writer which writes events to out
+func NewEventWriter(out io.Writer) *EventWriter {
+	return &EventWriter{
+		mu:  &sync.Mutex{},
+		out: out,
+		now: time.Now,
+	}
+}
+
+// OpenEventWriter creates event writer which appends events to the file
+func OpenEventWriter(path string) (*EventWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEventWriter(f), nil
+}
+
+// Record writes events of step for each region of the deployer
+func (e *EventWriter) Record(d deployer.DeployManager, step, status string, config schemas.Config, stepErr error) error {
+	if e == nil {
+		return nil
+	}
+
+	dp := d.GetDeployer()
+	now := e.now().Format(time.RFC3339)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.out)
+	for _, region := range dp.Stack.Regions {
+		if len(config.Region) > 0 && config.Region != region.Region {
+			continue
+		}
+
+		event := schemas.DeploymentEvent{
+			Time:             now,
+			Application:      dp.AwsConfig.Name,
+			Stack:            dp.GetStackName(),
+			Region:           region.Region,
+			Step:             step,
+			Status:           status,
+			AutoScalingGroup: dp.AsgNames[region.Region],
+			Capacity:         dp.AppliedCapacity,
+			HealthyCount:     dp.HealthyCount[region.Region],
+		}
+		if len(config.TargetAutoscalingGroup) > 0 {
+			event.AutoScalingGroup = config.TargetAutoscalingGroup
+		}
+		if stepErr != nil {
+			event.Error = stepErr.Error()
+		}
+
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the file of events
+func (e *EventWriter) Close() error {
+	if e == nil {
+		return nil
+	}
+
+	if c, ok := e.out.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// newTestDeployer creates a deployer of stack which is deployed in two regions
+func newTestDeployer() deployer.DeployManager {
+	return &deployer.BlueGreen{Deployer: &deployer.Deployer{
+		Mode:      constants.BlueGreenDeployment,
+		AwsConfig: schemas.AWSConfig{Name: "hello"},
+		Stack: schemas.Stack{
+			Stack:           "artd",
+			ReplacementType: constants.BlueGreenDeployment,
+			Regions: []schemas.RegionConfig{
+				{Region: "ap-northeast-2"},
+				{Region: "us-east-1"},
+			},
+		},
+		AsgNames:        map[string]string{"ap-northeast-2": "hello-artd_apnortheast2-v001"},
+		HealthyCount:    map[string]int64{"ap-northeast-2": 2},
+		AppliedCapacity: &schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+		StepStatus:      map[int64]bool{constants.StepCheckPrevious: true, constants.StepDeploy: true},
+	}}
+}
+
+func TestRunner_RunStep(t *testing.T) {
+	buf := &bytes.Buffer{}
+	events := NewEventWriter(buf)
+	events.now = func() time.Time {
+		return time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	r := Runner{Logger: Logger.New(), Events: events}
+	d := newTestDeployer()
+	config := schemas.Config{Region: "ap-northeast-2"}
+
+	if err := r.runStep(d, "StepDeploy", func(schemas.Config) error { return nil }, config); err != nil {
+		t.Fatal(err)
+	}
+
+	stepErr := errors.New("unhealthy instances")
+	if err := r.runStep(d, "StepHealthCheck", func(schemas.Config) error { return stepErr }, config); err != stepErr {
+		t.Fatalf("expected: %v, got: %v", stepErr, err)
+	}
+
+	if err := r.runStep(d, "StepCleanChecking", func(schemas.Config) error { panic("nil pointer") }, config); err == nil || err.Error() != "StepCleanChecking panicked: nil pointer" {
+		t.Fatalf("panic should be returned as error: %v", err)
+	}
+
+	var output []schemas.DeploymentEvent
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var event schemas.DeploymentEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		output = append(output, event)
+	}
+
+	event := func(step, status, err string) schemas.DeploymentEvent {
+		return schemas.DeploymentEvent{
+			Time:             "2020-10-01T00:00:00Z",
+			Application:      "hello",
+			Stack:            "artd",
+			Region:           "ap-northeast-2",
+			Step:             step,
+			Status:           status,
+			AutoScalingGroup: "hello-artd_apnortheast2-v001",
+			Capacity:         &schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+			HealthyCount:     2,
+			Error:            err,
+		}
+	}
+
+	expected := []schemas.DeploymentEvent{
+		event("StepDeploy", constants.StepStarted, ""),
+		event("StepDeploy", constants.StepSucceeded, ""),
+		event("StepHealthCheck", constants.StepStarted, ""),
+		event("StepHealthCheck", constants.StepFailed, "unhealthy instances"),
+		event("StepCleanChecking", constants.StepStarted, ""),
+		event("StepCleanChecking", constants.StepFailed, "StepCleanChecking panicked: nil pointer"),
+	}
+
+	if diff := deep.Equal(output, expected); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestEventWriter_RecordAllRegions(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewEventWriter(buf).Record(newTestDeployer(), "StepDeploy", constants.StepStarted, schemas.Config{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var regions []string
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var event schemas.DeploymentEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		regions = append(regions, event.Region)
+	}
+
+	if diff := deep.Equal(regions, []string{"ap-northeast-2", "us-east-1"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// nil writer means that events are not recorded
+	var events *EventWriter
+	if err := events.Record(newTestDeployer(), "StepDeploy", constants.StepStarted, schemas.Config{}, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunner_DeploymentResult(t *testing.T) {
+	r := Runner{Builder: builder.Builder{
+		AwsConfig: schemas.AWSConfig{Name: "hello"},
+		Config:    schemas.Config{Region: "ap-northeast-2"},
+	}}
+	d := newTestDeployer()
+
+	testData := []struct {
+		Failed     bool
+		RolledBack bool
+		Err        error
+		Expected   string
+	}{
+		{Expected: constants.DeploymentSucceeded},
+		{Failed: true, Err: errors.New("deploy failed"), Expected: constants.DeploymentFailed},
+		{RolledBack: true, Err: errors.New("rolled back"), Expected: constants.DeploymentRolledBack},
+		{Err: errors.New("cancelled"), Expected: constants.DeploymentStopped},
+	}
+
+	for _, td := range testData {
+		failed, rolledBack := newStackSet(), newStackSet()
+		if td.Failed {
+			failed.Add("artd")
+		}
+		if td.RolledBack {
+			rolledBack.Add("artd")
+		}
+
+		result := r.deploymentResult([]deployer.DeployManager{d}, failed, rolledBack, newStackSet(), td.Err)
+		if len(result.Stacks) != 1 {
+			t.Fatalf("expected a stack, got: %d", len(result.Stacks))
+		}
+
+		stack := result.Stacks[0]
+		if stack.Status != td.Expected {
+			t.Errorf("expected: %s, got: %s", td.Expected, stack.Status)
+		}
+
+		if (result.Error != "") != (td.Err != nil) {
+			t.Errorf("unexpected error of result: %s", result.Error)
+		}
+
+		if !stack.Steps["deploy"] || stack.Steps["verify"] {
+			t.Errorf("unexpected steps: %v", stack.Steps)
+		}
+
+		expected := []schemas.RegionResult{
+			{
+				Region:           "ap-northeast-2",
+				AutoScalingGroup: "hello-artd_apnortheast2-v001",
+				Capacity:         &schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+				HealthyCount:     2,
+			},
+		}
+		if diff := deep.Equal(stack.Regions, expected); diff != nil {
+			t.Error(diff)
+		}
+	}
+}
@@ -17,15 +17,24 @@ limitations under the license.
 package runner
 
 import (
+	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/GwonsooLee/kubenx/pkg/color"
+	"github.com/olekukonko/tablewriter"
 	Logger "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
@@ -37,18 +46,38 @@ import (
 	"github.com/DevopsArtFactory/goployer/pkg/helper"
 	"github.com/DevopsArtFactory/goployer/pkg/initializer"
 	"github.com/DevopsArtFactory/goployer/pkg/inspector"
+	"github.com/DevopsArtFactory/goployer/pkg/lock"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
 	"github.com/DevopsArtFactory/goployer/pkg/refresh"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
-	"github.com/DevopsArtFactory/goployer/pkg/slack"
+	"github.com/DevopsArtFactory/goployer/pkg/state"
+	"github.com/DevopsArtFactory/goployer/pkg/storage"
 	"github.com/DevopsArtFactory/goployer/pkg/tool"
+	"github.com/DevopsArtFactory/goployer/pkg/validator"
 )
 
 type Runner struct {
 	Logger     *Logger.Logger
 	Builder    builder.Builder
 	Collector  collector.Collector
-	Slacker    slack.Slack
+	Notifier   notifier.Router
+	StateStore state.Store
 	FuncMapper map[string]func() error
+
+	// Context stops deployment between steps when it is cancelled
+	Context context.Context
+
+	// Out is where logs and summaries are written
+	Out io.Writer
+
+	// Observer is called with the state of deployer whenever it is saved
+	Observer func(schemas.DeploymentState)
+
+	// Events records transitions of deployment steps. nil means that events are not recorded
+	Events *EventWriter
+
+	// Args are positional arguments of command
+	Args []string
 }
 
 // NewRunner creates a new runner
@@ -56,24 +85,66 @@ func NewRunner(newBuilder builder.Builder, mode string) (Runner, error) {
 	newRunner := Runner{
 		Logger:  Logger.New(),
 		Builder: newBuilder,
-		Slacker: slack.NewSlackClient(newBuilder.Config.SlackOff),
+		Context: context.Background(),
+		Out:     os.Stdout,
+	}
+
+	n, err := notifier.New(newBuilder.AwsConfig.Name, newBuilder.AwsConfig.Notifications, newBuilder.Config.SlackOff)
+	if err != nil {
+		return newRunner, err
+	}
+	newRunner.Notifier = n
+
+	if newBuilder.Config.Output == constants.CSVOutput && mode != "report" {
+		return newRunner, fmt.Errorf("%s output is only supported by report", constants.CSVOutput)
 	}
 
 	if checkBuilderConfigurationNeeded(mode) {
-		newRunner.Collector = collector.NewCollector(newBuilder.MetricConfig, newBuilder.Config.AssumeRole)
+		c, err := collector.NewCollector(newBuilder.MetricConfig, newBuilder.Config.AssumeRole)
+		if err != nil {
+			return newRunner, err
+		}
+		newRunner.Collector = c
 	}
 
-	newRunner.FuncMapper = map[string]func() error{
-		"deploy":  newRunner.Deploy,
-		"delete":  newRunner.Delete,
-		"status":  newRunner.Status,
-		"update":  newRunner.Update,
-		"refresh": newRunner.Refresh,
+	if tool.IsStringInArray(mode, []string{"deploy", "resume"}) {
+		store, err := state.NewStore(newBuilder.Config.StateDir)
+		if err != nil {
+			return newRunner, err
+		}
+		newRunner.StateStore = store
+
+		if len(newBuilder.Config.EventsFile) > 0 {
+			events, err := OpenEventWriter(newBuilder.Config.EventsFile)
+			if err != nil {
+				return newRunner, err
+			}
+			newRunner.Events = events
+		}
 	}
 
+	newRunner.FuncMapper = newRunner.funcMapper()
+
 	return newRunner, nil
 }
 
+// funcMapper maps modes to the functions of runner
+func (r Runner) funcMapper() map[string]func() error {
+	return map[string]func() error{
+		"deploy":   r.Deploy,
+		"delete":   r.Delete,
+		"status":   r.Status,
+		"update":   r.Update,
+		"refresh":  r.Refresh,
+		"plan":     r.Plan,
+		"rollback": r.Rollback,
+		"resume":   r.Resume,
+		"unlock":   r.Unlock,
+		"history":  r.History,
+		"report":   r.Report,
+	}
+}
+
 // SetupBuilder setup builder struct for configuration
 func SetupBuilder(mode string) (builder.Builder, error) {
 	// Create new builder
@@ -130,20 +201,78 @@ func ServerSetup(config schemas.Config) (builder.Builder, error) {
 
 // setManifestToBuilder creates builderSt with manifest configurations
 func setManifestToBuilder(builderSt builder.Builder) (builder.Builder, error) {
+	fileBytes, err := readManifest(builderSt.Config.Manifest, builderSt.Config.ManifestS3Region)
+	if err != nil {
+		return builder.Builder{}, err
+	}
+
+	if err := validator.ValidateManifest(fileBytes); err != nil {
+		return builder.Builder{}, fmt.Errorf("manifest is not valid: %s\n%v", builderSt.Config.Manifest, err)
+	}
+
 	if !strings.HasPrefix(builderSt.Config.Manifest, constants.S3Prefix) {
 		builderSt = builderSt.SetManifestConfig()
 	} else {
-		s := aws.BootstrapManifestService(builderSt.Config.ManifestS3Region, "")
-		fileBytes, err := s.S3Service.GetManifest(FilterS3Path(builderSt.Config.Manifest))
-		if err != nil {
-			return builder.Builder{}, err
-		}
 		builderSt = builderSt.SetManifestConfigWithS3(fileBytes)
 	}
 
 	return builderSt, nil
 }
 
+// readManifest reads manifest from local file or s3
+func readManifest(manifest, s3Region string) ([]byte, error) {
+	if !strings.HasPrefix(manifest, constants.S3Prefix) {
+		return ioutil.ReadFile(manifest)
+	}
+
+	s := aws.BootstrapManifestService(s3Region, "")
+	return s.S3Service.GetManifest(FilterS3Path(manifest))
+}
+
+// Validate validates manifest with the schema of manifest
+func Validate(out io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: goployer validate <manifest>")
+	}
+
+	manifest := args[0]
+	s3Region := viper.GetString("manifest-s3-region")
+	if strings.HasPrefix(manifest, constants.S3Prefix) && len(s3Region) == 0 {
+		return errors.New("you have to specify region of s3 bucket: --manifest-s3-region")
+	}
+
+	fileBytes, err := readManifest(manifest, s3Region)
+	if err != nil {
+		return err
+	}
+
+	validErr := validator.ValidateManifest(fileBytes)
+	if output := viper.GetString("output"); tool.IsDocumentOutput(output) {
+		result := validator.Result{Manifest: manifest, Valid: validErr == nil, Errors: validator.Errors{}}
+		if errs, ok := validErr.(validator.Errors); ok {
+			result.Errors = errs
+		} else if validErr != nil {
+			result.Errors = validator.Errors{{Message: validErr.Error()}}
+		}
+
+		if err := tool.PrintDocument(out, result, output); err != nil {
+			return err
+		}
+
+		if validErr != nil {
+			return fmt.Errorf("manifest is not valid: %s", manifest)
+		}
+		return nil
+	}
+
+	if validErr != nil {
+		return fmt.Errorf("manifest is not valid: %s\n%v", manifest, validErr)
+	}
+
+	_, err = fmt.Fprintf(out, "manifest is valid: %s\n", manifest)
+	return err
+}
+
 // Initialize creates necessary files for goployer
 func Initialize(args []string) error {
 	var appName string
@@ -204,6 +333,11 @@ func AddManifest(args []string) error {
 
 // Start function is the starting point of all processes.
 func Start(builderSt builder.Builder, mode string) error {
+	return StartWith(builderSt, mode, nil)
+}
+
+// StartWith is the same as Start except that runner is customized before running
+func StartWith(builderSt builder.Builder, mode string, customize func(r *Runner)) error {
 	if checkBuilderConfigurationNeeded(mode) {
 		// Check validation of configurations
 		if err := builderSt.CheckValidation(); err != nil {
@@ -212,16 +346,18 @@ func Start(builderSt builder.Builder, mode string) error {
 	}
 
 	// run with runner
-	return withRunner(builderSt, mode, func(slacker slack.Slack) error {
+	return withRunner(builderSt, mode, customize, func(n notifier.Notifier) error {
 		// These are post actions after deployment
-		if !builderSt.Config.SlackOff {
-			if mode == "deploy" {
-				slacker.SendSimpleMessage(fmt.Sprintf(":100: Deployment is done: %s", builderSt.AwsConfig.Name))
-			}
+		if mode == "deploy" || mode == "resume" {
+			n.Notify(constants.NotificationFinished, fmt.Sprintf(":100: Deployment is done: %s", builderSt.AwsConfig.Name))
+		}
 
-			if mode == "delete" {
-				slacker.SendSimpleMessage(fmt.Sprintf(":100: Delete process is done: %s", builderSt.AwsConfig.Name))
-			}
+		if mode == "delete" {
+			n.Notify(constants.NotificationFinished, fmt.Sprintf(":100: Delete process is done: %s", builderSt.AwsConfig.Name))
+		}
+
+		if mode == "rollback" {
+			n.Notify(constants.NotificationFinished, fmt.Sprintf(":100: Rollback is done: %s", builderSt.AwsConfig.Name))
 		}
 
 		return nil
@@ -229,23 +365,34 @@ func Start(builderSt builder.Builder, mode string) error {
 }
 
 // withRunner creates runner and runs the deployment process
-func withRunner(builderSt builder.Builder, mode string, postAction func(slacker slack.Slack) error) error {
+func withRunner(builderSt builder.Builder, mode string, customize func(r *Runner), postAction func(n notifier.Notifier) error) error {
 	runner, err := NewRunner(builderSt, mode)
 	if err != nil {
 		return err
 	}
+	defer runner.Events.Close()
+
+	if customize != nil {
+		customize(&runner)
+		runner.FuncMapper = runner.funcMapper()
+	}
 	runner.LogFormatting(builderSt.Config.LogLevel)
 
 	if err := runner.Run(mode); err != nil {
 		return err
 	}
 
-	return postAction(runner.Slacker)
+	return postAction(runner.Notifier)
 }
 
 // LogFormatting sets log format
 func (r Runner) LogFormatting(logLevel string) {
-	r.Logger.SetOutput(os.Stdout)
+	// logs should not be mixed with the document of json or yaml output
+	if tool.IsDocumentOutput(r.Builder.Config.Output) {
+		r.Logger.SetOutput(os.Stderr)
+	} else {
+		r.Logger.SetOutput(r.Out)
+	}
 	r.Logger.SetLevel(constants.LogLevelMapper[logLevel])
 }
 
@@ -259,12 +406,13 @@ func (r Runner) Run(mode string) error {
 }
 
 // Deploy is the main function of `goployer deploy`
-func (r Runner) Deploy() error {
-	out := os.Stdout
+func (r Runner) Deploy() (err error) {
+	out := r.Out
+	// panic is returned as error so that server keeps running other deployments
 	defer func() {
-		if err := recover(); err != nil {
-			Logger.Error(err)
-			os.Exit(1)
+		if p := recover(); p != nil {
+			Logger.Error(p)
+			err = fmt.Errorf("deployment panicked: %v", p)
 		}
 	}()
 
@@ -275,25 +423,24 @@ func (r Runner) Deploy() error {
 	//Send Beginning Message
 	r.Logger.Infof("Beginning deployment: %s", r.Builder.AwsConfig.Name)
 
-	if err := r.Builder.PrintSummary(out, r.Builder.Config.Stack, r.Builder.Config.Region); err != nil {
-		return err
+	// summary is a part of the result document with json or yaml output
+	if !tool.IsDocumentOutput(r.Builder.Config.Output) {
+		if err := r.Builder.PrintSummary(out, r.Builder.Config.Stack, r.Builder.Config.Region); err != nil {
+			return err
+		}
 	}
 
-	if r.Slacker.ValidClient() {
-		r.Logger.Debug("Slack configuration is valid")
-		var stacks []schemas.Stack
+	if r.Notifier.Enabled() {
+		r.Logger.Debug("Notification configuration is valid")
 		for _, s := range r.Builder.Stacks {
 			if len(r.Builder.Config.Stack) == 0 || r.Builder.Config.Stack == s.Stack {
-				stacks = append(stacks, s)
+				// failed channels are turned off by notifier
+				r.Notifier.ForStack(s).NotifySummary(r.Builder.Config, []schemas.Stack{s})
 			}
 		}
-		if err := r.Slacker.SendSummaryMessage(r.Builder.Config, stacks, r.Builder.AwsConfig.Name); err != nil {
-			r.Logger.Warn(err.Error())
-			r.Slacker.SlackOff = true
-		}
 	} else if !r.Builder.Config.SlackOff {
-		// Slack variables are not set
-		r.Logger.Warn("no slack variables exists. [ SLACK_TOKEN, SLACK_CHANNEL or SLACK_WEBHOOK_URL ]")
+		// Neither slack variables nor notification channels are set
+		r.Logger.Warn("no notification channel exists. [ SLACK_TOKEN, SLACK_CHANNEL, SLACK_WEBHOOK_URL or notifications ]")
 	}
 
 	if r.Builder.MetricConfig.Enabled {
@@ -314,26 +461,72 @@ func (r Runner) Deploy() error {
 			continue
 		}
 
+		if st, err := r.StateStore.Load(r.Builder.AwsConfig.Name, stack.Stack, r.Builder.Config.Region); err == nil && st != nil {
+			r.Logger.Warnf("unfinished deployment of %s saved at %s will be overwritten, use `goployer resume` to continue it", stack.Stack, st.UpdatedAt)
+		}
+
 		r.Logger.Debugf("add deployer setup function : %s", stack.Stack)
-		deployers = append(deployers, getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector))
+		deployers = append(deployers, getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Notifier.ForStack(stack), r.Collector))
 	}
 	r.Logger.Debugf("successfully assign deployer to stacks")
+	started := deployers
+
+	failed := newStackSet()
+	rolledBack := newStackSet()
+	unverified := newStackSet()
+	defer r.runFailureHooks(started, r.Builder.Config, failed, rolledBack, unverified)
+	if tool.IsDocumentOutput(r.Builder.Config.Output) {
+		defer func() {
+			result := r.deploymentResult(started, failed, rolledBack, unverified, err)
+			if err := tool.PrintDocument(out, result, r.Builder.Config.Output); err != nil {
+				r.Logger.Errorf("failed to print result of deployment: %s", err.Error())
+			}
+		}()
+	}
 
-	errs := make(chan error)
+	var stacks []schemas.Stack
+	for _, d := range deployers {
+		stacks = append(stacks, d.GetDeployer().Stack)
+	}
+
+	lk, err := r.AcquireLock(stacks, r.Builder.Config.Region)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lk)
+
+	// each deployer sends at most one error so that none of them blocks after the first error is received
+	errs := make(chan error, len(deployers))
 	// Check Previous Version
 	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.CheckPreviousResources(r.Builder.Config); err != nil {
+			if err := r.runStep(deployer, "StepCheckPrevious", deployer.CheckPreviousResources, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepCheckPrevious] check previous deployer error occurred: %s", err.Error())
+				failed.Add(deployer.GetDeployer().GetStackName())
 				errs <- err
+				return
 			}
+			r.SaveState(deployer, r.Builder.Config)
 
-			if err := deployer.Deploy(r.Builder.Config); err != nil {
+			if err := r.runHooks(deployer, constants.PreDeployHook, r.Builder.Config); err != nil {
+				r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.PreDeployHook, err.Error())
+				failed.Add(deployer.GetDeployer().GetStackName())
+				errs <- err
+				return
+			}
+
+			if err := r.runStep(deployer, "StepDeploy", deployer.Deploy, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepDeploy] deploy step error occurred: %s", err.Error())
+				failed.Add(deployer.GetDeployer().GetStackName())
+				errs <- err
+			} else if err := r.runHooks(deployer, constants.PostDeployHook, r.Builder.Config); err != nil {
+				r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.PostDeployHook, err.Error())
+				failed.Add(deployer.GetDeployer().GetStackName())
 				errs <- err
 			}
+			r.SaveState(deployer, r.Builder.Config)
 		}(d)
 	}
 	go func() {
@@ -345,46 +538,61 @@ func (r Runner) Deploy() error {
 		return errFlag
 	}
 
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
+
 	// Health checking step
 	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.HealthChecking(r.Builder.Config); err != nil {
+			if err := r.runStep(deployer, "StepHealthCheck", deployer.HealthChecking, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepHealthCheck] check new deployment error occurred: %s", err.Error())
+				if r.RollbackDeployment(deployer, failed) {
+					rolledBack.Add(deployer.GetDeployer().GetStackName())
+				}
+			} else if err := r.runHooks(deployer, constants.PostHealthyHook, r.Builder.Config); err != nil {
+				r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.PostHealthyHook, err.Error())
+				if r.RollbackDeployment(deployer, failed) {
+					rolledBack.Add(deployer.GetDeployer().GetStackName())
+				}
 			}
+			r.SaveState(deployer, r.Builder.Config)
 		}(d)
 	}
 	wg.Wait()
+	deployers = failed.Exclude(rolledBack.Exclude(deployers))
+
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
 
 	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			// Attach scaling policy
-			if err := deployer.FinishAdditionalWork(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepFinishAdditionalWork] finish additional work error occurred: %s", err.Error())
-			}
-
-			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
-			}
-
-			if err := deployer.CleanPreviousVersion(r.Builder.Config); err != nil {
-				r.Logger.Errorf("[StepCleanPreviousVersion] clean previous verson error occurred: %s", err.Error())
-			}
+			r.finishDeployment(deployer, failed, rolledBack, unverified)
 		}(d)
 	}
 	wg.Wait()
+	deployers = failed.Exclude(unverified.Exclude(rolledBack.Exclude(deployers)))
+
+	if err := r.checkCancelled(); err != nil {
+		return err
+	}
 
 	//CleanChecking
 	for _, d := range deployers {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.CleanChecking(r.Builder.Config); err != nil {
+			if err := r.runStep(deployer, "StepCleanChecking", deployer.CleanChecking, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepCleanChecking] clean checking error occurred: %s", err.Error())
+			} else if err := r.runHooks(deployer, constants.PostCleanupHook, r.Builder.Config); err != nil {
+				r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.PostCleanupHook, err.Error())
 			}
+			r.SaveState(deployer, r.Builder.Config)
 		}(d)
 	}
 	wg.Wait()
@@ -394,9 +602,10 @@ func (r Runner) Deploy() error {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.GatherMetrics(r.Builder.Config); err != nil {
+			if err := r.runStep(deployer, "StepGatherMetrics", deployer.GatherMetrics, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepGatherMetrics] gather metrics error occurred: %s", err.Error())
 			}
+			r.SaveState(deployer, r.Builder.Config)
 		}(d)
 	}
 	wg.Wait()
@@ -406,13 +615,667 @@ func (r Runner) Deploy() error {
 		wg.Add(1)
 		go func(deployer deployer.DeployManager) {
 			defer wg.Done()
-			if err := deployer.RunAPITest(r.Builder.Config); err != nil {
+			if err := r.runStep(deployer, "StepRunAPITest", deployer.RunAPITest, r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepRunAPITest] API test error occurred: %s", err.Error())
 			}
+			r.SaveState(deployer, r.Builder.Config)
 		}(d)
 	}
 	wg.Wait()
 
+	// deployment is over so that there is nothing to resume except for verification
+	for _, d := range failed.Exclude(unverified.Exclude(started)) {
+		r.ClearState(d, r.Builder.Config)
+	}
+
+	if stacks := rolledBack.List(); len(stacks) > 0 {
+		return fmt.Errorf("deployment is rolled back: %s", strings.Join(stacks, ", "))
+	}
+
+	if stacks := unverified.List(); len(stacks) > 0 {
+		return fmt.Errorf("deployment failed verification, previous version is kept until it is resumed: %s", strings.Join(stacks, ", "))
+	}
+
+	if stacks := failed.List(); len(stacks) > 0 {
+		return fmt.Errorf("deployment failed, previous version is kept until it is resumed: %s", strings.Join(stacks, ", "))
+	}
+
+	return nil
+}
+
+// finishDeployment finishes additional work, verifies the new version and cleans the previous one.
+// Stacks which are not completed are added to one of failed, rolledBack and unverified.
+func (r Runner) finishDeployment(d deployer.DeployManager, failed, rolledBack, unverified *stackSet) {
+	// Attach scaling policy
+	if err := r.runStep(d, "StepFinishAdditionalWork", d.FinishAdditionalWork, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepFinishAdditionalWork] finish additional work error occurred: %s", err.Error())
+		if r.RollbackDeployment(d, failed) {
+			rolledBack.Add(d.GetDeployer().GetStackName())
+			return
+		}
+
+		// traffic is back on the previous version after canary is aborted or rollback fails, so it must not be cleaned
+		var aborted *deployer.CanaryAbortedError
+		if errors.As(err, &aborted) || failed.Has(d.GetDeployer().GetStackName()) {
+			failed.Add(d.GetDeployer().GetStackName())
+			r.SaveState(d, r.Builder.Config)
+			return
+		}
+	}
+	r.SaveState(d, r.Builder.Config)
+
+	// previous version should not be cleaned if the new one does not pass verification
+	if err := r.runStep(d, "StepVerify", d.Verify, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepVerify] verification error occurred: %s", err.Error())
+		if r.RollbackDeployment(d, failed) {
+			rolledBack.Add(d.GetDeployer().GetStackName())
+		} else if !failed.Has(d.GetDeployer().GetStackName()) {
+			unverified.Add(d.GetDeployer().GetStackName())
+		}
+		r.SaveState(d, r.Builder.Config)
+		return
+	}
+	r.SaveState(d, r.Builder.Config)
+
+	// previous version is not cleaned while hooks before cleanup are failed
+	if err := r.runHooks(d, constants.PreCleanupHook, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.PreCleanupHook, err.Error())
+		failed.Add(d.GetDeployer().GetStackName())
+		return
+	}
+
+	if err := r.runStep(d, "StepTriggerLifecycleCallbacks", d.TriggerLifecycleCallbacks, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
+		// previous version is not cleaned while lifecycle callbacks are failed
+		failed.Add(d.GetDeployer().GetStackName())
+		r.SaveState(d, r.Builder.Config)
+		return
+	}
+	r.SaveState(d, r.Builder.Config)
+
+	if err := r.runStep(d, "StepCleanPreviousVersion", d.CleanPreviousVersion, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepCleanPreviousVersion] clean previous verson error occurred: %s", err.Error())
+	}
+	r.SaveState(d, r.Builder.Config)
+}
+
+// Resume continues unfinished deployments from the persisted states
+func (r Runner) Resume() error {
+	if err := tool.LocalCheck("Do you really want to resume the deployment? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	resumed := 0
+	for _, stack := range r.Builder.Stacks {
+		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
+			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+			continue
+		}
+
+		st, err := r.StateStore.Load(r.Builder.AwsConfig.Name, stack.Stack, r.Builder.Config.Region)
+		if err != nil {
+			return err
+		}
+
+		if st == nil {
+			r.Logger.Infof("No unfinished deployment exists: %s", stack.Stack)
+			continue
+		}
+
+		if st.Mode != stack.ReplacementType {
+			return fmt.Errorf("replacement type of stack is changed from %s to %s: %s", st.Mode, stack.ReplacementType, stack.Stack)
+		}
+
+		// timeout is counted again from now on
+		config := st.Config
+		config.StartTimestamp = time.Now().Unix()
+		config.AutoApply = r.Builder.Config.AutoApply
+
+		lk, err := r.AcquireLock([]schemas.Stack{stack}, config.Region)
+		if err != nil {
+			return err
+		}
+
+		d := getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, config.Region, r.Notifier.ForStack(stack), r.Collector)
+		d.ImportState(*st)
+
+		steps := deployer.RemainingSteps(d)
+		if len(steps) > 0 {
+			r.Logger.Infof("Resuming deployment of %s from %s (saved at %s)", stack.Stack, steps[0].Name, st.UpdatedAt)
+		}
+
+		for _, step := range steps {
+			if err := r.runStep(d, step.Name, step.Run, config); err != nil {
+				r.Logger.Errorf("[%s] resumed deployment error occurred: %s", step.Name, err.Error())
+				r.SaveState(d, config)
+				releaseLock(lk)
+				return err
+			}
+			r.SaveState(d, config)
+		}
+
+		r.ClearState(d, config)
+		releaseLock(lk)
+		resumed++
+	}
+
+	if resumed == 0 {
+		return errors.New("no unfinished deployment to resume")
+	}
+
+	return nil
+}
+
+// runStep runs a step of deployer and records transitions of the step to events.
+// Panic of the step is regarded as failure because steps run in goroutines which cannot be recovered by caller.
+func (r Runner) runStep(d deployer.DeployManager, step string, run func(config schemas.Config) error, config schemas.Config) error {
+	r.recordEvent(d, step, constants.StepStarted, config, nil)
+
+	err := r.recoverStep(step, run, config)
+	if err != nil {
+		r.recordEvent(d, step, constants.StepFailed, config, err)
+		r.Notifier.ForStack(d.GetDeployer().Stack).Notify(constants.NotificationFailed, fmt.Sprintf(":x: %s is failed : %s / %s", step, d.GetDeployer().GetStackName(), err.Error()))
+	} else {
+		r.recordEvent(d, step, constants.StepSucceeded, config, nil)
+	}
+
+	return err
+}
+
+// recoverStep runs step and turns panic into error
+func (r Runner) recoverStep(step string, run func(config schemas.Config) error, config schemas.Config) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			r.Logger.Errorf("[%s] panic occurred: %v\n%s", step, p, debug.Stack())
+			err = fmt.Errorf("%s panicked: %v", step, p)
+		}
+	}()
+
+	return run(config)
+}
+
+// runHooks runs lifecycle hooks of the phase as a step
+func (r Runner) runHooks(d deployer.DeployManager, phase string, config schemas.Config) error {
+	if len(d.GetDeployer().Hooks(phase)) == 0 {
+		return nil
+	}
+
+	return r.runStep(d, fmt.Sprintf("StepHooks:%s", phase), func(config schemas.Config) error {
+		return d.GetDeployer().RunHooks(phase, config)
+	}, config)
+}
+
+// runFailureHooks runs on_failure hooks of stacks which are failed, rolled back or unverified
+func (r Runner) runFailureHooks(deployers []deployer.DeployManager, config schemas.Config, sets ...*stackSet) {
+	for _, d := range deployers {
+		stack := d.GetDeployer().GetStackName()
+		for _, set := range sets {
+			if !set.Has(stack) {
+				continue
+			}
+
+			if err := r.runHooks(d, constants.OnFailureHook, config); err != nil {
+				r.Logger.Errorf("[%s] lifecycle hook error occurred: %s", constants.OnFailureHook, err.Error())
+			}
+			break
+		}
+	}
+}
+
+// recordEvent writes event of step. Failure of recording does not stop deployment.
+func (r Runner) recordEvent(d deployer.DeployManager, step, status string, config schemas.Config, stepErr error) {
+	if err := r.Events.Record(d, step, status, config, stepErr); err != nil {
+		r.Logger.Warnf("failed to record event of %s: %s", d.GetDeployer().GetStackName(), err.Error())
+	}
+}
+
+// deploymentResult makes the document of deployment from deployers and the results of stacks
+func (r Runner) deploymentResult(deployers []deployer.DeployManager, failed, rolledBack, unverified *stackSet, deployErr error) schemas.DeploymentResult {
+	result := schemas.DeploymentResult{
+		Summary: r.Builder.Summary(r.Builder.Config.Stack, r.Builder.Config.Region),
+		Stacks:  []schemas.StackResult{},
+	}
+	if deployErr != nil {
+		result.Error = deployErr.Error()
+	}
+
+	for _, d := range deployers {
+		dp := d.GetDeployer()
+		stack := dp.GetStackName()
+
+		sr := schemas.StackResult{
+			Stack:   stack,
+			Mode:    dp.Mode,
+			Status:  constants.DeploymentSucceeded,
+			Steps:   map[string]bool{},
+			Regions: []schemas.RegionResult{},
+			APITest: dp.APITestResult,
+		}
+
+		switch {
+		case failed.Has(stack):
+			sr.Status = constants.DeploymentFailed
+		case rolledBack.Has(stack):
+			sr.Status = constants.DeploymentRolledBack
+		case unverified.Has(stack):
+			sr.Status = constants.DeploymentUnverified
+		case deployErr != nil:
+			sr.Status = constants.DeploymentStopped
+		}
+
+		for step, name := range constants.StepNames {
+			sr.Steps[name] = dp.StepStatus[step]
+		}
+
+		for _, region := range dp.Stack.Regions {
+			if len(r.Builder.Config.Region) > 0 && r.Builder.Config.Region != region.Region {
+				continue
+			}
+
+			sr.Regions = append(sr.Regions, schemas.RegionResult{
+				Region:           region.Region,
+				AutoScalingGroup: dp.AsgNames[region.Region],
+				Capacity:         dp.AppliedCapacity,
+				HealthyCount:     dp.HealthyCount[region.Region],
+			})
+		}
+		result.Stacks = append(result.Stacks, sr)
+	}
+
+	return result
+}
+
+// SaveState persists the progress of deployment so that it can be resumed
+func (r Runner) SaveState(d deployer.DeployManager, config schemas.Config) {
+	st := d.ExportState(config)
+	if err := r.StateStore.Save(st); err != nil {
+		r.Logger.Warnf("failed to save deployment state of %s: %s", d.GetDeployer().GetStackName(), err.Error())
+	}
+
+	if r.Observer != nil {
+		r.Observer(st)
+	}
+}
+
+// NewLocker creates locker with the configured backend. It returns nil if lock is disabled.
+// Leases are kept in the metric table if metrics are stored in dynamodb, otherwise in local files.
+func (r Runner) NewLocker() (*lock.Locker, error) {
+	ttl := r.Builder.Config.LockTTL
+	if ttl <= 0 {
+		ttl = constants.DefaultLockTTL
+	}
+
+	metricTable, useMetricTable := r.Collector.Storage.(storage.DynamoDB)
+	useMetricTable = useMetricTable && r.Builder.MetricConfig.Enabled
+	backend := r.Builder.Config.LockBackend
+	if len(backend) == 0 {
+		backend = constants.FileLockBackend
+		if useMetricTable {
+			backend = constants.DynamoDBLockBackend
+		}
+	}
+
+	var b lock.Backend
+	switch backend {
+	case constants.NoLockBackend:
+		return nil, nil
+	case constants.DynamoDBLockBackend:
+		if !useMetricTable {
+			return nil, errors.New("dynamodb lock backend requires metrics to be stored in dynamodb")
+		}
+		b = lock.DynamoDBBackend{
+			Client: metricTable.Client,
+			Table:  metricTable.Table,
+		}
+	case constants.FileLockBackend:
+		fb, err := lock.NewFileBackend("")
+		if err != nil {
+			return nil, err
+		}
+		b = fb
+	default:
+		return nil, fmt.Errorf("lock backend is not supported: %s", backend)
+	}
+
+	l := lock.NewLocker(b, ttl, r.Logger)
+	return &l, nil
+}
+
+// AcquireLock takes deployment locks of stacks in the region so that only one deployment runs for each of them
+func (r Runner) AcquireLock(stacks []schemas.Stack, region string) (*lock.Lock, error) {
+	locker, err := r.NewLocker()
+	if err != nil || locker == nil {
+		return nil, err
+	}
+
+	lk, err := locker.Acquire(lock.Keys(r.Builder.AwsConfig.Name, stacks, region))
+	if err != nil {
+		return nil, err
+	}
+	r.Logger.Infof("Deployment lock is acquired: %s", strings.Join(lk.Keys(), ", "))
+
+	return lk, nil
+}
+
+// Unlock removes deployment locks of the stack which are left by killed processes
+func (r Runner) Unlock() error {
+	if len(r.Builder.Config.Stack) == 0 {
+		return errors.New("you should specify stack to unlock: --stack")
+	}
+
+	locker, err := r.NewLocker()
+	if err != nil {
+		return err
+	}
+
+	if locker == nil {
+		return fmt.Errorf("lock backend is disabled: %s", r.Builder.Config.LockBackend)
+	}
+
+	var stacks []schemas.Stack
+	for _, stack := range r.Builder.Stacks {
+		if stack.Stack == r.Builder.Config.Stack {
+			stacks = append(stacks, stack)
+		}
+	}
+
+	var leases []schemas.Lease
+	for _, key := range lock.Keys(r.Builder.AwsConfig.Name, stacks, r.Builder.Config.Region) {
+		lease, err := locker.Backend.Get(key)
+		if err != nil {
+			return err
+		}
+
+		if lease == nil {
+			r.Logger.Infof("No lock exists: %s", key)
+			continue
+		}
+		leases = append(leases, *lease)
+		fmt.Fprintln(r.Out, lock.HeldError{Lease: *lease}.Description())
+	}
+
+	if len(leases) == 0 {
+		return nil
+	}
+
+	if err := tool.LocalCheck("Do you really want to remove the locks? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	for _, lease := range leases {
+		if err := locker.Backend.Delete(lease.Key, ""); err != nil {
+			return err
+		}
+		r.Logger.Infof("Lock is removed: %s", lease.Key)
+	}
+
+	return nil
+}
+
+// History shows deployment records of the stacks which are stored in the metric storage
+func (r Runner) History() error {
+	if !r.Builder.MetricConfig.Enabled {
+		return errors.New("metrics are disabled, history is read from the metric storage")
+	}
+
+	app := r.Builder.AwsConfig.Name
+	switch {
+	case len(r.Args) == 0:
+		since, err := ParseSince(r.Builder.Config.Since, time.Now())
+		if err != nil {
+			return err
+		}
+
+		histories, err := r.Collector.ListHistory(app, r.selectedStacks(), r.Builder.Config.Region, since)
+		if err != nil {
+			return err
+		}
+
+		return PrintHistories(r.Out, histories, r.Builder.Config.Output)
+	case r.Args[0] == "show" && len(r.Args) == 2:
+		h, err := r.Collector.GetHistory(app, r.Args[1])
+		if err != nil {
+			return err
+		}
+
+		return PrintHistory(r.Out, *h, r.Builder.Config.Output)
+	case r.Args[0] == "diff" && len(r.Args) == 3:
+		old, err := r.Collector.GetHistory(app, r.Args[1])
+		if err != nil {
+			return err
+		}
+
+		new, err := r.Collector.GetHistory(app, r.Args[2])
+		if err != nil {
+			return err
+		}
+
+		diffs, err := collector.DiffHistory(*old, *new)
+		if err != nil {
+			return err
+		}
+
+		return PrintHistoryDiff(r.Out, diffs, r.Builder.Config.Output)
+	}
+
+	return errors.New(constants.HistoryUsage)
+}
+
+// Report shows DORA metrics of the stacks computed from deployment records in the metric storage
+func (r Runner) Report() error {
+	if len(r.Args) != 1 || r.Args[0] != "dora" {
+		return errors.New(constants.ReportUsage)
+	}
+
+	if !r.Builder.MetricConfig.Enabled {
+		return errors.New("metrics are disabled, report is computed from the metric storage")
+	}
+
+	period := r.Builder.Config.Period
+	if len(period) == 0 {
+		period = constants.DefaultReportPeriod
+	}
+
+	now := time.Now()
+	since, err := ParseSince(period, now)
+	if err != nil {
+		return fmt.Errorf("period is not valid, use duration like 30d or date like 2020-01-02: %s", period)
+	}
+
+	histories, err := r.Collector.ListHistory(r.Builder.AwsConfig.Name, r.selectedStacks(), r.Builder.Config.Region, since)
+	if err != nil {
+		return err
+	}
+
+	return PrintDORAReports(r.Out, collector.ComputeDORA(histories, since, now), r.Builder.Config.Output)
+}
+
+// selectedStacks returns stacks selected with stack option or all stacks if it is not specified
+func (r Runner) selectedStacks() []schemas.Stack {
+	var stacks []schemas.Stack
+	for _, stack := range r.Builder.Stacks {
+		if r.Builder.Config.Stack == "" || stack.Stack == r.Builder.Config.Stack {
+			stacks = append(stacks, stack)
+		}
+	}
+
+	return stacks
+}
+
+// releaseLock releases lock if it is acquired
+func releaseLock(lk *lock.Lock) {
+	if lk != nil {
+		lk.Release()
+	}
+}
+
+// checkCancelled returns error if deployment is cancelled. The saved states are kept so that deployment can be resumed.
+func (r Runner) checkCancelled() error {
+	if r.Context == nil || r.Context.Err() == nil {
+		return nil
+	}
+
+	return fmt.Errorf("deployment is cancelled, use `goployer resume` to continue it: %s", r.Builder.AwsConfig.Name)
+}
+
+// ClearState removes the persisted state of deployment
+func (r Runner) ClearState(d deployer.DeployManager, config schemas.Config) {
+	if err := r.StateStore.Delete(r.Builder.AwsConfig.Name, d.GetDeployer().GetStackName(), config.Region); err != nil {
+		r.Logger.Warnf("failed to delete deployment state of %s: %s", d.GetDeployer().GetStackName(), err.Error())
+	}
+}
+
+// RollbackDeployment rolls back the deployment if rollback_on_failure is set in the stack.
+// It returns true only if the deployment is rolled back, and stack whose rollback fails is added to failed.
+func (r Runner) RollbackDeployment(d deployer.DeployManager, failed *stackSet) bool {
+	dp := d.GetDeployer()
+	if !dp.Stack.RollbackOnFailure {
+		return false
+	}
+
+	r.Logger.Warnf("[StepRollback] start rolling back deployment: %s", dp.GetStackName())
+	if err := r.runStep(d, "StepRollback", d.Rollback, r.Builder.Config); err != nil {
+		r.Logger.Errorf("[StepRollback] rollback error occurred: %s", err.Error())
+		failed.Add(dp.GetStackName())
+		return false
+	}
+
+	return true
+}
+
+// stackSet is a set of stack names which is safe for concurrent use
+type stackSet struct {
+	mu     *sync.Mutex
+	stacks []string
+}
+
+// newStackSet creates an empty stack set
+func newStackSet() *stackSet {
+	return &stackSet{mu: &sync.Mutex{}}
+}
+
+// Add adds stack to the set
+func (s *stackSet) Add(stack string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !tool.IsStringInArray(stack, s.stacks) {
+		s.stacks = append(s.stacks, stack)
+	}
+}
+
+// Has checks if stack is in the set
+func (s *stackSet) Has(stack string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return tool.IsStringInArray(stack, s.stacks)
+}
+
+// List returns stacks in the set
+func (s *stackSet) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.stacks...)
+}
+
+// Exclude returns deployers whose stack is not in the set
+func (s *stackSet) Exclude(deployers []deployer.DeployManager) []deployer.DeployManager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ret []deployer.DeployManager
+	for _, d := range deployers {
+		if !tool.IsStringInArray(d.GetDeployer().GetStackName(), s.stacks) {
+			ret = append(ret, d)
+		}
+	}
+
+	return ret
+}
+
+// Plan shows resources that `goployer deploy` would create, resize and delete
+func (r Runner) Plan() error {
+	r.Logger.Debug("create deployers for stacks to plan")
+	var plans []schemas.Plan
+	for _, stack := range r.Builder.Stacks {
+		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
+			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+			continue
+		}
+
+		d := getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Notifier.ForStack(stack), r.Collector)
+		if err := d.CheckPreviousResources(r.Builder.Config); err != nil {
+			return err
+		}
+
+		plan, err := d.Plan(r.Builder.Config)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, plan)
+	}
+
+	return PrintPlans(r.Out, plans, r.Builder.Config.Output)
+}
+
+// Rollback is the main function for `goployer rollback`
+func (r Runner) Rollback() error {
+	if err := tool.LocalCheck("Do you really want to rollback this application? ", r.Builder.Config.AutoApply); err != nil {
+		return err
+	}
+
+	r.Logger.Infof("Beginning rollback: %s", r.Builder.AwsConfig.Name)
+
+	if r.Builder.MetricConfig.Enabled {
+		if err := r.CheckEnabledMetrics(); err != nil {
+			return err
+		}
+	}
+
+	for _, stack := range r.Builder.Stacks {
+		if r.Builder.Config.Stack != "" && stack.Stack != r.Builder.Config.Stack {
+			r.Logger.Debugf("Skipping this stack, stack=%s", stack.Stack)
+			continue
+		}
+
+		// previous version always replaces the current one in blue/green way
+		stack.ReplacementType = constants.BlueGreenDeployment
+		d := getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Notifier.ForStack(stack), r.Collector)
+		if err := RollbackStack(d, r.Builder.Config); err != nil {
+			return err
+		}
+	}
+
+	r.Logger.Info("Rollback operation is finished")
+	return nil
+}
+
+// RollbackStack brings back the previous version of stack and drains the current one
+func RollbackStack(d deployer.DeployManager, config schemas.Config) error {
+	if err := d.CheckPreviousResources(config); err != nil {
+		return err
+	}
+
+	if err := d.GetDeployer().RollbackToVersion(config); err != nil {
+		return err
+	}
+
+	steps := []func(config schemas.Config) error{
+		d.HealthChecking,
+		d.FinishAdditionalWork,
+		d.TriggerLifecycleCallbacks,
+		d.CleanPreviousVersion,
+		d.CleanChecking,
+	}
+
+	for _, step := range steps {
+		if err := step(config); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -453,7 +1316,7 @@ func (r Runner) Delete() error {
 		}
 
 		r.Logger.Debugf("add deployer setup function : %s", stack.Stack)
-		d := getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector)
+		d := getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Notifier.ForStack(stack), r.Collector)
 		deployers = append(deployers, d)
 	}
 
@@ -476,6 +1339,7 @@ func (r Runner) Delete() error {
 			if err := deployer.TriggerLifecycleCallbacks(r.Builder.Config); err != nil {
 				r.Logger.Errorf("[StepTriggerLifecycleCallbacks] trigger lifecycle callbacks error occurred: %s", err.Error())
 				errs <- err
+				return
 			}
 
 			// Clear previous Version
@@ -563,7 +1427,7 @@ func (r Runner) Status() error {
 
 	inspector.StatusSummary = inspector.SetStatusSummary(group, securityGroups)
 
-	if err := inspector.Print(); err != nil {
+	if err := inspector.Print(r.Out, r.Builder.Config.Output); err != nil {
 		return err
 	}
 
@@ -590,10 +1454,12 @@ func (r Runner) Update() error {
 	if err := CheckUpdateInformation(oldCapacity, newCapacity); err != nil {
 		return err
 	}
-	color.Cyan.Fprintln(os.Stdout, "[ AS IS ]")
-	color.Cyan.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", oldCapacity.Min, oldCapacity.Desired, oldCapacity.Max)
-	color.Green.Fprintln(os.Stdout, "[ TO BE ]")
-	color.Green.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", newCapacity.Min, newCapacity.Desired, newCapacity.Max)
+	if !tool.IsDocumentOutput(r.Builder.Config.Output) {
+		color.Cyan.Fprintln(os.Stdout, "[ AS IS ]")
+		color.Cyan.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", oldCapacity.Min, oldCapacity.Desired, oldCapacity.Max)
+		color.Green.Fprintln(os.Stdout, "[ TO BE ]")
+		color.Green.Fprintf(os.Stdout, "Min: %d, Desired: %d, Max: %d", newCapacity.Min, newCapacity.Desired, newCapacity.Max)
+	}
 
 	if err := tool.LocalCheck("Do you really want to update? ", r.Builder.Config.AutoApply); err != nil {
 		return err
@@ -621,7 +1487,7 @@ func (r Runner) Update() error {
 
 	r.Logger.Debugf("create deployer for update")
 	deployers := []deployer.DeployManager{
-		getDeployer(r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Slacker, r.Collector),
+		getDeployer(r.Context, r.Logger, stack, r.Builder.AwsConfig, r.Builder.APITestTemplates, r.Builder.Config.Region, r.Notifier.ForStack(stack), r.Collector),
 	}
 
 	// Health checking step
@@ -648,6 +1514,15 @@ func (r Runner) Update() error {
 
 	r.Logger.Debugf("Health check process is done")
 	r.Logger.Infof("update operation is finished")
+
+	if tool.IsDocumentOutput(r.Builder.Config.Output) {
+		return tool.PrintDocument(r.Out, inspector.UpdateResult{
+			AutoScalingGroup: i.UpdateFields.AutoscalingName,
+			PreviousCapacity: oldCapacity,
+			Capacity:         newCapacity,
+		}, r.Builder.Config.Output)
+	}
+
 	return nil
 }
 
@@ -687,7 +1562,7 @@ func (r Runner) Refresh() error {
 		return err
 	}
 
-	if err := refresher.PrintResult(); err != nil {
+	if err := refresher.PrintResult(r.Out, r.Builder.Config.Output); err != nil {
 		return err
 	}
 
@@ -700,7 +1575,7 @@ func (r Runner) Refresh() error {
 }
 
 // Generate new deployer
-func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.AWSConfig, apiTestTemplates []*schemas.APITestTemplate, region string, slack slack.Slack, c collector.Collector) deployer.DeployManager {
+func getDeployer(ctx context.Context, logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.AWSConfig, apiTestTemplates []*schemas.APITestTemplate, region string, n notifier.Notifier, c collector.Collector) deployer.DeployManager {
 	var att *schemas.APITestTemplate
 	if stack.APITestEnabled {
 		for _, at := range apiTestTemplates {
@@ -717,8 +1592,9 @@ func getDeployer(logger *Logger.Logger, stack schemas.Stack, awsConfig schemas.A
 		AwsConfig:        awsConfig,
 		APITestTemplates: att,
 		Region:           region,
-		Slack:            slack,
+		Notifier:         n,
 		Collector:        c,
+		Context:          ctx,
 	}
 
 	var d deployer.DeployManager
@@ -748,10 +1624,249 @@ func (r Runner) CheckEnabledMetrics() error {
 
 // FilterS3Path detects s3 path
 func FilterS3Path(path string) (string, string) {
-	path = strings.ReplaceAll(path, constants.S3Prefix, "")
-	split := strings.Split(path, "/")
+	return tool.ParseS3Path(path)
+}
+
+// PrintPlans prints plans of stacks with the output format
+func PrintPlans(out io.Writer, plans []schemas.Plan, output string) error {
+	if tool.IsDocumentOutput(output) {
+		return tool.PrintDocument(out, plans, output)
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Stack", "Region", "Action", "Resource", "Name", "Detail"})
+	table.SetCenterSeparator("|")
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+
+	for _, plan := range plans {
+		for _, change := range plan.Changes {
+			table.Append([]string{plan.Stack, change.Region, change.Action, change.Resource, change.Name, change.Detail})
+		}
+	}
+	table.Render()
+
+	return nil
+}
+
+// PrintHistories prints deployment records with the output format
+func PrintHistories(out io.Writer, histories []collector.History, output string) error {
+	if tool.IsDocumentOutput(output) {
+		return tool.PrintDocument(out, histories, output)
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Autoscaling Group", "Status", "Started", "Duration", "AMI", "Instance Type", "TG Requests", "LB Requests"})
+	table.SetCenterSeparator("|")
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+
+	for _, h := range histories {
+		duration := "-"
+		if h.DeployedDate != nil {
+			duration = h.Duration().String()
+		}
+
+		table.Append([]string{
+			h.AutoScalingGroup,
+			h.Status,
+			h.StartDate.Format(constants.HistoryTimeFormat),
+			duration,
+			h.Ami,
+			h.InstanceType,
+			fmt.Sprintf("%.0f", h.TargetGroupRequests),
+			fmt.Sprintf("%.0f", h.LoadBalancerRequests),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+// PrintHistory prints the detail of single deployment record with the output format
+func PrintHistory(out io.Writer, h collector.History, output string) error {
+	if tool.IsDocumentOutput(output) {
+		return tool.PrintDocument(out, h, output)
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(constants.HistoryTimeFormat)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 5, 3, ' ', tabwriter.TabIndent)
+	fmt.Fprintf(w, "Autoscaling Group\t%s\n", h.AutoScalingGroup)
+	fmt.Fprintf(w, "Stack\t%s\n", h.Stack)
+	fmt.Fprintf(w, "Region\t%s\n", h.Region)
+	fmt.Fprintf(w, "Status\t%s\n", h.Status)
+	fmt.Fprintf(w, "Replacement Type\t%s\n", h.StackConfig.ReplacementType)
+	fmt.Fprintf(w, "AMI\t%s\n", h.Ami)
+	fmt.Fprintf(w, "Instance Type\t%s\n", h.InstanceType)
+	fmt.Fprintf(w, "Capacity\tmin %d / desired %d / max %d\n", h.StackConfig.Capacity.Min, h.StackConfig.Capacity.Desired, h.StackConfig.Capacity.Max)
+	fmt.Fprintf(w, "Started\t%s\n", h.StartDate.Format(constants.HistoryTimeFormat))
+	fmt.Fprintf(w, "Deployed\t%s\n", formatTime(h.DeployedDate))
+	fmt.Fprintf(w, "Terminated\t%s\n", formatTime(h.TerminatedDate))
+	fmt.Fprintf(w, "Rolled back\t%s\n", formatTime(h.RolledbackDate))
+	fmt.Fprintf(w, "Uptime\t%.2f hours\n", h.UptimeHour)
+	fmt.Fprintf(w, "Target Group Requests\t%.0f\n", h.TargetGroupRequests)
+	fmt.Fprintf(w, "Load Balancer Requests\t%.0f\n", h.LoadBalancerRequests)
+
+	var keys []string
+	for k := range h.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintln(w, "Tags\t")
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", k, h.Tags[k])
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(h.ReleaseNotes) > 0 {
+		fmt.Fprintf(out, "\nRelease Notes\n%s\n", h.ReleaseNotes)
+	}
+
+	if len(h.Userdata) > 0 {
+		fmt.Fprintf(out, "\nUserdata\n%s\n", h.Userdata)
+	}
+
+	return nil
+}
+
+// PrintHistoryDiff prints differences between two deployment records with the output format
+func PrintHistoryDiff(out io.Writer, diffs []collector.HistoryDiff, output string) error {
+	if tool.IsDocumentOutput(output) {
+		if diffs == nil {
+			diffs = []collector.HistoryDiff{}
+		}
+		return tool.PrintDocument(out, diffs, output)
+	}
+
+	if len(diffs) == 0 {
+		_, err := fmt.Fprintln(out, "no difference")
+		return err
+	}
+
+	section := ""
+	for _, d := range diffs {
+		if d.Section != section {
+			section = d.Section
+			fmt.Fprintln(out, section)
+		}
+
+		switch {
+		case section == "userdata" && strings.HasPrefix(d.Key, "+"):
+			fmt.Fprintf(out, "  + %s\n", d.New)
+		case section == "userdata":
+			fmt.Fprintf(out, "  - %s\n", d.Old)
+		default:
+			fmt.Fprintf(out, "  %s: %q -> %q\n", d.Key, d.Old, d.New)
+		}
+	}
+
+	return nil
+}
+
+// PrintDORAReports prints DORA metrics with the output format
+func PrintDORAReports(out io.Writer, reports []collector.DORAReport, output string) error {
+	switch output {
+	case constants.JSONOutput, constants.YAMLOutput:
+		if reports == nil {
+			reports = []collector.DORAReport{}
+		}
+		return tool.PrintDocument(out, reports, output)
+	case constants.CSVOutput:
+		w := csv.NewWriter(out)
+		w.Write([]string{"stack", "since", "until", "deployments", "deployment_frequency_per_day", "lead_time_second", "failed_deployments", "change_failure_rate", "restored_failures", "mean_time_to_restore_second"})
+		for _, r := range reports {
+			w.Write([]string{
+				r.Stack,
+				r.Since.Format(time.RFC3339),
+				r.Until.Format(time.RFC3339),
+				strconv.Itoa(r.Deployments),
+				strconv.FormatFloat(r.DeploymentFrequency, 'f', 3, 64),
+				formatSeconds(r.LeadTimeSecond),
+				strconv.Itoa(r.FailedDeployments),
+				strconv.FormatFloat(r.ChangeFailureRate, 'f', 3, 64),
+				strconv.Itoa(r.RestoredFailures),
+				formatSeconds(r.MeanTimeToRestoreSecond),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	table := tablewriter.NewWriter(out)
+	table.SetHeader([]string{"Stack", "Deployments", "Frequency", "Lead Time", "Change Failure Rate", "MTTR"})
+	table.SetCenterSeparator("|")
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+
+	for _, r := range reports {
+		table.Append([]string{
+			r.Stack,
+			strconv.Itoa(r.Deployments),
+			fmt.Sprintf("%.2f/day", r.DeploymentFrequency),
+			formatDuration(r.LeadTimeSecond),
+			fmt.Sprintf("%.1f%% (%d)", r.ChangeFailureRate*100, r.FailedDeployments),
+			formatDuration(r.MeanTimeToRestoreSecond),
+		})
+	}
+	table.Render()
+
+	return nil
+}
+
+// formatSeconds formats seconds or returns empty string if it does not exist
+func formatSeconds(v *float64) string {
+	if v == nil {
+		return constants.EmptyString
+	}
+
+	return strconv.FormatFloat(*v, 'f', 0, 64)
+}
+
+// formatDuration formats seconds as duration or returns - if it does not exist
+func formatDuration(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+
+	return time.Duration(*v * float64(time.Second)).Round(time.Second).String()
+}
+
+// ParseSince parses the beginning of period from duration like 72h or 7d, date or RFC3339 timestamp
+func ParseSince(since string, now time.Time) (time.Time, error) {
+	if len(since) == 0 {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(since, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(since, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(since); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, since, now.Location()); err == nil {
+			return t, nil
+		}
+	}
 
-	return split[0], strings.Join(split[1:], "/")
+	return time.Time{}, fmt.Errorf("since is not valid, use duration like 72h or 7d, or date like 2020-01-02: %s", since)
 }
 
 // askApplicationName gets application name from interactive terminal
@@ -770,7 +1885,7 @@ func askApplicationName() (string, error) {
 
 // checkBuilderConfigurationNeeded checks if mode needs configuration settings like builder, metrics etc
 func checkBuilderConfigurationNeeded(mode string) bool {
-	return tool.IsStringInArray(mode, []string{"deploy", "delete"})
+	return tool.IsStringInArray(mode, []string{"deploy", "delete", "plan", "rollback", "resume", "unlock", "history", "report"})
 }
 
 // CheckUpdateInformation checks if updated information is valid or not
@@ -17,16 +17,31 @@ limitations under the license.
 package runner
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-test/deep"
+	Logger "github.com/sirupsen/logrus"
 
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/collector"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/deployer"
+	"github.com/DevopsArtFactory/goployer/pkg/helper"
+	"github.com/DevopsArtFactory/goployer/pkg/notifier"
 	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+	"github.com/DevopsArtFactory/goployer/pkg/state"
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
 )
 
 func TestFilterS3Path(t *testing.T) {
@@ -118,3 +133,306 @@ func TestGoroutineError(t *testing.T) {
 		t.Errorf("validation error")
 	}
 }
+
+func TestPrintPlans(t *testing.T) {
+	plans := []schemas.Plan{
+		{
+			Stack: "artd",
+			Mode:  constants.BlueGreenDeployment,
+			Changes: []schemas.PlanChange{
+				{
+					Region:   constants.DefaultRegion,
+					Action:   constants.PlanCreate,
+					Resource: "autoscaling group",
+					Name:     "hello-artd_useast1-v001",
+					Detail:   "min: 1, desired: 1, max: 1",
+				},
+			},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := PrintPlans(buf, plans, constants.JSONOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	var output []schemas.Plan
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(output, plans); diff != nil {
+		t.Error(diff)
+	}
+
+	buf.Reset()
+	if err := PrintPlans(buf, plans, constants.TableOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "hello-artd_useast1-v001") {
+		t.Errorf("table does not contain change: %s", buf.String())
+	}
+
+	// plan is written to the output of runner
+	buf.Reset()
+	r := Runner{Logger: Logger.New(), Out: buf, Builder: builder.Builder{Config: schemas.Config{Output: constants.JSONOutput}}}
+	if err := r.Plan(); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(buf.String()) != "null" {
+		t.Errorf("unexpected plan output: %q", buf.String())
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	testData := []struct {
+		since    string
+		expected time.Time
+		err      bool
+	}{
+		{since: "", expected: time.Time{}},
+		{since: "72h", expected: now.Add(-72 * time.Hour)},
+		{since: "7d", expected: now.AddDate(0, 0, -7)},
+		{since: "2020-10-01", expected: time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{since: "2020-10-01T09:00:00Z", expected: time.Date(2020, 10, 1, 9, 0, 0, 0, time.UTC)},
+		{since: "yesterday", err: true},
+		{since: "-1d", err: true},
+	}
+
+	for _, td := range testData {
+		out, err := ParseSince(td.since, now)
+		if (err != nil) != td.err {
+			t.Errorf("unexpected error for %s: %v", td.since, err)
+		}
+
+		if !td.err && !out.Equal(td.expected) {
+			t.Errorf("%s: expected %s, got %s", td.since, td.expected, out)
+		}
+	}
+}
+
+func TestPrintHistories(t *testing.T) {
+	started := time.Date(2020, 10, 10, 12, 0, 0, 0, time.UTC)
+	deployed := started.Add(5 * time.Minute)
+	histories := []collector.History{
+		{
+			AutoScalingGroup:    "hello-artd_useast1-v002",
+			Status:              "deployed",
+			Ami:                 "ami-2",
+			InstanceType:        "t3.small",
+			StartDate:           started,
+			DeployedDate:        &deployed,
+			DurationSecond:      300,
+			TargetGroupRequests: 1200,
+		},
+		{
+			AutoScalingGroup: "hello-artd_useast1-v001",
+			Status:           "creating",
+			StartDate:        started.Add(-time.Hour),
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := PrintHistories(buf, histories, constants.TableOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"hello-artd_useast1-v002", "5m0s", "ami-2", "1200", "hello-artd_useast1-v001"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("table does not contain %s: %s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := PrintHistories(buf, histories, constants.JSONOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	var output []collector.History
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(output, histories); diff != nil {
+		t.Error(diff)
+	}
+
+	buf.Reset()
+	diffs := []collector.HistoryDiff{
+		{Section: "config", Key: "ami", Old: "ami-1", New: "ami-2"},
+		{Section: "userdata", Key: "-2", Old: "echo hello"},
+		{Section: "userdata", Key: "+2", New: "echo world"},
+	}
+	if err := PrintHistoryDiff(buf, diffs, constants.TableOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "config\n  ami: \"ami-1\" -> \"ami-2\"\nuserdata\n  - echo hello\n  + echo world\n"
+	if buf.String() != expected {
+		t.Errorf("expected: %q, got: %q", expected, buf.String())
+	}
+}
+
+func TestPrintDORAReports(t *testing.T) {
+	since := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	leadTime := float64(5400)
+	reports := []collector.DORAReport{
+		{Stack: "artd", Since: since, Until: since.AddDate(0, 0, 10), Deployments: 5, DeploymentFrequency: 0.5, LeadTimeSecond: &leadTime, FailedDeployments: 1, ChangeFailureRate: 0.2},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := PrintDORAReports(buf, reports, constants.TableOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"artd", "0.50/day", "1h30m0s", "20.0% (1)"} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("table does not contain %s: %s", s, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := PrintDORAReports(buf, reports, constants.CSVOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "stack,since,until,deployments,deployment_frequency_per_day,lead_time_second,failed_deployments,change_failure_rate,restored_failures,mean_time_to_restore_second\n" +
+		"artd,2020-10-01T00:00:00Z,2020-10-11T00:00:00Z,5,0.500,5400,1,0.200,0,\n"
+	if buf.String() != expected {
+		t.Errorf("expected csv:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := PrintDORAReports(buf, nil, constants.JSONOutput); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(buf.String()) != "[]" {
+		t.Errorf("empty report should be printed as an empty list: %s", buf.String())
+	}
+}
+
+func TestRunner_FinishDeploymentCanaryAbort(t *testing.T) {
+	region := constants.DefaultRegion
+	cloud := fake.NewCloud(region)
+	tgArn := cloud.AddTargetGroup("hello-dev", 80)
+	lbArn := cloud.AddLoadBalancer("hello-dev", tgArn)
+
+	prevAsg := tool.GenerateAsgName(tool.BuildPrefixName("hello", "dev", region), 0)
+	cloud.AddAutoScalingGroup(prevAsg, schemas.Capacity{Min: 2, Max: 2, Desired: 2}, []string{tgArn}, nil)
+
+	userdata := filepath.Join(t.TempDir(), "userdata.sh")
+	if err := os.WriteFile(userdata, []byte("#!/bin/bash\necho hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h := helper.DeployerHelper{
+		Logger: Logger.New(),
+		Stack: schemas.Stack{
+			Stack:           "dev",
+			Env:             "dev",
+			ReplacementType: constants.CanaryDeployment,
+			Capacity:        schemas.Capacity{Min: 2, Max: 2, Desired: 2},
+			Userdata:        schemas.Userdata{Type: "local", Path: userdata},
+			BlockDevices:    []schemas.BlockDevice{{DeviceName: "/dev/xvda", VolumeSize: 8, VolumeType: "gp3"}},
+			ProgressiveCanary: &schemas.ProgressiveCanary{
+				Steps:    []schemas.CanaryStep{{Weight: 10}, {Weight: 100}},
+				Analysis: schemas.CanaryAnalysis{Max5xxRate: 5},
+			},
+			Regions: []schemas.RegionConfig{
+				{
+					Region:                 region,
+					AmiID:                  "ami-0123456789abcdef0",
+					InstanceType:           "t3.micro",
+					VPC:                    "fake-vpc",
+					SecurityGroups:         []string{"hello-dev"},
+					HealthcheckTargetGroup: "hello-dev",
+					TargetGroups:           []string{"hello-dev"},
+				},
+			},
+		},
+		AwsConfig: schemas.AWSConfig{Name: "hello"},
+		Notifier:  notifier.Router{},
+	}
+	d := deployer.InitDeploymentConfiguration(&h, []aws.Client{cloud.Client()})
+	c := &deployer.Canary{
+		PrevHealthCheckTargetGroups: map[string]string{},
+		PrevTargetGroups:            map[string][]string{},
+		TargetGroups:                map[string][]*string{},
+		LoadBalancer:                map[string]string{},
+		LBSecurityGroup:             map[string]*string{},
+		CanaryTraffic:               map[string]*schemas.CanaryTraffic{},
+		Deployer:                    &d,
+	}
+
+	store, err := state.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := schemas.Config{
+		Timeout:         time.Minute,
+		PollingInterval: 10 * time.Millisecond,
+		StartTimestamp:  time.Now().Unix(),
+		DisableMetrics:  true,
+	}
+	r := Runner{Logger: Logger.New(), Builder: builder.Builder{Config: config}, StateStore: store}
+
+	for _, step := range []func(config schemas.Config) error{c.CheckPreviousResources, c.Deploy, c.HealthChecking} {
+		if err := step(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cloud.SetTargetGroupMetrics(cloud.TargetGroupArn(c.GenerateCanaryTargetGroupName(0)), aws.TargetGroupMetrics{RequestCount: 100, Target5xxCount: 10})
+
+	failed, rolledBack, unverified := newStackSet(), newStackSet(), newStackSet()
+	r.finishDeployment(c, failed, rolledBack, unverified)
+
+	if !failed.Has("dev") || rolledBack.Has("dev") || unverified.Has("dev") {
+		t.Fatalf("aborted canary should be failed: failed=%v, rolledBack=%v, unverified=%v", failed.List(), rolledBack.List(), unverified.List())
+	}
+
+	group := cloud.AutoScalingGroup(prevAsg)
+	if group == nil {
+		t.Fatalf("previous autoscaling group is deleted: %s", prevAsg)
+	}
+
+	if *group.MinSize != 2 || *group.MaxSize != 2 || *group.DesiredCapacity != 2 || len(group.Instances) != 2 {
+		t.Errorf("previous autoscaling group should keep its capacity: min=%d, max=%d, desired=%d, instances=%d", *group.MinSize, *group.MaxSize, *group.DesiredCapacity, len(group.Instances))
+	}
+
+	if weights := cloud.ListenerWeights(lbArn); len(weights) != 1 || weights[tgArn] != 1 {
+		t.Errorf("traffic is not restored to original target group: %v", weights)
+	}
+}
+
+func TestRunner_RollbackDeploymentFailure(t *testing.T) {
+	store, err := state.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Runner{Logger: Logger.New(), StateStore: store}
+
+	// deployer without aws client cannot delete the new autoscaling group
+	d := newTestDeployer()
+	d.GetDeployer().Stack.RollbackOnFailure = true
+
+	failed := newStackSet()
+	if r.RollbackDeployment(d, failed) {
+		t.Error("deployment should not be regarded as rolled back when rollback fails")
+	}
+
+	if !failed.Has("artd") {
+		t.Errorf("stack should be failed when rollback fails: %v", failed.List())
+	}
+
+	d.GetDeployer().Stack.RollbackOnFailure = false
+	failed = newStackSet()
+	if r.RollbackDeployment(d, failed) || failed.Has("artd") {
+		t.Error("deployment should not be rolled back without rollback_on_failure")
+	}
+}
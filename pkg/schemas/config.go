@@ -33,6 +33,13 @@ type Config struct { // Do not add comments for this struct
 	OverrideSpotType       string `json:"override_spot_types"`
 	ReleaseNotes           string `json:"release_notes"`
 	ReleaseNotesBase64     string `json:"release_notes_base64"`
+	CommitTimestamp        string `json:"commit_timestamp"`
+	Output                 string `json:"output"`
+	StateDir               string `json:"state_dir"`
+	EventsFile             string `json:"events_file"`
+	LockBackend            string `json:"lock_backend"`
+	Since                  string `json:"since"`
+	Period                 string `json:"period"`
 	Application            string
 	TargetAutoscalingGroup string
 	Min                    int64 `json:"min"`
@@ -40,9 +47,11 @@ type Config struct { // Do not add comments for this struct
 	Desired                int64 `json:"desired"`
 	InstanceWarmup         int64 `json:"instance_warmup"`
 	MinHealthyPercentage   int64 `json:"min_healthy_percentage"`
+	ToVersion              int64 `json:"to_version"`
 	StartTimestamp         int64
 	Timeout                time.Duration `json:"timeout"`
 	PollingInterval        time.Duration `json:"polling_interval"`
+	LockTTL                time.Duration `json:"lock_ttl"`
 	AutoApply              bool          `json:"auto-apply"`
 	DisableMetrics         bool          `json:"disable_metrics"`
 	SlackOff               bool          `json:"slack_off"`
@@ -65,11 +74,17 @@ type YamlConfig struct {
 	// List of scheduled actions
 	ScheduledActions []ScheduledAction `yaml:"scheduled_actions"`
 
+	// Map of account alias to IAM role which stacks of the account assume
+	Accounts map[string]Account `yaml:"accounts,omitempty"`
+
 	// List of stack configuration
 	Stacks []Stack `yaml:"stacks"`
 
 	// API Test configuration
 	APITestTemplates []*APITestTemplate `yaml:"api_test_templates,omitempty"`
+
+	// Channels of notification and routes of events
+	Notifications *Notifications `yaml:"notifications,omitempty"`
 }
 
 // AWS Related Configurations except for stack
@@ -85,6 +100,24 @@ type AWSConfig struct {
 
 	// List of scheduled action configuration
 	ScheduledActions []ScheduledAction
+
+	// Map of account alias to IAM role
+	Accounts map[string]Account
+
+	// Channels of notification and routes of events
+	Notifications *Notifications
+}
+
+// Account configuration for assuming IAM role
+type Account struct {
+	// IAM Role ARN for assume role
+	AssumeRole string `yaml:"assume_role"`
+
+	// External ID required by trust policy of the role
+	ExternalID string `yaml:"external_id,omitempty"`
+
+	// Session name of assumed role
+	SessionName string `yaml:"session_name,omitempty"`
 }
 
 // Userdata configuration
@@ -94,6 +127,12 @@ type Userdata struct {
 
 	// Path of userdata file
 	Path string `yaml:"path"`
+
+	// Region of s3 bucket which contains userdata
+	Region string `yaml:"region,omitempty"`
+
+	// Template renders userdata as go template with deployment variables
+	Template bool `yaml:"template,omitempty"`
 }
 
 // Scheduled Action configurations
@@ -120,6 +159,11 @@ type Stack struct {
 	Env string `yaml:"env,omitempty"`
 
 	// Type of Replacement for deployment
+	// Valid types are
+	// `BlueGreen`: creates new autoscaling group and deletes the previous one after health checking
+	// `Canary`: deploys new autoscaling group with canary instances before replacing the previous one
+	// `RollingUpdate`: replaces instances of the previous autoscaling group in batches
+	// `DeployOnly`: creates new autoscaling group without touching the previous one
 	ReplacementType string `yaml:"replacement_type"`
 
 	// Percentage of instances to terminate in one batch during termination process in BlueGreen deployment for termination delay
@@ -128,6 +172,15 @@ type Stack struct {
 	// Instance count per round in rolling update replacement type
 	RollingUpdateInstanceCount int64 `yaml:"rolling_update_instance_count"`
 
+	// Whether or not to delete the new autoscaling group and restore the previous one when health checking fails
+	RollbackOnFailure bool `yaml:"rollback_on_failure,omitempty"`
+
+	// Steps and analysis of progressive canary which shifts traffic gradually in canary deployment
+	ProgressiveCanary *ProgressiveCanary `yaml:"progressive_canary,omitempty"`
+
+	// Metrics which should not be breached before cleaning previous version
+	Verification *Verification `yaml:"verification,omitempty"`
+
 	// Userdata configuration for stack deployment
 	Userdata Userdata `yaml:"userdata,omitempty"`
 
@@ -143,6 +196,12 @@ type Stack struct {
 	// IAM Role ARN for assume role
 	AssumeRole string `yaml:"assume_role,omitempty"`
 
+	// External ID for assume role
+	ExternalID string `yaml:"external_id,omitempty"`
+
+	// Session name for assume role
+	SessionName string `yaml:"session_name,omitempty"`
+
 	// Polling interval when health checking
 	PollingInterval time.Duration `yaml:"polling_interval,omitempty"`
 
@@ -179,10 +238,165 @@ type Stack struct {
 	// Lifecycle hooks of autoscaling group
 	LifecycleHooks *LifecycleHooks `yaml:"lifecycle_hooks,omitempty"`
 
+	// Health checkers of new instances which are combined with healthcheck_target_group or healthcheck_load_balancer
+	HealthCheck *HealthCheck `yaml:"health_check,omitempty"`
+
+	// Criteria of healthy deployment which replace the requirement that every desired instance is healthy
+	HealthPolicy *HealthPolicy `yaml:"health_policy,omitempty"`
+
+	// Handling of failed scaling activities while new instances are launched
+	ScalingFailure *ScalingFailure `yaml:"scaling_failure,omitempty"`
+
+	// Routes of notification events for the stack which replace the routes of manifest
+	Notifications *StackNotifications `yaml:"notifications,omitempty"`
+
 	// List of region configurations
 	Regions []RegionConfig `yaml:"regions"`
 }
 
+// Notification configuration
+type Notifications struct {
+	// Channels which notifications are sent to
+	Channels []NotificationChannel `yaml:"channels,omitempty"`
+
+	// Routes of events to channels. Every event is sent to all channels if no route is specified
+	Routes []NotificationRoute `yaml:"routes,omitempty"`
+}
+
+// Notification configuration of stack
+type StackNotifications struct {
+	// Routes of events to channels of manifest
+	Routes []NotificationRoute `yaml:"routes"`
+}
+
+// Notification channel configuration. String values can refer to environment variables like ${TEAMS_WEBHOOK_URL}
+type NotificationChannel struct {
+	// Name of channel used in routes
+	Name string `yaml:"name"`
+
+	// Type of channel
+	// Valid types are
+	// `slack`: slack incoming webhook or bot token with channel
+	// `teams`: Microsoft Teams incoming webhook
+	// `webhook`: generic JSON webhook
+	// `email`: SMTP email
+	Type string `yaml:"type"`
+
+	// URL of incoming webhook of slack, teams or generic webhook
+	WebhookURL string `yaml:"webhook_url,omitempty"`
+
+	// Bot token of slack
+	Token string `yaml:"token,omitempty"`
+
+	// Channel ID of slack
+	Channel string `yaml:"channel,omitempty"`
+
+	// Key of HMAC-SHA256 signature sent in `X-Goployer-Signature` header of generic webhook
+	Secret string `yaml:"secret,omitempty"`
+
+	// SMTP server and recipients of email
+	SMTP *SMTPConfig `yaml:"smtp,omitempty"`
+}
+
+// SMTP configuration
+type SMTPConfig struct {
+	// Host of SMTP server
+	Host string `yaml:"host"`
+
+	// Port of SMTP server
+	Port int64 `yaml:"port,omitempty"`
+
+	// Username of SMTP authentication
+	Username string `yaml:"username,omitempty"`
+
+	// Password of SMTP authentication
+	Password string `yaml:"password,omitempty"`
+
+	// Sender address
+	From string `yaml:"from"`
+
+	// Recipient addresses
+	To []string `yaml:"to"`
+}
+
+// Notification route configuration
+type NotificationRoute struct {
+	// Events sent to the channels. Empty means all events
+	// Valid events are `start`, `progress`, `healthy`, `failed`, `cleaned`, `api_test` and `finished`
+	Events []string `yaml:"events,omitempty"`
+
+	// Names of channels
+	Channels []string `yaml:"channels"`
+}
+
+// Progressive canary configuration
+type ProgressiveCanary struct {
+	// Traffic shifting steps in ascending order of weight
+	Steps []CanaryStep `yaml:"steps"`
+
+	// Thresholds of metrics checked after each step
+	Analysis CanaryAnalysis `yaml:"analysis,omitempty"`
+}
+
+// Canary step configuration
+type CanaryStep struct {
+	// Percentage of traffic forwarded to the new target group
+	Weight int64 `yaml:"weight"`
+
+	// Duration to wait before analyzing metrics of the step
+	Pause time.Duration `yaml:"pause,omitempty"`
+}
+
+// Canary analysis configuration
+type CanaryAnalysis struct {
+	// Maximum percentage of 5xx responses of the new target group
+	Max5xxRate float64 `yaml:"max_5xx_rate,omitempty"`
+
+	// Maximum average response time of the new target group in seconds
+	MaxLatency float64 `yaml:"max_latency,omitempty"`
+
+	// Minimum request count of the step to judge metrics
+	MinRequestCount int64 `yaml:"min_request_count,omitempty"`
+}
+
+// Verification configuration
+type Verification struct {
+	// Duration to observe metrics after new instances become healthy
+	Window time.Duration `yaml:"window"`
+
+	// List of metric queries with thresholds
+	Metrics []VerificationMetric `yaml:"metrics"`
+}
+
+// Verification metric configuration
+type VerificationMetric struct {
+	// Name of verification
+	Name string `yaml:"name"`
+
+	// Namespace of metric
+	Namespace string `yaml:"namespace"`
+
+	// Name of metric
+	Metric string `yaml:"metric"`
+
+	// Type of statistics for metric
+	Statistic string `yaml:"statistic"`
+
+	// Comparison operator which means the metric is breached
+	Comparison string `yaml:"comparison"`
+
+	// Threshold of metric
+	Threshold float64 `yaml:"threshold"`
+
+	// Dimensions of metric
+	// If empty, target group of the new deployment is used for AWS/ApplicationELB and autoscaling group for the others
+	Dimensions map[string]string `yaml:"dimensions,omitempty"`
+
+	// How to treat the metric without datapoint in the window
+	// Valid values are `breaching` and `notBreaching`. default is `breaching`
+	TreatMissingData string `yaml:"treat_missing_data,omitempty"`
+}
+
 // Instance Market Options Configuration
 type InstanceMarketOptions struct {
 	// Type of market for EC2 instance
@@ -210,6 +424,11 @@ type MixedInstancesPolicy struct {
 	SpotInstancePools int64 `yaml:"spot_instance_pools"`
 
 	// Allocation strategy for spot instances
+	// Valid strategies are
+	// `lowest-price` (default): launches spot instances from the lowest priced pools
+	// `capacity-optimized`: launches spot instances from the pools with optimal capacity
+	// `capacity-optimized-prioritized`: launches spot instances from optimal pools in the order of override
+	// `price-capacity-optimized`: launches spot instances from the lowest priced pools with high capacity
 	SpotAllocationStrategy string `yaml:"spot_allocation_strategy"`
 
 	// Maximum spot price
@@ -239,7 +458,14 @@ type BlockDevice struct {
 	// Size of volume
 	VolumeSize int64 `yaml:"volume_size"`
 
-	// Type of volume (gp2, io1, io2, st1, sc1)
+	// Type of volume
+	// Valid types are
+	// `gp2`: general purpose SSD
+	// `gp3`: general purpose SSD with provisioned performance
+	// `io1`: provisioned IOPS SSD
+	// `io2`: provisioned IOPS SSD with higher durability
+	// `st1`: throughput optimized HDD
+	// `sc1`: cold HDD
 	VolumeType string `yaml:"volume_type"`
 
 	// IOPS for io1, io2 volume
@@ -256,6 +482,151 @@ type BlockDevice struct {
 type LifecycleCallbacks struct {
 	// List of command before terminating previous autoscaling group
 	PreTerminatePastClusters []string `yaml:"pre_terminate_past_cluster"`
+
+	// How long to wait for commands to finish in every instance. Default is 10m
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// What to do when commands fail or time out in any instance
+	// Valid policies are
+	// `abort`: previous autoscaling group is not cleaned (default)
+	// `continue`: failures are reported and cleaning continues
+	// `retry`: commands are sent again to failed instances, and cleaning is aborted if they still fail
+	FailurePolicy string `yaml:"failure_policy,omitempty"`
+
+	// Number of retries with retry policy. Default is 1
+	Retries int64 `yaml:"retries,omitempty"`
+
+	// Hooks before new autoscaling group is created
+	PreDeploy []LifecycleHook `yaml:"pre_deploy,omitempty"`
+
+	// Hooks after new autoscaling group is created
+	PostDeploy []LifecycleHook `yaml:"post_deploy,omitempty"`
+
+	// Hooks after instances of new autoscaling group are healthy
+	PostHealthy []LifecycleHook `yaml:"post_healthy,omitempty"`
+
+	// Hooks before previous autoscaling group is cleaned
+	PreCleanup []LifecycleHook `yaml:"pre_cleanup,omitempty"`
+
+	// Hooks after previous autoscaling group is cleaned
+	PostCleanup []LifecycleHook `yaml:"post_cleanup,omitempty"`
+
+	// Hooks after any step of deployment fails
+	OnFailure []LifecycleHook `yaml:"on_failure,omitempty"`
+}
+
+// Scaling failure configuration
+type ScalingFailure struct {
+	// Policy when autoscaling group cannot launch instances because of terminal failure like invalid AMI or insufficient capacity
+	// Valid policies are
+	// `abort`: deployment fails immediately (default)
+	// `continue`: failure is only reported and deployment waits until timeout
+	FailurePolicy string `yaml:"failure_policy,omitempty"`
+
+	// Instance types which are used in order when instances cannot be launched because of capacity or spot price
+	FallbackInstanceTypes []string `yaml:"fallback_instance_types,omitempty"`
+}
+
+// Health policy configuration
+type HealthPolicy struct {
+	// Required percentage of healthy instances out of desired capacity. Cannot be used with healthy_count
+	HealthyPercentage int64 `yaml:"healthy_percentage,omitempty"`
+
+	// Required number of healthy instances. Cannot be used with healthy_percentage
+	HealthyCount int64 `yaml:"healthy_count,omitempty"`
+
+	// Minimum number of healthy instances in each availability zone of autoscaling group
+	MinHealthyPerAZ int64 `yaml:"min_healthy_per_az,omitempty"`
+
+	// Number of consecutive polls which should meet the criteria. Default is 1
+	ConsecutiveSuccesses int64 `yaml:"consecutive_successes,omitempty"`
+
+	// Maximum number of unhealthy instances which are terminated to be relaunched by autoscaling group
+	ReplaceUnhealthy int64 `yaml:"replace_unhealthy,omitempty"`
+
+	// How long instance should stay unhealthy before it is replaced. Default is 5m
+	ReplaceUnhealthyAfter time.Duration `yaml:"replace_unhealthy_after,omitempty"`
+}
+
+// Health check configuration
+type HealthCheck struct {
+	// Checkers which every new instance should pass to be healthy
+	Checkers []HealthChecker `yaml:"checkers"`
+}
+
+// Health checker configuration
+type HealthChecker struct {
+	// Name of checker. Default is the type
+	Name string `yaml:"name,omitempty"`
+
+	// Type of checker
+	// Valid types are
+	// `http`: send request to private ip of instance
+	// `tcp`: connect to private ip of instance
+	// `ssm`: run commands in instance with systems manager, which should exit 0
+	// `ec2_status`: wait for instance and system status checks of EC2
+	Type string `yaml:"type"`
+
+	// Port of http or tcp checker
+	Port int64 `yaml:"port,omitempty"`
+
+	// Path of http checker. Default is /
+	Path string `yaml:"path,omitempty"`
+
+	// Expected status code of http checker. Default is 200
+	ExpectedStatus int64 `yaml:"expected_status,omitempty"`
+
+	// Regular expression which response body of http checker should match
+	BodyMatch string `yaml:"body_match,omitempty"`
+
+	// Commands of ssm checker
+	Commands []string `yaml:"commands,omitempty"`
+
+	// Timeout of a check. Default is 5s, and 1m for ssm checker
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Lifecycle hook configuration. Hooks run in every region of stack.
+// Local commands get GOPLOYER_APP, GOPLOYER_STACK, GOPLOYER_REGION, GOPLOYER_PHASE, GOPLOYER_NEW_ASG and GOPLOYER_OLD_ASGS
+// environment variables, and url, headers and body of http hook can refer to them like ${GOPLOYER_NEW_ASG}.
+type LifecycleHook struct {
+	// Name of hook
+	Name string `yaml:"name"`
+
+	// Type of hook
+	// Valid types are
+	// `ssm`: run commands in instances with systems manager
+	// `local`: run commands in the machine where goployer runs. goployer server allows it only with --allow-local-hooks
+	// `http`: send http request
+	Type string `yaml:"type"`
+
+	// Commands of ssm or local hook
+	Commands []string `yaml:"commands,omitempty"`
+
+	// Instances where ssm hook runs: `new` or `old`.
+	// Default is `old` in pre_deploy and pre_cleanup, and `new` in other phases
+	Targets string `yaml:"targets,omitempty"`
+
+	// URL of http hook
+	URL string `yaml:"url,omitempty"`
+
+	// Method of http hook. Default is POST
+	Method string `yaml:"method,omitempty"`
+
+	// Headers of http hook
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Body of http hook
+	Body string `yaml:"body,omitempty"`
+
+	// How long to wait for hook to finish. Default is 10m
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// What to do when hook fails: `abort`(default), `continue` or `retry`
+	FailurePolicy string `yaml:"failure_policy,omitempty"`
+
+	// Number of retries with retry policy. Default is 1
+	Retries int64 `yaml:"retries,omitempty"`
 }
 
 // Policy of scaling policy
@@ -359,13 +730,13 @@ type RegionConfig struct {
 // Instance capacity of autoscaling group
 type Capacity struct {
 	// Minimum number of instances
-	Min int64 `yaml:"min"`
+	Min int64 `yaml:"min" json:"min"`
 
 	// Maximum number of instances
-	Max int64 `yaml:"max"`
+	Max int64 `yaml:"max" json:"max"`
 
 	// Desired number of instances
-	Desired int64 `yaml:"desired"`
+	Desired int64 `yaml:"desired" json:"desired"`
 }
 
 // Lifecycle Hooks
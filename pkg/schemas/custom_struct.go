@@ -19,7 +19,7 @@ package schemas
 import vegeta "github.com/tsenart/vegeta/lib"
 
 type MetricResult struct {
-	URL    string
-	Method string
-	Data   vegeta.Metrics
+	URL    string         `json:"url"`
+	Method string         `json:"method"`
+	Data   vegeta.Metrics `json:"data"`
 }
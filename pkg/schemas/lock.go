@@ -0,0 +1,35 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package schemas
+
+// Lease is a lock of deployment held by a goployer process until it expires
+type Lease struct {
+	// Key of lock which consists of application, stack and region
+	Key string `json:"key"`
+
+	// Identity of holder
+	Holder string `json:"holder"`
+
+	// Random token which distinguishes holders
+	Token string `json:"token"`
+
+	// Unix time when lease is acquired
+	AcquiredAt int64 `json:"acquired_at"`
+
+	// Unix time when lease expires unless it is renewed
+	ExpiresAt int64 `json:"expires_at"`
+}
@@ -37,15 +37,46 @@ type MetricConfig struct {
 
 // Storage configurations
 type Storage struct {
-	// Storage Type - dynamodb
+	// Storage Type - dynamodb, local or s3
 	Type string `yaml:"type"`
 
-	// Storage Name
+	// Storage Name - table name of dynamodb, database file of local or bucket of s3
 	Name string `yaml:"name"`
+
+	// Billing mode of dynamodb table - `PAY_PER_REQUEST`(default) or `PROVISIONED`
+	BillingMode string `yaml:"billing_mode,omitempty"`
+
+	// Key prefix of objects in s3 bucket, default is deployments
+	Prefix string `yaml:"prefix,omitempty"`
 }
 
 // Configurations of metrics
 type Metrics struct {
 	// Timezone of metrics
 	BaseTimezone string
+
+	// User-defined cloudwatch metrics gathered over the lifetime of autoscaling group
+	Custom []CustomMetric `yaml:"custom,omitempty"`
+}
+
+// User-defined cloudwatch metric
+type CustomMetric struct {
+	// Name of field where the metric is stored
+	Name string `yaml:"name"`
+
+	// Namespace of metric
+	Namespace string `yaml:"namespace"`
+
+	// Name of metric
+	Metric string `yaml:"metric"`
+
+	// Dimensions of metric
+	// Values can use {{ .AutoScalingGroup }}, {{ .TargetGroup }} and {{ .LoadBalancer }}
+	Dimensions map[string]string `yaml:"dimensions,omitempty"`
+
+	// Type of statistics for metric - Average, Sum, Minimum, Maximum, SampleCount or percentile like p99
+	Statistic string `yaml:"statistic"`
+
+	// Period of datapoints in seconds, default is 300
+	Period int64 `yaml:"period,omitempty"`
 }
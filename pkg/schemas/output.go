@@ -0,0 +1,143 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package schemas
+
+// DeploymentSummary is the summary of configurations which deployment is started with
+type DeploymentSummary struct {
+	// Name of application
+	Application string `json:"application"`
+
+	// Region specified for deployment. Empty means all regions of stacks
+	Region string `json:"region,omitempty"`
+
+	// Configurations applied from command line
+	Config map[string]string `json:"config"`
+
+	// Stacks selected for deployment
+	Stacks []StackSummary `json:"stacks"`
+}
+
+// StackSummary is the summary of stack configuration
+type StackSummary struct {
+	// Name of stack
+	Stack string `json:"stack"`
+
+	// Name of AWS account
+	Account string `json:"account,omitempty"`
+
+	// Environment of stack
+	Env string `json:"env,omitempty"`
+
+	// Replacement type of stack
+	ReplacementType string `json:"replacement_type"`
+
+	// Regions of stack
+	Regions []string `json:"regions"`
+
+	// Capacity of stack in the manifest
+	Capacity Capacity `json:"capacity"`
+}
+
+// DeploymentResult is the document printed after deployment
+type DeploymentResult struct {
+	// Configurations which deployment is started with
+	Summary DeploymentSummary `json:"summary"`
+
+	// Results of stacks
+	Stacks []StackResult `json:"stacks"`
+
+	// Error which deployment is finished with
+	Error string `json:"error,omitempty"`
+}
+
+// StackResult is the result of deployment of a stack
+type StackResult struct {
+	// Name of stack
+	Stack string `json:"stack"`
+
+	// Replacement type of stack
+	Mode string `json:"mode"`
+
+	// Result of stack: succeeded, failed, rolled_back, unverified or stopped
+	Status string `json:"status"`
+
+	// Whether or not each step is finished
+	Steps map[string]bool `json:"steps"`
+
+	// Results of regions
+	Regions []RegionResult `json:"regions"`
+
+	// Result of API test
+	APITest *APITestResult `json:"api_test,omitempty"`
+}
+
+// RegionResult is the result of deployment in a region
+type RegionResult struct {
+	// Name of region
+	Region string `json:"region"`
+
+	// Name of new autoscaling group
+	AutoScalingGroup string `json:"auto_scaling_group,omitempty"`
+
+	// Capacity applied to new autoscaling group
+	Capacity *Capacity `json:"capacity,omitempty"`
+
+	// The last healthy instance count of new autoscaling group
+	HealthyCount int64 `json:"healthy_count"`
+}
+
+// APITestResult is the result of API test
+type APITestResult struct {
+	// Name of API test template
+	Name string `json:"name"`
+
+	// Metrics of APIs
+	Metrics []MetricResult `json:"metrics"`
+}
+
+// DeploymentEvent is a transition of deployment step in a region
+type DeploymentEvent struct {
+	// Time of transition in RFC3339
+	Time string `json:"time"`
+
+	// Name of application
+	Application string `json:"application"`
+
+	// Name of stack
+	Stack string `json:"stack"`
+
+	// Name of region
+	Region string `json:"region"`
+
+	// Name of step
+	Step string `json:"step"`
+
+	// Status of step: started, succeeded or failed
+	Status string `json:"status"`
+
+	// Name of new autoscaling group
+	AutoScalingGroup string `json:"auto_scaling_group,omitempty"`
+
+	// Capacity applied to new autoscaling group
+	Capacity *Capacity `json:"capacity,omitempty"`
+
+	// The last healthy instance count of new autoscaling group
+	HealthyCount int64 `json:"healthy_count"`
+
+	// Error of failed step
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,47 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package schemas
+
+// Plan is a list of changes that deployment of a stack would make
+type Plan struct {
+	// Name of stack
+	Stack string `json:"stack"`
+
+	// Replacement type of stack
+	Mode string `json:"mode"`
+
+	// Changes of AWS resources
+	Changes []PlanChange `json:"changes"`
+}
+
+// PlanChange is a single change of AWS resource
+type PlanChange struct {
+	// Region of resource
+	Region string `json:"region"`
+
+	// Action applied to resource: create, resize, attach or delete
+	Action string `json:"action"`
+
+	// Type of resource
+	Resource string `json:"resource"`
+
+	// Name of resource
+	Name string `json:"name"`
+
+	// Additional information about the change
+	Detail string `json:"detail,omitempty"`
+}
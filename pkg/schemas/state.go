@@ -0,0 +1,104 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package schemas
+
+// DeploymentState is the progress of deployment of a stack which is persisted after each step
+type DeploymentState struct {
+	// Name of application
+	Application string `json:"application"`
+
+	// Name of stack
+	Stack string `json:"stack"`
+
+	// Region specified for deployment. Empty means all regions of the stack
+	Region string `json:"region"`
+
+	// Replacement type of stack
+	Mode string `json:"mode"`
+
+	// Time when state is saved
+	UpdatedAt string `json:"updated_at"`
+
+	// Configuration which deployment was started with
+	Config Config `json:"config"`
+
+	// Finished steps of deployment
+	StepStatus map[int64]bool `json:"step_status"`
+
+	// Name of new autoscaling group per region
+	AsgNames map[string]string `json:"asg_names"`
+
+	// Previous autoscaling groups per region
+	PrevAsgs map[string][]string `json:"prev_asgs"`
+
+	// Instances of previous autoscaling groups per region
+	PrevInstances map[string][]string `json:"prev_instances"`
+
+	// Versions of previous autoscaling groups per region
+	PrevVersions map[string][]int `json:"prev_versions"`
+
+	// Capacity of previous autoscaling group per region
+	PrevInstanceCount map[string]Capacity `json:"prev_instance_count"`
+
+	// Latest autoscaling group per region
+	LatestAsg map[string]string `json:"latest_asg"`
+
+	// Additional security group per region
+	SecurityGroup map[string]*string `json:"security_group"`
+
+	// Deployment flag of previous autoscaling group per region
+	DeploymentFlag map[string]string `json:"deployment_flag"`
+
+	// Capacity applied to new autoscaling group
+	AppliedCapacity *Capacity `json:"applied_capacity,omitempty"`
+
+	// Target groups of previous autoscaling group per region (Canary, RollingUpdate)
+	PrevTargetGroups map[string][]string `json:"prev_target_groups,omitempty"`
+
+	// Target groups attached to new autoscaling group per region (Canary, RollingUpdate)
+	TargetGroups map[string][]*string `json:"target_groups,omitempty"`
+
+	// Health check target group of previous autoscaling group per region (Canary, RollingUpdate)
+	PrevHealthCheckTargetGroups map[string]string `json:"prev_healthcheck_target_groups,omitempty"`
+
+	// Load balancer created for deployment per region (Canary, RollingUpdate)
+	LoadBalancer map[string]string `json:"load_balancer,omitempty"`
+
+	// Security group of load balancer created for deployment per region (Canary, RollingUpdate)
+	LBSecurityGroup map[string]*string `json:"lb_security_group,omitempty"`
+
+	// Traffic shifting of progressive canary per region (Canary)
+	CanaryTraffic map[string]*CanaryTraffic `json:"canary_traffic,omitempty"`
+}
+
+// CanaryTraffic is traffic shifting between the original and the canary target group of progressive canary
+type CanaryTraffic struct {
+	// ARN of the original target group
+	OriginalTargetGroup string `json:"original_target_group"`
+
+	// ARN of the canary target group
+	CanaryTargetGroup string `json:"canary_target_group"`
+
+	// ARN of the load balancer which the original target group belongs to
+	LoadBalancer string `json:"load_balancer"`
+
+	// ARNs of listeners forwarding to the original target group
+	Listeners []string `json:"listeners"`
+
+	// Percentage of traffic currently forwarded to the canary target group
+	Weight int64 `json:"weight"`
+}
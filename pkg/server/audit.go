@@ -0,0 +1,97 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// AuditEntry is a record of request to deployment API
+type AuditEntry struct {
+	Time        string   `json:"time"`
+	RemoteAddr  string   `json:"remote_addr"`
+	Client      string   `json:"client,omitempty"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Application string   `json:"application,omitempty"`
+	Stacks      []string `json:"stacks,omitempty"`
+	JobID       string   `json:"job_id,omitempty"`
+	Status      int      `json:"status"`
+	Reason      string   `json:"reason,omitempty"`
+}
+
+// AuditLog writes audit entries as json lines
+type AuditLog struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAuditLog creates a new audit log
+func NewAuditLog(out io.Writer) *AuditLog {
+	return &AuditLog{out: out}
+}
+
+// Write writes an audit entry
+func (a *AuditLog) Write(entry AuditEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err = a.out.Write(append(b, '\n'))
+	return err
+}
+
+// auditKey is the context key of audit entry
+const auditKey = contextKey("audit")
+
+// auditOf returns audit entry of request which handlers fill in
+func auditOf(req *http.Request) *AuditEntry {
+	e, _ := req.Context().Value(auditKey).(*AuditEntry)
+	return e
+}
+
+// withAudit sets audit entry to request
+func withAudit(req *http.Request, e *AuditEntry) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), auditKey, e))
+}
+
+// statusWriter keeps status code of response for audit log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records status code
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush sends buffered data to client for streaming
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,250 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/DevopsArtFactory/goployer/pkg/tool"
+)
+
+const (
+	// TimestampHeader is the header of unix time when HMAC signed request is made
+	TimestampHeader = "X-Goployer-Timestamp"
+
+	// maxClockSkew is the maximum difference between timestamp of signed request and server time
+	maxClockSkew = 5 * time.Minute
+
+	// wildcard allows every application, stack or env
+	wildcard = "*"
+)
+
+var (
+	// ErrUnauthorized is returned when request does not have valid credentials
+	ErrUnauthorized = errors.New("request is not authenticated")
+)
+
+// AuthConfig is the configuration of clients which are allowed to use goployer server
+type AuthConfig struct {
+	Clients []Client `yaml:"clients"`
+}
+
+// Client is a caller of goployer server with permissions
+type Client struct {
+	// Name of client which is written to audit log
+	Name string `yaml:"name"`
+
+	// Bearer token of client
+	Token string `yaml:"token,omitempty"`
+
+	// Secret for HMAC signed requests
+	Secret string `yaml:"secret,omitempty"`
+
+	// Applications which client can deploy. "*" means all applications
+	Applications []string `yaml:"applications"`
+
+	// Stacks which client can deploy. Empty means all stacks
+	Stacks []string `yaml:"stacks,omitempty"`
+
+	// Envs of stacks which client can deploy. Empty means all envs
+	Envs []string `yaml:"envs,omitempty"`
+
+	// Whether or not client can override assume role of manifest
+	AllowAssumeRole bool `yaml:"allow_assume_role,omitempty"`
+}
+
+// LoadAuthConfig reads auth configuration file
+func LoadAuthConfig(path string) (AuthConfig, error) {
+	var config AuthConfig
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return config, fmt.Errorf("failed to parse auth config %s: %v", path, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, err
+	}
+
+	return config, nil
+}
+
+// Validate checks clients of auth configuration
+func (a AuthConfig) Validate() error {
+	if len(a.Clients) == 0 {
+		return errors.New("no client is defined in auth config")
+	}
+
+	names := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, c := range a.Clients {
+		if len(c.Name) == 0 {
+			return errors.New("name of client is required")
+		}
+
+		if names[c.Name] {
+			return fmt.Errorf("duplicated client name: %s", c.Name)
+		}
+		names[c.Name] = true
+
+		if (len(c.Token) == 0) == (len(c.Secret) == 0) {
+			return fmt.Errorf("either token or secret should be specified: %s", c.Name)
+		}
+
+		if len(c.Token) > 0 {
+			if tokens[c.Token] {
+				return fmt.Errorf("token is used by more than one client: %s", c.Name)
+			}
+			tokens[c.Token] = true
+		}
+
+		if len(c.Applications) == 0 {
+			return fmt.Errorf("applications are required, use \"*\" to allow all applications: %s", c.Name)
+		}
+	}
+
+	return nil
+}
+
+// Authenticate finds client of request with bearer token or HMAC signature.
+// Request body is read for the signature and restored so that handlers can read it again.
+func (a AuthConfig) Authenticate(req *http.Request) (*Client, error) {
+	header := req.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(header, "Bearer "):
+		token := strings.TrimPrefix(header, "Bearer ")
+		for i, c := range a.Clients {
+			if len(c.Token) > 0 && subtle.ConstantTimeCompare([]byte(c.Token), []byte(token)) == 1 {
+				return &a.Clients[i], nil
+			}
+		}
+	case strings.HasPrefix(header, "HMAC "):
+		parts := strings.SplitN(strings.TrimPrefix(header, "HMAC "), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: authorization should be HMAC <client>:<signature>", ErrUnauthorized)
+		}
+
+		for i, c := range a.Clients {
+			if c.Name != parts[0] || len(c.Secret) == 0 {
+				continue
+			}
+
+			if err := verifySignature(req, c.Secret, parts[1]); err != nil {
+				return nil, err
+			}
+			return &a.Clients[i], nil
+		}
+	case len(header) == 0:
+		return nil, fmt.Errorf("%w: authorization header is missing", ErrUnauthorized)
+	}
+
+	return nil, fmt.Errorf("%w: invalid credentials", ErrUnauthorized)
+}
+
+// Allows checks whether or not client can deploy the stack of application
+func (c *Client) Allows(app, stack, env string) error {
+	if !isAllowed(c.Applications, app) {
+		return fmt.Errorf("client %s is not allowed to deploy application: %s", c.Name, app)
+	}
+
+	if len(c.Stacks) > 0 && !isAllowed(c.Stacks, stack) {
+		return fmt.Errorf("client %s is not allowed to deploy stack: %s", c.Name, stack)
+	}
+
+	if len(c.Envs) > 0 && !isAllowed(c.Envs, env) {
+		return fmt.Errorf("client %s is not allowed to deploy env: %s", c.Name, env)
+	}
+
+	return nil
+}
+
+// Sign makes HMAC signature of request with secret
+func Sign(secret, timestamp, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n", timestamp, method, uri)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks timestamp and signature of request
+func verifySignature(req *http.Request, secret, signature string) error {
+	timestamp := req.Header.Get(TimestampHeader)
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s header should be unix time", ErrUnauthorized, TimestampHeader)
+	}
+
+	if skew := time.Since(time.Unix(sec, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return fmt.Errorf("%w: request is expired", ErrUnauthorized)
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(secret, timestamp, req.Method, req.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return fmt.Errorf("%w: invalid signature", ErrUnauthorized)
+	}
+
+	return nil
+}
+
+// isAllowed checks whether or not value is in the allowed list
+func isAllowed(allowed []string, value string) bool {
+	return tool.IsStringInArray(wildcard, allowed) || tool.IsStringInArray(value, allowed)
+}
+
+type contextKey string
+
+// clientKey is the context key of authenticated client
+const clientKey = contextKey("client")
+
+// clientOf returns authenticated client of request. nil means that authentication is disabled
+func clientOf(req *http.Request) *Client {
+	c, _ := req.Context().Value(clientKey).(*Client)
+	return c
+}
+
+// withClient sets authenticated client to request
+func withClient(req *http.Request, c *Client) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), clientKey, c))
+}
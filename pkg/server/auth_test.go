@@ -0,0 +1,254 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAuthConfig = AuthConfig{
+	Clients: []Client{
+		{Name: "ci", Token: "ci-token", Applications: []string{"hello"}, Envs: []string{"dev"}},
+		{Name: "ops", Secret: "ops-secret", Applications: []string{"*"}, AllowAssumeRole: true},
+	},
+}
+
+func TestAuthConfig_Validate(t *testing.T) {
+	testData := []struct {
+		clients []Client
+		err     string
+	}{
+		{clients: testAuthConfig.Clients},
+		{clients: nil, err: "no client is defined in auth config"},
+		{clients: []Client{{Token: "a", Applications: []string{"*"}}}, err: "name of client is required"},
+		{clients: []Client{{Name: "a", Applications: []string{"*"}}}, err: "either token or secret should be specified: a"},
+		{clients: []Client{{Name: "a", Token: "a", Secret: "a", Applications: []string{"*"}}}, err: "either token or secret should be specified: a"},
+		{clients: []Client{{Name: "a", Token: "a"}}, err: "applications are required, use \"*\" to allow all applications: a"},
+		{clients: []Client{{Name: "a", Token: "a", Applications: []string{"*"}}, {Name: "a", Token: "b", Applications: []string{"*"}}}, err: "duplicated client name: a"},
+		{clients: []Client{{Name: "a", Token: "a", Applications: []string{"*"}}, {Name: "b", Token: "a", Applications: []string{"*"}}}, err: "token is used by more than one client: b"},
+	}
+
+	for _, td := range testData {
+		err := AuthConfig{Clients: td.clients}.Validate()
+		if (err == nil && td.err != "") || (err != nil && err.Error() != td.err) {
+			t.Errorf("expected %q, got %v", td.err, err)
+		}
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goployer-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "auth.yaml")
+	if err := ioutil.WriteFile(path, []byte("clients:\n  - name: ci\n    token: ci-token\n    applications: [hello]\n    stacks: [artd]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadAuthConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Clients) != 1 || config.Clients[0].Stacks[0] != "artd" {
+		t.Errorf("unexpected config: %+v", config)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("clients:\n  - name: ci\n    tokens: ci-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadAuthConfig(path); err == nil {
+		t.Error("unknown field should not be allowed")
+	}
+}
+
+func signedRequest(secret, method, uri string, body []byte, timestamp time.Time) *http.Request {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	req := httptest.NewRequest(method, uri, bytes.NewReader(body))
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set("Authorization", fmt.Sprintf("HMAC ops:%s", Sign(secret, ts, method, uri, body)))
+
+	return req
+}
+
+func TestAuthConfig_Authenticate(t *testing.T) {
+	body := []byte(`{"config":{}}`)
+
+	bearer := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/deployments", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	tampered := signedRequest("ops-secret", http.MethodPost, "/deployments", body, time.Now())
+	tampered.Body = ioutil.NopCloser(strings.NewReader(`{"config":{"assume_role":"x"}}`))
+
+	testData := []struct {
+		name     string
+		req      *http.Request
+		expected string
+	}{
+		{name: "bearer", req: bearer("ci-token"), expected: "ci"},
+		{name: "wrong token", req: bearer("wrong")},
+		{name: "missing header", req: httptest.NewRequest(http.MethodGet, "/deployments", nil)},
+		{name: "hmac", req: signedRequest("ops-secret", http.MethodPost, "/deployments", body, time.Now()), expected: "ops"},
+		{name: "wrong secret", req: signedRequest("wrong", http.MethodPost, "/deployments", body, time.Now())},
+		{name: "expired", req: signedRequest("ops-secret", http.MethodPost, "/deployments", body, time.Now().Add(-10*time.Minute))},
+		{name: "tampered body", req: tampered},
+	}
+
+	for _, td := range testData {
+		client, err := testAuthConfig.Authenticate(td.req)
+		if td.expected == "" {
+			if err == nil {
+				t.Errorf("%s: request should not be authenticated", td.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %v", td.name, err)
+			continue
+		}
+
+		if client.Name != td.expected {
+			t.Errorf("%s: expected %s, got %s", td.name, td.expected, client.Name)
+		}
+	}
+
+	// body is restored for handlers after the signature is verified
+	req := signedRequest("ops-secret", http.MethodPost, "/deployments", body, time.Now())
+	if _, err := testAuthConfig.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(req.Body); !bytes.Equal(b, body) {
+		t.Errorf("body is not restored: %s", b)
+	}
+}
+
+func TestClient_Allows(t *testing.T) {
+	client := Client{Name: "ci", Applications: []string{"hello"}, Stacks: []string{"artd"}, Envs: []string{"dev"}}
+
+	testData := []struct {
+		app     string
+		stack   string
+		env     string
+		allowed bool
+	}{
+		{app: "hello", stack: "artd", env: "dev", allowed: true},
+		{app: "world", stack: "artd", env: "dev"},
+		{app: "hello", stack: "artp", env: "dev"},
+		{app: "hello", stack: "artd", env: "prod"},
+	}
+
+	for _, td := range testData {
+		if err := client.Allows(td.app, td.stack, td.env); (err == nil) != td.allowed {
+			t.Errorf("%s/%s/%s: expected allowed=%t, got %v", td.app, td.stack, td.env, td.allowed, err)
+		}
+	}
+}
+
+func TestServer_Authorization(t *testing.T) {
+	f := newFakeRunner()
+	audit := bytes.Buffer{}
+	s := New()
+	s.Jobs = NewJobManager(f.run)
+	s.Auth = &testAuthConfig
+	s.Audit = NewAuditLog(&audit)
+	s = s.SetRouter()
+
+	// stacks of testBuilder have no env, so ci client cannot access the job
+	job := s.Jobs.Submit(testBuilder("artd"))
+	f.waitStarted(t, "artd")
+	defer s.Jobs.Cancel(job.ID)
+
+	testData := []struct {
+		token    string
+		method   string
+		path     string
+		expected int
+	}{
+		{method: http.MethodGet, path: "/deployments/" + job.ID, expected: http.StatusUnauthorized},
+		{token: "wrong", method: http.MethodGet, path: "/deployments/" + job.ID, expected: http.StatusUnauthorized},
+		{token: "ci-token", method: http.MethodGet, path: "/deployments/" + job.ID, expected: http.StatusForbidden},
+		{token: "ci-token", method: http.MethodDelete, path: "/deployments/" + job.ID, expected: http.StatusForbidden},
+		{token: "ci-token", method: http.MethodGet, path: "/deployments", expected: http.StatusOK},
+	}
+
+	for _, td := range testData {
+		req := httptest.NewRequest(td.method, td.path, nil)
+		if td.token != "" {
+			req.Header.Set("Authorization", "Bearer "+td.token)
+		}
+
+		rec := httptest.NewRecorder()
+		s.Router.ServeHTTP(rec, req)
+		if rec.Code != td.expected {
+			t.Errorf("%s %s: expected %d, got %d", td.method, td.path, td.expected, rec.Code)
+		}
+	}
+
+	// assume role cannot be overridden without permission
+	req := httptest.NewRequest(http.MethodPost, "/deployments", strings.NewReader(`{"config":{"assume_role":"arn:aws:iam::123456789012:role/admin"}}`))
+	req.Header.Set("Authorization", "Bearer ci-token")
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, rec.Code)
+	}
+
+	var entries []AuditEntry
+	decoder := json.NewDecoder(&audit)
+	for decoder.More() {
+		var e AuditEntry
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	if len(entries) != len(testData)+1 {
+		t.Fatalf("expected %d audit entries, got %d", len(testData)+1, len(entries))
+	}
+
+	if e := entries[0]; e.Status != http.StatusUnauthorized || e.Reason == "" || e.Client != "" {
+		t.Errorf("unexpected audit entry of unauthenticated request: %+v", e)
+	}
+
+	if e := entries[2]; e.Status != http.StatusForbidden || e.Client != "ci" || e.JobID != job.ID || e.Application != "hello" {
+		t.Errorf("unexpected audit entry of forbidden request: %+v", e)
+	}
+
+	if e := entries[len(entries)-1]; e.Status != http.StatusForbidden || e.Reason != "client ci is not allowed to override assume role" {
+		t.Errorf("unexpected audit entry of assume role: %+v", e)
+	}
+}
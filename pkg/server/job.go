@@ -0,0 +1,506 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Status of deployment job
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+var (
+	// ErrJobNotFound is returned when there is no job with the id
+	ErrJobNotFound = errors.New("deployment job is not found")
+
+	// ErrJobFinished is returned when a finished job is cancelled
+	ErrJobFinished = errors.New("deployment job is already finished")
+)
+
+// RunFunc runs deployment with builder and reports the state of each stack to observer
+type RunFunc func(ctx context.Context, b builder.Builder, out io.Writer, observer func(schemas.DeploymentState)) error
+
+// Job is a deployment requested to goployer server
+type Job struct {
+	ID          string
+	Application string
+	Region      string
+	Stacks      []schemas.Stack
+	Status      string
+	Err         error
+	CreatedAt   time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+
+	builder builder.Builder
+	states  map[string]schemas.DeploymentState
+	logs    *logBuffer
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// JobStatus is the response of deployment job
+type JobStatus struct {
+	ID          string        `json:"id"`
+	Application string        `json:"application"`
+	Status      string        `json:"status"`
+	Error       string        `json:"error,omitempty"`
+	CreatedAt   string        `json:"created_at"`
+	StartedAt   string        `json:"started_at,omitempty"`
+	FinishedAt  string        `json:"finished_at,omitempty"`
+	Stacks      []StackStatus `json:"stacks"`
+}
+
+// StackStatus is the progress of deployment of a stack
+type StackStatus struct {
+	Stack   string         `json:"stack"`
+	Env     string         `json:"env,omitempty"`
+	Mode    string         `json:"mode"`
+	Regions []RegionStatus `json:"regions"`
+}
+
+// RegionStatus is the progress of deployment of a stack in a region
+type RegionStatus struct {
+	Region           string          `json:"region"`
+	AutoScalingGroup string          `json:"autoscaling_group,omitempty"`
+	Steps            map[string]bool `json:"steps"`
+}
+
+// JobManager queues deployment jobs so that a stack of application is deployed by one job at a time
+type JobManager struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	pending []string
+	running map[string]string
+	run     RunFunc
+
+	// MaxFinishedJobs is how many finished jobs are kept. The oldest ones are removed first.
+	MaxFinishedJobs int
+}
+
+// NewJobManager creates a new job manager
+func NewJobManager(run RunFunc) *JobManager {
+	return &JobManager{
+		jobs:            map[string]*Job{},
+		running:         map[string]string{},
+		run:             run,
+		MaxFinishedJobs: constants.DefaultMaxFinishedJobs,
+	}
+}
+
+// Submit queues a new deployment job and starts it if no other job is deploying the same stacks
+func (m *JobManager) Submit(b builder.Builder) JobStatus {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:          newJobID(),
+		Application: b.AwsConfig.Name,
+		Region:      b.Config.Region,
+		Status:      JobQueued,
+		CreatedAt:   time.Now(),
+		builder:     b,
+		states:      map[string]schemas.DeploymentState{},
+		logs:        newLogBuffer(),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	job.Stacks = selectStacks(b)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[job.ID] = job
+	m.order = append(m.order, job.ID)
+	m.pending = append(m.pending, job.ID)
+	m.schedule()
+
+	return job.status()
+}
+
+// Status returns the status of job
+func (m *JobManager) Status(id string) (JobStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+
+	return job.status(), nil
+}
+
+// List returns the status of all jobs in the order of submission
+func (m *JobManager) List() []JobStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ret := []JobStatus{}
+	for _, id := range m.order {
+		ret = append(ret, m.jobs[id].status())
+	}
+
+	return ret
+}
+
+// Cancel removes a queued job or stops a running job before its next step
+func (m *JobManager) Cancel(id string) (JobStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+
+	switch job.Status {
+	case JobQueued:
+		var remained []string
+		for _, p := range m.pending {
+			if p != id {
+				remained = append(remained, p)
+			}
+		}
+		m.pending = remained
+
+		job.Status = JobCancelled
+		job.FinishedAt = time.Now()
+		job.cancel()
+		job.logs.Close()
+		m.schedule()
+		m.evict()
+	case JobRunning:
+		job.cancel()
+	default:
+		return job.status(), ErrJobFinished
+	}
+
+	return job.status(), nil
+}
+
+// jobLogs returns logs of job
+func (m *JobManager) jobLogs(id string) (*logBuffer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	return job.logs, nil
+}
+
+// schedule starts pending jobs in order unless their stacks are being deployed.
+// Stacks of a job which is still pending are also blocked so that later jobs cannot overtake it.
+func (m *JobManager) schedule() {
+	blocked := map[string]bool{}
+	for key := range m.running {
+		blocked[key] = true
+	}
+
+	var remained []string
+	for _, id := range m.pending {
+		job := m.jobs[id]
+		keys := job.keys()
+
+		available := true
+		for _, key := range keys {
+			if blocked[key] {
+				available = false
+			}
+			blocked[key] = true
+		}
+
+		if !available {
+			remained = append(remained, id)
+			continue
+		}
+
+		for _, key := range keys {
+			m.running[key] = id
+		}
+		job.Status = JobRunning
+		job.StartedAt = time.Now()
+		go m.execute(job)
+	}
+	m.pending = remained
+}
+
+// execute runs job and schedules the next jobs after it is finished
+func (m *JobManager) execute(job *Job) {
+	err := m.runJob(job)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.FinishedAt = time.Now()
+	job.Err = err
+	switch {
+	case err == nil:
+		job.Status = JobSucceeded
+	case job.ctx.Err() != nil:
+		job.Status = JobCancelled
+	default:
+		job.Status = JobFailed
+	}
+	job.cancel()
+	job.logs.Close()
+
+	for _, key := range job.keys() {
+		if m.running[key] == job.ID {
+			delete(m.running, key)
+		}
+	}
+	m.schedule()
+	m.evict()
+}
+
+// runJob runs deployment of job. Panic of deployment fails the job instead of stopping the server.
+func (m *JobManager) runJob(job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("deployment job panicked: %v", r)
+			fmt.Fprintf(job.logs, "%s\n%s", err.Error(), debug.Stack())
+		}
+	}()
+
+	return m.run(job.ctx, job.builder, job.logs, func(st schemas.DeploymentState) {
+		m.observe(job, st)
+	})
+}
+
+// evict removes the oldest finished jobs which exceed MaxFinishedJobs
+func (m *JobManager) evict() {
+	if m.MaxFinishedJobs <= 0 {
+		return
+	}
+
+	var finished []string
+	for _, id := range m.order {
+		if m.jobs[id].isFinished() {
+			finished = append(finished, id)
+		}
+	}
+
+	if len(finished) <= m.MaxFinishedJobs {
+		return
+	}
+
+	removed := map[string]bool{}
+	for _, id := range finished[:len(finished)-m.MaxFinishedJobs] {
+		removed[id] = true
+		delete(m.jobs, id)
+	}
+
+	var order []string
+	for _, id := range m.order {
+		if !removed[id] {
+			order = append(order, id)
+		}
+	}
+	m.order = order
+}
+
+// observe keeps the copy of the latest state of stack
+func (m *JobManager) observe(job *Job, st schemas.DeploymentState) {
+	steps := map[int64]bool{}
+	for k, v := range st.StepStatus {
+		steps[k] = v
+	}
+	st.StepStatus = steps
+
+	asgNames := map[string]string{}
+	for k, v := range st.AsgNames {
+		asgNames[k] = v
+	}
+	st.AsgNames = asgNames
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job.states[st.Stack] = st
+}
+
+// keys returns lock keys of stacks in job
+func (j *Job) keys() []string {
+	var ret []string
+	for _, stack := range j.Stacks {
+		ret = append(ret, fmt.Sprintf("%s/%s", j.Application, stack.Stack))
+	}
+
+	return ret
+}
+
+// isFinished checks if job will not change any more
+func (j *Job) isFinished() bool {
+	return j.Status != JobQueued && j.Status != JobRunning
+}
+
+// status makes response of job
+func (j *Job) status() JobStatus {
+	ret := JobStatus{
+		ID:          j.ID,
+		Application: j.Application,
+		Status:      j.Status,
+		CreatedAt:   formatTime(j.CreatedAt),
+		StartedAt:   formatTime(j.StartedAt),
+		FinishedAt:  formatTime(j.FinishedAt),
+		Stacks:      []StackStatus{},
+	}
+
+	if j.Err != nil {
+		ret.Error = j.Err.Error()
+	}
+
+	for _, stack := range j.Stacks {
+		st := j.states[stack.Stack]
+		ss := StackStatus{
+			Stack:   stack.Stack,
+			Env:     stack.Env,
+			Mode:    stack.ReplacementType,
+			Regions: []RegionStatus{},
+		}
+
+		for _, region := range stack.Regions {
+			if j.Region != "" && j.Region != region.Region {
+				continue
+			}
+
+			rs := RegionStatus{
+				Region:           region.Region,
+				AutoScalingGroup: st.AsgNames[region.Region],
+				Steps:            map[string]bool{},
+			}
+			for step, name := range constants.StepNames {
+				rs.Steps[name] = st.StepStatus[step]
+			}
+			ss.Regions = append(ss.Regions, rs)
+		}
+		ret.Stacks = append(ret.Stacks, ss)
+	}
+
+	sort.Slice(ret.Stacks, func(i, k int) bool {
+		return ret.Stacks[i].Stack < ret.Stacks[k].Stack
+	})
+
+	return ret
+}
+
+// selectStacks returns stacks which are going to be deployed
+func selectStacks(b builder.Builder) []schemas.Stack {
+	var ret []schemas.Stack
+	for _, stack := range b.Stacks {
+		if b.Config.Stack == "" || b.Config.Stack == stack.Stack {
+			ret = append(ret, stack)
+		}
+	}
+
+	return ret
+}
+
+// newJobID creates a random id of job
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// formatTime formats time with RFC3339 or returns empty string for zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+// logBuffer keeps logs of job and notifies readers of new logs
+type logBuffer struct {
+	mu     sync.Mutex
+	data   []byte
+	closed bool
+	notify chan struct{}
+}
+
+// newLogBuffer creates a new log buffer
+func newLogBuffer() *logBuffer {
+	return &logBuffer{notify: make(chan struct{})}
+}
+
+// Write appends logs and wakes up readers
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return len(p), nil
+	}
+
+	l.data = append(l.data, p...)
+	close(l.notify)
+	l.notify = make(chan struct{})
+
+	return len(p), nil
+}
+
+// Close marks the end of logs
+func (l *logBuffer) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.closed {
+		l.closed = true
+		close(l.notify)
+	}
+}
+
+// Since returns logs after offset, a channel closed on the next write and whether logs are finished
+func (l *logBuffer) Since(offset int) ([]byte, <-chan struct{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var data []byte
+	if offset < len(l.data) {
+		data = append(data, l.data[offset:]...)
+	}
+
+	return data, l.notify, l.closed
+}
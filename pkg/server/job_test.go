@@ -0,0 +1,300 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// fakeRunner blocks deployment of each stack until it is released
+type fakeRunner struct {
+	started  chan string
+	released map[string]chan error
+}
+
+func newFakeRunner() fakeRunner {
+	return fakeRunner{
+		started: make(chan string, 10),
+		released: map[string]chan error{
+			"":     make(chan error),
+			"artd": make(chan error),
+			"artp": make(chan error),
+		},
+	}
+}
+
+func (f fakeRunner) run(ctx context.Context, b builder.Builder, out io.Writer, observer func(schemas.DeploymentState)) error {
+	fmt.Fprintf(out, "deploying %s\n", b.Config.Stack)
+	observer(schemas.DeploymentState{
+		Stack:      b.Config.Stack,
+		StepStatus: map[int64]bool{constants.StepCheckPrevious: true},
+		AsgNames:   map[string]string{"ap-northeast-2": "hello-artd_apnortheast2-v001"},
+	})
+	f.started <- b.Config.Stack
+
+	select {
+	case err := <-f.released[b.Config.Stack]:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f fakeRunner) waitStarted(t *testing.T, expected string) {
+	t.Helper()
+	select {
+	case stack := <-f.started:
+		if stack != expected {
+			t.Fatalf("expected %s to start, got %s", expected, stack)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("%s is not started", expected)
+	}
+}
+
+func testBuilder(stack string) builder.Builder {
+	return builder.Builder{
+		Config:    schemas.Config{Stack: stack},
+		AwsConfig: schemas.AWSConfig{Name: "hello"},
+		Stacks: []schemas.Stack{
+			{Stack: "artd", ReplacementType: constants.BlueGreenDeployment, Regions: []schemas.RegionConfig{{Region: "ap-northeast-2"}}},
+			{Stack: "artp", ReplacementType: constants.CanaryDeployment, Regions: []schemas.RegionConfig{{Region: "ap-northeast-2"}}},
+		},
+	}
+}
+
+func waitStatus(t *testing.T, m *JobManager, id, expected string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		st, err := m.Status(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Status == expected {
+			return st
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("status of %s is not %s", id, expected)
+	return JobStatus{}
+}
+
+func TestJobManager_Queue(t *testing.T) {
+	f := newFakeRunner()
+	m := NewJobManager(f.run)
+
+	first := m.Submit(testBuilder("artd"))
+	f.waitStarted(t, "artd")
+
+	second := m.Submit(testBuilder("artd"))
+	other := m.Submit(testBuilder("artp"))
+	f.waitStarted(t, "artp")
+
+	if st, _ := m.Status(second.ID); st.Status != JobQueued {
+		t.Errorf("second job of the same stack should be queued, got %s", st.Status)
+	}
+
+	st := waitStatus(t, m, first.ID, JobRunning)
+	if len(st.Stacks) != 1 || len(st.Stacks[0].Regions) != 1 {
+		t.Fatalf("unexpected stacks: %+v", st.Stacks)
+	}
+	region := st.Stacks[0].Regions[0]
+	if !region.Steps["check_previous"] || region.Steps["deploy"] {
+		t.Errorf("unexpected steps: %+v", region.Steps)
+	}
+	if region.AutoScalingGroup != "hello-artd_apnortheast2-v001" {
+		t.Errorf("unexpected autoscaling group: %s", region.AutoScalingGroup)
+	}
+
+	f.released["artd"] <- nil
+	f.waitStarted(t, "artd")
+	waitStatus(t, m, second.ID, JobRunning)
+
+	st = waitStatus(t, m, first.ID, JobSucceeded)
+	if st.FinishedAt == "" {
+		t.Error("finished time of succeeded job should be set")
+	}
+
+	f.released["artd"] <- fmt.Errorf("health check failed")
+	f.released["artp"] <- nil
+	if st := waitStatus(t, m, other.ID, JobSucceeded); st.Error != "" {
+		t.Errorf("unexpected error: %s", st.Error)
+	}
+	if st := waitStatus(t, m, second.ID, JobFailed); st.Error != "health check failed" {
+		t.Errorf("unexpected error: %s", st.Error)
+	}
+
+	if jobs := m.List(); len(jobs) != 3 || jobs[0].ID != first.ID {
+		t.Errorf("unexpected job list: %+v", jobs)
+	}
+}
+
+func TestJobManager_Cancel(t *testing.T) {
+	f := newFakeRunner()
+	m := NewJobManager(f.run)
+
+	running := m.Submit(testBuilder(""))
+	f.waitStarted(t, "")
+	queued := m.Submit(testBuilder("artd"))
+
+	if st, err := m.Cancel(queued.ID); err != nil || st.Status != JobCancelled {
+		t.Errorf("queued job should be cancelled at once: %+v, %v", st, err)
+	}
+
+	if _, err := m.Cancel(running.ID); err != nil {
+		t.Error(err)
+	}
+	waitStatus(t, m, running.ID, JobCancelled)
+
+	if _, err := m.Cancel(running.ID); err != ErrJobFinished {
+		t.Errorf("expected %v, got %v", ErrJobFinished, err)
+	}
+
+	if _, err := m.Cancel("unknown"); err != ErrJobNotFound {
+		t.Errorf("expected %v, got %v", ErrJobNotFound, err)
+	}
+}
+
+func TestJobManager_Panic(t *testing.T) {
+	f := newFakeRunner()
+	m := NewJobManager(func(ctx context.Context, b builder.Builder, out io.Writer, observer func(schemas.DeploymentState)) error {
+		if b.Config.Stack == "artp" {
+			panic("nil pointer")
+		}
+		return f.run(ctx, b, out, observer)
+	})
+
+	running := m.Submit(testBuilder("artd"))
+	f.waitStarted(t, "artd")
+
+	panicked := m.Submit(testBuilder("artp"))
+	if st := waitStatus(t, m, panicked.ID, JobFailed); st.Error != "deployment job panicked: nil pointer" {
+		t.Errorf("unexpected error: %s", st.Error)
+	}
+
+	f.released["artd"] <- nil
+	waitStatus(t, m, running.ID, JobSucceeded)
+}
+
+func TestJobManager_Evict(t *testing.T) {
+	f := newFakeRunner()
+	m := NewJobManager(f.run)
+	m.MaxFinishedJobs = 2
+
+	var finished []JobStatus
+	for i := 0; i < 3; i++ {
+		job := m.Submit(testBuilder("artd"))
+		f.waitStarted(t, "artd")
+		f.released["artd"] <- nil
+		finished = append(finished, waitStatus(t, m, job.ID, JobSucceeded))
+	}
+
+	running := m.Submit(testBuilder("artd"))
+	f.waitStarted(t, "artd")
+	defer m.Cancel(running.ID)
+
+	if _, err := m.Status(finished[0].ID); err != ErrJobNotFound {
+		t.Errorf("the oldest finished job should be removed: %v", err)
+	}
+
+	if jobs := m.List(); len(jobs) != 3 || jobs[0].ID != finished[1].ID || jobs[2].ID != running.ID {
+		t.Errorf("unexpected job list: %+v", jobs)
+	}
+}
+
+func TestServer_Deployment(t *testing.T) {
+	f := newFakeRunner()
+	s := New()
+	s.Jobs = NewJobManager(f.run)
+	s.Audit = NewAuditLog(ioutil.Discard)
+	s = s.SetRouter()
+
+	job := s.Jobs.Submit(testBuilder("artd"))
+	f.waitStarted(t, "artd")
+
+	testData := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{method: http.MethodGet, path: "/deployments", expected: http.StatusOK},
+		{method: http.MethodGet, path: "/deployments/" + job.ID, expected: http.StatusOK},
+		{method: http.MethodGet, path: "/deployments/unknown", expected: http.StatusNotFound},
+		{method: http.MethodPut, path: "/deployments", expected: http.StatusMethodNotAllowed},
+		{method: http.MethodGet, path: "/deploy", expected: http.StatusMethodNotAllowed},
+		{method: http.MethodDelete, path: "/deployments/unknown", expected: http.StatusNotFound},
+	}
+
+	for _, td := range testData {
+		rec := httptest.NewRecorder()
+		s.Router.ServeHTTP(rec, httptest.NewRequest(td.method, td.path, nil))
+		if rec.Code != td.expected {
+			t.Errorf("%s %s: expected %d, got %d", td.method, td.path, td.expected, rec.Code)
+		}
+	}
+
+	// files of the server host and the lock cannot be controlled by request
+	for _, body := range []string{
+		`{"config":{"state_dir":"/etc"}}`,
+		`{"config":{"events_file":"/root/.ssh/authorized_keys"}}`,
+		`{"config":{"lock_backend":"none"}}`,
+	} {
+		rec := httptest.NewRecorder()
+		s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/deployments", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/deployments/"+job.ID, nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("expected %d, got %d", http.StatusAccepted, rec.Code)
+	}
+
+	// logs are streamed until the job is finished
+	rec = httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/deployments/"+job.ID+"/logs", nil))
+	if rec.Body.String() != "deploying artd\n" {
+		t.Errorf("unexpected logs: %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/deployments/"+job.ID, nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected %d, got %d", http.StatusConflict, rec.Code)
+	}
+
+	var body ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error != ErrJobFinished.Error() {
+		t.Errorf("unexpected error response: %+v, %v", body, err)
+	}
+}
@@ -17,10 +17,16 @@ limitations under the license.
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	Logger "github.com/sirupsen/logrus"
 
@@ -31,43 +37,99 @@ import (
 )
 
 var (
-	defaultLogLevel   = Logger.InfoLevel
-	defaultServerAddr = "localhost"
-	defaultServerPort = int64(9037)
+	defaultLogLevel        = Logger.InfoLevel
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 type Server struct {
 	ServerConfig Config
 	Router       *http.ServeMux
 	Logger       *Logger.Logger
+	Jobs         *JobManager
+	Auth         *AuthConfig
+	Audit        *AuditLog
 }
 
 type Config struct {
 	Addr string
 	Port int64
+
+	// TLS certificate and key files. Server listens with HTTPS if they are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	// Path of auth configuration. Authentication is disabled if it is empty
+	AuthConfig string
+
+	// Path of audit log file. Audit log is written to stderr if it is empty
+	AuditLog string
+
+	// AllowLocalHooks allows lifecycle hooks which run commands in the server host
+	AllowLocalHooks bool
 }
 
 type RequestBody struct {
 	Config schemas.Config `json:"config"`
 }
 
+// ErrorResponse is the response body of failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
 func New() Server {
 	return Server{
 		Router: http.NewServeMux(),
 		Logger: Logger.New(),
+		Jobs:   NewJobManager(runDeployment),
+		Audit:  NewAuditLog(os.Stderr),
 		ServerConfig: Config{
-			Addr: defaultServerAddr,
-			Port: defaultServerPort,
+			Addr: constants.DefaultServerAddr,
+			Port: constants.DefaultServerPort,
 		},
 	}
 }
 
 func (s Server) SetRouter() Server {
 	s.Router.HandleFunc("/health", s.Healthcheck)
-	s.Router.HandleFunc("/deploy", s.TriggerDeploy)
+	s.Router.HandleFunc("/deploy", s.secure(s.TriggerDeploy))
+	s.Router.HandleFunc("/deployments", s.secure(s.Deployments))
+	s.Router.HandleFunc("/deployments/", s.secure(s.Deployment))
 	return s
 }
 
+// SetSecurity loads auth configuration and opens audit log
+func (s Server) SetSecurity() (Server, error) {
+	if (len(s.ServerConfig.TLSCertFile) == 0) != (len(s.ServerConfig.TLSKeyFile) == 0) {
+		return s, errors.New("tls-cert-file and tls-key-file should be used together")
+	}
+
+	if len(s.ServerConfig.AuthConfig) > 0 {
+		auth, err := LoadAuthConfig(s.ServerConfig.AuthConfig)
+		if err != nil {
+			return s, err
+		}
+		s.Auth = &auth
+		s.Logger.Infof("Authentication is enabled with %d clients", len(auth.Clients))
+
+		if len(s.ServerConfig.TLSCertFile) == 0 {
+			s.Logger.Warnf("credentials are sent in plain text without TLS, use --tls-cert-file and --tls-key-file")
+		}
+	} else {
+		s.Logger.Warnf("authentication is disabled and anyone who can reach the server can deploy, use --auth-config")
+	}
+
+	if len(s.ServerConfig.AuditLog) > 0 {
+		f, err := os.OpenFile(s.ServerConfig.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return s, err
+		}
+		s.Audit = NewAuditLog(f)
+	}
+
+	return s, nil
+}
+
 func (s Server) SetDefaultSetting() Server {
 	s.Logger.Infof("Setup Default Settings")
 
@@ -77,33 +139,388 @@ func (s Server) SetDefaultSetting() Server {
 	return s
 }
 
+// Run serves requests until context is cancelled
+func (s Server) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    s.GetAddr(),
+		Handler: s.Router,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if len(s.ServerConfig.TLSCertFile) > 0 {
+			srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+			s.Logger.Infof("Start goployer server with TLS: %s", srv.Addr)
+			errs <- srv.ListenAndServeTLS(s.ServerConfig.TLSCertFile, s.ServerConfig.TLSKeyFile)
+			return
+		}
+
+		s.Logger.Infof("Start goployer server: %s", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	s.Logger.Infof("Shutting down goployer server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+
+	return srv.Shutdown(shutdownCtx)
+}
+
 func (s Server) Healthcheck(w http.ResponseWriter, req *http.Request) {
 	s.Logger.Infof("%s %s healthy", req.RemoteAddr, req.Method)
 }
 
+// secure authenticates request and writes audit log after it is handled
+func (s Server) secure(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		entry := &AuditEntry{
+			Time:       time.Now().Format(time.RFC3339),
+			RemoteAddr: req.RemoteAddr,
+			Method:     req.Method,
+			Path:       req.URL.Path,
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		req = withAudit(req, entry)
+
+		defer func() {
+			entry.Status = sw.status
+			if err := s.Audit.Write(*entry); err != nil {
+				s.Logger.Errorf("failed to write audit log: %s", err.Error())
+			}
+		}()
+
+		if s.Auth != nil {
+			client, err := s.Auth.Authenticate(req)
+			if err != nil {
+				sw.Header().Set("WWW-Authenticate", `Bearer realm="goployer"`)
+				writeError(sw, req, http.StatusUnauthorized, err)
+				return
+			}
+			entry.Client = client.Name
+			req = withClient(req, client)
+		}
+
+		next(sw, req)
+	}
+}
+
+// Deployments handles requests of deployment jobs
+func (s Server) Deployments(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		s.TriggerDeploy(w, req)
+	case http.MethodGet:
+		jobs := []JobStatus{}
+		for _, job := range s.Jobs.List() {
+			if authorizeJob(clientOf(req), job) == nil {
+				jobs = append(jobs, job)
+			}
+		}
+		writeJSON(w, http.StatusOK, jobs)
+	default:
+		writeError(w, req, http.StatusMethodNotAllowed, fmt.Errorf("method is not allowed: %s", req.Method))
+	}
+}
+
+// Deployment handles requests of a deployment job
+func (s Server) Deployment(w http.ResponseWriter, req *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/deployments/"), "/"), "/")
+	id := path[0]
+
+	isStatus := len(path) == 1 && req.Method == http.MethodGet
+	isCancel := len(path) == 1 && req.Method == http.MethodDelete
+	isLogs := len(path) == 2 && path[1] == "logs" && req.Method == http.MethodGet
+	if !isStatus && !isCancel && !isLogs {
+		writeError(w, req, http.StatusNotFound, fmt.Errorf("no route for %s %s", req.Method, req.URL.Path))
+		return
+	}
+
+	st, err := s.Jobs.Status(id)
+	if err != nil {
+		writeError(w, req, statusCode(err), err)
+		return
+	}
+	auditJob(req, st)
+
+	if err := authorizeJob(clientOf(req), st); err != nil {
+		writeError(w, req, http.StatusForbidden, err)
+		return
+	}
+
+	switch {
+	case isStatus:
+		writeJSON(w, http.StatusOK, st)
+	case isCancel:
+		st, err := s.Jobs.Cancel(id)
+		if err != nil {
+			writeError(w, req, statusCode(err), err)
+			return
+		}
+		s.Logger.Infof("cancel deployment job: %s", id)
+		writeJSON(w, http.StatusAccepted, st)
+	case isLogs:
+		s.StreamLogs(w, req, id)
+	}
+}
+
+// TriggerDeploy queues a deployment job and returns it without waiting
 func (s Server) TriggerDeploy(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(w, req, http.StatusMethodNotAllowed, fmt.Errorf("method is not allowed: %s", req.Method))
+		return
+	}
+
 	body, err := parameterParsing(req.Body)
 	if err != nil {
-		s.Logger.Errorf(err.Error())
+		writeError(w, req, http.StatusBadRequest, err)
+		return
+	}
+
+	client := clientOf(req)
+	if client != nil && len(body.Config.AssumeRole) > 0 && !client.AllowAssumeRole {
+		writeError(w, req, http.StatusForbidden, fmt.Errorf("client %s is not allowed to override assume role", client.Name))
+		return
+	}
+
+	// These point to files of the server host or turn off the lock, so they are only set by the server itself
+	if err := checkServerOnlyConfig(body.Config); err != nil {
+		writeError(w, req, http.StatusBadRequest, err)
+		return
+	}
+
+	body.Config, err = builder.RefineConfig(body.Config)
+	if err != nil {
+		writeError(w, req, http.StatusBadRequest, err)
 		return
 	}
 
+	// There is nobody to answer the confirmation in server mode
+	body.Config.AutoApply = true
+
 	builder, err := runner.ServerSetup(body.Config)
 	if err != nil {
-		s.Logger.Errorf(err.Error())
+		writeError(w, req, http.StatusBadRequest, err)
 		return
 	}
 
-	if err := runner.Start(builder, "server"); err != nil {
-		s.Logger.Errorf(err.Error())
+	if err := builder.CheckValidation(); err != nil {
+		writeError(w, req, http.StatusBadRequest, err)
 		return
 	}
+
+	entry := auditOf(req)
+	stacks := selectStacks(builder)
+	if entry != nil {
+		entry.Application = builder.AwsConfig.Name
+		for _, stack := range stacks {
+			entry.Stacks = append(entry.Stacks, stack.Stack)
+		}
+	}
+
+	if client != nil {
+		for _, stack := range stacks {
+			if err := client.Allows(builder.AwsConfig.Name, stack.Stack, stack.Env); err != nil {
+				writeError(w, req, http.StatusForbidden, err)
+				return
+			}
+		}
+
+		// roles of stacks and accounts in manifest are also assumed by the server
+		if err := checkAssumeRole(client, stacks); err != nil {
+			writeError(w, req, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if !s.ServerConfig.AllowLocalHooks {
+		if err := checkLocalHooks(stacks); err != nil {
+			writeError(w, req, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	job := s.Jobs.Submit(builder)
+	s.Logger.Infof("deployment job is submitted: %s, application=%s", job.ID, job.Application)
+	if entry != nil {
+		entry.JobID = job.ID
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/deployments/%s", job.ID))
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// StreamLogs writes logs of job until the job is finished or client is disconnected
+func (s Server) StreamLogs(w http.ResponseWriter, req *http.Request, id string) {
+	logs, err := s.Jobs.jobLogs(id)
+	if err != nil {
+		writeError(w, req, statusCode(err), err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	flusher, _ := w.(http.Flusher)
+
+	offset := 0
+	for {
+		data, notify, closed := logs.Since(offset)
+		if len(data) > 0 {
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			offset += len(data)
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if closed {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-req.Context().Done():
+			return
+		}
+	}
 }
 
 func (s Server) GetAddr() string {
 	return fmt.Sprintf("%s:%d", s.ServerConfig.Addr, s.ServerConfig.Port)
 }
 
+// runDeployment runs deployment of job with runner
+func runDeployment(ctx context.Context, b builder.Builder, out io.Writer, observer func(schemas.DeploymentState)) error {
+	return runner.StartWith(b, "deploy", func(r *runner.Runner) {
+		r.Context = ctx
+		r.Out = io.MultiWriter(os.Stdout, out)
+		r.Observer = observer
+	})
+}
+
+// authorizeJob checks whether or not client can access all stacks of job. nil client means that authentication is disabled
+func authorizeJob(client *Client, job JobStatus) error {
+	if client == nil {
+		return nil
+	}
+
+	if !isAllowed(client.Applications, job.Application) {
+		return fmt.Errorf("client %s is not allowed to access application: %s", client.Name, job.Application)
+	}
+
+	for _, stack := range job.Stacks {
+		if err := client.Allows(job.Application, stack.Stack, stack.Env); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// auditJob records job of request to audit entry
+func auditJob(req *http.Request, job JobStatus) {
+	entry := auditOf(req)
+	if entry == nil {
+		return
+	}
+
+	entry.JobID = job.ID
+	entry.Application = job.Application
+	for _, stack := range job.Stacks {
+		entry.Stacks = append(entry.Stacks, stack.Stack)
+	}
+}
+
+// statusCode returns http status code of job error
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrJobFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes body as json with status code
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes error as json with status code and keeps the reason in audit entry
+func writeError(w http.ResponseWriter, req *http.Request, code int, err error) {
+	if entry := auditOf(req); entry != nil {
+		entry.Reason = err.Error()
+	}
+	writeJSON(w, code, ErrorResponse{Error: err.Error()})
+}
+
+// checkServerOnlyConfig returns error if request sets configuration which is not allowed in server mode
+func checkServerOnlyConfig(config schemas.Config) error {
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{name: "state_dir", value: config.StateDir},
+		{name: "events_file", value: config.EventsFile},
+		{name: "lock_backend", value: config.LockBackend},
+	}
+
+	for _, f := range fields {
+		if len(f.value) > 0 {
+			return fmt.Errorf("%s cannot be set by deployment request", f.name)
+		}
+	}
+
+	return nil
+}
+
+// checkAssumeRole returns error if client is not allowed to assume role of any stack
+func checkAssumeRole(client *Client, stacks []schemas.Stack) error {
+	if client.AllowAssumeRole {
+		return nil
+	}
+
+	for _, stack := range stacks {
+		if len(stack.AssumeRole) > 0 {
+			return fmt.Errorf("client %s is not allowed to assume role of stack: %s", client.Name, stack.Stack)
+		}
+	}
+
+	return nil
+}
+
+// checkLocalHooks returns error if any stack has lifecycle hook which runs commands in the server host
+func checkLocalHooks(stacks []schemas.Stack) error {
+	for _, stack := range stacks {
+		lc := stack.LifecycleCallbacks
+		if lc == nil {
+			continue
+		}
+
+		for _, hooks := range [][]schemas.LifecycleHook{lc.PreDeploy, lc.PostDeploy, lc.PostHealthy, lc.PreCleanup, lc.PostCleanup, lc.OnFailure} {
+			for _, hook := range hooks {
+				if hook.Type == constants.LocalHook {
+					return fmt.Errorf("local lifecycle hook is not allowed in server: %s/%s", stack.Stack, hook.Name)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // parameterParsing returns RequestBody
 func parameterParsing(body io.Reader) (RequestBody, error) {
 	decoder := json.NewDecoder(body)
@@ -122,10 +539,5 @@ func parameterParsing(body io.Reader) (RequestBody, error) {
 		r.Config.PollingInterval = constants.DefaultPollingInterval
 	}
 
-	r.Config, err = builder.RefineConfig(r.Config)
-	if err != nil {
-		return r, err
-	}
-
 	return r, nil
 }
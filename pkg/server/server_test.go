@@ -0,0 +1,113 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package server
+
+import (
+	"testing"
+
+	"github.com/DevopsArtFactory/goployer/pkg/builder"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestCheckLocalHooks(t *testing.T) {
+	testData := []struct {
+		stacks  []schemas.Stack
+		wantErr bool
+	}{
+		{
+			stacks: []schemas.Stack{{Stack: "dev"}},
+		},
+		{
+			stacks: []schemas.Stack{
+				{Stack: "dev", LifecycleCallbacks: &schemas.LifecycleCallbacks{
+					PostDeploy: []schemas.LifecycleHook{{Name: "notify", Type: constants.HTTPHook}},
+				}},
+			},
+		},
+		{
+			stacks: []schemas.Stack{
+				{Stack: "dev"},
+				{Stack: "prod", LifecycleCallbacks: &schemas.LifecycleCallbacks{
+					OnFailure: []schemas.LifecycleHook{{Name: "cleanup", Type: constants.LocalHook, Commands: []string{"echo"}}},
+				}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, td := range testData {
+		err := checkLocalHooks(td.stacks)
+		if (err != nil) != td.wantErr {
+			t.Errorf("checkLocalHooks() error = %v, wantErr %v", err, td.wantErr)
+		}
+	}
+}
+
+func TestCheckAssumeRole(t *testing.T) {
+	awsConfig := schemas.AWSConfig{
+		Name: "hello",
+		Accounts: map[string]schemas.Account{
+			"prod": {AssumeRole: "arn:aws:iam::123456789012:role/deploy"},
+		},
+	}
+
+	testData := []struct {
+		name    string
+		client  Client
+		stacks  []schemas.Stack
+		stack   string
+		wantErr bool
+	}{
+		{
+			name:   "no role",
+			client: Client{Name: "ci"},
+			stacks: []schemas.Stack{{Stack: "dev"}},
+		},
+		{
+			name:    "role of stack",
+			client:  Client{Name: "ci"},
+			stacks:  []schemas.Stack{{Stack: "dev"}, {Stack: "qa", AssumeRole: "arn:aws:iam::123456789012:role/qa"}},
+			wantErr: true,
+		},
+		{
+			name:    "role of account",
+			client:  Client{Name: "ci"},
+			stacks:  []schemas.Stack{{Stack: "prod", Account: "prod"}},
+			wantErr: true,
+		},
+		{
+			name:   "stack without role is selected",
+			client: Client{Name: "ci"},
+			stacks: []schemas.Stack{{Stack: "dev"}, {Stack: "prod", Account: "prod"}},
+			stack:  "dev",
+		},
+		{
+			name:   "client allowed to assume role",
+			client: Client{Name: "ops", AllowAssumeRole: true},
+			stacks: []schemas.Stack{{Stack: "prod", Account: "prod"}},
+		},
+	}
+
+	for _, td := range testData {
+		b := builder.Builder{Config: schemas.Config{Stack: td.stack}, AwsConfig: awsConfig}.SetStacks(td.stacks)
+		err := checkAssumeRole(&td.client, selectStacks(b))
+		if (err != nil) != td.wantErr {
+			t.Errorf("%s: checkAssumeRole() error = %v, wantErr %v", td.name, err, td.wantErr)
+		}
+	}
+}
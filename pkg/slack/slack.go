@@ -43,6 +43,9 @@ type Slack struct {
 	WebhookURL string
 	SlackOff   bool
 	Color      string
+
+	// ThreadTS is the timestamp of parent message. Messages are sent to its thread if it is set
+	ThreadTS string
 }
 
 // NewSlackClient creates new slack client
@@ -121,7 +124,28 @@ func (s Slack) SendMessageWithWebHook(msg string) error {
 
 // SendMessage really sends message with token
 func (s Slack) SendMessage(msgOpt ...slack.MsgOption) error {
+	_, err := s.PostMessage(msgOpt...)
+	return err
+}
+
+// PostMessage sends message with token and returns timestamp of the message
+func (s Slack) PostMessage(msgOpt ...slack.MsgOption) (string, error) {
+	if len(s.ThreadTS) > 0 {
+		msgOpt = append(msgOpt, slack.MsgOptionTS(s.ThreadTS))
+	}
+
 	channel, timestamp, text, err := s.Client.SendMessage(s.ChannelID, msgOpt...)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Debugf("channel: %s, timestamp: %s, text: %s", channel, timestamp, text)
+	return timestamp, nil
+}
+
+// UpdateMessage replaces the message of timestamp
+func (s Slack) UpdateMessage(timestamp string, msgOpt ...slack.MsgOption) error {
+	channel, _, text, err := s.Client.UpdateMessage(s.ChannelID, timestamp, msgOpt...)
 	if err != nil {
 		return err
 	}
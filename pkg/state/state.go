@@ -0,0 +1,111 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Store keeps deployment states as files in a local directory
+type Store struct {
+	Dir string
+}
+
+// NewStore creates a new state store. Default directory is used if dir is empty.
+func NewStore(dir string) (Store, error) {
+	if len(dir) == 0 {
+		home, err := homedir.Dir()
+		if err != nil {
+			return Store{}, err
+		}
+		dir = filepath.Join(home, constants.DefaultStateDirectory)
+	}
+
+	return Store{Dir: dir}, nil
+}
+
+// Save writes deployment state to the file
+func (s Store) Save(state schemas.DeploymentState) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file first not to leave broken state when process is killed
+	path := s.path(state.Application, state.Stack, state.Region)
+	tmp := fmt.Sprintf("%s.tmp", path)
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Load reads deployment state from the file. It returns nil if no state exists.
+func (s Store) Load(app, stack, region string) (*schemas.DeploymentState, error) {
+	b, err := ioutil.ReadFile(s.path(app, stack, region))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state schemas.DeploymentState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+// Delete removes deployment state file
+func (s Store) Delete(app, stack, region string) error {
+	if err := os.Remove(s.path(app, stack, region)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns file path of deployment state
+func (s Store) path(app, stack, region string) string {
+	return filepath.Join(s.Dir, FileName(app, stack, region))
+}
+
+// FileName returns file name of deployment state keyed by application, stack and region
+func FileName(app, stack, region string) string {
+	if len(region) == 0 {
+		region = "all"
+	}
+
+	return fmt.Sprintf("%s.json", strings.Join([]string{app, stack, region}, "_"))
+}
@@ -0,0 +1,88 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package state
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func TestFileName(t *testing.T) {
+	testData := []struct {
+		app, stack, region string
+		expected           string
+	}{
+		{app: "hello", stack: "artd", region: "ap-northeast-2", expected: "hello_artd_ap-northeast-2.json"},
+		{app: "hello", stack: "artd", region: "", expected: "hello_artd_all.json"},
+	}
+
+	for _, td := range testData {
+		if out := FileName(td.app, td.stack, td.region); out != td.expected {
+			t.Errorf("expected: %s, got: %s", td.expected, out)
+		}
+	}
+}
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := schemas.DeploymentState{
+		Application: "hello",
+		Stack:       "artd",
+		Region:      constants.DefaultRegion,
+		Mode:        constants.BlueGreenDeployment,
+		StepStatus: map[int64]bool{
+			constants.StepCheckPrevious: true,
+			constants.StepDeploy:        false,
+		},
+		AsgNames:        map[string]string{constants.DefaultRegion: "hello-artd_useast1-v001"},
+		PrevAsgs:        map[string][]string{constants.DefaultRegion: {"hello-artd_useast1-v000"}},
+		AppliedCapacity: &schemas.Capacity{Min: 1, Max: 2, Desired: 1},
+	}
+
+	if loaded, err := store.Load(state.Application, state.Stack, state.Region); err != nil || loaded != nil {
+		t.Fatalf("expected no state before saving, got %v, %v", loaded, err)
+	}
+
+	if err := store.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load(state.Application, state.Stack, state.Region)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(*loaded, state); diff != nil {
+		t.Errorf("loaded state is different: %v", diff)
+	}
+
+	if err := store.Delete(state.Application, state.Stack, state.Region); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := store.Load(state.Application, state.Stack, state.Region); err != nil || loaded != nil {
+		t.Errorf("expected state to be deleted, got %v, %v", loaded, err)
+	}
+}
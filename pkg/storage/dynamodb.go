@@ -0,0 +1,138 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package storage
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// DynamoDB keeps records in dynamodb table
+type DynamoDB struct {
+	Client      aws.DynamoDBClient
+	Table       string
+	BillingMode string
+}
+
+// Prepare creates table if it does not exist
+func (d DynamoDB) Prepare(logger *Logger.Logger) error {
+	isExist, err := d.Client.CheckTableExists(d.Table)
+	if err != nil {
+		return err
+	}
+
+	if isExist {
+		logger.Infof("you already had a table : %s", d.Table)
+		return nil
+	}
+
+	logger.Infof("you don't have a table : %s", d.Table)
+	if err := d.Client.CreateTable(d.Table, d.BillingMode); err != nil {
+		return err
+	}
+	logger.Infof("new table is created : %s", d.Table)
+
+	return nil
+}
+
+// Put replaces the record of autoscaling group
+func (d DynamoDB) Put(asg string, record Record) error {
+	item, err := dynamodbattribute.MarshalMap(record)
+	if err != nil {
+		return err
+	}
+	item[constants.HashKey] = &dynamodb.AttributeValue{S: &asg}
+
+	return d.Client.PutItem(d.Table, item)
+}
+
+// Update sets fields to the record of autoscaling group
+func (d DynamoDB) Update(asg string, fields Record) error {
+	item, err := dynamodbattribute.MarshalMap(fields)
+	if err != nil {
+		return err
+	}
+
+	return d.Client.UpdateItem(d.Table, asg, item)
+}
+
+// Get returns the record of autoscaling group
+func (d DynamoDB) Get(asg string) (Record, error) {
+	item, err := d.Client.GetSingleItem(asg, d.Table)
+	if err != nil || len(item) == 0 {
+		return nil, err
+	}
+
+	return unmarshal(item)
+}
+
+// List returns records of application with the index of application and stack.
+// Tables created by old versions do not have the index, so records are scanned from them.
+func (d DynamoDB) List(app, stack string) ([]Record, error) {
+	conditions := map[string]string{constants.ApplicationKey: app}
+	if len(stack) > 0 {
+		conditions[constants.StackNameKey] = stack
+	}
+
+	items, err := d.Client.QueryIndex(d.Table, constants.ApplicationIndex, conditions)
+	if isIndexNotFound(err) {
+		items, err = d.Client.ScanItems(d.Table)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Record
+	for _, item := range items {
+		r, err := unmarshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		// scanned records include other applications and old records without application
+		if r.matches(app, stack) {
+			ret = append(ret, r)
+		}
+	}
+
+	return ret, nil
+}
+
+// unmarshal makes record from dynamodb item
+func unmarshal(item map[string]*dynamodb.AttributeValue) (Record, error) {
+	r := Record{}
+	if err := dynamodbattribute.UnmarshalMap(item, &r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// isIndexNotFound checks if error is caused by the index which does not exist.
+// Other validation errors like wrong key conditions are not hidden by scanning the table.
+func isIndexNotFound(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == "ValidationException" && strings.Contains(aerr.Message(), "does not have the specified index")
+}
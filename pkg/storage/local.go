@@ -0,0 +1,191 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	Logger "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+const (
+	// recordBucket is the bucket of database where records are kept
+	recordBucket = "deployments"
+
+	// openTimeout is how long to wait for other process which opens the database
+	openTimeout = 10 * time.Second
+)
+
+// Local keeps records in an embedded database file for laptops and CI
+type Local struct {
+	Path string
+}
+
+// NewLocal creates local storage. Default path is used if path is empty.
+func NewLocal(path string) (Local, error) {
+	if len(path) == 0 {
+		path = filepath.Join("~", constants.DefaultLocalStoragePath)
+	}
+
+	if strings.HasPrefix(path, "~") {
+		expanded, err := homedir.Expand(path)
+		if err != nil {
+			return Local{}, err
+		}
+		path = expanded
+	}
+
+	return Local{Path: path}, nil
+}
+
+// Prepare creates database file if it does not exist
+func (l Local) Prepare(logger *Logger.Logger) error {
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+		return err
+	}
+
+	err := l.update(func(b *bolt.Bucket) error {
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	logger.Infof("deployment records are kept in : %s", l.Path)
+
+	return nil
+}
+
+// Put replaces the record of autoscaling group
+func (l Local) Put(asg string, record Record) error {
+	r := Record{}
+	r.merge(record)
+	r[constants.HashKey] = asg
+
+	return l.update(func(b *bolt.Bucket) error {
+		return put(b, asg, r)
+	})
+}
+
+// Update sets fields to the record of autoscaling group
+func (l Local) Update(asg string, fields Record) error {
+	return l.update(func(b *bolt.Bucket) error {
+		r := Record{constants.HashKey: asg}
+		if v := b.Get([]byte(asg)); v != nil {
+			current, err := decode(v)
+			if err != nil {
+				return err
+			}
+			r = current
+		}
+		r.merge(fields)
+
+		return put(b, asg, r)
+	})
+}
+
+// Get returns the record of autoscaling group
+func (l Local) Get(asg string) (Record, error) {
+	var ret Record
+	err := l.view(func(b *bolt.Bucket) error {
+		v := b.Get([]byte(asg))
+		if v == nil {
+			return nil
+		}
+
+		r, err := decode(v)
+		ret = r
+		return err
+	})
+
+	return ret, err
+}
+
+// List returns records of application
+func (l Local) List(app, stack string) ([]Record, error) {
+	var ret []Record
+	err := l.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			r, err := decode(v)
+			if err != nil {
+				return err
+			}
+
+			if r.matches(app, stack) {
+				ret = append(ret, r)
+			}
+			return nil
+		})
+	})
+
+	return ret, err
+}
+
+// update runs function in read-write transaction
+func (l Local) update(fn func(b *bolt.Bucket) error) error {
+	db, err := bolt.Open(l.Path, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(recordBucket))
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// view runs function in read-only transaction. Nothing is done if database does not exist.
+func (l Local) view(fn func(b *bolt.Bucket) error) error {
+	if _, err := os.Stat(l.Path); os.IsNotExist(err) {
+		return nil
+	}
+
+	db, err := bolt.Open(l.Path, 0600, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(recordBucket))
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+// put stores record as json
+func put(b *bolt.Bucket, asg string, r Record) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(asg), v)
+}
@@ -0,0 +1,135 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+)
+
+// S3 keeps each record as a JSON lines object in s3 bucket so that it can be queried with Athena or S3 Select.
+// Updates are not atomic, so records should not be updated by several processes at the same time.
+type S3 struct {
+	Client aws.S3Client
+	Bucket string
+	Prefix string
+}
+
+// NewS3 creates s3 storage. Default prefix is used if prefix is empty.
+func NewS3(client aws.S3Client, bucket, prefix string) S3 {
+	if len(prefix) == 0 {
+		prefix = constants.DefaultS3StoragePrefix
+	}
+
+	return S3{
+		Client: client,
+		Bucket: bucket,
+		Prefix: strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// Prepare checks if records can be listed from the bucket
+func (s S3) Prepare(logger *Logger.Logger) error {
+	if _, err := s.Client.ListObjectKeys(s.Bucket, s.Prefix); err != nil {
+		return err
+	}
+	logger.Infof("deployment records are kept in : s3://%s/%s", s.Bucket, s.Prefix)
+
+	return nil
+}
+
+// Put replaces the record of autoscaling group
+func (s S3) Put(asg string, record Record) error {
+	r := Record{}
+	r.merge(record)
+	r[constants.HashKey] = asg
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return s.Client.PutObject(s.Bucket, s.key(asg), append(b, '\n'))
+}
+
+// Update sets fields to the record of autoscaling group
+func (s S3) Update(asg string, fields Record) error {
+	r, err := s.Get(asg)
+	if err != nil {
+		return err
+	}
+
+	if r == nil {
+		r = Record{}
+	}
+	r.merge(fields)
+
+	return s.Put(asg, r)
+}
+
+// Get returns the record of autoscaling group
+func (s S3) Get(asg string) (Record, error) {
+	b, err := s.Client.GetObject(s.Bucket, s.key(asg))
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return decode(b)
+}
+
+// List returns records of application
+func (s S3) List(app, stack string) ([]Record, error) {
+	keys, err := s.Client.ListObjectKeys(s.Bucket, fmt.Sprintf("%s/", s.Prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []Record
+	for _, key := range keys {
+		b, err := s.Client.GetObject(s.Bucket, key)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := decode(b)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.matches(app, stack) {
+			ret = append(ret, r)
+		}
+	}
+
+	return ret, nil
+}
+
+// key returns object key of the record
+func (s S3) key(asg string) string {
+	return fmt.Sprintf("%s/%s.jsonl", s.Prefix, asg)
+}
@@ -0,0 +1,140 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+// Storage keeps deployment records of autoscaling groups
+type Storage interface {
+	// Prepare creates table, database file or checks bucket where records are kept
+	Prepare(logger *Logger.Logger) error
+
+	// Put replaces the record of autoscaling group
+	Put(asg string, record Record) error
+
+	// Update sets fields to the record of autoscaling group. The record is created if it does not exist.
+	Update(asg string, fields Record) error
+
+	// Get returns the record of autoscaling group or nil if it does not exist
+	Get(asg string) (Record, error)
+
+	// List returns records of application. Records of all stacks are returned if stack is empty.
+	List(app, stack string) ([]Record, error)
+}
+
+// Record is a deployment record. Values are string, float64 or maps of them.
+type Record map[string]interface{}
+
+// New creates storage with the type in metric configuration
+func New(mc schemas.MetricConfig, client aws.MetricClient) (Storage, error) {
+	switch mc.Storage.Type {
+	case constants.EmptyString, constants.DynamoDBStorage:
+		return DynamoDB{
+			Client:      client.DynamoDBService,
+			Table:       mc.Storage.Name,
+			BillingMode: mc.Storage.BillingMode,
+		}, nil
+	case constants.LocalStorage:
+		return NewLocal(mc.Storage.Name)
+	case constants.S3Storage:
+		return NewS3(client.S3Service, mc.Storage.Name, mc.Storage.Prefix), nil
+	}
+
+	return nil, fmt.Errorf("storage type is not supported: %s", mc.Storage.Type)
+}
+
+// String returns string value of the field
+func (r Record) String(key string) string {
+	if v, ok := r[key].(string); ok {
+		return v
+	}
+
+	return constants.EmptyString
+}
+
+// Number returns number value of the field. Numbers stored as string are parsed.
+func (r Record) Number(key string) float64 {
+	switch v := r[key].(type) {
+	case float64:
+		return v
+	case string:
+		n, _ := strconv.ParseFloat(v, 64)
+		return n
+	}
+
+	return 0
+}
+
+// Sum returns sum of all numbers in the field including nested maps
+func (r Record) Sum(key string) float64 {
+	return sum(r[key])
+}
+
+// sum adds up numbers in v recursively
+func sum(v interface{}) float64 {
+	var ret float64
+	switch val := v.(type) {
+	case float64:
+		ret = val
+	case map[string]float64:
+		for _, n := range val {
+			ret += n
+		}
+	case map[string]map[string]float64:
+		for _, m := range val {
+			ret += sum(m)
+		}
+	case map[string]interface{}:
+		for _, vv := range val {
+			ret += sum(vv)
+		}
+	}
+
+	return ret
+}
+
+// merge sets fields to the record
+func (r Record) merge(fields Record) {
+	for k, v := range fields {
+		r[k] = v
+	}
+}
+
+// matches checks if record belongs to the application and stack
+func (r Record) matches(app, stack string) bool {
+	return r.String(constants.ApplicationKey) == app && (len(stack) == 0 || r.String(constants.StackNameKey) == stack)
+}
+
+// decode makes record from json
+func decode(b []byte) (Record, error) {
+	var r Record
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,231 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package storage
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	Logger "github.com/sirupsen/logrus"
+
+	"github.com/DevopsArtFactory/goployer/pkg/aws"
+	"github.com/DevopsArtFactory/goployer/pkg/aws/fake"
+	"github.com/DevopsArtFactory/goployer/pkg/constants"
+	"github.com/DevopsArtFactory/goployer/pkg/schemas"
+)
+
+func testStorages(t *testing.T) map[string]Storage {
+	client := fake.NewCloud("ap-northeast-2").MetricClient()
+	ret := map[string]Storage{}
+	configs := map[string]schemas.Storage{
+		constants.DynamoDBStorage: {Type: constants.DynamoDBStorage, Name: "goployer-metrics"},
+		constants.LocalStorage:    {Type: constants.LocalStorage, Name: filepath.Join(t.TempDir(), "metrics.db")},
+		constants.S3Storage:       {Type: constants.S3Storage, Name: "goployer-metrics"},
+	}
+
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+	for name, config := range configs {
+		s, err := New(schemas.MetricConfig{Storage: config}, client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Prepare(logger); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		ret[name] = s
+	}
+
+	return ret
+}
+
+func TestNew(t *testing.T) {
+	client := fake.NewCloud("ap-northeast-2").MetricClient()
+	if _, err := New(schemas.MetricConfig{Storage: schemas.Storage{Type: "mysql"}}, client); err == nil {
+		t.Error("unsupported storage type should not be allowed")
+	}
+
+	s, err := New(schemas.MetricConfig{Storage: schemas.Storage{Type: constants.S3Storage, Name: "bucket"}}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key := s.(S3).key("hello-dev_apnortheast2-v001"); key != "deployments/hello-dev_apnortheast2-v001.jsonl" {
+		t.Errorf("unexpected object key: %s", key)
+	}
+}
+
+func TestDynamoDB_Prepare(t *testing.T) {
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+
+	testData := []struct {
+		billingMode string
+		expected    string
+	}{
+		{billingMode: "", expected: constants.PayPerRequestBillingMode},
+		{billingMode: constants.ProvisionedBillingMode, expected: constants.ProvisionedBillingMode},
+	}
+
+	for _, td := range testData {
+		cloud := fake.NewCloud("ap-northeast-2")
+		d := DynamoDB{Client: cloud.MetricClient().DynamoDBService, Table: "goployer-metrics", BillingMode: td.billingMode}
+		if err := d.Prepare(logger); err != nil {
+			t.Fatal(err)
+		}
+
+		if mode := cloud.BillingMode("goployer-metrics"); mode != td.expected {
+			t.Errorf("expected billing mode %s, got %s", td.expected, mode)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	asg := "hello-dev_apnortheast2-v001"
+	stats := map[string]map[string]float64{"tg-a": {"requestSum": 10}, "tg-b": {"requestSum": 5}}
+
+	for name, s := range testStorages(t) {
+		if r, err := s.Get(asg); err != nil || r != nil {
+			t.Errorf("%s: record should not exist: %v, %v", name, r, err)
+		}
+
+		record := Record{constants.ApplicationKey: "hello", constants.StackNameKey: "artd", "deployment_status": "creating"}
+		if err := s.Put(asg, record); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if err := s.Update(asg, Record{"deployment_status": "deployed", "tg_request_count": stats, "uptime_hour": "1.5"}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		r, err := s.Get(asg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if r.String(constants.HashKey) != asg || r.String("deployment_status") != "deployed" || r.String(constants.StackNameKey) != "artd" {
+			t.Errorf("%s: unexpected record: %v", name, r)
+		}
+
+		if sum := r.Sum("tg_request_count"); sum != 15 {
+			t.Errorf("%s: expected request sum 15, got %f", name, sum)
+		}
+
+		if uptime := r.Number("uptime_hour"); uptime != 1.5 {
+			t.Errorf("%s: expected uptime 1.5, got %f", name, uptime)
+		}
+
+		// records which do not exist are created by update
+		if err := s.Update("hello-prod_apnortheast2-v001", Record{constants.ApplicationKey: "hello", constants.StackNameKey: "artp"}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if err := s.Put("other-dev_apnortheast2-v001", Record{constants.ApplicationKey: "other", constants.StackNameKey: "artd"}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		testData := []struct {
+			app, stack string
+			expected   []string
+		}{
+			{app: "hello", expected: []string{"hello-dev_apnortheast2-v001", "hello-prod_apnortheast2-v001"}},
+			{app: "hello", stack: "artp", expected: []string{"hello-prod_apnortheast2-v001"}},
+			{app: "none"},
+		}
+
+		for _, td := range testData {
+			records, err := s.List(td.app, td.stack)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			var got []string
+			for _, r := range records {
+				got = append(got, r.String(constants.HashKey))
+			}
+
+			if strings.Join(got, ",") != strings.Join(td.expected, ",") {
+				t.Errorf("%s: %s/%s expected %v, got %v", name, td.app, td.stack, td.expected, got)
+			}
+		}
+	}
+}
+
+// noIndexDynamoDB is dynamodb whose table is created by old versions without the index of application
+type noIndexDynamoDB struct {
+	aws.DynamoDBClient
+}
+
+func (noIndexDynamoDB) QueryIndex(string, string, map[string]string) ([]map[string]*dynamodb.AttributeValue, error) {
+	return nil, awserr.New("ValidationException", "The table does not have the specified index", nil)
+}
+
+func TestDynamoDB_ListWithoutIndex(t *testing.T) {
+	d := DynamoDB{Client: noIndexDynamoDB{fake.NewCloud("ap-northeast-2").MetricClient().DynamoDBService}, Table: "goployer-metrics"}
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+	if err := d.Prepare(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	records := map[string]Record{
+		"hello-dev_apnortheast2-v001": {constants.ApplicationKey: "hello", constants.StackNameKey: "artd"},
+		"other-dev_apnortheast2-v001": {constants.ApplicationKey: "other", constants.StackNameKey: "artd"},
+		"old-dev_apnortheast2-v001":   {constants.StackNameKey: "artd"},
+	}
+	for asg, r := range records {
+		if err := d.Put(asg, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output, err := d.List("hello", "artd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(output) != 1 || output[0].String(constants.HashKey) != "hello-dev_apnortheast2-v001" {
+		t.Errorf("only records of application should be listed: %v", output)
+	}
+}
+
+// invalidQueryDynamoDB is dynamodb which rejects query with validation error other than missing index
+type invalidQueryDynamoDB struct {
+	aws.DynamoDBClient
+}
+
+func (invalidQueryDynamoDB) QueryIndex(string, string, map[string]string) ([]map[string]*dynamodb.AttributeValue, error) {
+	return nil, awserr.New("ValidationException", "Query condition missed key schema element", nil)
+}
+
+func TestDynamoDB_ListWithInvalidQuery(t *testing.T) {
+	d := DynamoDB{Client: invalidQueryDynamoDB{fake.NewCloud("ap-northeast-2").MetricClient().DynamoDBService}, Table: "goployer-metrics"}
+	logger := Logger.New()
+	logger.SetOutput(ioutil.Discard)
+	if err := d.Prepare(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.List("hello", "artd"); err == nil || !strings.Contains(err.Error(), "missed key schema element") {
+		t.Errorf("validation error should be returned instead of scanning table: %v", err)
+	}
+}
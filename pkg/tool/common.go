@@ -21,19 +21,26 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
+	"github.com/ghodss/yaml"
 
 	"github.com/DevopsArtFactory/goployer/pkg/constants"
 )
 
+// percentileRegex matches percentile statistics between p0 and p100
+var percentileRegex = regexp.MustCompile(`^p(100|\d{1,2}(\.\d{1,2})?)$`)
+
 // Fatal Error
 func FatalError(err error) {
 	log.Fatalf("error: %v", err)
@@ -203,6 +210,38 @@ func ParseTargetGroupName(arn string) string {
 	return strings.Split(arn, "/")[1]
 }
 
+// GetTargetGroupDimension returns value of TargetGroup dimension in cloudwatch from target group ARN
+func GetTargetGroupDimension(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
+
+// GetLoadBalancerDimension returns value of LoadBalancer dimension in cloudwatch from load balancer ARN
+func GetLoadBalancerDimension(arn string) string {
+	return strings.TrimPrefix(arn[strings.LastIndex(arn, ":")+1:], "loadbalancer/")
+}
+
+// IsPercentileStatistic checks if statistic is a percentile of cloudwatch like p99 or p99.9
+func IsPercentileStatistic(statistic string) bool {
+	return percentileRegex.MatchString(statistic)
+}
+
+// ParseTimestamp parses unix timestamp in seconds or RFC3339 time
+func ParseTimestamp(s string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+// ParseS3Path splits s3 URL into bucket and key
+func ParseS3Path(path string) (string, string) {
+	path = strings.TrimPrefix(path, constants.S3Prefix)
+	split := strings.Split(path, "/")
+
+	return split[0], strings.Join(split[1:], "/")
+}
+
 // LocalCheck checks whether or not to continue when it is run on localhost.
 // Cannot add windows because goployer could be run on Windows..
 func LocalCheck(message string, autoApply bool) error {
@@ -224,3 +263,27 @@ func PrintTemplate(data interface{}, t *template.Template) error {
 	}
 	return w.Flush()
 }
+
+// IsDocumentOutput checks if output format is a machine-readable document
+func IsDocumentOutput(output string) bool {
+	return output == constants.JSONOutput || output == constants.YAMLOutput
+}
+
+// PrintDocument prints data as json or yaml document. yaml keys follow json tags of data.
+func PrintDocument(out io.Writer, data interface{}, output string) error {
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if output == constants.YAMLOutput {
+		if b, err = yaml.JSONToYAML(b); err != nil {
+			return err
+		}
+		_, err = out.Write(b)
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, string(b))
+	return err
+}
@@ -17,6 +17,7 @@ limitations under the license.
 package tool
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"testing"
@@ -55,3 +56,77 @@ func TestIsTargetGroupArn(t *testing.T) {
 		}
 	}
 }
+
+func TestIsPercentileStatistic(t *testing.T) {
+	testData := []struct {
+		Input    string
+		Expected bool
+	}{
+		{Input: "p99", Expected: true},
+		{Input: "p99.9", Expected: true},
+		{Input: "p100", Expected: true},
+		{Input: "p0", Expected: true},
+		{Input: "p101", Expected: false},
+		{Input: "P99", Expected: false},
+		{Input: "Average", Expected: false},
+		{Input: "p99.999", Expected: false},
+	}
+
+	for _, td := range testData {
+		if output := IsPercentileStatistic(td.Input); output != td.Expected {
+			t.Errorf("expected: %t, output: %t, input: %s", td.Expected, output, td.Input)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	testData := []struct {
+		Input    string
+		Expected int64
+		Err      bool
+	}{
+		{Input: "1601510400", Expected: 1601510400},
+		{Input: "2020-10-01T09:00:00+09:00", Expected: 1601510400},
+		{Input: "2020-10-01", Err: true},
+	}
+
+	for _, td := range testData {
+		output, err := ParseTimestamp(td.Input)
+		if (err != nil) != td.Err {
+			t.Errorf("unexpected error for %s: %v", td.Input, err)
+		}
+
+		if !td.Err && output.Unix() != td.Expected {
+			t.Errorf("expected: %d, output: %d, input: %s", td.Expected, output.Unix(), td.Input)
+		}
+	}
+}
+
+func TestPrintDocument(t *testing.T) {
+	data := struct {
+		Stack   string   `json:"stack"`
+		Regions []string `json:"regions"`
+	}{
+		Stack:   "artd",
+		Regions: []string{"ap-northeast-2"},
+	}
+
+	testData := []struct {
+		Output   string
+		Expected string
+	}{
+		{Output: constants.JSONOutput, Expected: "{\n  \"stack\": \"artd\",\n  \"regions\": [\n    \"ap-northeast-2\"\n  ]\n}\n"},
+		{Output: constants.YAMLOutput, Expected: "regions:\n- ap-northeast-2\nstack: artd\n"},
+	}
+
+	for _, td := range testData {
+		buf := &bytes.Buffer{}
+		if err := PrintDocument(buf, data, td.Output); err != nil {
+			t.Fatal(err)
+		}
+
+		if buf.String() != td.Expected {
+			t.Errorf("expected: %q, output: %q", td.Expected, buf.String())
+		}
+	}
+}
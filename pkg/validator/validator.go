@@ -0,0 +1,340 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package validator
+
+import (
+	// embed is required for the generated schema of manifest
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defPrefix = "#/definitions/"
+
+// manifestSchema is generated by hack/schemas with the comments of schemas package
+//
+//go:embed schema.json
+var manifestSchema []byte
+
+// definition is a subset of JSON schema which is generated for manifest
+type definition struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*definition `json:"properties,omitempty"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties,omitempty"`
+	Items                *definition            `json:"items,omitempty"`
+	AnyOf                []*definition          `json:"anyOf,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+}
+
+type schema struct {
+	definition
+	Definitions map[string]*definition `json:"definitions"`
+}
+
+// Error is a violation of manifest with its position
+type Error struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+// Errors is a list of violations in manifest
+type Errors []Error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Result is the document of manifest validation
+type Result struct {
+	Manifest string `json:"manifest"`
+	Valid    bool   `json:"valid"`
+	Errors   Errors `json:"errors"`
+}
+
+// Validator validates manifest with JSON schema
+type Validator struct {
+	schema schema
+}
+
+// NewValidator creates a validator with the schema of manifest
+func NewValidator() (Validator, error) {
+	var s schema
+	if err := json.Unmarshal(manifestSchema, &s); err != nil {
+		return Validator{}, fmt.Errorf("error parsing schema of manifest: %v", err)
+	}
+
+	return Validator{schema: s}, nil
+}
+
+// ValidateManifest validates manifest with the schema of manifest
+func ValidateManifest(manifest []byte) error {
+	v, err := NewValidator()
+	if err != nil {
+		return err
+	}
+
+	return v.Validate(manifest)
+}
+
+// Validate checks unknown keys, types and allowed values of manifest
+func (v Validator) Validate(manifest []byte) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(manifest, &root); err != nil {
+		return err
+	}
+
+	errs := v.validate(&root, &v.schema.definition, "")
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// violations in anchors are reported once even if aliases refer to them several times
+	var ret Errors
+	seen := map[string]bool{}
+	for _, e := range errs {
+		key := fmt.Sprintf("%d:%d:%s", e.Line, e.Column, e.Message)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ret = append(ret, e)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].Line != ret[j].Line {
+			return ret[i].Line < ret[j].Line
+		}
+		return ret[i].Column < ret[j].Column
+	})
+
+	return ret
+}
+
+// validate checks node with definition recursively
+func (v Validator) validate(node *yaml.Node, def *definition, path string) Errors {
+	node = resolve(node)
+	if node == nil || def == nil {
+		return nil
+	}
+
+	if len(def.Ref) > 0 {
+		return v.validate(node, v.schema.Definitions[strings.TrimPrefix(def.Ref, defPrefix)], path)
+	}
+
+	if len(def.AnyOf) > 0 {
+		var first Errors
+		for i, option := range def.AnyOf {
+			errs := v.validate(node, option, path)
+			if len(errs) == 0 {
+				return nil
+			}
+
+			if i == 0 {
+				first = errs
+			}
+		}
+		return first
+	}
+
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch {
+	case def.Type == "object" || def.Properties != nil:
+		return v.validateObject(node, def, path)
+	case def.Type == "array":
+		if node.Kind != yaml.SequenceNode {
+			return Errors{newError(node, path, fmt.Sprintf("expected array, but got %s", kindOf(node)))}
+		}
+
+		var errs Errors
+		for i, item := range node.Content {
+			errs = append(errs, v.validate(item, def.Items, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case len(def.Type) > 0:
+		return validateScalar(node, def, path)
+	}
+
+	return nil
+}
+
+// validateObject checks keys and values of mapping
+func (v Validator) validateObject(node *yaml.Node, def *definition, path string) Errors {
+	if node.Kind != yaml.MappingNode {
+		return Errors{newError(node, path, fmt.Sprintf("expected object, but got %s", kindOf(node)))}
+	}
+
+	additional, allowed := v.additionalProperties(def)
+
+	var errs Errors
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		// merge key brings keys of other mappings
+		if key.Value == "<<" {
+			merged := []*yaml.Node{resolve(value)}
+			if merged[0] != nil && merged[0].Kind == yaml.SequenceNode {
+				merged = merged[0].Content
+			}
+
+			for _, m := range merged {
+				if m = resolve(m); m != nil {
+					errs = append(errs, v.validateObject(m, def, path)...)
+				}
+			}
+			continue
+		}
+
+		fieldPath := key.Value
+		if len(path) > 0 {
+			fieldPath = fmt.Sprintf("%s.%s", path, key.Value)
+		}
+
+		if prop, ok := def.Properties[key.Value]; ok {
+			errs = append(errs, v.validate(value, prop, fieldPath)...)
+			continue
+		}
+
+		if additional != nil {
+			errs = append(errs, v.validate(value, additional, fieldPath)...)
+			continue
+		}
+
+		// top-level keys only holding anchors are used to share configurations between stacks
+		if allowed || (len(path) == 0 && len(value.Anchor) > 0) {
+			continue
+		}
+
+		errs = append(errs, newError(key, path, fmt.Sprintf("unknown field %q", key.Value)))
+	}
+
+	return errs
+}
+
+// additionalProperties returns the definition of values in map, or whether unknown keys are allowed
+func (v Validator) additionalProperties(def *definition) (*definition, bool) {
+	if len(def.AdditionalProperties) == 0 {
+		return nil, true
+	}
+
+	var allowed bool
+	if err := json.Unmarshal(def.AdditionalProperties, &allowed); err == nil {
+		return nil, allowed
+	}
+
+	var additional definition
+	if err := json.Unmarshal(def.AdditionalProperties, &additional); err != nil {
+		return nil, true
+	}
+
+	return &additional, true
+}
+
+// validateScalar checks type and allowed values of scalar
+func validateScalar(node *yaml.Node, def *definition, path string) Errors {
+	if node.Kind != yaml.ScalarNode {
+		return Errors{newError(node, path, fmt.Sprintf("expected %s, but got %s", def.Type, kindOf(node)))}
+	}
+
+	var valid bool
+	switch def.Type {
+	case "integer":
+		valid = node.Tag == "!!int"
+	case "number":
+		valid = node.Tag == "!!int" || node.Tag == "!!float"
+	case "boolean":
+		valid = node.Tag == "!!bool"
+	default:
+		valid = true
+	}
+
+	if !valid {
+		return Errors{newError(node, path, fmt.Sprintf("expected %s, but got %q", def.Type, node.Value))}
+	}
+
+	if len(def.Enum) == 0 {
+		return nil
+	}
+
+	// values are case insensitive as goployer normalizes them
+	for _, e := range def.Enum {
+		if strings.EqualFold(e, node.Value) {
+			return nil
+		}
+	}
+
+	return Errors{newError(node, path, fmt.Sprintf("%q is not allowed, valid values are %s", node.Value, strings.Join(def.Enum, ", ")))}
+}
+
+// resolve returns the content of document and the anchored node of alias
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+
+	return nil
+}
+
+func kindOf(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+func newError(node *yaml.Node, path, message string) Error {
+	return Error{
+		Line:    node.Line,
+		Column:  node.Column,
+		Path:    path,
+		Message: message,
+	}
+}
@@ -0,0 +1,125 @@
+/*
+copyright 2020 the Goployer authors
+
+licensed under the apache license, version 2.0 (the "license");
+you may not use this file except in compliance with the license.
+you may obtain a copy of the license at
+
+    http://www.apache.org/licenses/license-2.0
+
+unless required by applicable law or agreed to in writing, software
+distributed under the license is distributed on an "as is" basis,
+without warranties or conditions of any kind, either express or implied.
+see the license for the specific language governing permissions and
+limitations under the license.
+*/
+
+package validator
+
+import (
+	"testing"
+)
+
+func TestValidateManifest(t *testing.T) {
+	testData := []struct {
+		name     string
+		manifest string
+		expected string
+	}{
+		{
+			name: "valid manifest",
+			manifest: `
+name: hello
+autoscaling: &autoscaling_policy
+  - name: scale_out
+    adjustment_type: ChangeInCapacity
+stacks:
+  - stack: artd
+    replacement_type: bluegreen
+    polling_interval: 30s
+    autoscaling: *autoscaling_policy
+    capacity:
+      min: 1
+    regions:
+      - region: ap-northeast-2
+        healthcheck_target_group: hello-artd
+`,
+		},
+		{
+			name: "unknown key",
+			manifest: `
+name: hello
+stacks:
+  - stack: artd
+    regions:
+      - region: ap-northeast-2
+        healthcheck_targetgroup: hello-artd
+`,
+			expected: `line 7, column 9: stacks[0].regions[0]: unknown field "healthcheck_targetgroup"`,
+		},
+		{
+			name: "enum values",
+			manifest: `
+stacks:
+  - stack: artd
+    replacement_type: greenblue
+    block_devices:
+      - device_name: /dev/xvda
+        volume_type: gp4
+    mixed_instances_policy:
+      spot_allocation_strategy: lowest-price
+`,
+			expected: `line 4, column 23: stacks[0].replacement_type: "greenblue" is not allowed, valid values are BlueGreen, Canary, RollingUpdate, DeployOnly
+line 7, column 22: stacks[0].block_devices[0].volume_type: "gp4" is not allowed, valid values are gp2, gp3, io1, io2, st1, sc1`,
+		},
+		{
+			name: "types",
+			manifest: `
+tags: project=test
+stacks:
+  - stack: artd
+    capacity:
+      min: one
+    ebs_optimized: yes please
+`,
+			expected: `line 2, column 7: tags: expected array, but got "project=test"
+line 6, column 12: stacks[0].capacity.min: expected integer, but got "one"
+line 7, column 20: stacks[0].ebs_optimized: expected boolean, but got "yes please"`,
+		},
+		{
+			name: "unknown key in anchor",
+			manifest: `
+alarms: &alarms
+  - name: scale_out
+    threshhold: 50
+stacks:
+  - stack: artd
+    alarms: *alarms
+  - stack: artp
+    alarms: *alarms
+`,
+			expected: `line 4, column 5: stacks[0].alarms[0]: unknown field "threshhold"`,
+		},
+		{
+			name: "unknown top level key",
+			manifest: `
+nmae: hello
+`,
+			expected: `line 2, column 1: unknown field "nmae"`,
+		},
+	}
+
+	for _, td := range testData {
+		err := ValidateManifest([]byte(td.manifest))
+		if len(td.expected) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", td.name, err)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != td.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%v", td.name, td.expected, err)
+		}
+	}
+}
@@ -17,7 +17,10 @@ limitations under the license.
 package main
 
 import (
-	"net/http"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
 	Logger "github.com/sirupsen/logrus"
 
@@ -26,16 +29,21 @@ import (
 
 func main() {
 	Logger.Infof("Booting up goployer server")
-	s := server.New().
+	s, err := server.New().
 		SetDefaultSetting().
-		SetRouter()
+		SetSecurity()
+	if err != nil {
+		Logger.Fatal(err)
+	}
+	s = s.SetRouter()
 
 	Logger.Infof("Server setting is done")
 
-	addr := s.GetAddr()
-	s.Logger.Infof("Start goployer server")
-	if err := http.ListenAndServe(addr, s.Router); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := s.Run(ctx); err != nil {
 		s.Logger.Errorf(err.Error())
+		os.Exit(1)
 	}
-	s.Logger.Infof("Shutting down goployer server")
 }